@@ -0,0 +1,139 @@
+// simulator replays a recorded track as a device gateway would send it,
+// used to test telemetry ingestion locally
+//
+//	go run ./cmd/simulator -car 1 -track cmd/simulator/track.csv
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/telemetry", "telemetry endpoint")
+	key := flag.String("key", os.Getenv("TELEMETRY_KEY"), "gateway key, TELEMETRY_KEY by default")
+	carID := flag.Int("car", 1, "id of the car sending the track")
+	track := flag.String("track", "cmd/simulator/track.csv", "csv file with latitude,longitude,odometer,fuel_level,ignition rows")
+	interval := flag.Duration("interval", time.Second, "delay between points")
+	batch := flag.Int("batch", 1, "points sent in one request")
+	flag.Parse()
+
+	points, err := readTrack(*track, *carID)
+	if err != nil {
+		log.Fatalf("failed to read track: %v", err)
+	}
+	log.Printf("replaying %d points for car %d", len(points), *carID)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	pending := make([]*types.TelemetryPointPayload, 0, *batch)
+	for i, p := range points {
+		p.RecordedAt = time.Now().UTC().Format(time.RFC3339)
+		pending = append(pending, p)
+
+		if len(pending) == *batch || i == len(points)-1 {
+			if err := send(client, *url, *key, pending); err != nil {
+				log.Fatalf("failed to send points: %v", err)
+			}
+			log.Printf("sent %d/%d points", i+1, len(points))
+			pending = pending[:0]
+		}
+		if i < len(points)-1 {
+			time.Sleep(*interval)
+		}
+	}
+}
+
+// empty columns are left out of the point, the first row can be a header
+func readTrack(path string, carID int) ([]*types.TelemetryPointPayload, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]*types.TelemetryPointPayload, 0, len(rows))
+	for i, row := range rows {
+		if len(row) != 5 {
+			return nil, fmt.Errorf("row %d has %d columns, expected 5", i+1, len(row))
+		}
+		if i == 0 && row[0] == "latitude" {
+			continue
+		}
+
+		p := &types.TelemetryPointPayload{CarID: carID}
+		if p.Latitude, err = parseOptional(row[0], parseFloat); err != nil {
+			return nil, fmt.Errorf("row %d: invalid latitude: %v", i+1, err)
+		}
+		if p.Longitude, err = parseOptional(row[1], parseFloat); err != nil {
+			return nil, fmt.Errorf("row %d: invalid longitude: %v", i+1, err)
+		}
+		if p.Odometer, err = parseOptional(row[2], strconv.Atoi); err != nil {
+			return nil, fmt.Errorf("row %d: invalid odometer: %v", i+1, err)
+		}
+		if p.FuelLevel, err = parseOptional(row[3], strconv.Atoi); err != nil {
+			return nil, fmt.Errorf("row %d: invalid fuel level: %v", i+1, err)
+		}
+		if p.Ignition, err = parseOptional(row[4], strconv.ParseBool); err != nil {
+			return nil, fmt.Errorf("row %d: invalid ignition: %v", i+1, err)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseOptional[T any](s string, parse func(string) (T, error)) (*T, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func send(client *http.Client, url string, key string, points []*types.TelemetryPointPayload) error {
+	body, err := json.Marshal(&types.TelemetryPayload{Points: points})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gateway-Key", key)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, msg)
+	}
+	return nil
+}
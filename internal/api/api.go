@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"path/filepath"
@@ -9,11 +10,12 @@ import (
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mwdev22/CarRental/docs"
 	"github.com/mwdev22/CarRental/internal/handlers"
+	"github.com/mwdev22/CarRental/internal/notify"
+	"github.com/mwdev22/CarRental/internal/scheduler"
 	"github.com/mwdev22/CarRental/internal/services"
-	"github.com/mwdev22/CarRental/internal/store"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store/postgres"
 	"github.com/mwdev22/CarRental/internal/utils"
-	"github.com/redis/go-redis/v9"
 	"github.com/rs/cors"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
@@ -42,30 +44,66 @@ func (a *api) Start() error {
 	fs := http.FileServer(http.Dir(logDir))
 	mux.Handle("/log/", http.StripPrefix("/log/", fs))
 
+	// public uploads, e.g. car images
+	uploadDir, err := filepath.Abs("./uploads")
+	if err != nil {
+		log.Fatalf("failed to resolve upload directory: %v", err)
+	}
+	files := storage.NewLocalStorage(uploadDir, "/uploads/")
+	mux.Handle("/uploads/", http.StripPrefix("/uploads/", storage.FileServer(uploadDir)))
+	// private uploads, e.g. company documents, never served directly, only through authorized handlers
+	documentDir, err := filepath.Abs("./documents")
+	if err != nil {
+		log.Fatalf("failed to resolve document directory: %v", err)
+	}
+	documents := storage.NewLocalStorage(documentDir, "")
+
 	// api docs
 	mux.Handle("/swagger/", httpSwagger.WrapHandler)
 
 	// --- STORAGE AND SERVICES ---
 	userStore := postgres.NewUserRepo(a.db)
-	userService := services.NewUserService(userStore)
 	carStore := postgres.NewCarRepository(a.db)
-	carService := services.NewCarService(carStore)
-	companyStore := postgres.NewCompanyRepository(a.db)
-	companyService := services.NewCompanyService(companyStore)
 	bookingStore := postgres.NewBookingRepository(a.db)
-	bookingService := services.NewBookingService(bookingStore, carStore, userStore)
+	companyStore := postgres.NewCompanyRepository(a.db)
+	notifier := notify.NewLogNotifier(utils.MakeLogger("notify"))
+	userService := services.NewUserService(userStore, carStore, companyStore, notifier)
+	carService := services.NewCarService(carStore, bookingStore, companyStore, userStore, notifier, files)
+	companyService := services.NewCompanyService(companyStore, carStore, bookingStore, userStore, notifier, documents)
+	bookingService := services.NewBookingService(bookingStore, carStore, userStore, companyStore, notifier)
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
+	// --- BACKGROUND JOBS ---
+	jobs := scheduler.New(utils.MakeLogger("scheduler"))
+	jobs.Every("no-show", 15*time.Minute, func(ctx context.Context) error {
+		return bookingService.ProcessNoShows(time.Now().UTC())
+	})
+	// hourly so prices switch soon after midnight
+	jobs.Every("car-prices", time.Hour, func(ctx context.Context) error {
+		_, err := carService.ApplyScheduledPrices(time.Now().UTC())
+		return err
+	})
+	jobs.Every("car-documents", 24*time.Hour, func(ctx context.Context) error {
+		return bookingService.CheckCarDocuments(time.Now().UTC())
+	})
+	jobs.Every("saved-searches", time.Hour, func(ctx context.Context) error {
+		return userService.NotifySavedSearches()
+	})
+	jobs.Every("favorite-prices", time.Hour, func(ctx context.Context) error {
+		return userService.NotifyPriceDrops()
+	})
+	jobs.Every("telemetry-retention", 24*time.Hour, func(ctx context.Context) error {
+		_, err := carService.PurgeTelemetry(time.Now().UTC())
+		return err
 	})
-	userCompCache := store.NewRedisCache(redisClient)
+	jobs.Start()
+	defer jobs.Stop()
 
 	// --- MAIN ROUTES ---
 
 	_ = handlers.NewUserHandler(mux, userService, utils.MakeLogger("user"))
 	_ = handlers.NewCarHandler(mux, carService, utils.MakeLogger("car"))
 	_ = handlers.NewCompanyHandler(mux, companyService, utils.MakeLogger("company"))
-	_ = handlers.NewBookingHandler(mux, bookingService, carService, userCompCache, utils.MakeLogger("booking"))
+	_ = handlers.NewBookingHandler(mux, bookingService, utils.MakeLogger("booking"))
 
 	c := cors.New(cors.Options{
 		AllowedOrigins:      []string{"*"},
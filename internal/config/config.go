@@ -10,6 +10,9 @@ import (
 
 var SecretKey []byte
 
+// shared key of telemetry device gateways, ingestion is disabled when empty
+var TelemetryKey []byte
+
 type config struct {
 	Addr        string
 	DatabaseURI string
@@ -30,6 +33,7 @@ func New() *config {
 	}
 
 	SecretKey = []byte(os.Getenv("SECRET_KEY"))
+	TelemetryKey = []byte(os.Getenv("TELEMETRY_KEY"))
 
 	return &config{
 		Addr:        os.Getenv("ADDR"),
@@ -7,26 +7,21 @@ import (
 	"strconv"
 
 	"github.com/mwdev22/CarRental/internal/services"
-	"github.com/mwdev22/CarRental/internal/store"
 	"github.com/mwdev22/CarRental/internal/types"
 	"github.com/mwdev22/CarRental/internal/utils"
 )
 
 type BookingHandler struct {
-	mux         *http.ServeMux
-	booking     *services.BookingService
-	userCompany store.Cache
-	car         *services.CarService
-	logger      *log.Logger
+	mux     *http.ServeMux
+	booking *services.BookingService
+	logger  *log.Logger
 }
 
-func NewBookingHandler(mux *http.ServeMux, booking *services.BookingService, car *services.CarService, userCompanyCache store.Cache, logger *log.Logger) *BookingHandler {
+func NewBookingHandler(mux *http.ServeMux, booking *services.BookingService, logger *log.Logger) *BookingHandler {
 	h := &BookingHandler{
-		mux:         mux,
-		car:         car,
-		userCompany: userCompanyCache,
-		booking:     booking,
-		logger:      logger,
+		mux:     mux,
+		booking: booking,
+		logger:  logger,
 	}
 
 	h.mux.HandleFunc("GET /booking/user/{id}", authMiddleware(h.handleGetUserBookings, logger))
@@ -36,6 +31,29 @@ func NewBookingHandler(mux *http.ServeMux, booking *services.BookingService, car
 	h.mux.HandleFunc("DELETE /booking/{id}", authMiddleware(h.handleDeleteBookingByID, logger))
 	h.mux.HandleFunc("PUT /booking/{id}", authMiddleware(h.handleUpdateBooking, logger))
 
+	// handing the booking over to another user, transfer history is listed with the booking
+	h.mux.HandleFunc("POST /booking/{id}/transfer", authMiddleware(h.handleTransferBooking, logger))
+	h.mux.HandleFunc("POST /booking/{id}/transfer/accept", authMiddleware(h.handleAcceptBookingTransfer, logger))
+	h.mux.HandleFunc("POST /booking/{id}/transfer/decline", authMiddleware(h.handleDeclineBookingTransfer, logger))
+
+	// confirmed by the company when the renter takes the car, bookings not picked up in time become no-shows
+	h.mux.HandleFunc("POST /booking/{id}/pickup", authMiddleware(h.handlePickUpBooking, logger))
+	// bookings made by category get their car assigned when booked, company can swap it until pickup
+	h.mux.HandleFunc("POST /booking/{id}/car", authMiddleware(h.handleAssignBookingCar, logger))
+
+	// additional drivers, invited people accept after they register,
+	// drivers are listed with the booking in GET /booking/{id}
+	h.mux.HandleFunc("POST /booking/{id}/drivers", authMiddleware(h.handleAddBookingDriver, logger))
+	h.mux.HandleFunc("POST /booking/{id}/drivers/accept", authMiddleware(h.handleAcceptDriverInvitation, logger))
+	h.mux.HandleFunc("DELETE /booking/{id}/drivers/{driverId}", authMiddleware(h.handleRemoveBookingDriver, logger))
+
+	// recurring bookings, e.g. every monday-friday for 8 weeks
+	h.mux.HandleFunc("POST /booking/series", authMiddleware(h.handleCreateBookingSeries, logger))
+	h.mux.HandleFunc("GET /booking/series/{id}", authMiddleware(h.handleGetBookingSeries, logger))
+	h.mux.HandleFunc("PUT /booking/series/{id}", authMiddleware(h.handleUpdateBookingSeries, logger))
+	h.mux.HandleFunc("DELETE /booking/series/{id}", authMiddleware(h.handleCancelBookingSeries, logger))
+	h.mux.HandleFunc("DELETE /booking/series/{id}/occurrence/{bookingId}", authMiddleware(h.handleCancelSeriesOccurrence, logger))
+
 	return h
 }
 
@@ -43,6 +61,21 @@ func (h *BookingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.mux.ServeHTTP(w, r)
 }
 
+// renter has access to his bookings, company staff handling bookings to the bookings of company cars
+func (h *BookingHandler) checkAccess(r *http.Request, renterID int, carID int) error {
+	userID, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+	if renterID == userID {
+		return nil
+	}
+	if err := h.booking.AuthorizeCar(carID, userID, types.CompanyPermBookings); err != nil {
+		return types.Unauthorized("user does not have access to this booking")
+	}
+	return nil
+}
+
 // @Summary Create a booking
 // @Description Creates a new booking record
 // @Accept json
@@ -94,17 +127,10 @@ func (h *BookingHandler) handleGetBookingByID(w http.ResponseWriter, r *http.Req
 		return err
 	}
 
-	rentedCar, err := h.car.GetByID(booking.CarID)
-	if err != nil {
-		return err
-	}
-
-	userID := r.Context().Value(userIdKey)
-	if booking.UserID != userID.(int) {
-		// if user is an owner of the company, verify it via cache
-		companyOwnerID, err := h.userCompany.Get(r.Context(), fmt.Sprintf("company:%d", rentedCar.CompanyID))
-		if err != nil || companyOwnerID != userID {
-			return types.Unauthorized("user does not have access to this booking")
+	// additional drivers can see the booking too
+	if !isDriver(booking, r.Context().Value(userIdKey)) {
+		if err := h.checkAccess(r, booking.UserID, booking.CarID); err != nil {
+			return err
 		}
 	}
 
@@ -161,21 +187,13 @@ func (h *BookingHandler) handleUpdateBooking(w http.ResponseWriter, r *http.Requ
 		return types.ValidationError(errors)
 	}
 
-	userId := r.Context().Value(userIdKey)
 	booking, err := h.booking.GetByID(idInt)
 	if err != nil {
 		return err
 	}
 
-	if booking.UserID != userId.(int) {
-		car, err := h.car.GetByID(booking.CarID)
-		if err != nil {
-			return err
-		}
-		companyOwnerID, err := h.userCompany.Get(r.Context(), fmt.Sprintf("company:%d", car.CompanyID))
-		if err != nil || companyOwnerID != userId {
-			return types.Unauthorized("user does not have access to this booking")
-		}
+	if err := h.checkAccess(r, booking.UserID, booking.CarID); err != nil {
+		return err
 	}
 
 	if err := h.booking.Update(idInt, &payload); err != nil {
@@ -199,21 +217,13 @@ func (h *BookingHandler) handleDeleteBookingByID(w http.ResponseWriter, r *http.
 		return types.BadPathParameter("id")
 	}
 
-	userId := r.Context().Value(userIdKey)
 	booking, err := h.booking.GetByID(idInt)
 	if err != nil {
 		return err
 	}
 
-	if booking.UserID != userId.(int) {
-		car, err := h.car.GetByID(booking.CarID)
-		if err != nil {
-			return err
-		}
-		companyOwnerID, err := h.userCompany.Get(r.Context(), fmt.Sprintf("company:%d", car.CompanyID))
-		if err != nil || companyOwnerID != userId {
-			return types.Unauthorized("user does not have access to this booking")
-		}
+	if err := h.checkAccess(r, booking.UserID, booking.CarID); err != nil {
+		return err
 	}
 
 	if err := h.booking.Delete(idInt); err != nil {
@@ -223,3 +233,429 @@ func (h *BookingHandler) handleDeleteBookingByID(w http.ResponseWriter, r *http.
 	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("booking %d deleted", idInt)})
 
 }
+
+// @Summary Mark booking as picked up
+// @Description Company confirms the renter took the car, only picked up bookings are safe from no-show handling
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/{id}/pickup [post]
+func (h *BookingHandler) handlePickUpBooking(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.booking.PickUp(idInt, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("booking %d picked up", idInt)})
+}
+
+// @Summary Transfer booking to another user
+// @Description Renter offers the booking to another user, it moves to him after he accepts
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Param payload body types.TransferBookingPayload true "New renter"
+// @Tags Booking
+// @Success 200 {object} types.BookingTransfer
+// @Router /booking/{id}/transfer [post]
+func (h *BookingHandler) handleTransferBooking(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.TransferBookingPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	transfer, err := h.booking.Transfer(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, transfer)
+}
+
+// @Summary Accept booking transfer
+// @Description Logged user becomes the renter of the booking transferred to him
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/{id}/transfer/accept [post]
+func (h *BookingHandler) handleAcceptBookingTransfer(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.booking.AcceptTransfer(idInt, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("booking %d transferred", idInt)})
+}
+
+// @Summary Decline booking transfer
+// @Description Recipient declines the transfer or renter withdraws it
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/{id}/transfer/decline [post]
+func (h *BookingHandler) handleDeclineBookingTransfer(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.booking.DeclineTransfer(idInt, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("transfer of booking %d declined", idInt)})
+}
+
+// @Summary Assign car to booking
+// @Description Company swaps the car of a booking made by category for another car of the same category
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Param payload body types.AssignCarPayload true "Car"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/{id}/car [post]
+func (h *BookingHandler) handleAssignBookingCar(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.AssignCarPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.booking.AssignCar(idInt, payload.CarID, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("car %d assigned to booking %d", payload.CarID, idInt)})
+}
+
+func isDriver(booking *types.Booking, userID any) bool {
+	for _, d := range booking.Drivers {
+		if d.UserID != nil && d.Status == types.BookingDriverAccepted && *d.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// @Summary Add a driver to the booking
+// @Description Adds existing user as an additional driver or invites him by email
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Param payload body types.AdditionalDriverPayload true "Driver"
+// @Tags Booking
+// @Success 200 {object} types.BookingDriver
+// @Router /booking/{id}/drivers [post]
+func (h *BookingHandler) handleAddBookingDriver(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.AdditionalDriverPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	booking, err := h.booking.GetByID(idInt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkAccess(r, booking.UserID, booking.CarID); err != nil {
+		return err
+	}
+
+	driver, err := h.booking.AddDriver(idInt, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, driver)
+}
+
+// @Summary Accept driver invitation
+// @Description Logged user joins the booking he was invited to by email
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/{id}/drivers/accept [post]
+func (h *BookingHandler) handleAcceptDriverInvitation(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.booking.AcceptDriverInvitation(idInt, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("joined booking %d as a driver", idInt)})
+}
+
+// @Summary Remove a driver from the booking
+// @Description Removes additional driver or cancels his invitation
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Param driverId path int true "Driver ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/{id}/drivers/{driverId} [delete]
+func (h *BookingHandler) handleRemoveBookingDriver(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	driverId, err := strconv.Atoi(r.PathValue("driverId"))
+	if err != nil {
+		return types.BadPathParameter("driverId")
+	}
+
+	booking, err := h.booking.GetByID(idInt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkAccess(r, booking.UserID, booking.CarID); err != nil {
+		return err
+	}
+
+	if err := h.booking.RemoveDriver(idInt, driverId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("driver %d removed", driverId)})
+}
+
+// @Summary Create a booking series
+// @Description Books the car on selected weekdays between start and end date, every occurrence is a separate booking
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param payload body types.CreateBookingSeriesPayload true "Booking series data"
+// @Tags Booking
+// @Success 200 {object} types.BookingSeriesResult
+// @Router /booking/series [post]
+func (h *BookingHandler) handleCreateBookingSeries(w http.ResponseWriter, r *http.Request) error {
+	var payload types.CreateBookingSeriesPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	result, err := h.booking.CreateSeries(userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, result)
+}
+
+// @Summary Get booking series by ID
+// @Description Retrieves a booking series with all of its occurrences
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Series ID"
+// @Tags Booking
+// @Success 200 {object} types.BookingSeriesResult
+// @Router /booking/series/{id} [get]
+func (h *BookingHandler) handleGetBookingSeries(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	result, err := h.booking.GetSeries(idInt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkAccess(r, result.Series.UserID, result.Series.CarID); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, result)
+}
+
+// @Summary Update booking series
+// @Description Changes end date or weekdays of the series, upcoming occurrences are regenerated
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Series ID"
+// @Param payload body types.UpdateBookingSeriesPayload true "Updated series data"
+// @Tags Booking
+// @Success 200 {object} types.BookingSeriesResult
+// @Router /booking/series/{id} [put]
+func (h *BookingHandler) handleUpdateBookingSeries(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.UpdateBookingSeriesPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	series, err := h.booking.GetSeries(idInt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkAccess(r, series.Series.UserID, series.Series.CarID); err != nil {
+		return err
+	}
+
+	result, err := h.booking.UpdateSeries(idInt, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, result)
+}
+
+// @Summary Cancel booking series
+// @Description Cancels all occurrences of the series that haven't started yet
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Series ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/series/{id} [delete]
+func (h *BookingHandler) handleCancelBookingSeries(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	series, err := h.booking.GetSeries(idInt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkAccess(r, series.Series.UserID, series.Series.CarID); err != nil {
+		return err
+	}
+
+	if err := h.booking.CancelSeries(idInt); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("booking series %d cancelled", idInt)})
+}
+
+// @Summary Cancel single occurrence of booking series
+// @Description Cancels one booking of the series, the rest stays untouched
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Series ID"
+// @Param bookingId path int true "Booking ID"
+// @Tags Booking
+// @Success 200 {object} map[string]string
+// @Router /booking/series/{id}/occurrence/{bookingId} [delete]
+func (h *BookingHandler) handleCancelSeriesOccurrence(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	bookingId, err := strconv.Atoi(r.PathValue("bookingId"))
+	if err != nil {
+		return types.BadPathParameter("bookingId")
+	}
+
+	series, err := h.booking.GetSeries(idInt)
+	if err != nil {
+		return err
+	}
+
+	if err := h.checkAccess(r, series.Series.UserID, series.Series.CarID); err != nil {
+		return err
+	}
+
+	if err := h.booking.CancelOccurrence(idInt, bookingId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("booking %d cancelled", bookingId)})
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
 )
 
 func TestCreateBooking(t *testing.T) {
@@ -64,3 +68,144 @@ func TestDeleteBooking(t *testing.T) {
 
 	checkResponse(resp, 200, t)
 }
+
+func TestBookingSeries(t *testing.T) {
+	start := time.Now().AddDate(0, 0, 7)
+	payload := &types.CreateBookingSeriesPayload{
+		CarID:     2,
+		StartDate: start.Format(time.DateOnly),
+		EndDate:   start.AddDate(0, 0, 13).Format(time.DateOnly),
+		Weekdays:  []int{1, 2, 3, 4, 5},
+	}
+
+	resp := sendPostRequest(testServer.URL+"/booking/series", payload, t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var result types.BookingSeriesResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(result.Bookings) != 10 {
+		t.Errorf("expected 10 occurrences, got %v", len(result.Bookings))
+	}
+
+	url := fmt.Sprintf("%s/booking/series/%d", testServer.URL, result.Series.ID)
+	checkResponse(sendGetRequest(url, t), http.StatusOK, t)
+
+	// same days again, all of them conflict
+	resp = sendPostRequest(testServer.URL+"/booking/series", payload, t)
+	checkResponse(resp, http.StatusConflict, t)
+
+	checkResponse(sendDeleteRequest(url, t), http.StatusOK, t)
+}
+
+func TestBookingDrivers(t *testing.T) {
+	start := time.Now().AddDate(0, 1, 0).Format(time.DateOnly)
+	payload := &types.CreateBookingPayload{
+		CarID:             3,
+		StartDate:         start,
+		EndDate:           start,
+		AdditionalDrivers: []*types.AdditionalDriverPayload{{Email: "not-a-mail"}},
+	}
+	resp := sendPostRequest(testServer.URL+"/booking", payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	payload.AdditionalDrivers[0].Email = utils.GenerateUniqueString("driver") + "@blabla.com"
+	resp = sendPostRequest(testServer.URL+"/booking", payload, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	claims, err := checkToken()
+	if err != nil {
+		t.Fatalf("failed to check token: %v", err)
+	}
+	resp = sendGetRequest(fmt.Sprintf("%s/booking/user/%.0f", testServer.URL, claims["id"]), t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var bookings []types.Booking
+	if err := json.Unmarshal(body, &bookings); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	bookingID := 0
+	for _, b := range bookings {
+		if b.CarID == 3 {
+			bookingID = b.ID
+		}
+	}
+
+	url := fmt.Sprintf("%s/booking/%d", testServer.URL, bookingID)
+	resp = sendPostRequest(url+"/drivers", &types.AdditionalDriverPayload{Email: utils.GenerateUniqueString("driver") + "@blabla.com"}, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendGetRequest(url, t)
+	body = checkResponse(resp, http.StatusOK, t)
+
+	var booking types.Booking
+	if err := json.Unmarshal(body, &booking); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(booking.Drivers) != 2 {
+		t.Fatalf("expected 2 invited drivers, got %v", len(booking.Drivers))
+	}
+
+	resp = sendDeleteRequest(fmt.Sprintf("%s/drivers/%d", url, booking.Drivers[0].ID), t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
+func TestPickUpBooking(t *testing.T) {
+	claims, err := checkToken()
+	if err != nil {
+		t.Fatalf("failed to check token: %v", err)
+	}
+	resp := sendGetRequest(fmt.Sprintf("%s/booking/user/%.0f", testServer.URL, claims["id"]), t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var bookings []types.Booking
+	if err := json.Unmarshal(body, &bookings); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(bookings) == 0 {
+		t.Fatalf("expected user to have bookings")
+	}
+
+	// only the company confirms the pickup, the renter here also owns it
+	resp = sendPostRequest(fmt.Sprintf("%s/booking/%d/pickup", testServer.URL, bookings[0].ID), nil, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	defer func(header string) { authHeader = header }(authHeader)
+	authHeader = registerUser(utils.GenerateUniqueString("renter")+"@blabla.com", types.UserTypeUser, t)
+	resp = sendPostRequest(fmt.Sprintf("%s/booking/%d/pickup", testServer.URL, bookings[0].ID), nil, t)
+	checkResponse(resp, http.StatusUnauthorized, t)
+}
+
+func TestBookingTransfer(t *testing.T) {
+	claims, err := checkToken()
+	if err != nil {
+		t.Fatalf("failed to check token: %v", err)
+	}
+	resp := sendGetRequest(fmt.Sprintf("%s/booking/user/%.0f", testServer.URL, claims["id"]), t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var bookings []types.Booking
+	if err := json.Unmarshal(body, &bookings); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	bookingID := 0
+	for _, b := range bookings {
+		if b.Status == types.BookingStatusConfirmed {
+			bookingID = b.ID
+		}
+	}
+	if bookingID == 0 {
+		t.Fatalf("expected user to have confirmed bookings")
+	}
+	url := fmt.Sprintf("%s/booking/%d/transfer", testServer.URL, bookingID)
+
+	resp = sendPostRequest(url, &types.TransferBookingPayload{}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendPostRequest(url, &types.TransferBookingPayload{Email: "nobody@blabla.com"}, t)
+	checkResponse(resp, http.StatusNotFound, t)
+
+	resp = sendPostRequest(url+"/accept", nil, t)
+	checkResponse(resp, http.StatusNotFound, t)
+}
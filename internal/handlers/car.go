@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 
 	"github.com/mwdev22/CarRental/internal/services"
@@ -23,16 +26,75 @@ func NewCarHandler(mux *http.ServeMux, car *services.CarService, logger *log.Log
 		logger: logger,
 	}
 
-	h.mux.HandleFunc("POST /car", makeHandler(h.handleCreateCar, logger))
+	// changes are made by members of the car's company whose role allows it, see /company/{id}/members
+	h.mux.HandleFunc("POST /car", authMiddleware(h.handleCreateCar, logger))
 	h.mux.HandleFunc("GET /car/{id}", makeHandler(h.handleGetCarByID, logger))
-	h.mux.HandleFunc("DELETE /car/{id}", makeHandler(h.handleDeleteCarByID, logger))
-	h.mux.HandleFunc("PUT /car/{id}", makeHandler(h.handleUpdateCarByID, logger))
+	h.mux.HandleFunc("DELETE /car/{id}", authMiddleware(h.handleDeleteCarByID, logger))
+	h.mux.HandleFunc("PUT /car/{id}", authMiddleware(h.handleUpdateCarByID, logger))
 
 	// check for allowed operators in utils/handlers.go
 	// you pass params like {field}[{operator}]={value}
 	// sorting like sort={field}-{direction}
 	// GET /car?page=1&page_size=10&sort=name-asc&make[ct]=Mercedes&model[ct]=CLA&year=2022
+	// features are filtered the same way, in takes comma separated values
+	// GET /car/batch?seats[gte]=7&transmission=automatic&fuel_type[in]=diesel,hybrid
+	// only available cars are listed, companies see the rest by filtering on status, e.g. status[in]=in_service,retired
+	// facets=true wraps the cars with counts for filter sidebars, counted over all pages
 	h.mux.HandleFunc("GET /car/batch", makeHandler(h.handleGetCars, logger))
+	// free text over make, model, color, year and features, combined with the same filters
+	// GET /car/search?q=white toyota hybrid 2022&seats[gte]=5
+	h.mux.HandleFunc("GET /car/search", makeHandler(h.handleSearchCars, logger))
+
+	// available, in_service or retired, retired cars can't be brought back
+	h.mux.HandleFunc("PUT /car/{id}/status", authMiddleware(h.handleSetCarStatus, logger))
+
+	// price history and scheduled changes, bookings are billed by the price effective on each day
+	h.mux.HandleFunc("GET /car/{id}/prices", authMiddleware(h.handleGetCarPrices, logger))
+	h.mux.HandleFunc("POST /car/{id}/prices", authMiddleware(h.handleScheduleCarPrice, logger))
+	h.mux.HandleFunc("DELETE /car/{id}/prices/{priceId}", authMiddleware(h.handleCancelCarPrice, logger))
+
+	// prices of the car over the next days with company's dynamic pricing, ?days=30 for a shorter period
+	h.mux.HandleFunc("GET /car/{id}/pricing/simulation", authMiddleware(h.handleSimulateCarPricing, logger))
+
+	// moving the car to another company, accepted by the staff of the receiving one,
+	// or between branches of its company right away
+	h.mux.HandleFunc("GET /car/{id}/transfers", authMiddleware(h.handleGetCarTransfers, logger))
+	h.mux.HandleFunc("POST /car/{id}/transfer", authMiddleware(h.handleTransferCar, logger))
+	h.mux.HandleFunc("POST /car/{id}/transfer/accept", authMiddleware(h.handleAcceptCarTransfer, logger))
+	h.mux.HandleFunc("POST /car/{id}/transfer/decline", authMiddleware(h.handleDeclineCarTransfer, logger))
+
+	// fleet classes, users can book any car of the category
+	h.mux.HandleFunc("GET /car/category", makeHandler(h.handleGetCarCategories, logger))
+
+	// manufacturer and model year read from the vin, GET /car/vin?vin=1M8GDM9AXKP042788
+	h.mux.HandleFunc("GET /car/vin", makeHandler(h.handleDecodeVIN, logger))
+
+	// maintenance windows block the car for bookings, managed by the staff of the company renting the car
+	h.mux.HandleFunc("GET /car/{id}/maintenance", authMiddleware(h.handleGetCarMaintenance, logger))
+	h.mux.HandleFunc("POST /car/{id}/maintenance", authMiddleware(h.handleScheduleCarMaintenance, logger))
+	h.mux.HandleFunc("DELETE /car/{id}/maintenance/{maintenanceId}", authMiddleware(h.handleCancelCarMaintenance, logger))
+
+	// odometer, fuel and service history, service is due by km or months whichever comes first
+	h.mux.HandleFunc("GET /car/{id}/history", authMiddleware(h.handleGetCarHistory, logger))
+	h.mux.HandleFunc("POST /car/{id}/readings", authMiddleware(h.handleAddCarReading, logger))
+	h.mux.HandleFunc("POST /car/{id}/services", authMiddleware(h.handleAddServiceRecord, logger))
+	h.mux.HandleFunc("GET /company/{id}/service-due", authMiddleware(h.handleGetServiceDue, logger))
+
+	// fleet as csv, the body of the import is the file, dry_run=true only validates the rows,
+	// export can be imported back, id and status columns are skipped
+	h.mux.HandleFunc("POST /company/{id}/cars/import", authMiddleware(h.handleImportCars, logger))
+	h.mux.HandleFunc("GET /company/{id}/cars/export", authMiddleware(h.handleExportCars, logger))
+
+	// telemetry from device gateways, they send the key in X-Gateway-Key header,
+	// last known position is returned with the car
+	h.mux.HandleFunc("POST /telemetry", gatewayMiddleware(h.handleIngestTelemetry, logger))
+
+	// gallery, images are uploaded as multipart form with the file in "image" field
+	h.mux.HandleFunc("GET /car/{id}/images", makeHandler(h.handleGetCarImages, logger))
+	h.mux.HandleFunc("POST /car/{id}/images", authMiddleware(h.handleUploadCarImage, logger))
+	h.mux.HandleFunc("PUT /car/{id}/images/order", authMiddleware(h.handleReorderCarImages, logger))
+	h.mux.HandleFunc("PUT /car/{id}/images/{imageId}/primary", authMiddleware(h.handleSetPrimaryCarImage, logger))
+	h.mux.HandleFunc("DELETE /car/{id}/images/{imageId}", authMiddleware(h.handleDeleteCarImage, logger))
 
 	return h
 }
@@ -60,7 +122,12 @@ func (h *CarHandler) handleCreateCar(w http.ResponseWriter, r *http.Request) err
 		return types.ValidationError(errors)
 	}
 
-	err := h.car.CreateCar(&payload)
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	err := h.car.CreateCar(userId, &payload)
 	if err != nil {
 		return err
 	}
@@ -92,7 +159,7 @@ func (h *CarHandler) handleGetCarByID(w http.ResponseWriter, r *http.Request) er
 }
 
 // @Summary Delete car by ID
-// @Description Deletes a car by its ID
+// @Description Deletes a car by its ID, cars with bookings are retired instead to keep the history
 // @Param Authorization header string true "Bearer Token"
 // @Param id path int true "Car ID"
 // @Tags Car
@@ -105,11 +172,21 @@ func (h *CarHandler) handleDeleteCarByID(w http.ResponseWriter, r *http.Request)
 		return types.BadPathParameter("id")
 	}
 
-	err = h.car.Delete(idInt)
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	retired, err := h.car.Delete(idInt, userId)
 	if err != nil {
 		return err
 	}
 
+	if retired {
+		return types.WriteJSON(w, http.StatusOK, map[string]string{
+			"message": "car has bookings, it was retired instead of deleted",
+		})
+	}
 	return types.WriteJSON(w, http.StatusOK, map[string]string{
 		"message": "car deleted successfully!",
 	})
@@ -141,7 +218,12 @@ func (h *CarHandler) handleUpdateCarByID(w http.ResponseWriter, r *http.Request)
 		return types.ValidationError(errors)
 	}
 
-	err = h.car.UpdateCar(idInt, &payload)
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	err = h.car.UpdateCar(idInt, userId, &payload)
 	if err != nil {
 		return err
 	}
@@ -154,28 +236,912 @@ func (h *CarHandler) handleUpdateCarByID(w http.ResponseWriter, r *http.Request)
 // @Summary Get cars
 // @Description Retrieves a list of cars with optional filters
 // @Produce json
-// @Param filters query string false "Filters for car retrieval. eg. make[ct]=Mercedes&model[ct]=CLA&year=2022"
+// @Param filters query string false "Filters for car retrieval. eg. make[ct]=Mercedes&model[ct]=CLA&year=2022&seats[gte]=7&fuel_type[in]=diesel,hybrid"
 // @Param sort query string false "sort for car retrieval, eg. id-asc"
 // @Param page query int false "page number for car retrieval"
 // @Param page_size query int false "number of items per page"
+// @Param near query string false "only cars parked within radius_km of the point, sorted by distance, eg. 52.2297,21.0122"
+// @Param radius_km query number false "search radius for near, 10 km by default"
+// @Param facets query bool false "return types.CarBatch with counts per make, category, transmission, price and company"
 // @Tags Car
 // @Success 200 {array} types.Car
 // @Router /cars [get]
 func (h *CarHandler) handleGetCars(w http.ResponseWriter, r *http.Request) error {
-	filters, err := utils.ParseQueryFilters(r)
+	filters, err := parseCarFilters(r)
 	if err != nil {
 		return err
 	}
 
-	opts, err := utils.ParseQueryOptions(r)
+	opts, err := utils.ParseQueryOptions(r, types.CarQueryFields)
 	if err != nil {
 		return err
 	}
 
+	return h.writeCars(w, r, filters, opts)
+}
+
+// with facets=true the cars come with facet counts of all the matching cars
+func (h *CarHandler) writeCars(w http.ResponseWriter, r *http.Request, filters []*types.QueryFilter, opts *types.QueryOptions) error {
 	cars, err := h.car.GetBatch(filters, opts)
 	if err != nil {
 		return err
 	}
 
-	return types.WriteJSON(w, http.StatusOK, cars)
+	if withFacets, _ := strconv.ParseBool(r.URL.Query().Get("facets")); !withFacets {
+		return types.WriteJSON(w, http.StatusOK, cars)
+	}
+	facets, err := h.car.GetFacets(filters, opts)
+	if err != nil {
+		return err
+	}
+	if cars == nil {
+		cars = []types.Car{}
+	}
+	return types.WriteJSON(w, http.StatusOK, &types.CarBatch{Cars: cars, Facets: facets})
+}
+
+// only companies can see cars that aren't available
+func parseCarFilters(r *http.Request) ([]*types.QueryFilter, error) {
+	filters, err := utils.ParseQueryFilters(r, types.CarQueryFields)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		if f.Field == "status" && !hasRole(r, types.UserTypeCompanyOwner) {
+			return nil, types.Unauthorized("only companies can list cars by status")
+		}
+	}
+	return filters, nil
+}
+
+// @Summary Search cars
+// @Description Searches available cars by free text over make, model, color, year and features, every word has to match,
+// @Description best matches first unless sorted otherwise, filters are the same as for the car list
+// @Produce json
+// @Param q query string true "search text, eg. white toyota hybrid 2022"
+// @Param filters query string false "Filters for car retrieval. eg. seats[gte]=7&fuel_type[in]=diesel,hybrid"
+// @Param sort query string false "sort for car retrieval, eg. price_per_day-asc"
+// @Param page query int false "page number for car retrieval"
+// @Param page_size query int false "number of items per page"
+// @Param facets query bool false "return types.CarBatch with counts per make, category, transmission, price and company"
+// @Tags Car
+// @Success 200 {array} types.Car
+// @Router /car/search [get]
+func (h *CarHandler) handleSearchCars(w http.ResponseWriter, r *http.Request) error {
+	terms := utils.Tokenize(r.URL.Query().Get("q"))
+	if len(terms) == 0 {
+		return types.BadQueryParameter("q, nothing to search for")
+	}
+
+	filters, err := parseCarFilters(r)
+	if err != nil {
+		return err
+	}
+
+	opts, err := utils.ParseQueryOptions(r, append(slices.Clip(types.CarQueryFields), "relevance"))
+	if err != nil {
+		return err
+	}
+	opts.Search = terms
+	if !r.URL.Query().Has("sort") && opts.Near == nil {
+		opts.SortField, opts.SortDiretion = "relevance", "desc"
+	}
+
+	return h.writeCars(w, r, filters, opts)
+}
+
+// @Summary Set car status
+// @Description Moves the car between available, in service and retired, car can be retired only without upcoming bookings
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.CarStatusPayload true "New status"
+// @Tags Car
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/status [put]
+func (h *CarHandler) handleSetCarStatus(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.CarStatusPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.SetStatus(idInt, userId, types.CarStatus(payload.Status)); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": fmt.Sprintf("car %d is %s", idInt, payload.Status),
+	})
+}
+
+// @Summary Get car history
+// @Description Odometer and fuel readings, service records and when the next service is due
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {object} types.CarHistory
+// @Router /car/{id}/history [get]
+func (h *CarHandler) handleGetCarHistory(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	history, err := h.car.GetHistory(idInt, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, history)
+}
+
+// @Summary Add car reading
+// @Description Records odometer and fuel level from check-out, check-in, manual entry or telemetry
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.CarReadingPayload true "Reading"
+// @Tags Car
+// @Success 200 {object} types.CarReading
+// @Router /car/{id}/readings [post]
+func (h *CarHandler) handleAddCarReading(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.CarReadingPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	reading, err := h.car.AddReading(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, reading)
+}
+
+// @Summary Add service record
+// @Description Records performed service with its cost, next service is counted from it
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.ServiceRecordPayload true "Service record"
+// @Tags Car
+// @Success 200 {object} types.ServiceRecord
+// @Router /car/{id}/services [post]
+func (h *CarHandler) handleAddServiceRecord(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.ServiceRecordPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	record, err := h.car.AddServiceRecord(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, record)
+}
+
+// @Summary Get car prices
+// @Description Past prices of the car and scheduled changes, oldest first
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {array} types.CarPrice
+// @Router /car/{id}/prices [get]
+func (h *CarHandler) handleGetCarPrices(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	prices, err := h.car.GetPrices(idInt, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, prices)
+}
+
+// @Summary Schedule car price change
+// @Description Changes the price per day from a future date, bookings are billed by the price effective on each day
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.CarPricePayload true "New price"
+// @Tags Car
+// @Success 200 {object} types.CarPrice
+// @Router /car/{id}/prices [post]
+func (h *CarHandler) handleScheduleCarPrice(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.CarPricePayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	price, err := h.car.SchedulePrice(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, price)
+}
+
+// @Summary Cancel scheduled price change
+// @Description Removes price change that didn't take effect yet
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param priceId path int true "Price ID"
+// @Tags Car
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/prices/{priceId} [delete]
+func (h *CarHandler) handleCancelCarPrice(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	priceId, err := strconv.Atoi(r.PathValue("priceId"))
+	if err != nil {
+		return types.BadPathParameter("priceId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.CancelPrice(idInt, priceId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "price change cancelled successfully!",
+	})
+}
+
+// @Summary Get cars due for service
+// @Description Cars of the company that are close to or past their service interval
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Tags Car
+// @Success 200 {array} types.ServiceStatus
+// @Router /company/{id}/service-due [get]
+func (h *CarHandler) handleGetServiceDue(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	due, err := h.car.GetServiceDue(idInt, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, due)
+}
+
+// @Summary Ingest telemetry
+// @Description Stores batch of positions and readings sent by a device gateway, points older than the retention period are skipped
+// @Accept json
+// @Produce json
+// @Param X-Gateway-Key header string true "Gateway key"
+// @Param payload body types.TelemetryPayload true "Telemetry points"
+// @Tags Car
+// @Success 200 {object} types.TelemetryResult
+// @Router /telemetry [post]
+func (h *CarHandler) handleIngestTelemetry(w http.ResponseWriter, r *http.Request) error {
+	var payload types.TelemetryPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	result, err := h.car.IngestTelemetry(&payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, result)
+}
+
+// @Summary Decode VIN
+// @Description Validates the check digit and reads the manufacturer, region and model year without external databases
+// @Produce json
+// @Param vin query string true "Vehicle identification number"
+// @Tags Car
+// @Success 200 {object} types.VINInfo
+// @Router /car/vin [get]
+func (h *CarHandler) handleDecodeVIN(w http.ResponseWriter, r *http.Request) error {
+	vin := r.URL.Query().Get("vin")
+	if vin == "" {
+		return types.BadQueryParameter("vin")
+	}
+
+	info, err := h.car.DecodeVIN(vin)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, info)
+}
+
+// @Summary Get car categories
+// @Description Retrieves fleet classes with their typical attributes
+// @Produce json
+// @Tags Car
+// @Success 200 {array} types.CarCategory
+// @Router /car/category [get]
+func (h *CarHandler) handleGetCarCategories(w http.ResponseWriter, r *http.Request) error {
+	categories, err := h.car.GetCategories()
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, categories)
+}
+
+// @Summary Get car images
+// @Description Retrieves the gallery of the car in display order
+// @Produce json
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {array} types.CarImage
+// @Router /car/{id}/images [get]
+func (h *CarHandler) handleGetCarImages(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	images, err := h.car.GetImages(idInt)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, images)
+}
+
+// @Summary Upload car image
+// @Description Adds jpeg, png or gif image up to 5MB to the gallery, thumbnail is generated on upload
+// @Accept multipart/form-data
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param image formData file true "Image"
+// @Tags Car
+// @Success 200 {object} types.CarImage
+// @Router /car/{id}/images [post]
+func (h *CarHandler) handleUploadCarImage(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	// some room for the rest of the form
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxImageSize+1<<20)
+	if err := r.ParseMultipartForm(services.MaxImageSize); err != nil {
+		return types.BadRequest("invalid form or image too large")
+	}
+
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		return types.BadRequest("missing image file")
+	}
+	defer file.Close()
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	image, err := h.car.AddImage(idInt, userId, file)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, image)
+}
+
+// @Summary Reorder car images
+// @Description Sets the display order of the gallery, all images of the car have to be listed
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.ReorderCarImagesPayload true "Image IDs in new order"
+// @Tags Car
+// @Success 200 {array} types.CarImage
+// @Router /car/{id}/images/order [put]
+func (h *CarHandler) handleReorderCarImages(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.ReorderCarImagesPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	images, err := h.car.ReorderImages(idInt, userId, payload.ImageIDs)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, images)
+}
+
+// @Summary Set primary car image
+// @Description Marks the image as the main picture of the car
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param imageId path int true "Image ID"
+// @Tags Car
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/images/{imageId}/primary [put]
+func (h *CarHandler) handleSetPrimaryCarImage(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	imageId, err := strconv.Atoi(r.PathValue("imageId"))
+	if err != nil {
+		return types.BadPathParameter("imageId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.SetPrimaryImage(idInt, imageId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "primary image set successfully!",
+	})
+}
+
+// @Summary Delete car image
+// @Description Removes the image and its thumbnail
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param imageId path int true "Image ID"
+// @Tags Car
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/images/{imageId} [delete]
+func (h *CarHandler) handleDeleteCarImage(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	imageId, err := strconv.Atoi(r.PathValue("imageId"))
+	if err != nil {
+		return types.BadPathParameter("imageId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.DeleteImage(idInt, imageId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "image deleted successfully!",
+	})
+}
+
+// @Summary Import cars
+// @Description Creates the fleet of the company from csv with header, all rows are validated first and cars are created
+// @Description only when every row is valid, errors are reported per line under the same names as for car creation
+// @Accept text/csv
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param dry_run query bool false "only validate the file"
+// @Param file body string true "make,model,year,color,registration_no,price_per_day,... one car per line"
+// @Tags Car
+// @Success 200 {object} types.CarImportResult
+// @Failure 400 {object} types.CarImportResult
+// @Router /company/{id}/cars/import [post]
+func (h *CarHandler) handleImportCars(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	dryRun := false
+	if value := r.URL.Query().Get("dry_run"); value != "" {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			return types.BadQueryParameter("dry_run")
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxImportSize)
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	result, err := h.car.ImportCars(idInt, userId, r.Body, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Errors) > 0 && !dryRun {
+		return types.WriteJSON(w, http.StatusBadRequest, result)
+	}
+	return types.WriteJSON(w, http.StatusOK, result)
+}
+
+// @Summary Export cars
+// @Description Fleet of the company as csv in the import format with id and status, retired cars included
+// @Produce text/csv
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Tags Car
+// @Success 200 {string} string
+// @Router /company/{id}/cars/export [get]
+func (h *CarHandler) handleExportCars(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var file bytes.Buffer
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.ExportCars(idInt, userId, &file); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=company-%d-cars.csv", idInt))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(file.Bytes())
+	return err
+}
+
+// @Summary Get maintenance of the car
+// @Description Lists scheduled maintenance windows of the car
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {array} types.Maintenance
+// @Router /car/{id}/maintenance [get]
+func (h *CarHandler) handleGetCarMaintenance(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	maintenance, err := h.car.GetMaintenance(idInt, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, maintenance)
+}
+
+// @Summary Schedule car maintenance
+// @Description Blocks the car for service, tyre change or inspection, returns bookings overlapping the window so they can be reassigned
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.CreateMaintenancePayload true "Maintenance window"
+// @Tags Car
+// @Success 200 {object} types.ScheduledMaintenance
+// @Router /car/{id}/maintenance [post]
+func (h *CarHandler) handleScheduleCarMaintenance(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.CreateMaintenancePayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	scheduled, err := h.car.ScheduleMaintenance(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, scheduled)
+}
+
+// @Summary Cancel car maintenance
+// @Description Removes the maintenance window, the car can be booked again in that time
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param maintenanceId path int true "Maintenance ID"
+// @Tags Car
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/maintenance/{maintenanceId} [delete]
+func (h *CarHandler) handleCancelCarMaintenance(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	maintenanceId, err := strconv.Atoi(r.PathValue("maintenanceId"))
+	if err != nil {
+		return types.BadPathParameter("maintenanceId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.CancelMaintenance(idInt, maintenanceId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("maintenance %d cancelled", maintenanceId)})
+}
+
+// @Summary Simulate dynamic pricing
+// @Description Daily prices of the car over the next days (60 by default) with company's dynamic pricing, even when it isn't enabled yet
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param days query int false "Number of days"
+// @Tags Car
+// @Success 200 {array} types.DailyPrice
+// @Router /car/{id}/pricing/simulation [get]
+func (h *CarHandler) handleSimulateCarPricing(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var days int
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		days, err = strconv.Atoi(daysStr)
+		if err != nil {
+			return types.BadQueryParameter("days")
+		}
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	prices, err := h.car.SimulatePricing(idInt, userId, days)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, prices)
+}
+
+// @Summary Transfer a car
+// @Description Offers the car to another company or moves it to another branch of its company, cars with future bookings need migrate_bookings to take them along
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param payload body types.TransferCarPayload true "Receiving company or branch"
+// @Tags Car
+// @Success 200 {object} types.CarTransfer
+// @Router /car/{id}/transfer [post]
+func (h *CarHandler) handleTransferCar(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.TransferCarPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	transfer, err := h.car.TransferCar(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, transfer)
+}
+
+// @Summary Accept a car transfer
+// @Description Owner of the receiving company adds the car to its fleet
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {object} types.CarTransfer
+// @Router /car/{id}/transfer/accept [post]
+func (h *CarHandler) handleAcceptCarTransfer(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	transfer, err := h.car.AcceptCarTransfer(idInt, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, transfer)
+}
+
+// @Summary Decline a car transfer
+// @Description Declined by the receiving company or withdrawn by the current one
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/transfer/decline [post]
+func (h *CarHandler) handleDeclineCarTransfer(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.car.DeclineCarTransfer(idInt, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": "car transfer declined"})
+}
+
+// @Summary Get car transfers
+// @Description History of moving the car between companies, oldest first
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags Car
+// @Success 200 {array} types.CarTransfer
+// @Router /car/{id}/transfers [get]
+func (h *CarHandler) handleGetCarTransfers(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	transfers, err := h.car.GetCarTransfers(idInt, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, transfers)
 }
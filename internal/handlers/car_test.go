@@ -3,10 +3,17 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mwdev22/CarRental/internal/config"
 	"github.com/mwdev22/CarRental/internal/types"
 	"github.com/mwdev22/CarRental/internal/utils"
 )
@@ -74,6 +81,7 @@ func TestUpdateCar(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create PUT request: %v", err)
 	}
+	req.Header.Set("Authorization", authHeader)
 
 	resp, err := testServer.Client().Do(req)
 	if err != nil {
@@ -110,6 +118,366 @@ func TestGetBatchCars(t *testing.T) {
 	}
 }
 
+func TestFilterCarsByFeatures(t *testing.T) {
+	payload := &types.CreateCarPayload{
+		Make:           "Volkswagen",
+		Model:          "Multivan",
+		Year:           2023,
+		Color:          "Black",
+		RegistrationNo: utils.GenerateUniqueString(""),
+		PricePerDay:    120.0,
+		CompanyID:      1,
+		Features:       &types.CarFeaturesPayload{Transmission: "automatic", FuelType: "diesel", Seats: 7},
+	}
+	resp := sendPostRequest(testServer.URL+"/car", payload, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	payload.Features.Transmission = "steam"
+	resp = sendPostRequest(testServer.URL+"/car", payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendGetRequest(testServer.URL+"/car/batch?seats[gte]=7&transmission=automatic&fuel_type[in]=diesel,hybrid", t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var cars []types.Car
+	if err := json.Unmarshal(body, &cars); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(cars) != 1 || cars[0].Model != "Multivan" {
+		t.Fatalf("expected only the multivan, got %v", cars)
+	}
+
+	// field names end up in the query, unknown ones are rejected
+	resp = sendGetRequest(testServer.URL+"/car/batch?year%20OR%201[eq]=1", t)
+	checkResponse(resp, http.StatusBadRequest, t)
+}
+
+func TestSearchCars(t *testing.T) {
+	for _, payload := range []*types.CreateCarPayload{
+		{Make: "Toyota", Model: "Corolla", Year: 2022, Color: "White", PricePerDay: 90, Features: &types.CarFeaturesPayload{FuelType: "hybrid"}},
+		{Make: "Toyota", Model: "Auris Hybrid", Year: 2019, Color: "Red", PricePerDay: 70, Features: &types.CarFeaturesPayload{FuelType: "petrol"}},
+	} {
+		payload.RegistrationNo = utils.GenerateUniqueString("")
+		payload.CompanyID = 1
+		resp := sendPostRequest(testServer.URL+"/car", payload, t)
+		checkResponse(resp, http.StatusOK, t)
+	}
+
+	search := func(query string) []types.Car {
+		resp := sendGetRequest(testServer.URL+"/car/search?"+query, t)
+		body := checkResponse(resp, http.StatusOK, t)
+		var cars []types.Car
+		if err := json.Unmarshal(body, &cars); err != nil {
+			t.Fatalf("failed to parse response body: %v", err)
+		}
+		return cars
+	}
+
+	if cars := search("q=White+toyota,+hybrid+2022"); len(cars) != 1 || cars[0].Model != "Corolla" {
+		t.Fatalf("expected only the corolla, got %v", cars)
+	}
+	// model weighs more than features
+	if cars := search("q=toyota+hybrid"); len(cars) != 2 || cars[0].Model != "Auris Hybrid" || *cars[0].Relevance <= *cars[1].Relevance {
+		t.Fatalf("expected the auris first, got %v", cars)
+	}
+	if cars := search("q=toyo&price_per_day[gte]=80"); len(cars) != 1 || cars[0].Model != "Corolla" {
+		t.Fatalf("expected search combined with filters, got %v", cars)
+	}
+
+	resp := sendGetRequest(testServer.URL+"/car/search?q=+,", t)
+	checkResponse(resp, http.StatusBadRequest, t)
+}
+
+func TestCarFacets(t *testing.T) {
+	resp := sendGetRequest(testServer.URL+"/car/batch?make[in]=Toyota,Volkswagen&limit=1&facets=true", t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var batch types.CarBatch
+	if err := json.Unmarshal(body, &batch); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(batch.Cars) != 1 || batch.Facets == nil {
+		t.Fatalf("expected one car with facets, got %s", body)
+	}
+	// counted over all pages, the two toyotas from the search test and the multivan
+	expected := []types.FacetCount{{Value: "Toyota", Count: 2}, {Value: "Volkswagen", Count: 1}}
+	if !slices.Equal(batch.Facets.Make, expected) {
+		t.Fatalf("expected make counts %v, got %v", expected, batch.Facets.Make)
+	}
+	expected = []types.FacetCount{{Value: "50-100", Count: 2}, {Value: "100-200", Count: 1}}
+	if !slices.Equal(batch.Facets.Price, expected) {
+		t.Fatalf("expected price counts %v, got %v", expected, batch.Facets.Price)
+	}
+}
+
+func TestImportCars(t *testing.T) {
+	importCSV := func(query string, file string) *http.Response {
+		req, err := http.NewRequest("POST", testServer.URL+"/company/9/cars/import"+query, strings.NewReader(file))
+		if err != nil {
+			t.Fatalf("failed to create POST request: %v", err)
+		}
+		req.Header.Set("Content-Type", "text/csv")
+		req.Header.Set("Authorization", authHeader)
+
+		resp, err := testServer.Client().Do(req)
+		if err != nil {
+			t.Fatalf("failed to send POST request: %v", err)
+		}
+		return resp
+	}
+
+	file := "make,model,year,color,registration_no,price_per_day,transmission,seats\n" +
+		"Skoda,Octavia,2022,Grey,IMP001,80,automatic,5\n" +
+		"Skoda,Superb,2023,Black,IMP002,abc,automatic,5\n" +
+		"Skoda,Kodiaq,2023,White,IMP001,110,steam,7\n"
+
+	body := checkResponse(importCSV("?dry_run=true", file), http.StatusOK, t)
+	var result types.CarImportResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if result.Rows != 3 || len(result.Errors) != 2 || result.Errors[0].Line != 3 || result.Errors[1].Errors["Transmission"] != "oneof" {
+		t.Fatalf("expected errors on lines 3 and 4, got %s", body)
+	}
+
+	// nothing is created when any row is invalid
+	checkResponse(importCSV("", file), http.StatusBadRequest, t)
+
+	file = strings.Join(strings.Split(file, "\n")[:2], "\n") + "\nSkoda,Superb,2023,Black,IMP002,95,manual,5\n"
+	body = checkResponse(importCSV("", file), http.StatusOK, t)
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if result.Created != 2 {
+		t.Fatalf("expected 2 cars created, got %s", body)
+	}
+
+	resp := sendGetRequest(testServer.URL+"/company/9/cars/export", t)
+	body = checkResponse(resp, http.StatusOK, t)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id,status,make,model") || !strings.Contains(lines[2], ",available,Skoda,Superb,2023,Black,IMP002,95,") {
+		t.Fatalf("unexpected export %s", body)
+	}
+
+	// imported cars start their price history like the ones created one by one
+	resp = sendGetRequest(fmt.Sprintf("%s/car/%s/prices", testServer.URL, strings.Split(lines[1], ",")[0]), t)
+	var prices []types.CarPrice
+	if err := json.Unmarshal(checkResponse(resp, http.StatusOK, t), &prices); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(prices) != 1 || prices[0].PricePerDay != 80 || !prices[0].Applied {
+		t.Fatalf("expected the imported price in effect, got %v", prices)
+	}
+
+	// export is rejected as duplicates when imported back
+	resp = importCSV("?dry_run=true", string(body))
+	body = checkResponse(resp, http.StatusOK, t)
+	if err := json.Unmarshal(body, &result); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Errors["RegistrationNo"] != "already registered" {
+		t.Fatalf("expected registration numbers to be taken, got %s", body)
+	}
+}
+
+func TestCarImages(t *testing.T) {
+	url := testServer.URL + "/car/1/images"
+
+	resp := sendFileRequest(url, "image", "notes.txt", []byte("not an image"), nil, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	var picture bytes.Buffer
+	if err := png.Encode(&picture, image.NewRGBA(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatalf("failed to encode picture: %v", err)
+	}
+	resp = sendFileRequest(url, "image", "car.png", picture.Bytes(), nil, t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var uploaded types.CarImage
+	if err := json.Unmarshal(body, &uploaded); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if !uploaded.Primary || !strings.HasPrefix(uploaded.ThumbnailURL, "/uploads/cars/1/") {
+		t.Fatalf("expected primary image with thumbnail, got %+v", uploaded)
+	}
+
+	resp = sendGetRequest(url, t)
+	body = checkResponse(resp, http.StatusOK, t)
+	var images []types.CarImage
+	if err := json.Unmarshal(body, &images); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("expected 1 image, got %v", len(images))
+	}
+
+	resp = sendDeleteRequest(fmt.Sprintf("%s/%d", url, uploaded.ID), t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
+func TestCarStatus(t *testing.T) {
+	resp := sendPutRequest(testServer.URL+"/car/2/status", &types.CarStatusPayload{Status: "parked"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendPutRequest(testServer.URL+"/car/2/status", &types.CarStatusPayload{Status: "in_service"}, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	// hidden from public listings, companies find it by status
+	resp, err := testServer.Client().Get(testServer.URL + "/car/batch?status=in_service")
+	if err != nil {
+		t.Fatalf("failed to send GET request: %v", err)
+	}
+	checkResponse(resp, http.StatusUnauthorized, t)
+
+	resp = sendGetRequest(testServer.URL+"/car/batch?status=in_service", t)
+	body := checkResponse(resp, http.StatusOK, t)
+	var cars []types.Car
+	if err := json.Unmarshal(body, &cars); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(cars) != 1 || cars[0].ID != 2 {
+		t.Fatalf("expected only car 2 in service, got %v", cars)
+	}
+
+	resp = sendPutRequest(testServer.URL+"/car/2/status", &types.CarStatusPayload{Status: "available"}, t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
+func TestCarHistory(t *testing.T) {
+	resp := sendPostRequest(testServer.URL+"/car/2/readings", &types.CarReadingPayload{Odometer: 1200, Source: "odometer"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendPostRequest(testServer.URL+"/car/2/readings", &types.CarReadingPayload{Odometer: 1200, Source: "manual"}, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendGetRequest(testServer.URL+"/car/2/history", t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var history types.CarHistory
+	if err := json.Unmarshal(body, &history); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(history.Readings) != 1 || history.Service == nil || history.Service.Odometer != 1200 {
+		t.Fatalf("expected the reading in history, got %+v", history)
+	}
+
+	resp = sendGetRequest(testServer.URL+"/company/1/service-due", t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
+func TestIngestTelemetry(t *testing.T) {
+	lat, lng := 52.2297, 21.0122
+	payload, err := json.Marshal(&types.TelemetryPayload{Points: []*types.TelemetryPointPayload{
+		{CarID: 2, RecordedAt: time.Now().UTC().Format(time.RFC3339), Latitude: &lat, Longitude: &lng},
+	}})
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+
+	send := func(key string) *http.Response {
+		req, err := http.NewRequest(http.MethodPost, testServer.URL+"/telemetry", bytes.NewReader(payload))
+		if err != nil {
+			t.Fatalf("failed to create POST request: %v", err)
+		}
+		req.Header.Set("X-Gateway-Key", key)
+		resp, err := testServer.Client().Do(req)
+		if err != nil {
+			t.Fatalf("failed to send POST request: %v", err)
+		}
+		return resp
+	}
+
+	// gateways don't use user tokens
+	checkResponse(send(""), http.StatusUnauthorized, t)
+
+	config.TelemetryKey = []byte("testgateway")
+	defer func() { config.TelemetryKey = nil }()
+	checkResponse(send("testgateway"), http.StatusOK, t)
+
+	resp := sendGetRequest(testServer.URL+"/car/2", t)
+	body := checkResponse(resp, http.StatusOK, t)
+	var car types.Car
+	if err := json.Unmarshal(body, &car); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if car.Position == nil || *car.Position.Longitude != lng {
+		t.Fatalf("expected last known position, got %v", car.Position)
+	}
+}
+
+func TestGetCarCategories(t *testing.T) {
+	resp := sendGetRequest(testServer.URL+"/car/category", t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var categories []types.CarCategory
+	if err := json.Unmarshal(body, &categories); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(categories) != 5 {
+		t.Fatalf("expected 5 categories, got %v", len(categories))
+	}
+}
+
+func TestCarVIN(t *testing.T) {
+	resp := sendGetRequest(testServer.URL+"/car/vin?vin=5YJ3E1EA2KF317000", t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var info types.VINInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if info.Manufacturer != "Tesla" || info.Year != 2019 {
+		t.Errorf("unexpected decoded vin: %+v", info)
+	}
+
+	resp = sendGetRequest(testServer.URL+"/car/vin?vin=5YJ3E1EA3KF317000", t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	// cars are created only with valid vin
+	payload := &types.CreateCarPayload{
+		Make:           "Tesla",
+		Model:          "Model 3",
+		Year:           2019,
+		Color:          "White",
+		RegistrationNo: utils.GenerateUniqueString(""),
+		PricePerDay:    120,
+		CompanyID:      1,
+		VIN:            "5YJ3E1EA3KF317000",
+		Documents:      &types.CarDocumentsPayload{InsuranceExpiry: "2030-01-01"},
+	}
+	resp = sendPostRequest(testServer.URL+"/car", payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	payload.Documents.InsuranceExpiry = "01.01.2030"
+	payload.VIN = "5YJ3E1EA2KF317000"
+	resp = sendPostRequest(testServer.URL+"/car", payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+}
+
+func TestCarMaintenance(t *testing.T) {
+	url := testServer.URL + "/car/1/maintenance"
+
+	resp := sendPostRequest(url, &types.CreateMaintenancePayload{Type: "car wash", StartDate: "2032-01-01", EndDate: "2032-01-02"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	// maintenance is managed by the company renting the car
+	resp = sendPostRequest(url, &types.CreateMaintenancePayload{Type: "service", StartDate: "2032-01-01", EndDate: "2032-01-02"}, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendPostRequest(url, &types.CreateMaintenancePayload{Type: "service", StartDate: "2032-01-02", EndDate: "2032-01-03"}, t)
+	checkResponse(resp, http.StatusConflict, t)
+}
+
+func TestCarTransfer(t *testing.T) {
+	url := testServer.URL + "/car/1/transfer"
+
+	resp := sendPostRequest(url, &types.TransferCarPayload{}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendPostRequest(url+"/accept", nil, t)
+	checkResponse(resp, http.StatusNotFound, t)
+}
+
 func TestDeleteCar(t *testing.T) {
 	url := testServer.URL + "/car/1"
 
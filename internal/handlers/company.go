@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"path"
 	"strconv"
 
 	"github.com/mwdev22/CarRental/internal/services"
@@ -25,8 +28,51 @@ func NewCompanyHandler(mux *http.ServeMux, company *services.CompanyService, log
 
 	h.mux.HandleFunc("POST /company", roleMiddleware(h.handleCreateCompany, types.UserTypeCompanyOwner, logger))
 	h.mux.HandleFunc("GET /company/{id}", roleMiddleware(h.handleGetCompanyByID, types.UserTypeCompanyOwner, logger))
-	h.mux.HandleFunc("PUT /company/{id}", roleMiddleware(h.handleUpdateCompany, types.UserTypeCompanyOwner, logger))
-	h.mux.HandleFunc("DELETE /company/{id}", roleMiddleware(h.handleDeleteCompany, types.UserTypeCompanyOwner, logger))
+	h.mux.HandleFunc("PUT /company/{id}", authMiddleware(h.handleUpdateCompany, logger))
+	h.mux.HandleFunc("DELETE /company/{id}", authMiddleware(h.handleDeleteCompany, logger))
+
+	// driver requirements, GET /company/{id}/rules?car_id=1 for rules of a single car
+	h.mux.HandleFunc("GET /company/{id}/rules", makeHandler(h.handleGetRentalRules, logger))
+	h.mux.HandleFunc("PUT /company/{id}/rules", authMiddleware(h.handleSetRentalRules, logger))
+
+	// daily prices adjusted by fleet utilisation and lead time
+	h.mux.HandleFunc("GET /company/{id}/pricing", authMiddleware(h.handleGetDynamicPricing, logger))
+	h.mux.HandleFunc("PUT /company/{id}/pricing", authMiddleware(h.handleSetDynamicPricing, logger))
+
+	// pickup and return places with opening hours, bookings at a branch are checked against them
+	h.mux.HandleFunc("GET /company/{id}/branches", makeHandler(h.handleGetBranches, logger))
+	h.mux.HandleFunc("POST /company/{id}/branches", authMiddleware(h.handleCreateBranch, logger))
+	h.mux.HandleFunc("GET /company/{id}/branches/{branchId}", makeHandler(h.handleGetBranch, logger))
+	h.mux.HandleFunc("PUT /company/{id}/branches/{branchId}", authMiddleware(h.handleUpdateBranch, logger))
+	h.mux.HandleFunc("DELETE /company/{id}/branches/{branchId}", authMiddleware(h.handleDeleteBranch, logger))
+
+	// staff with roles, invited by email and joining once they accept with the account of that email,
+	// owners and managers manage the members, members can leave on their own
+	h.mux.HandleFunc("GET /company/{id}/members", authMiddleware(h.handleGetMembers, logger))
+	h.mux.HandleFunc("POST /company/{id}/members", authMiddleware(h.handleInviteMember, logger))
+	h.mux.HandleFunc("POST /company/{id}/members/accept", authMiddleware(h.handleAcceptMemberInvitation, logger))
+	h.mux.HandleFunc("DELETE /company/{id}/members/{memberId}", authMiddleware(h.handleRemoveMember, logger))
+
+	// new companies are pending until an admin verifies their business documents,
+	// only cars of verified companies are listed and can be booked, documents are uploaded
+	// as multipart form with the file in "document" field and its description in "name" field
+	h.mux.HandleFunc("GET /company/{id}/documents", authMiddleware(h.handleGetCompanyDocuments, logger))
+	h.mux.HandleFunc("POST /company/{id}/documents", authMiddleware(h.handleUploadCompanyDocument, logger))
+	h.mux.HandleFunc("GET /company/{id}/documents/{documentId}", authMiddleware(h.handleDownloadCompanyDocument, logger))
+	h.mux.HandleFunc("DELETE /company/{id}/documents/{documentId}", authMiddleware(h.handleDeleteCompanyDocument, logger))
+	// verification queue, admins only
+	h.mux.HandleFunc("GET /company/pending", roleMiddleware(h.handleGetPendingCompanies, types.UserTypeAdmin, logger))
+	h.mux.HandleFunc("POST /company/{id}/verify", roleMiddleware(h.handleVerifyCompany, types.UserTypeAdmin, logger))
+
+	// renters review completed bookings, hidden reviews aren't listed and don't count to the ratings
+	h.mux.HandleFunc("POST /booking/{id}/review", authMiddleware(h.handleCreateReview, logger))
+	h.mux.HandleFunc("GET /car/{id}/reviews", makeHandler(h.handleGetCarReviews, logger))
+	h.mux.HandleFunc("GET /company/{id}/reviews", makeHandler(h.handleGetCompanyReviews, logger))
+	h.mux.HandleFunc("POST /review/{id}/reply", authMiddleware(h.handleReplyToReview, logger))
+	h.mux.HandleFunc("POST /review/{id}/report", authMiddleware(h.handleReportReview, logger))
+	// moderation queue, admins only
+	h.mux.HandleFunc("GET /review/reported", roleMiddleware(h.handleGetReportedReviews, types.UserTypeAdmin, logger))
+	h.mux.HandleFunc("POST /review/{id}/moderate", roleMiddleware(h.handleModerateReview, types.UserTypeAdmin, logger))
 
 	// check for allowed operators in utils/handlers.go
 	// for example: get the first 10 companies with name ends with "company" and
@@ -72,7 +118,7 @@ func (h *CompanyHandler) handleCreateCompany(w http.ResponseWriter, r *http.Requ
 	}
 
 	return types.WriteJSON(w, http.StatusOK, map[string]string{
-		"message": "company created successfully!",
+		"message": "company created successfully! upload business documents to get it verified before its cars are listed",
 	})
 }
 
@@ -137,7 +183,7 @@ func (h *CompanyHandler) handleUpdateCompany(w http.ResponseWriter, r *http.Requ
 }
 
 // @Summary Delete a company
-// @Description Deletes a company by ID
+// @Description Deletes a company by ID, companies whose cars have bookings cannot be deleted and get 409
 // @Param id path int true "Company ID"
 // @Param Authorization header string true "Bearer Token"
 // @Tags Company
@@ -170,16 +216,18 @@ func (h *CompanyHandler) handleDeleteCompany(w http.ResponseWriter, r *http.Requ
 // @Param Authorization header string true "Bearer Token"
 // @Param filters query object false "Query filters"
 // @Param options query object false "Query options"
+// @Param near query string false "only companies located within radius_km of the point, sorted by distance, eg. 52.2297,21.0122"
+// @Param radius_km query number false "search radius for near, 10 km by default"
 // @Tags Company
 // @Success 200 {array} types.Company
 // @Router /companies [get]
 func (h *CompanyHandler) handleGetCopmanies(w http.ResponseWriter, r *http.Request) error {
-	filters, err := utils.ParseQueryFilters(r)
+	filters, err := utils.ParseQueryFilters(r, types.CompanyQueryFields)
 	if err != nil {
 		return err
 	}
 
-	opts, err := utils.ParseQueryOptions(r)
+	opts, err := utils.ParseQueryOptions(r, types.CompanyQueryFields)
 	if err != nil {
 		return err
 	}
@@ -191,3 +239,829 @@ func (h *CompanyHandler) handleGetCopmanies(w http.ResponseWriter, r *http.Reque
 
 	return types.WriteJSON(w, http.StatusOK, companies)
 }
+
+// @Summary Get rental rules
+// @Description Retrieves driver requirements of the company or a single car
+// @Produce json
+// @Param id path int true "Company ID"
+// @Param car_id query int false "Car ID"
+// @Tags Company
+// @Success 200 {object} types.RentalRules
+// @Router /company/{id}/rules [get]
+func (h *CompanyHandler) handleGetRentalRules(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var carId *int
+	if carIdStr := r.URL.Query().Get("car_id"); carIdStr != "" {
+		carIdInt, err := strconv.Atoi(carIdStr)
+		if err != nil {
+			return types.BadQueryParameter("car_id")
+		}
+		carId = &carIdInt
+	}
+
+	rules, err := h.company.GetRentalRules(companyId, carId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, rules)
+}
+
+// @Summary Set rental rules
+// @Description Sets driver requirements for the whole company or a single car of the company when car_id is provided
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param payload body types.RentalRulesPayload true "Rental rules"
+// @Tags Company
+// @Success 200 {object} map[string]string
+// @Router /company/{id}/rules [put]
+func (h *CompanyHandler) handleSetRentalRules(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.RentalRulesPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.company.SetRentalRules(companyId, userId, &payload); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "rental rules updated successfully!",
+	})
+}
+
+// @Summary Get dynamic pricing
+// @Description Retrieves dynamic pricing settings of the company
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Tags Company
+// @Success 200 {object} types.DynamicPricing
+// @Router /company/{id}/pricing [get]
+func (h *CompanyHandler) handleGetDynamicPricing(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	pricing, err := h.company.GetDynamicPricing(companyId, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, pricing)
+}
+
+// @Summary Set dynamic pricing
+// @Description Sets how daily prices of company cars follow fleet utilisation and lead time, floor and ceiling are multiples of the car price
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param payload body types.DynamicPricingPayload true "Dynamic pricing"
+// @Tags Company
+// @Success 200 {object} map[string]string
+// @Router /company/{id}/pricing [put]
+func (h *CompanyHandler) handleSetDynamicPricing(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.DynamicPricingPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.company.SetDynamicPricing(companyId, userId, &payload); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "dynamic pricing updated successfully!",
+	})
+}
+
+func branchPathValues(r *http.Request) (int, int, error) {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, 0, types.BadPathParameter("id")
+	}
+	branchId, err := strconv.Atoi(r.PathValue("branchId"))
+	if err != nil {
+		return 0, 0, types.BadPathParameter("branchId")
+	}
+	return companyId, branchId, nil
+}
+
+// @Summary Get company branches
+// @Description Branches of the company with opening hours and closures
+// @Produce json
+// @Param id path int true "Company ID"
+// @Tags Company
+// @Success 200 {array} types.Branch
+// @Router /company/{id}/branches [get]
+func (h *CompanyHandler) handleGetBranches(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	branches, err := h.company.GetBranches(companyId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, branches)
+}
+
+// @Summary Create a branch
+// @Description Adds a branch to the company, opening hours are in the branch time zone, days without hours are closed
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param payload body types.BranchPayload true "Branch"
+// @Tags Company
+// @Success 201 {object} types.Branch
+// @Router /company/{id}/branches [post]
+func (h *CompanyHandler) handleCreateBranch(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.BranchPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	branch, err := h.company.CreateBranch(companyId, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusCreated, branch)
+}
+
+// @Summary Get a branch
+// @Produce json
+// @Param id path int true "Company ID"
+// @Param branchId path int true "Branch ID"
+// @Tags Company
+// @Success 200 {object} types.Branch
+// @Router /company/{id}/branches/{branchId} [get]
+func (h *CompanyHandler) handleGetBranch(w http.ResponseWriter, r *http.Request) error {
+	companyId, branchId, err := branchPathValues(r)
+	if err != nil {
+		return err
+	}
+
+	branch, err := h.company.GetBranch(companyId, branchId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, branch)
+}
+
+// @Summary Update a branch
+// @Description Replaces the branch details including all opening hours and closures
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param branchId path int true "Branch ID"
+// @Param payload body types.BranchPayload true "Branch"
+// @Tags Company
+// @Success 200 {object} types.Branch
+// @Router /company/{id}/branches/{branchId} [put]
+func (h *CompanyHandler) handleUpdateBranch(w http.ResponseWriter, r *http.Request) error {
+	companyId, branchId, err := branchPathValues(r)
+	if err != nil {
+		return err
+	}
+
+	var payload types.BranchPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	branch, err := h.company.UpdateBranch(companyId, branchId, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, branch)
+}
+
+// @Summary Delete a branch
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param branchId path int true "Branch ID"
+// @Tags Company
+// @Success 200 {object} map[string]string
+// @Router /company/{id}/branches/{branchId} [delete]
+func (h *CompanyHandler) handleDeleteBranch(w http.ResponseWriter, r *http.Request) error {
+	companyId, branchId, err := branchPathValues(r)
+	if err != nil {
+		return err
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.company.DeleteBranch(companyId, branchId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("branch %d deleted", branchId)})
+}
+
+// @Summary Get company members
+// @Description Active members and pending invitations of the company
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Tags Company
+// @Success 200 {array} types.CompanyMember
+// @Router /company/{id}/members [get]
+func (h *CompanyHandler) handleGetMembers(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	members, err := h.company.GetMembers(companyId, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, members)
+}
+
+// @Summary Invite a company member
+// @Description Sends the invitation by email, only owners can invite owners and managers
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param payload body types.InviteMemberPayload true "Invitation"
+// @Tags Company
+// @Success 201 {object} types.CompanyMember
+// @Router /company/{id}/members [post]
+func (h *CompanyHandler) handleInviteMember(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.InviteMemberPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	member, err := h.company.InviteMember(companyId, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusCreated, member)
+}
+
+// @Summary Accept company invitation
+// @Description Joins the company with the role of the invitation sent to the user's email
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Tags Company
+// @Success 200 {object} types.CompanyMember
+// @Router /company/{id}/members/accept [post]
+func (h *CompanyHandler) handleAcceptMemberInvitation(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	member, err := h.company.AcceptInvitation(companyId, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, member)
+}
+
+// @Summary Remove a company member
+// @Description Removes the member or withdraws the invitation, the last owner cannot be removed
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param memberId path int true "Member ID"
+// @Tags Company
+// @Success 200 {object} map[string]string
+// @Router /company/{id}/members/{memberId} [delete]
+func (h *CompanyHandler) handleRemoveMember(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	memberId, err := strconv.Atoi(r.PathValue("memberId"))
+	if err != nil {
+		return types.BadPathParameter("memberId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.company.RemoveMember(companyId, memberId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("member %d removed", memberId)})
+}
+
+// @Summary Get company documents
+// @Description Business documents uploaded for the verification, visible to the company managers and admins
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Tags Company
+// @Success 200 {array} types.CompanyDocument
+// @Router /company/{id}/documents [get]
+func (h *CompanyHandler) handleGetCompanyDocuments(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	documents, err := h.company.GetDocuments(companyId, userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, documents)
+}
+
+// @Summary Upload company document
+// @Description Adds pdf, jpeg or png business document up to 10MB, rejected companies go back to the verification queue
+// @Accept multipart/form-data
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param name formData string true "What the document is, e.g. business registration"
+// @Param document formData file true "Document"
+// @Tags Company
+// @Success 201 {object} types.CompanyDocument
+// @Router /company/{id}/documents [post]
+func (h *CompanyHandler) handleUploadCompanyDocument(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	// some room for the rest of the form
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxDocumentSize+1<<20)
+	if err := r.ParseMultipartForm(services.MaxDocumentSize); err != nil {
+		return types.BadRequest("invalid form or document too large")
+	}
+
+	file, _, err := r.FormFile("document")
+	if err != nil {
+		return types.BadRequest("missing document file")
+	}
+	defer file.Close()
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	document, err := h.company.AddDocument(companyId, userId, r.FormValue("name"), file)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusCreated, document)
+}
+
+// @Summary Download company document
+// @Description Content of the business document, documents are private to the company managers and admins
+// @Produce application/pdf,image/jpeg,image/png
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param documentId path int true "Document ID"
+// @Tags Company
+// @Success 200 {file} file
+// @Router /company/{id}/documents/{documentId} [get]
+func (h *CompanyHandler) handleDownloadCompanyDocument(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	documentId, err := strconv.Atoi(r.PathValue("documentId"))
+	if err != nil {
+		return types.BadPathParameter("documentId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	document, file, err := h.company.OpenDocument(companyId, documentId, userId)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", document.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(document.Key)))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	_, err = io.Copy(w, file)
+	return err
+}
+
+// @Summary Delete company document
+// @Description Removes the document and its file, documents of verified companies are kept
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param documentId path int true "Document ID"
+// @Tags Company
+// @Success 200 {object} map[string]string
+// @Router /company/{id}/documents/{documentId} [delete]
+func (h *CompanyHandler) handleDeleteCompanyDocument(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+	documentId, err := strconv.Atoi(r.PathValue("documentId"))
+	if err != nil {
+		return types.BadPathParameter("documentId")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.company.DeleteDocument(companyId, documentId, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("document %d deleted", documentId)})
+}
+
+// @Summary Get companies awaiting verification
+// @Description Verification queue of pending companies that uploaded documents, oldest first, admins only
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Tags Company
+// @Success 200 {array} types.Company
+// @Router /company/pending [get]
+func (h *CompanyHandler) handleGetPendingCompanies(w http.ResponseWriter, r *http.Request) error {
+	companies, err := h.company.GetAwaitingVerification()
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, companies)
+}
+
+// @Summary Verify a company
+// @Description Admin verifies the pending company or rejects it with a reason, the company is notified by email
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Company ID"
+// @Param payload body types.VerifyCompanyPayload true "Decision"
+// @Tags Company
+// @Success 200 {object} types.Company
+// @Router /company/{id}/verify [post]
+func (h *CompanyHandler) handleVerifyCompany(w http.ResponseWriter, r *http.Request) error {
+	companyId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.VerifyCompanyPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	company, err := h.company.Verify(companyId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, company)
+}
+
+// @Summary Review a booking
+// @Description Renter rates the car and the company after the booking is completed, once per booking
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Booking ID"
+// @Param review body types.CreateReviewPayload true "Review"
+// @Tags Company
+// @Success 201 {object} types.Review
+// @Router /booking/{id}/review [post]
+func (h *CompanyHandler) handleCreateReview(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.CreateReviewPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	review, err := h.company.CreateReview(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusCreated, review)
+}
+
+// @Summary Get car reviews
+// @Description Reviews of the car, sortable by id, car_rating, company_rating and created
+// @Produce json
+// @Param id path int true "Car ID"
+// @Param sort query string false "sort for review retrieval, eg. created-desc"
+// @Param page query int false "page number for review retrieval"
+// @Param page_size query int false "number of items per page"
+// @Tags Company
+// @Success 200 {array} types.Review
+// @Router /car/{id}/reviews [get]
+func (h *CompanyHandler) handleGetCarReviews(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	opts, err := utils.ParseQueryOptions(r, types.ReviewQueryFields)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := h.company.GetCarReviews(idInt, opts)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, reviews)
+}
+
+// @Summary Get company reviews
+// @Description Reviews of all cars rented by the company, sortable by id, car_rating, company_rating and created
+// @Produce json
+// @Param id path int true "Company ID"
+// @Param sort query string false "sort for review retrieval, eg. created-desc"
+// @Param page query int false "page number for review retrieval"
+// @Param page_size query int false "number of items per page"
+// @Tags Company
+// @Success 200 {array} types.Review
+// @Router /company/{id}/reviews [get]
+func (h *CompanyHandler) handleGetCompanyReviews(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	opts, err := utils.ParseQueryOptions(r, types.ReviewQueryFields)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := h.company.GetCompanyReviews(idInt, opts)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, reviews)
+}
+
+// @Summary Reply to a review
+// @Description Owner of the reviewed company answers the review, sending it again replaces the reply
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Param reply body types.ReviewReplyPayload true "Reply"
+// @Tags Company
+// @Success 200 {object} types.Review
+// @Router /review/{id}/reply [post]
+func (h *CompanyHandler) handleReplyToReview(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.ReviewReplyPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	review, err := h.company.ReplyToReview(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, review)
+}
+
+// @Summary Report a review
+// @Description Sends the review to the admin moderation queue, it stays visible until moderated
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Param report body types.ReportReviewPayload true "Reason"
+// @Tags Company
+// @Success 200 {object} map[string]string
+// @Router /review/{id}/report [post]
+func (h *CompanyHandler) handleReportReview(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.ReportReviewPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	if err := h.company.ReportReview(idInt, &payload); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("review %d reported", idInt)})
+}
+
+// @Summary Get reported reviews
+// @Description Moderation queue of reported reviews, admins only
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param sort query string false "sort for review retrieval, eg. created-asc"
+// @Param page query int false "page number for review retrieval"
+// @Param page_size query int false "number of items per page"
+// @Tags Company
+// @Success 200 {array} types.Review
+// @Router /review/reported [get]
+func (h *CompanyHandler) handleGetReportedReviews(w http.ResponseWriter, r *http.Request) error {
+	opts, err := utils.ParseQueryOptions(r, types.ReviewQueryFields)
+	if err != nil {
+		return err
+	}
+
+	reviews, err := h.company.GetReportedReviews(opts)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, reviews)
+}
+
+// @Summary Moderate a review
+// @Description Admin keeps the review published or hides it, hidden reviews don't count to the ratings
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Review ID"
+// @Param status body types.ModerateReviewPayload true "Status"
+// @Tags Company
+// @Success 200 {object} types.Review
+// @Router /review/{id}/moderate [post]
+func (h *CompanyHandler) handleModerateReview(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.ModerateReviewPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	review, err := h.company.ModerateReview(idInt, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, review)
+}
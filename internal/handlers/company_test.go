@@ -26,10 +26,40 @@ func generateCompanies(count int, t *testing.T) ([]byte, []*types.CreateCompanyP
 		resp := sendPostRequest(url, payload, t)
 		body = checkResponse(resp, http.StatusOK, t)
 		payloads = append(payloads, payload)
+
+		verifyCompany(payload.Name, t)
 	}
 	return body, payloads
 }
 
+// helper function to find the company by name, needed because creating doesn't return its id
+func findCompany(name string, t *testing.T) *types.Company {
+	resp := sendGetRequest(testServer.URL+"/company/batch?name[eq]="+name, t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var companies []types.Company
+	if err := json.Unmarshal(body, &companies); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(companies) != 1 {
+		t.Fatalf("expected company %s, got %+v", name, companies)
+	}
+	return &companies[0]
+}
+
+// helper function to upload a document of the company and verify it as admin,
+// so its cars are listed and can be booked
+func verifyCompany(name string, t *testing.T) {
+	company := findCompany(name, t)
+
+	url := fmt.Sprintf("%s/company/%d", testServer.URL, company.ID)
+	resp := sendFileRequest(url+"/documents", "document", "registration.pdf", []byte("%PDF-1.4"), map[string]string{"name": "registration"}, t)
+	checkResponse(resp, http.StatusCreated, t)
+
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusVerified}, t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
 func TestCreateCompany(t *testing.T) {
 
 	body, _ := generateCompanies(1, t)
@@ -88,15 +118,270 @@ func TestUpdateCompany(t *testing.T) {
 	}
 }
 
+func TestDynamicPricing(t *testing.T) {
+	url := testServer.URL + "/company/1/pricing"
+	resp := sendGetRequest(url, t)
+	checkResponse(resp, http.StatusNotFound, t)
+
+	// floor can't be above the car price
+	resp = sendPutRequest(url, &types.DynamicPricingPayload{Floor: 1.2, Ceiling: 1.5}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	payload := &types.DynamicPricingPayload{TargetUtilisation: 0.7, UtilisationFactor: 0.5, LastMinuteDays: 3, LastMinuteFactor: 0.1, Floor: 0.8, Ceiling: 1.5}
+	resp = sendPutRequest(url, payload, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendGetRequest(url, t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var pricing types.DynamicPricing
+	if err := json.Unmarshal(body, &pricing); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if pricing.Enabled || pricing.Floor != payload.Floor || pricing.LastMinuteDays != payload.LastMinuteDays {
+		t.Errorf("expected saved dynamic pricing %+v, got %+v", payload, pricing)
+	}
+}
+
+func TestCompanyBranches(t *testing.T) {
+	url := testServer.URL + "/company/1/branches"
+
+	payload := &types.BranchPayload{Name: "centre", Address: "main street 1", TimeZone: "Mars/Olympus"}
+	resp := sendPostRequest(url, payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	payload.TimeZone = "Europe/Warsaw"
+	payload.OpeningHours = []*types.OpeningHoursPayload{{Weekday: 1, Opens: "18:00", Closes: "08:00"}}
+	resp = sendPostRequest(url, payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	payload.OpeningHours[0].Opens, payload.OpeningHours[0].Closes = "08:00", "18:00"
+	resp = sendPostRequest(url, payload, t)
+	body := checkResponse(resp, http.StatusCreated, t)
+
+	var branch types.Branch
+	if err := json.Unmarshal(body, &branch); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+
+	resp = sendGetRequest(fmt.Sprintf("%s/%d", url, branch.ID), t)
+	body = checkResponse(resp, http.StatusOK, t)
+	if err := json.Unmarshal(body, &branch); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(branch.OpeningHours) != 1 || branch.OpeningHours[0].Opens != "08:00" {
+		t.Errorf("expected saved opening hours, got %+v", branch.OpeningHours)
+	}
+
+	resp = sendGetRequest(url, t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
+func TestCompanyMembers(t *testing.T) {
+	url := testServer.URL + "/company/1/members"
+
+	resp := sendPostRequest(url, &types.InviteMemberPayload{Email: "mechanic@blabla.com", Role: "driver"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	email := utils.GenerateUniqueString("mechanic") + "@blabla.com"
+	resp = sendPostRequest(url, &types.InviteMemberPayload{Email: email, Role: types.CompanyRoleMechanic}, t)
+	body := checkResponse(resp, http.StatusCreated, t)
+
+	var invited types.CompanyMember
+	if err := json.Unmarshal(body, &invited); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if invited.Status != types.CompanyMemberInvited {
+		t.Errorf("expected invited member, got %+v", invited)
+	}
+
+	resp = sendPostRequest(url, &types.InviteMemberPayload{Email: email, Role: types.CompanyRoleManager}, t)
+	checkResponse(resp, http.StatusConflict, t)
+
+	// the mechanic joins with his own account and gets only maintenance access
+	ownerHeader := authHeader
+	authHeader = registerUser(email, types.UserTypeUser, t)
+
+	resp = sendGetRequest(testServer.URL+"/company/1/service-due", t)
+	checkResponse(resp, http.StatusUnauthorized, t)
+
+	resp = sendPostRequest(url+"/accept", nil, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendGetRequest(testServer.URL+"/company/1/service-due", t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendPutRequest(testServer.URL+"/company/1/rules", &types.RentalRulesPayload{MinAge: 21}, t)
+	checkResponse(resp, http.StatusUnauthorized, t)
+
+	resp = sendGetRequest(url, t)
+	checkResponse(resp, http.StatusUnauthorized, t)
+
+	authHeader = ownerHeader
+
+	resp = sendGetRequest(url, t)
+	body = checkResponse(resp, http.StatusOK, t)
+
+	var members []types.CompanyMember
+	if err := json.Unmarshal(body, &members); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(members) != 2 || members[1].Status != types.CompanyMemberActive {
+		t.Fatalf("expected the owner and the active mechanic, got %+v", members)
+	}
+
+	// company can't be left without an owner
+	resp = sendDeleteRequest(fmt.Sprintf("%s/%d", url, members[0].ID), t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendDeleteRequest(fmt.Sprintf("%s/%d", url, members[1].ID), t)
+	checkResponse(resp, http.StatusOK, t)
+}
+
+func TestCompanyVerification(t *testing.T) {
+	listedCars := func(companyId int) []types.Car {
+		resp := sendGetRequest(fmt.Sprintf("%s/car/batch?company_id[eq]=%d", testServer.URL, companyId), t)
+		body := checkResponse(resp, http.StatusOK, t)
+
+		var cars []types.Car
+		if err := json.Unmarshal(body, &cars); err != nil {
+			t.Fatalf("failed to parse response body: %v", err)
+		}
+		return cars
+	}
+
+	// new company of another owner waits for the admin
+	adminHeader := authHeader
+	ownerHeader := registerUser(utils.GenerateUniqueString("owner")+"@blabla.com", types.UserTypeCompanyOwner, t)
+	authHeader = ownerHeader
+
+	payload := &types.CreateCompanyPayload{
+		Name:    utils.GenerateUniqueString("pending"),
+		Email:   utils.GenerateUniqueString("pending_email"),
+		Phone:   utils.GenerateUniqueString("48"),
+		Address: utils.GenerateUniqueString("pending_address"),
+	}
+	checkResponse(sendPostRequest(testServer.URL+"/company", payload, t), http.StatusOK, t)
+	company := findCompany(payload.Name, t)
+	if company.Status != types.CompanyStatusPending {
+		t.Errorf("expected pending company, got %s", company.Status)
+	}
+	url := fmt.Sprintf("%s/company/%d", testServer.URL, company.ID)
+
+	car := &types.CreateCarPayload{Make: "Fiat", Model: "Panda", Year: 2021, Color: "Red", RegistrationNo: utils.GenerateUniqueString(""), PricePerDay: 40, CompanyID: company.ID}
+	checkResponse(sendPostRequest(testServer.URL+"/car", car, t), http.StatusOK, t)
+	if cars := listedCars(company.ID); len(cars) != 0 {
+		t.Errorf("expected no listed cars of pending company, got %d", len(cars))
+	}
+
+	resp := sendFileRequest(url+"/documents", "document", "notes.txt", []byte("not a document"), map[string]string{"name": "registration"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+	resp = sendFileRequest(url+"/documents", "document", "registration.pdf", []byte("%PDF-1.4"), map[string]string{"name": "registration"}, t)
+	body := checkResponse(resp, http.StatusCreated, t)
+	var document types.CompanyDocument
+	if err := json.Unmarshal(body, &document); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+
+	// documents are downloaded only by the company and admins
+	resp = sendGetRequest(testServer.URL+document.URL, t)
+	body = checkResponse(resp, http.StatusOK, t)
+	if string(body) != "%PDF-1.4" || resp.Header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("expected the uploaded pdf, got %s %q", resp.Header.Get("Content-Type"), body)
+	}
+	authHeader = registerUser(utils.GenerateUniqueString("renter")+"@blabla.com", types.UserTypeUser, t)
+	resp = sendGetRequest(testServer.URL+document.URL, t)
+	checkResponse(resp, http.StatusUnauthorized, t)
+	authHeader = ownerHeader
+
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusVerified}, t)
+	checkResponse(resp, http.StatusForbidden, t)
+
+	authHeader = adminHeader
+
+	resp = sendGetRequest(testServer.URL+"/company/pending", t)
+	body = checkResponse(resp, http.StatusOK, t)
+	var pending []types.Company
+	if err := json.Unmarshal(body, &pending); err != nil {
+		t.Fatalf("failed to parse response body: %v", err)
+	}
+	if len(pending) != 1 || pending[0].ID != company.ID {
+		t.Errorf("expected company %d awaiting verification, got %+v", company.ID, pending)
+	}
+
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusRejected}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusRejected, Reason: "registration is unreadable"}, t)
+	checkResponse(resp, http.StatusOK, t)
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusVerified}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	// another document sends it back to the queue
+	authHeader = ownerHeader
+	resp = sendFileRequest(url+"/documents", "document", "registration.pdf", []byte("%PDF-1.4 readable"), map[string]string{"name": "registration"}, t)
+	checkResponse(resp, http.StatusCreated, t)
+	authHeader = adminHeader
+
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusVerified}, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	cars := listedCars(company.ID)
+	if len(cars) != 1 {
+		t.Fatalf("expected the car of verified company to be listed, got %d cars", len(cars))
+	}
+	resp = sendPostRequest(testServer.URL+"/booking", &types.CreateBookingPayload{CarID: cars[0].ID, StartDate: "2021-02-01", EndDate: "2021-02-02"}, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	// rejecting verified company hides its cars again
+	resp = sendPostRequest(url+"/verify", &types.VerifyCompanyPayload{Status: types.CompanyStatusRejected, Reason: "fake registration"}, t)
+	checkResponse(resp, http.StatusOK, t)
+	if cars := listedCars(company.ID); len(cars) != 0 {
+		t.Errorf("expected no listed cars of rejected company, got %d", len(cars))
+	}
+	resp = sendPostRequest(testServer.URL+"/booking", &types.CreateBookingPayload{CarID: cars[0].ID, StartDate: "2021-03-01", EndDate: "2021-03-02"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+}
+
+func TestReviews(t *testing.T) {
+	resp := sendPostRequest(testServer.URL+"/booking/1/review", &types.CreateReviewPayload{CarRating: 6, CompanyRating: 5}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendPostRequest(testServer.URL+"/booking/999/review", &types.CreateReviewPayload{CarRating: 5, CompanyRating: 5}, t)
+	checkResponse(resp, http.StatusNotFound, t)
+
+	resp = sendGetRequest(testServer.URL+"/car/1/reviews?sort=car_rating-desc", t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendGetRequest(testServer.URL+"/review/reported", t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendPostRequest(testServer.URL+"/review/1/moderate", &types.ModerateReviewPayload{Status: types.ReviewStatusReported}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+}
+
 func TestDeleteCompany(t *testing.T) {
+	// cars of the first company were booked, the bookings keep them
 	url := testServer.URL + "/company/1"
 	resp := sendDeleteRequest(url, t)
+	checkResponse(resp, http.StatusConflict, t)
+	resp = sendGetRequest(url, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	payload := &types.CreateCompanyPayload{
+		Name:    utils.GenerateUniqueString("deleted"),
+		Email:   utils.GenerateUniqueString("deleted_email"),
+		Phone:   utils.GenerateUniqueString("48"),
+		Address: utils.GenerateUniqueString("deleted_address"),
+	}
+	checkResponse(sendPostRequest(testServer.URL+"/company", payload, t), http.StatusOK, t)
+	url = fmt.Sprintf("%s/company/%d", testServer.URL, findCompany(payload.Name, t).ID)
+
+	resp = sendDeleteRequest(url, t)
 	checkResponse(resp, http.StatusOK, t)
 
 	// try to retrieve the company to validate if it was deleted
 	resp = sendGetRequest(url, t)
 	checkResponse(resp, http.StatusNotFound, t)
-
 }
 
 func TestGetCompanies(t *testing.T) {
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
 	"net/http"
@@ -108,6 +109,35 @@ func roleMiddleware(h apiFunc, role types.UserRole, logger *log.Logger) http.Han
 	}
 }
 
+// device gateways authenticate with the shared key instead of user tokens
+func gatewayMiddleware(h apiFunc, logger *log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := []byte(r.Header.Get("X-Gateway-Key"))
+		if len(config.TelemetryKey) == 0 || subtle.ConstantTimeCompare(key, config.TelemetryKey) != 1 {
+			types.WriteJSON(w, http.StatusUnauthorized, map[string]string{
+				"error": "invalid gateway key",
+			})
+			return
+		}
+
+		makeHandler(h, logger)(w, r)
+	}
+}
+
+// for public routes that show more to privileged users, admin has every role
+func hasRole(r *http.Request, role types.UserRole) bool {
+	claims, err := parseToken(r.Header.Get("Authorization"))
+	if err != nil {
+		return false
+	}
+	roleFloat, ok := claims["role"].(float64)
+	if !ok {
+		return false
+	}
+	tokenRole := types.UserRole(int(roleFloat))
+	return tokenRole == role || tokenRole == types.UserTypeAdmin
+}
+
 func parseToken(authHeader string) (jwt.MapClaims, error) {
 	if authHeader == "" {
 		return nil, fmt.Errorf("missing Authorization header")
@@ -6,17 +6,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"testing"
 
 	"github.com/mwdev22/CarRental/internal/config"
+	"github.com/mwdev22/CarRental/internal/notify"
 	"github.com/mwdev22/CarRental/internal/services"
-	"github.com/mwdev22/CarRental/internal/store"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store/mock"
+	"github.com/mwdev22/CarRental/internal/types"
 	"github.com/mwdev22/CarRental/internal/utils"
-	"github.com/redis/go-redis/v9"
 )
 
 var (
@@ -24,6 +26,8 @@ var (
 	authHeader   string
 	testUsername = utils.GenerateUniqueString("testuser2")
 	testPassword = "testpassword"
+	uploadDir    string
+	documentDir  string
 )
 
 func TestMain(m *testing.M) {
@@ -38,6 +42,8 @@ func TestMain(m *testing.M) {
 	code := m.Run()
 
 	os.Remove("./test.db")
+	os.RemoveAll(uploadDir)
+	os.RemoveAll(documentDir)
 
 	os.Exit(code)
 }
@@ -46,29 +52,34 @@ func initializeTests() (*httptest.Server, error) {
 
 	// stores and services
 	userStore := mock.NewUserRepository()
-	userService := services.NewUserService(userStore)
 
-	companyStore := mock.NewCompanyRepository()
-	companyService := services.NewCompanyService(companyStore)
+	var err error
+	uploadDir, err = os.MkdirTemp("", "uploads")
+	if err != nil {
+		return nil, err
+	}
+	files := storage.NewLocalStorage(uploadDir, "/uploads/")
+	documentDir, err = os.MkdirTemp("", "documents")
+	if err != nil {
+		return nil, err
+	}
 
-	carStore := mock.NewCarRepository()
-	carService := services.NewCarService(carStore)
+	companyStore := mock.NewCompanyRepository()
+	carStore := mock.NewCarRepository(companyStore)
+	bookingStore := mock.NewBookingStore(carStore, companyStore)
+	userService := services.NewUserService(userStore, carStore, companyStore, notify.NewLogNotifier(log.Default()))
+	companyService := services.NewCompanyService(companyStore, carStore, bookingStore, userStore, notify.NewLogNotifier(log.Default()), storage.NewLocalStorage(documentDir, ""))
 
-	bookingStore := mock.NewBookingStore()
-	bookingService := services.NewBookingService(bookingStore, carStore, userStore)
+	carService := services.NewCarService(carStore, bookingStore, companyStore, userStore, notify.NewLogNotifier(log.Default()), files)
 
-	r := redis.NewClient(&redis.Options{
-		Addr: "localhost:6379",
-		DB:   5,
-	})
-	c := store.NewRedisCache(r)
+	bookingService := services.NewBookingService(bookingStore, carStore, userStore, companyStore, notify.NewLogNotifier(log.Default()))
 
 	// handlers
 	mux := http.NewServeMux()
 	_ = NewUserHandler(mux, userService, log.Default())
 	_ = NewCompanyHandler(mux, companyService, log.Default())
 	_ = NewCarHandler(mux, carService, log.Default())
-	_ = NewBookingHandler(mux, bookingService, carService, c, log.Default())
+	_ = NewBookingHandler(mux, bookingService, log.Default())
 	// setup the test server
 	testServer = httptest.NewServer(mux)
 	return testServer, nil
@@ -111,6 +122,26 @@ func checkToken() (map[string]interface{}, error) {
 	return claims, nil
 }
 
+// helper function to register another user, returns the auth header of the new user
+func registerUser(email string, role types.UserRole, t *testing.T) string {
+	payload := &types.CreateUserPayload{
+		Username: utils.GenerateUniqueString("user"),
+		Password: testPassword,
+		Email:    email,
+		Role:     role,
+	}
+	checkResponse(sendPostRequest(testServer.URL+"/register", payload, t), http.StatusOK, t)
+
+	resp := sendPostRequest(testServer.URL+"/login", &types.LoginPayload{Username: payload.Username, Password: payload.Password}, t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var responseBody map[string]string
+	if err := json.Unmarshal(body, &responseBody); err != nil {
+		t.Fatalf("failed to parse login response body: %v", err)
+	}
+	return "Bearer " + responseBody["token"]
+}
+
 // helper function to send a GET request
 func sendGetRequest(url string, t *testing.T) *http.Response {
 	req, err := http.NewRequest("GET", url, nil)
@@ -168,6 +199,38 @@ func sendPutRequest(url string, payload interface{}, t *testing.T) *http.Respons
 	return resp
 }
 
+// helper function to send a POST request with a file and other fields as multipart form
+func sendFileRequest(url string, field string, filename string, content []byte, fields map[string]string, t *testing.T) *http.Response {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("failed to write form field: %v", err)
+		}
+	}
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		t.Fatalf("failed to create form file: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("failed to write form file: %v", err)
+	}
+	writer.Close()
+
+	req, err := http.NewRequest("POST", url, &body)
+	if err != nil {
+		t.Fatalf("failed to create POST request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", authHeader)
+
+	resp, err := testServer.Client().Do(req)
+	if err != nil {
+		t.Fatalf("failed to send POST request: %v", err)
+	}
+	return resp
+}
+
 // helper function to send a GET request
 func sendDeleteRequest(url string, t *testing.T) *http.Response {
 	req, err := http.NewRequest("DELETE", url, nil)
@@ -35,6 +35,18 @@ func NewUserHandler(mux *http.ServeMux, user *services.UserService, logger *log.
 	h.mux.HandleFunc("DELETE /user/{id}", authMiddleware(h.handleDeleteUser, logger))
 	h.mux.HandleFunc("PUT /user/{id}", authMiddleware(h.handleUpdateUser, logger))
 
+	// driver details checked against company rental rules
+	h.mux.HandleFunc("GET /user/{id}/driver", authMiddleware(h.handleGetDriverProfile, logger))
+	h.mux.HandleFunc("PUT /user/{id}/driver", authMiddleware(h.handleUpdateDriverProfile, logger))
+
+	// saved car searches and favourite cars, notify sends emails about new matching cars and price drops
+	h.mux.HandleFunc("GET /user/{id}/favorites", authMiddleware(h.handleGetFavorites, logger))
+	h.mux.HandleFunc("POST /car/{id}/favorite", authMiddleware(h.handleAddFavorite, logger))
+	h.mux.HandleFunc("DELETE /car/{id}/favorite", authMiddleware(h.handleRemoveFavorite, logger))
+	h.mux.HandleFunc("GET /user/{id}/searches", authMiddleware(h.handleGetSavedSearches, logger))
+	h.mux.HandleFunc("POST /user/{id}/searches", authMiddleware(h.handleSaveSearch, logger))
+	h.mux.HandleFunc("DELETE /user/{id}/searches/{searchId}", authMiddleware(h.handleDeleteSavedSearch, logger))
+
 	return h
 }
 
@@ -245,3 +257,241 @@ func (h *UserHandler) handleCheckToken(w http.ResponseWriter, r *http.Request) e
 		"data": claims,
 	})
 }
+
+// @Summary Get driver profile
+// @Description Retrieves user's driving license details
+// @Produce json
+// @Param id path int true "User ID"
+// @Param Authorization header string true "Bearer Token"
+// @Tags User
+// @Success 200 {object} types.DriverProfile
+// @Router /user/{id}/driver [get]
+func (h *UserHandler) handleGetDriverProfile(w http.ResponseWriter, r *http.Request) error {
+	idFromToken := r.Context().Value(userIdKey)
+	userIDInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	if idFromToken != nil && idFromToken != userIDInt {
+		return types.Unauthorized("user can only view their own driver profile")
+	}
+
+	profile, err := h.user.GetDriverProfile(userIDInt)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, profile)
+}
+
+// @Summary Update driver profile
+// @Description Creates or replaces user's driving license details
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param Authorization header string true "Bearer Token"
+// @Param payload body types.UpdateDriverProfilePayload true "Driver details"
+// @Tags User
+// @Success 200 {object} map[string]string
+// @Router /user/{id}/driver [put]
+func (h *UserHandler) handleUpdateDriverProfile(w http.ResponseWriter, r *http.Request) error {
+	idFromToken := r.Context().Value(userIdKey)
+	userIDInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	if idFromToken != nil && idFromToken != userIDInt {
+		return types.Unauthorized("user can only update their own driver profile")
+	}
+
+	var payload types.UpdateDriverProfilePayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	if err := h.user.UpdateDriverProfile(userIDInt, &payload); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{
+		"message": "driver profile updated successfully!",
+	})
+}
+
+// users can see and manage only their own favourites and searches
+func ownUserID(r *http.Request) (int, error) {
+	userId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, types.BadPathParameter("id")
+	}
+	if idFromToken := r.Context().Value(userIdKey); idFromToken != nil && idFromToken != userId {
+		return 0, types.Unauthorized("user can only access their own favorites and searches")
+	}
+	return userId, nil
+}
+
+// @Summary Get favourite cars
+// @Description Cars marked as favourite by the user, oldest first
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "User ID"
+// @Tags User
+// @Success 200 {array} types.FavoriteCar
+// @Router /user/{id}/favorites [get]
+func (h *UserHandler) handleGetFavorites(w http.ResponseWriter, r *http.Request) error {
+	userId, err := ownUserID(r)
+	if err != nil {
+		return err
+	}
+
+	favorites, err := h.user.GetFavorites(userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, favorites)
+}
+
+// @Summary Add a favourite car
+// @Description Marks the car as favourite, with notify the user gets an email when its price drops
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Param favorite body types.FavoriteCarPayload true "Notification settings"
+// @Tags User
+// @Success 200 {object} types.FavoriteCar
+// @Router /car/{id}/favorite [post]
+func (h *UserHandler) handleAddFavorite(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	var payload types.FavoriteCarPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	favorite, err := h.user.AddFavorite(idInt, userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, favorite)
+}
+
+// @Summary Remove a favourite car
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "Car ID"
+// @Tags User
+// @Success 200 {object} map[string]string
+// @Router /car/{id}/favorite [delete]
+func (h *UserHandler) handleRemoveFavorite(w http.ResponseWriter, r *http.Request) error {
+	idInt, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return types.BadPathParameter("id")
+	}
+
+	userId, ok := r.Context().Value(userIdKey).(int)
+	if !ok {
+		return types.Unauthorized("user id not found in token")
+	}
+
+	if err := h.user.RemoveFavorite(idInt, userId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("car %d removed from favorites", idInt)})
+}
+
+// @Summary Get saved searches
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "User ID"
+// @Tags User
+// @Success 200 {array} types.SavedSearch
+// @Router /user/{id}/searches [get]
+func (h *UserHandler) handleGetSavedSearches(w http.ResponseWriter, r *http.Request) error {
+	userId, err := ownUserID(r)
+	if err != nil {
+		return err
+	}
+
+	searches, err := h.user.GetSavedSearches(userId)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, searches)
+}
+
+// @Summary Save a car search
+// @Description Saves filters of GET /car/batch, with notify the user gets an email about new matching cars
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "User ID"
+// @Param search body types.SavedSearchPayload true "Search, eg. query make=Toyota&price_per_day[lte]=150"
+// @Tags User
+// @Success 201 {object} types.SavedSearch
+// @Router /user/{id}/searches [post]
+func (h *UserHandler) handleSaveSearch(w http.ResponseWriter, r *http.Request) error {
+	userId, err := ownUserID(r)
+	if err != nil {
+		return err
+	}
+
+	var payload types.SavedSearchPayload
+	if err := types.ParseJSON(r, &payload); err != nil {
+		return types.InvalidJSON(err)
+	}
+
+	if errors := utils.ValidateStruct(&payload); len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	search, err := h.user.SaveSearch(userId, &payload)
+	if err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusCreated, search)
+}
+
+// @Summary Delete a saved search
+// @Produce json
+// @Param Authorization header string true "Bearer Token"
+// @Param id path int true "User ID"
+// @Param searchId path int true "Saved search ID"
+// @Tags User
+// @Success 200 {object} map[string]string
+// @Router /user/{id}/searches/{searchId} [delete]
+func (h *UserHandler) handleDeleteSavedSearch(w http.ResponseWriter, r *http.Request) error {
+	userId, err := ownUserID(r)
+	if err != nil {
+		return err
+	}
+
+	searchId, err := strconv.Atoi(r.PathValue("searchId"))
+	if err != nil {
+		return types.BadPathParameter("searchId")
+	}
+
+	if err := h.user.DeleteSavedSearch(userId, searchId); err != nil {
+		return err
+	}
+
+	return types.WriteJSON(w, http.StatusOK, map[string]string{"message": fmt.Sprintf("saved search %d deleted", searchId)})
+}
@@ -160,6 +160,76 @@ func TestGetUser(t *testing.T) {
 
 }
 
+func TestDriverProfile(t *testing.T) {
+	claims, err := checkToken()
+	if err != nil {
+		t.Fatalf("failed to check token: %v", err)
+	}
+
+	userID, ok := claims["id"].(float64)
+	if !ok {
+		t.Fatalf("expected user ID in claims, got: %v", claims)
+	}
+	url := fmt.Sprintf("%s/user/%.0f/driver", testServer.URL, userID)
+
+	// no profile yet
+	resp := sendGetRequest(url, t)
+	checkResponse(resp, http.StatusNotFound, t)
+
+	payload := &types.UpdateDriverProfilePayload{
+		DateOfBirth:     "1990-05-01",
+		LicenseNumber:   "ABC123456",
+		LicenseCountry:  "PL",
+		LicenseCategory: "B",
+		LicenseIssued:   "2008-06-01",
+		LicenseExpiry:   "2040-06-01",
+	}
+	resp = sendPutRequest(url, payload, t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendGetRequest(url, t)
+	body := checkResponse(resp, http.StatusOK, t)
+
+	var profile types.DriverProfile
+	if err := json.Unmarshal(body, &profile); err != nil {
+		t.Fatalf("failed to unmarshal response body: %v", err)
+	}
+	if profile.LicenseNumber != payload.LicenseNumber {
+		t.Errorf("expected license number %s, got %s", payload.LicenseNumber, profile.LicenseNumber)
+	}
+
+	// license issued before birth
+	payload.LicenseIssued = "1980-01-01"
+	resp = sendPutRequest(url, payload, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+}
+
+func TestFavorites(t *testing.T) {
+	claims, err := checkToken()
+	if err != nil {
+		t.Fatalf("failed to check token: %v", err)
+	}
+	userURL := fmt.Sprintf("%s/user/%.0f", testServer.URL, claims["id"])
+
+	resp := sendPostRequest(testServer.URL+"/car/999/favorite", &types.FavoriteCarPayload{Notify: true}, t)
+	checkResponse(resp, http.StatusNotFound, t)
+
+	resp = sendGetRequest(userURL+"/favorites", t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendPostRequest(userURL+"/searches", &types.SavedSearchPayload{Name: "by engine", Query: "engine=v8"}, t)
+	checkResponse(resp, http.StatusBadRequest, t)
+
+	resp = sendPostRequest(userURL+"/searches", &types.SavedSearchPayload{Name: "automatic", Query: "transmission=automatic", Notify: true}, t)
+	checkResponse(resp, http.StatusCreated, t)
+
+	resp = sendGetRequest(userURL+"/searches", t)
+	checkResponse(resp, http.StatusOK, t)
+
+	resp = sendDeleteRequest(userURL+"/searches/999", t)
+	checkResponse(resp, http.StatusNotFound, t)
+}
+
 func TestDeleteUser(t *testing.T) {
 	claims, err := checkToken()
 	if err != nil {
@@ -0,0 +1,28 @@
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+// Notifier delivers messages to users, e.g. by email
+type Notifier interface {
+	Notify(ctx context.Context, to string, subject string, body string) error
+}
+
+// LogNotifier only writes the messages to the logger,
+// used until a mail provider is configured
+type LogNotifier struct {
+	logger *log.Logger
+}
+
+func NewLogNotifier(logger *log.Logger) *LogNotifier {
+	return &LogNotifier{
+		logger: logger,
+	}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, to string, subject string, body string) error {
+	n.logger.Printf("to: %s, subject: %s, body: %s", to, subject, body)
+	return nil
+}
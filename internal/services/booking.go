@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"math"
+	"strings"
 	"time"
 
+	"github.com/mwdev22/CarRental/internal/notify"
 	"github.com/mwdev22/CarRental/internal/store"
 	"github.com/mwdev22/CarRental/internal/types"
 )
@@ -13,13 +16,17 @@ type BookingService struct {
 	bookingStore store.BookingStore
 	carStore     store.CarStore
 	userStore    store.UserStore
+	companyStore store.CompanyStore
+	notifier     notify.Notifier
 }
 
-func NewBookingService(bookingStore store.BookingStore, carStore store.CarStore, userStore store.UserStore) *BookingService {
+func NewBookingService(bookingStore store.BookingStore, carStore store.CarStore, userStore store.UserStore, companyStore store.CompanyStore, notifier notify.Notifier) *BookingService {
 	return &BookingService{
 		bookingStore: bookingStore,
 		carStore:     carStore,
 		userStore:    userStore,
+		companyStore: companyStore,
+		notifier:     notifier,
 	}
 }
 
@@ -32,13 +39,6 @@ func (s *BookingService) Create(userId int, payload *types.CreateBookingPayload)
 
 	}
 
-	car, err := s.carStore.GetByID(context.Background(), payload.CarID)
-	if err != nil {
-		return types.DatabaseError(err)
-	} else if car == nil {
-		return types.NotFound("car")
-	}
-
 	startDate, err := time.Parse(time.DateOnly, payload.StartDate)
 	if err != nil {
 		return types.InternalServerError(err.Error())
@@ -52,25 +52,92 @@ func (s *BookingService) Create(userId int, payload *types.CreateBookingPayload)
 		return types.BadRequest("start date cannot be after end date")
 	}
 
-	if !s.bookingStore.CheckDateAvailability(context.Background(), payload.CarID, startDate, endDate) {
-		return types.BadRequest("car is not available on selected dates")
+	var car *types.Car
+	var categoryID *int
+	if payload.CarID != 0 {
+		car, err = s.carStore.GetByID(context.Background(), payload.CarID)
+		if err != nil {
+			return types.DatabaseError(err)
+		} else if car == nil {
+			return types.NotFound("car")
+		}
+
+		if car.Status != types.CarStatusAvailable {
+			return types.BadRequest("car is not available for rent")
+		}
+		if err := checkVerified(context.Background(), s.companyStore, car.CompanyID); err != nil {
+			return err
+		}
+		if err := checkDocuments(car, endDate); err != nil {
+			return err
+		}
+		if !s.bookingStore.CheckDateAvailability(context.Background(), payload.CarID, startDate, endDate) {
+			return types.BadRequest("car is not available on selected dates")
+		}
+	} else {
+		// car has to come from the company of the chosen branch
+		companyID := payload.CompanyID
+		if payload.BranchID != 0 && companyID == 0 {
+			branch, err := s.companyStore.GetBranch(context.Background(), payload.BranchID)
+			if err != nil {
+				return err
+			}
+			companyID = branch.CompanyID
+		}
+		car, err = s.carFromCategory(context.Background(), payload.CategoryID, companyID, startDate, endDate)
+		if err != nil {
+			return err
+		}
+		categoryID = &payload.CategoryID
+	}
+
+	surcharge, err := s.checkDriver(context.Background(), userId, car, startDate, endDate)
+	if err != nil {
+		return err
 	}
 
-	// estimate price based on days
-	totalPrice := math.Ceil(endDate.Sub(startDate).Hours()) / 24 * car.PricePerDay
+	drivers, err := s.prepareDrivers(context.Background(), user, car, startDate, endDate, payload.AdditionalDrivers)
+	if err != nil {
+		return err
+	}
+
+	days, err := s.dailyPrices(context.Background(), car, startDate, endDate)
+	if err != nil {
+		return err
+	}
 
 	book := &types.Booking{
-		CarID:     payload.CarID,
-		UserID:    userId,
-		StartDate: startDate,
-		EndDate:   endDate,
-		Total:     totalPrice,
+		CarID:      car.ID,
+		UserID:     userId,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		CreatedBy:  &userId,
+		CategoryID: categoryID,
+	}
+	if payload.BranchID != 0 {
+		book.BranchID = &payload.BranchID
+		book.PickupTime = &payload.PickupTime
+		book.ReturnTime = &payload.ReturnTime
 	}
 
+	branchFee, err := s.branchFee(context.Background(), car, book)
+	if err != nil {
+		return err
+	}
+	book.Total = bookingTotal(days[:billedDays(startDate, endDate)], surcharge) + driverFees(drivers) + branchFee
+
 	if err := s.bookingStore.Create(context.Background(), book); err != nil {
 		return types.DatabaseError(err)
 	}
 
+	for _, driver := range drivers {
+		driver.BookingID = book.ID
+		if err := s.bookingStore.AddDriver(context.Background(), driver); err != nil {
+			return types.DatabaseError(err)
+		}
+		s.notifyDriver(context.Background(), book, driver)
+	}
+
 	return nil
 }
 
@@ -79,6 +146,16 @@ func (s *BookingService) GetByID(id int) (*types.Booking, error) {
 	if err != nil {
 		return nil, types.DatabaseError(err)
 	}
+
+	book.Drivers, err = s.bookingStore.GetDrivers(context.Background(), id)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	book.Transfers, err = s.bookingStore.GetTransfers(context.Background(), id)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
 	return book, nil
 }
 
@@ -111,19 +188,46 @@ func (s *BookingService) Update(id int, payload *types.UpdateBookingPayload) err
 	if startDate.After(endDate) {
 		return types.BadRequest("start date cannot be after end date")
 	}
+	if err := checkDocuments(car, endDate); err != nil {
+		return err
+	}
 
-	// estimate price based on days once again
-	totalPrice := (1 + math.Ceil(endDate.Sub(startDate).Hours())/24) * car.PricePerDay
+	// new dates may not fit the driver anymore, e.g. license expires in the meantime
+	surcharge, err := s.checkDriver(context.Background(), book.UserID, car, startDate, endDate)
+	if err != nil {
+		return err
+	}
+
+	// the same goes for additional drivers
+	drivers, err := s.recalculateDrivers(context.Background(), book.ID, car, startDate, endDate)
+	if err != nil {
+		return err
+	}
+
+	days, err := s.dailyPrices(context.Background(), car, startDate, endDate)
+	if err != nil {
+		return err
+	}
 
-	// update booking
-	book.Total = totalPrice
+	// update booking, branch has to be open on the new dates as well
 	book.StartDate = startDate
 	book.EndDate = endDate
+	branchFee, err := s.branchFee(context.Background(), car, book)
+	if err != nil {
+		return err
+	}
+	book.Total = bookingTotal(days, surcharge) + driverFees(drivers) + branchFee
 
 	if err := s.bookingStore.Update(context.Background(), book); err != nil {
 		return types.DatabaseError(err)
 	}
 
+	for _, driver := range drivers {
+		if err := s.bookingStore.UpdateDriver(context.Background(), driver); err != nil {
+			return types.DatabaseError(err)
+		}
+	}
+
 	return nil
 }
 
@@ -136,8 +240,384 @@ func (s *BookingService) GetByUserID(userId int) ([]*types.Booking, error) {
 }
 
 func (s *BookingService) Delete(id int) error {
+	if err := s.declinePendingTransfer(context.Background(), id); err != nil {
+		return err
+	}
 	if err := s.bookingStore.Delete(context.Background(), id); err != nil {
 		return types.DatabaseError(err)
 	}
 	return nil
 }
+
+// max number of bookings a single series can expand into
+const maxSeriesOccurrences = 366
+
+// number of rented days, both start and end day are included
+func rentalDays(startDate, endDate time.Time) int {
+	return 1 + int(math.Ceil(endDate.Sub(startDate).Hours()/24))
+}
+
+// number of days the renter pays for, the return day isn't charged
+func billedDays(startDate, endDate time.Time) int {
+	return int(math.Ceil(endDate.Sub(startDate).Hours() / 24))
+}
+
+// daily prices of the car with additional daily fees, e.g. young driver surcharge
+func bookingTotal(days []*types.DailyPrice, feesPerDay float64) float64 {
+	total := 0.0
+	for _, day := range days {
+		total += day.Price + feesPerDay
+	}
+	return total
+}
+
+// rules for the car, falling back to company wide ones, nil if company has none
+func (s *BookingService) rentalRules(ctx context.Context, car *types.Car) (*types.RentalRules, error) {
+	rules, err := s.companyStore.GetRentalRules(ctx, car.CompanyID, &car.ID)
+	if err != nil || rules != nil {
+		return rules, err
+	}
+	return s.companyStore.GetRentalRules(ctx, car.CompanyID, nil)
+}
+
+// verifies the user can drive the car in given period and returns his daily surcharge,
+// companies without rental rules don't require driver details
+func (s *BookingService) checkDriver(ctx context.Context, userId int, car *types.Car, startDate, endDate time.Time) (float64, error) {
+	rules, err := s.rentalRules(ctx, car)
+	if err != nil {
+		return 0, types.DatabaseError(err)
+	}
+
+	surcharge, errors, err := s.evaluateDriver(ctx, rules, userId, startDate, endDate)
+	if err != nil {
+		return 0, err
+	} else if len(errors) > 0 {
+		return 0, types.ValidationError(errors)
+	}
+
+	return surcharge, nil
+}
+
+// same as checkDriver but with already loaded rules, failed checks are returned as validation errors
+func (s *BookingService) evaluateDriver(ctx context.Context, rules *types.RentalRules, userId int, startDate, endDate time.Time) (float64, map[string]string, error) {
+	if rules == nil {
+		return 0, nil, nil
+	}
+
+	profile, err := s.userStore.GetDriverProfile(ctx, userId)
+	if err != nil {
+		return 0, nil, types.DatabaseError(err)
+	}
+
+	if errors := checkEligibility(profile, rules, startDate, endDate); len(errors) > 0 {
+		return 0, errors, nil
+	}
+
+	return driverSurcharge(profile, rules, startDate), nil, nil
+}
+
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
+
+// dates between start and end (inclusive) that fall on one of the weekdays
+func expandSeries(startDate, endDate time.Time, weekdays types.WeekdayMask) []time.Time {
+	var dates []time.Time
+	for d := startDate; !d.After(endDate); d = d.AddDate(0, 0, 1) {
+		if weekdays.Has(d.Weekday()) {
+			dates = append(dates, d)
+		}
+	}
+	return dates
+}
+
+// splits the dates into the ones where car is free and formatted conflicting ones
+func (s *BookingService) splitAvailable(ctx context.Context, carID int, dates []time.Time) ([]time.Time, []string) {
+	free := make([]time.Time, 0, len(dates))
+	conflicts := make([]string, 0)
+	for _, d := range dates {
+		if s.bookingStore.CheckDateAvailability(ctx, carID, d, d) {
+			free = append(free, d)
+		} else {
+			conflicts = append(conflicts, d.Format(time.DateOnly))
+		}
+	}
+	return free, conflicts
+}
+
+// bookings of the series on given dates, they are saved by the store together with the series
+func (s *BookingService) occurrences(ctx context.Context, series *types.BookingSeries, car *types.Car, dates []time.Time, feesPerDay float64) ([]*types.Booking, error) {
+	if len(dates) == 0 {
+		return nil, nil
+	}
+	// one quote for the whole span, dates are sorted
+	days, err := s.dailyPrices(ctx, car, dates[0], dates[len(dates)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]*types.Booking, 0, len(dates))
+	for _, d := range dates {
+		day := days[rentalDays(dates[0], d)-1]
+		book := &types.Booking{
+			CarID:     series.CarID,
+			UserID:    series.UserID,
+			StartDate: d,
+			EndDate:   d,
+			Total:     bookingTotal([]*types.DailyPrice{day}, feesPerDay),
+			CreatedBy: &series.UserID,
+		}
+		books = append(books, book)
+	}
+	return books, nil
+}
+
+func (s *BookingService) CreateSeries(userId int, payload *types.CreateBookingSeriesPayload) (*types.BookingSeriesResult, error) {
+	ctx := context.Background()
+
+	user, err := s.userStore.GetByID(ctx, userId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	} else if user == nil {
+		return nil, types.NotFound("user")
+	}
+
+	car, err := s.carStore.GetByID(ctx, payload.CarID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	} else if car == nil {
+		return nil, types.NotFound("car")
+	}
+	if car.Status != types.CarStatusAvailable {
+		return nil, types.BadRequest("car is not available for rent")
+	}
+	if err := checkVerified(ctx, s.companyStore, car.CompanyID); err != nil {
+		return nil, err
+	}
+
+	startDate, err := time.Parse(time.DateOnly, payload.StartDate)
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+	endDate, err := time.Parse(time.DateOnly, payload.EndDate)
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+
+	if startDate.After(endDate) {
+		return nil, types.BadRequest("start date cannot be after end date")
+	}
+	if err := checkDocuments(car, endDate); err != nil {
+		return nil, err
+	}
+
+	weekdays := types.NewWeekdayMask(payload.Weekdays)
+	dates := expandSeries(startDate, endDate, weekdays)
+	if len(dates) == 0 {
+		return nil, types.BadRequest("series has no occurrences in selected range")
+	} else if len(dates) > maxSeriesOccurrences {
+		return nil, types.BadRequest(fmt.Sprintf("series cannot have more than %d occurrences", maxSeriesOccurrences))
+	}
+
+	surcharge, err := s.checkDriver(ctx, userId, car, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	free, conflicts := s.splitAvailable(ctx, car.ID, dates)
+	if len(free) == 0 || (len(conflicts) > 0 && !payload.SkipConflicts) {
+		return nil, types.Conflict("car is not available on " + strings.Join(conflicts, ", "))
+	}
+
+	series := &types.BookingSeries{
+		UserID:    userId,
+		CarID:     car.ID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Weekdays:  weekdays,
+	}
+	books, err := s.occurrences(ctx, series, car, free, surcharge)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.bookingStore.CreateSeries(ctx, series, books); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	return &types.BookingSeriesResult{
+		Series:    series,
+		Bookings:  books,
+		Conflicts: conflicts,
+	}, nil
+}
+
+func (s *BookingService) GetSeries(id int) (*types.BookingSeriesResult, error) {
+	series, err := s.bookingStore.GetSeriesByID(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+
+	books, err := s.bookingStore.GetBySeriesID(context.Background(), id)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	return &types.BookingSeriesResult{
+		Series:    series,
+		Bookings:  books,
+		Conflicts: []string{},
+	}, nil
+}
+
+// regenerates upcoming occurrences of the series, past ones are left untouched
+func (s *BookingService) UpdateSeries(id int, payload *types.UpdateBookingSeriesPayload) (*types.BookingSeriesResult, error) {
+	ctx := context.Background()
+
+	series, err := s.bookingStore.GetSeriesByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.carStore.GetByID(ctx, series.CarID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	if car.Status != types.CarStatusAvailable {
+		return nil, types.BadRequest("car is not available for rent")
+	}
+	if err := checkVerified(ctx, s.companyStore, car.CompanyID); err != nil {
+		return nil, err
+	}
+
+	endDate := series.EndDate
+	if payload.EndDate != "" {
+		endDate, err = time.Parse(time.DateOnly, payload.EndDate)
+		if err != nil {
+			return nil, types.InternalServerError(err.Error())
+		}
+	}
+	weekdays := series.Weekdays
+	if len(payload.Weekdays) > 0 {
+		weekdays = types.NewWeekdayMask(payload.Weekdays)
+	}
+
+	if series.StartDate.After(endDate) {
+		return nil, types.BadRequest("start date cannot be after end date")
+	}
+	if err := checkDocuments(car, endDate); err != nil {
+		return nil, err
+	}
+
+	from := today()
+	if series.StartDate.After(from) {
+		from = series.StartDate
+	}
+
+	books, err := s.bookingStore.GetBySeriesID(ctx, id)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	// active upcoming occurrences by date
+	upcoming := make(map[string]*types.Booking)
+	for _, b := range books {
+		if b.Status != types.BookingStatusCancelled && !b.StartDate.Before(from) {
+			upcoming[b.StartDate.Format(time.DateOnly)] = b
+		}
+	}
+
+	wanted := expandSeries(from, endDate, weekdays)
+	if len(wanted) > maxSeriesOccurrences {
+		return nil, types.BadRequest(fmt.Sprintf("series cannot have more than %d occurrences", maxSeriesOccurrences))
+	}
+
+	wantedDates := make(map[string]bool, len(wanted))
+	missing := make([]time.Time, 0)
+	for _, d := range wanted {
+		key := d.Format(time.DateOnly)
+		wantedDates[key] = true
+		if _, ok := upcoming[key]; !ok {
+			missing = append(missing, d)
+		}
+	}
+
+	surcharge, err := s.checkDriver(ctx, series.UserID, car, from, endDate)
+	if err != nil {
+		return nil, err
+	}
+
+	free, conflicts := s.splitAvailable(ctx, series.CarID, missing)
+	if len(conflicts) > 0 && !payload.SkipConflicts {
+		return nil, types.Conflict("car is not available on " + strings.Join(conflicts, ", "))
+	}
+
+	added, err := s.occurrences(ctx, series, car, free, surcharge)
+	if err != nil {
+		return nil, err
+	}
+
+	// occurrences that no longer match the series are cancelled
+	cancelled := make([]*types.Booking, 0)
+	for key, b := range upcoming {
+		if !wantedDates[key] {
+			cancelled = append(cancelled, b)
+		}
+	}
+
+	series.EndDate = endDate
+	series.Weekdays = weekdays
+	if err := s.bookingStore.UpdateSeries(ctx, series, added, cancelled); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	result, err := s.GetSeries(id)
+	if err != nil {
+		return nil, err
+	}
+	result.Conflicts = conflicts
+	return result, nil
+}
+
+// cancels all occurrences that haven't started yet
+func (s *BookingService) CancelSeries(id int) error {
+	ctx := context.Background()
+
+	books, err := s.bookingStore.GetBySeriesID(ctx, id)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	from := today()
+	for _, b := range books {
+		if b.Status == types.BookingStatusCancelled || b.StartDate.Before(from) {
+			continue
+		}
+		b.Status = types.BookingStatusCancelled
+		if err := s.bookingStore.Update(ctx, b); err != nil {
+			return types.DatabaseError(err)
+		}
+	}
+
+	return nil
+}
+
+func (s *BookingService) CancelOccurrence(seriesId int, bookingId int) error {
+	book, err := s.bookingStore.GetByID(context.Background(), bookingId)
+	if err != nil {
+		return err
+	}
+
+	if book.SeriesID == nil || *book.SeriesID != seriesId {
+		return types.NotFound(fmt.Sprintf("booking %d in series %d", bookingId, seriesId))
+	}
+	if book.Status == types.BookingStatusCancelled {
+		return types.BadRequest("booking is already cancelled")
+	}
+
+	book.Status = types.BookingStatusCancelled
+	if err := s.bookingStore.Update(context.Background(), book); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	return nil
+}
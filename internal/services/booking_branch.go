@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func isClosed(branch *types.Branch, date time.Time) bool {
+	for _, c := range branch.Closures {
+		if c.Date.Equal(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// closing time still counts as open, the car can be returned right before the branch closes
+func isOpen(branch *types.Branch, date time.Time, at string) bool {
+	for _, h := range branch.OpeningHours {
+		if h.Weekday == date.Weekday() && h.Opens <= at && at <= h.Closes {
+			return true
+		}
+	}
+	return false
+}
+
+// fee for pickup and return at the booking's branch, both have to be within its opening hours
+// unless the branch offers out of hours service, 0 for bookings without a branch
+func (s *BookingService) branchFee(ctx context.Context, car *types.Car, book *types.Booking) (float64, error) {
+	if book.BranchID == nil {
+		return 0, nil
+	}
+	startDate, endDate, pickupTime, returnTime := book.StartDate, book.EndDate, *book.PickupTime, *book.ReturnTime
+
+	branch, err := s.companyStore.GetBranch(ctx, *book.BranchID)
+	if err != nil {
+		return 0, err
+	}
+	if branch.CompanyID != car.CompanyID {
+		return 0, types.BadRequest("branch doesn't belong to the company renting the car")
+	}
+	if startDate.Equal(endDate) && returnTime <= pickupTime {
+		return 0, types.BadRequest("return time has to be after pickup time")
+	}
+
+	loc, err := time.LoadLocation(branch.TimeZone)
+	if err != nil {
+		return 0, types.InternalServerError(fmt.Sprintf("invalid time zone of branch %d: %v", branch.ID, err))
+	}
+	pickup, err := time.ParseInLocation(time.DateOnly+" 15:04", startDate.Format(time.DateOnly)+" "+pickupTime, loc)
+	if err != nil {
+		return 0, types.BadRequest("invalid pickup time")
+	}
+	if book.PickedUp == nil && pickup.Before(time.Now()) {
+		return 0, types.BadRequest("pickup time has already passed")
+	}
+
+	fee := 0.0
+	for _, visit := range []struct {
+		name string
+		date time.Time
+		at   string
+	}{
+		{"pickup", startDate, pickupTime},
+		{"return", endDate, returnTime},
+	} {
+		if isClosed(branch, visit.date) {
+			return 0, types.BadRequest(fmt.Sprintf("branch is closed on %s", visit.date.Format(time.DateOnly)))
+		}
+		if isOpen(branch, visit.date, visit.at) {
+			continue
+		}
+		if branch.OutOfHoursFee == nil {
+			return 0, types.BadRequest(fmt.Sprintf("branch isn't open at %s on %s, %s has to be within opening hours",
+				visit.at, visit.date.Format(time.DateOnly), visit.name))
+		}
+		fee += *branch.OutOfHoursFee
+	}
+	return fee, nil
+}
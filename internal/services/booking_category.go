@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// cheapest car of the category that is free in given period, companyID 0 means any company
+func (s *BookingService) carFromCategory(ctx context.Context, categoryID int, companyID int, startDate, endDate time.Time) (*types.Car, error) {
+	if _, err := s.carStore.GetCategoryByID(ctx, categoryID); err != nil {
+		return nil, types.NotFound(fmt.Sprintf("car category %d", categoryID))
+	}
+
+	cars, err := s.carStore.GetByCategory(ctx, categoryID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	// cars of unverified companies can't be booked, each company is checked once
+	verified := make(map[int]bool)
+	for i := range cars {
+		if cars[i].Status != types.CarStatusAvailable || (companyID != 0 && cars[i].CompanyID != companyID) || checkDocuments(&cars[i], endDate) != nil {
+			continue
+		}
+		ok, checked := verified[cars[i].CompanyID]
+		if !checked {
+			ok = checkVerified(ctx, s.companyStore, cars[i].CompanyID) == nil
+			verified[cars[i].CompanyID] = ok
+		}
+		if !ok {
+			continue
+		}
+		if s.bookingStore.CheckDateAvailability(ctx, cars[i].ID, startDate, endDate) {
+			return &cars[i], nil
+		}
+	}
+
+	return nil, types.Conflict("no car of the category is available on selected dates")
+}
+
+// company swaps the car of category booking for another one of the same category,
+// renter keeps the price he booked with
+func (s *BookingService) AssignCar(bookingId int, carId int, userId int) error {
+	ctx := context.Background()
+
+	book, err := s.bookingStore.GetByID(ctx, bookingId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	if err := s.AuthorizeCar(book.CarID, userId, types.CompanyPermBookings); err != nil {
+		return err
+	}
+
+	if book.CategoryID == nil {
+		return types.BadRequest("car can only be changed for bookings made by category")
+	}
+	if book.Status != types.BookingStatusConfirmed {
+		return types.BadRequest("car can only be changed before pickup")
+	}
+	if book.CarID == carId {
+		return nil
+	}
+
+	current, err := s.carStore.GetByID(ctx, book.CarID)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	car, err := s.carStore.GetByID(ctx, carId)
+	if err != nil {
+		return types.NotFound(fmt.Sprintf("car %d", carId))
+	}
+
+	if car.Status != types.CarStatusAvailable {
+		return types.BadRequest("car is not available for rent")
+	}
+	if err := checkDocuments(car, book.EndDate); err != nil {
+		return err
+	}
+	if car.CategoryID == nil || *car.CategoryID != *book.CategoryID {
+		return types.BadRequest("car is from a different category")
+	}
+	if car.CompanyID != current.CompanyID {
+		return types.BadRequest("car belongs to a different company")
+	}
+	if !s.bookingStore.CheckDateAvailability(ctx, carId, book.StartDate, book.EndDate) {
+		return types.BadRequest("car is not available on booked dates")
+	}
+
+	book.CarID = carId
+	if err := s.bookingStore.Update(ctx, book); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+const (
+	// company is told about bookings the car can't serve this many days before they start
+	documentNoticeDays = 7
+	// longest rental the documents are checked ahead for
+	documentHorizonDays = 365
+)
+
+// car can't be rented past the day its first document expires
+func checkDocuments(car *types.Car, endDate time.Time) error {
+	expiry, name := car.FirstExpiry()
+	if expiry != nil && expiry.Before(endDate) {
+		return types.BadRequest(fmt.Sprintf("car's %s expires on %s, before the end of the rental", name, expiry.Format(time.DateOnly)))
+	}
+	return nil
+}
+
+// daily, cars with expired documents are taken out of service and companies are warned
+// about upcoming bookings that can't be picked up unless the documents are renewed
+func (s *BookingService) CheckCarDocuments(now time.Time) error {
+	ctx := context.Background()
+	day := now.UTC().Truncate(24 * time.Hour)
+
+	cars, err := s.carStore.GetExpiringDocuments(ctx, day.AddDate(0, 0, documentHorizonDays))
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	// one car shouldn't stop the others from being checked
+	var errs []error
+	for i := range cars {
+		if err := s.checkCarDocuments(ctx, &cars[i], day); err != nil {
+			errs = append(errs, fmt.Errorf("car %d: %w", cars[i].ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *BookingService) checkCarDocuments(ctx context.Context, car *types.Car, day time.Time) error {
+	expiry, name := car.FirstExpiry()
+	company, err := s.companyStore.GetByID(ctx, car.CompanyID)
+	if err != nil {
+		return err
+	}
+
+	if expiry.Before(day) && car.Status == types.CarStatusAvailable {
+		if err := s.carStore.UpdateStatus(ctx, car.ID, types.CarStatusInService); err != nil {
+			return err
+		}
+		car.Status = types.CarStatusInService
+		_ = s.notifier.Notify(ctx, company.Email, "Car documents expired",
+			fmt.Sprintf("%s of car %s %s (%s) expired on %s, the car was taken out of service until it's renewed",
+				name, car.Make, car.Model, car.RegistrationNo, expiry.Format(time.DateOnly)))
+	}
+
+	// sent once, on the day the booking is the notice period away
+	start := day.AddDate(0, 0, documentNoticeDays)
+	books, err := s.bookingStore.GetOverlapping(ctx, car.ID, start, start)
+	if err != nil {
+		return err
+	}
+	for _, book := range books {
+		if book.Status != types.BookingStatusConfirmed || !book.StartDate.Equal(start) || checkDocuments(car, book.EndDate) == nil {
+			continue
+		}
+		_ = s.notifier.Notify(ctx, company.Email, "Booking blocked by expiring documents",
+			fmt.Sprintf("%s of car %s %s (%s) expires on %s, booking %d (%s - %s) can't be picked up until it's renewed or the booking is moved to another car",
+				name, car.Make, car.Model, car.RegistrationNo, expiry.Format(time.DateOnly), book.ID,
+				book.StartDate.Format(time.DateOnly), book.EndDate.Format(time.DateOnly)))
+	}
+	return nil
+}
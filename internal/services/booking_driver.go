@@ -0,0 +1,289 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func driverFees(drivers []*types.BookingDriver) float64 {
+	var total float64
+	for _, d := range drivers {
+		total += d.Fee
+	}
+	return total
+}
+
+// daily additional driver fee and the driver's own surcharge for the whole rental
+func (s *BookingService) driverFee(ctx context.Context, rules *types.RentalRules, userId int, startDate, endDate time.Time) (float64, map[string]string, error) {
+	if rules == nil {
+		return 0, nil, nil
+	}
+
+	surcharge, errors, err := s.evaluateDriver(ctx, rules, userId, startDate, endDate)
+	if err != nil || len(errors) > 0 {
+		return 0, errors, err
+	}
+
+	return float64(billedDays(startDate, endDate)) * (rules.AdditionalDriverFee + surcharge), nil, nil
+}
+
+// builds the driver from payload, existing users have to pass the rental rules right away,
+// people without an account are only invited
+func (s *BookingService) newDriver(ctx context.Context, rules *types.RentalRules, startDate, endDate time.Time, payload *types.AdditionalDriverPayload) (*types.BookingDriver, map[string]string, error) {
+	var user *types.User
+	var err error
+	if payload.UserID != 0 {
+		user, err = s.userStore.GetByID(ctx, payload.UserID)
+		if err != nil || user == nil {
+			return nil, map[string]string{"UserID": fmt.Sprintf("user %d not found", payload.UserID)}, nil
+		}
+	} else {
+		user, err = s.userStore.GetByEmail(ctx, payload.Email)
+		if err != nil {
+			return nil, nil, types.DatabaseError(err)
+		}
+	}
+
+	if user == nil {
+		return &types.BookingDriver{
+			Email:  strings.ToLower(payload.Email),
+			Status: types.BookingDriverInvited,
+		}, nil, nil
+	}
+
+	fee, errors, err := s.driverFee(ctx, rules, user.ID, startDate, endDate)
+	if err != nil || len(errors) > 0 {
+		return nil, errors, err
+	}
+
+	return &types.BookingDriver{
+		UserID: &user.ID,
+		Email:  strings.ToLower(user.Email),
+		Status: types.BookingDriverAccepted,
+		Fee:    fee,
+	}, nil, nil
+}
+
+// resolves additional drivers of a new booking, errors are keyed by the driver position in payload
+func (s *BookingService) prepareDrivers(ctx context.Context, renter *types.User, car *types.Car, startDate, endDate time.Time, payloads []*types.AdditionalDriverPayload) ([]*types.BookingDriver, error) {
+	if len(payloads) == 0 {
+		return nil, nil
+	}
+
+	rules, err := s.rentalRules(ctx, car)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	drivers := make([]*types.BookingDriver, 0, len(payloads))
+	errors := make(map[string]string)
+	seen := map[string]bool{strings.ToLower(renter.Email): true}
+	for i, payload := range payloads {
+		driver, driverErrors, err := s.newDriver(ctx, rules, startDate, endDate, payload)
+		if err != nil {
+			return nil, err
+		}
+		for field, msg := range driverErrors {
+			errors[fmt.Sprintf("AdditionalDrivers[%d].%s", i, field)] = msg
+		}
+		if driver == nil {
+			continue
+		}
+		if seen[driver.Email] {
+			errors[fmt.Sprintf("AdditionalDrivers[%d]", i)] = "driver is already on the booking"
+			continue
+		}
+		seen[driver.Email] = true
+		drivers = append(drivers, driver)
+	}
+
+	if len(errors) > 0 {
+		return nil, types.ValidationError(errors)
+	}
+	return drivers, nil
+}
+
+// re-checks accepted drivers for new booking dates and recalculates their fees,
+// changes are not saved
+func (s *BookingService) recalculateDrivers(ctx context.Context, bookingId int, car *types.Car, startDate, endDate time.Time) ([]*types.BookingDriver, error) {
+	drivers, err := s.bookingStore.GetDrivers(ctx, bookingId)
+	if err != nil || len(drivers) == 0 {
+		return nil, err
+	}
+
+	rules, err := s.rentalRules(ctx, car)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	errors := make(map[string]string)
+	for _, driver := range drivers {
+		if driver.Status != types.BookingDriverAccepted || driver.UserID == nil {
+			continue
+		}
+		fee, driverErrors, err := s.driverFee(ctx, rules, *driver.UserID, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		for field, msg := range driverErrors {
+			errors[fmt.Sprintf("Drivers[%s].%s", driver.Email, field)] = msg
+		}
+		driver.Fee = fee
+	}
+
+	if len(errors) > 0 {
+		return nil, types.ValidationError(errors)
+	}
+	return drivers, nil
+}
+
+// notification failure shouldn't undo the booking, so errors are ignored
+func (s *BookingService) notifyDriver(ctx context.Context, book *types.Booking, driver *types.BookingDriver) {
+	period := fmt.Sprintf("%s - %s", book.StartDate.Format(time.DateOnly), book.EndDate.Format(time.DateOnly))
+	if driver.Status == types.BookingDriverInvited {
+		_ = s.notifier.Notify(ctx, driver.Email, "Invitation to drive",
+			fmt.Sprintf("you have been invited as an additional driver of booking %d (%s), register and accept the invitation to join", book.ID, period))
+		return
+	}
+	_ = s.notifier.Notify(ctx, driver.Email, "Added as a driver",
+		fmt.Sprintf("you have been added as an additional driver of booking %d (%s)", book.ID, period))
+}
+
+func (s *BookingService) AddDriver(bookingId int, payload *types.AdditionalDriverPayload) (*types.BookingDriver, error) {
+	ctx := context.Background()
+
+	book, err := s.GetByID(bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	car, err := s.carStore.GetByID(ctx, book.CarID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	renter, err := s.userStore.GetByID(ctx, book.UserID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	rules, err := s.rentalRules(ctx, car)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	driver, errors, err := s.newDriver(ctx, rules, book.StartDate, book.EndDate, payload)
+	if err != nil {
+		return nil, err
+	} else if len(errors) > 0 {
+		return nil, types.ValidationError(errors)
+	}
+
+	if strings.EqualFold(driver.Email, renter.Email) {
+		return nil, types.BadRequest("renter cannot be an additional driver")
+	}
+	for _, d := range book.Drivers {
+		if d.Email == driver.Email {
+			return nil, types.BadRequest("driver is already on the booking")
+		}
+	}
+
+	driver.BookingID = book.ID
+	if err := s.bookingStore.AddDriver(ctx, driver); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	book.Total += driver.Fee
+	if err := s.bookingStore.Update(ctx, book); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	s.notifyDriver(ctx, book, driver)
+	return driver, nil
+}
+
+func (s *BookingService) RemoveDriver(bookingId int, driverId int) error {
+	ctx := context.Background()
+
+	book, err := s.GetByID(bookingId)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range book.Drivers {
+		if d.ID != driverId {
+			continue
+		}
+		if err := s.bookingStore.DeleteDriver(ctx, d.ID); err != nil {
+			return types.DatabaseError(err)
+		}
+		book.Total -= d.Fee
+		if err := s.bookingStore.Update(ctx, book); err != nil {
+			return types.DatabaseError(err)
+		}
+		return nil
+	}
+
+	return types.NotFound(fmt.Sprintf("driver %d of booking %d", driverId, bookingId))
+}
+
+// invited user joins the booking once he passes the rental rules
+func (s *BookingService) AcceptDriverInvitation(bookingId int, userId int) error {
+	ctx := context.Background()
+
+	user, err := s.userStore.GetByID(ctx, userId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	book, err := s.GetByID(bookingId)
+	if err != nil {
+		return err
+	}
+
+	var invitation *types.BookingDriver
+	for _, d := range book.Drivers {
+		if d.Status == types.BookingDriverInvited && strings.EqualFold(d.Email, user.Email) {
+			invitation = d
+			break
+		}
+	}
+	if invitation == nil {
+		return types.NotFound("driver invitation for the user")
+	}
+
+	car, err := s.carStore.GetByID(ctx, book.CarID)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	rules, err := s.rentalRules(ctx, car)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	fee, errors, err := s.driverFee(ctx, rules, user.ID, book.StartDate, book.EndDate)
+	if err != nil {
+		return err
+	} else if len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	invitation.UserID = &user.ID
+	invitation.Status = types.BookingDriverAccepted
+	invitation.Fee = fee
+	if err := s.bookingStore.UpdateDriver(ctx, invitation); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	book.Total += fee
+	if err := s.bookingStore.Update(ctx, book); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	return nil
+}
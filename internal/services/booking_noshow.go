@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func (s *BookingService) PickUp(bookingId int, userId int) error {
+	book, err := s.bookingStore.GetByID(context.Background(), bookingId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	if err := s.AuthorizeCar(book.CarID, userId, types.CompanyPermBookings); err != nil {
+		return err
+	}
+
+	if book.Status != types.BookingStatusConfirmed {
+		return types.BadRequest("only confirmed bookings can be picked up")
+	}
+	if today().Before(book.StartDate) {
+		return types.BadRequest("booking hasn't started yet")
+	}
+
+	car, err := s.carStore.GetByID(context.Background(), book.CarID)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	if err := checkDocuments(car, book.EndDate); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	book.PickedUp = &now
+	book.Status = types.BookingStatusPickedUp
+	if err := s.bookingStore.Update(context.Background(), book); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	return s.declinePendingTransfer(context.Background(), book.ID)
+}
+
+// marks bookings that weren't picked up within the grace period of their company as no-shows,
+// renter is charged the no-show fee instead of the total when the company set one and the car is free again
+func (s *BookingService) ProcessNoShows(now time.Time) error {
+	ctx := context.Background()
+
+	due, err := s.bookingStore.GetNoShowsDue(ctx, now)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	// one broken booking shouldn't stop the others from being processed
+	var errs []error
+	for _, d := range due {
+		if err := s.processNoShow(ctx, d); err != nil {
+			errs = append(errs, fmt.Errorf("booking %d: %w", d.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *BookingService) processNoShow(ctx context.Context, due *types.NoShowDue) error {
+	book := &due.Booking
+	book.Status = types.BookingStatusNoShow
+	if due.NoShowFee > 0 {
+		book.Total = due.NoShowFee
+	}
+	if err := s.bookingStore.Update(ctx, book); err != nil {
+		return err
+	}
+	if err := s.declinePendingTransfer(ctx, book.ID); err != nil {
+		return err
+	}
+
+	s.notifyNoShow(ctx, book)
+	return nil
+}
+
+// notification failure shouldn't undo the no-show, so errors are ignored
+func (s *BookingService) notifyNoShow(ctx context.Context, book *types.Booking) {
+	car, err := s.carStore.GetByID(ctx, book.CarID)
+	if err != nil {
+		return
+	}
+	period := fmt.Sprintf("%s - %s", book.StartDate.Format(time.DateOnly), book.EndDate.Format(time.DateOnly))
+
+	if user, err := s.userStore.GetByID(ctx, book.UserID); err == nil && user != nil {
+		_ = s.notifier.Notify(ctx, user.Email, "Booking marked as no-show",
+			fmt.Sprintf("car of booking %d (%s) wasn't picked up in time, the booking was closed and %.2f was charged", book.ID, period, book.Total))
+	}
+
+	if company, err := s.companyStore.GetByID(ctx, car.CompanyID); err == nil && company != nil {
+		_ = s.notifier.Notify(ctx, company.Email, "Booking marked as no-show",
+			fmt.Sprintf("car %d of booking %d (%s) wasn't picked up in time, it is available again for the remaining days", car.ID, book.ID, period))
+	}
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/store"
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// price of the car on every rented day, adjusted when the company has dynamic pricing enabled
+func (s *BookingService) dailyPrices(ctx context.Context, car *types.Car, startDate, endDate time.Time) ([]*types.DailyPrice, error) {
+	rules, err := s.companyStore.GetDynamicPricing(ctx, car.CompanyID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	if rules != nil && !rules.Enabled {
+		rules = nil
+	}
+	return quote(ctx, s.carStore, s.bookingStore, car, rules, startDate, endDate)
+}
+
+// daily prices with given rules, base prices only when rules are nil
+func quote(ctx context.Context, cars store.CarStore, bookings store.BookingStore, car *types.Car, rules *types.DynamicPricing, startDate, endDate time.Time) ([]*types.DailyPrice, error) {
+	prices, err := cars.GetPrices(ctx, car.ID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	var utilisation []float64
+	if rules != nil {
+		utilisation, err = fleetUtilisation(ctx, cars, bookings, car.CompanyID, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	days := make([]*types.DailyPrice, rentalDays(startDate, endDate))
+	for i := range days {
+		day := startDate.AddDate(0, 0, i)
+		price := &types.DailyPrice{Date: day, BasePrice: priceOn(car, prices, day), Multiplier: 1}
+		if rules != nil {
+			price.Utilisation = utilisation[i]
+			price.Multiplier = priceMultiplier(rules, utilisation[i], leadDays(day))
+		}
+		price.Price = math.Round(price.BasePrice*price.Multiplier*100) / 100
+		days[i] = price
+	}
+	return days, nil
+}
+
+// share of the company cars that are booked on each day between start and end,
+// retired cars are not part of the fleet
+func fleetUtilisation(ctx context.Context, cars store.CarStore, bookings store.BookingStore, companyID int, startDate, endDate time.Time) ([]float64, error) {
+	fleet, err := cars.GetBatch(ctx, []*types.QueryFilter{
+		{Field: "company_id", Operator: "=", Value: companyID},
+		{Field: "status", Operator: "!=", Value: types.CarStatusRetired},
+	}, nil)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get company cars: %v", err))
+	}
+
+	utilisation := make([]float64, rentalDays(startDate, endDate))
+	if len(fleet) == 0 {
+		return utilisation, nil
+	}
+	carIDs := make([]int, len(fleet))
+	for i, car := range fleet {
+		carIDs[i] = car.ID
+	}
+
+	books, err := bookings.GetOverlappingCars(ctx, carIDs, startDate, endDate)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	for i := range utilisation {
+		day := startDate.AddDate(0, 0, i)
+		booked := make(map[int]bool)
+		for _, book := range books {
+			if !book.StartDate.After(day) && !book.EndDate.Before(day) {
+				booked[book.CarID] = true
+			}
+		}
+		utilisation[i] = float64(len(booked)) / float64(len(fleet))
+	}
+	return utilisation, nil
+}
+
+// days left until the day is rented, counted from today
+func leadDays(day time.Time) int {
+	return int(day.Sub(today()).Hours() / 24)
+}
+
+func priceMultiplier(rules *types.DynamicPricing, utilisation float64, leadDays int) float64 {
+	multiplier := 1 + rules.UtilisationFactor*(utilisation-rules.TargetUtilisation)
+	if leadDays < rules.LastMinuteDays {
+		multiplier += rules.LastMinuteFactor
+	}
+	if rules.EarlyBookingDays > 0 && leadDays >= rules.EarlyBookingDays {
+		multiplier += rules.EarlyBookingFactor
+	}
+	return math.Min(math.Max(multiplier, rules.Floor), rules.Ceiling)
+}
@@ -2,15 +2,23 @@ package services
 
 import (
 	"context"
+	"log"
+	"net/http"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/mwdev22/CarRental/internal/notify"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store/mock"
 	"github.com/mwdev22/CarRental/internal/types"
 	"github.com/mwdev22/CarRental/internal/utils"
 )
 
 func TestBookingService(t *testing.T) {
-	bookingService := NewBookingService(mock.NewBookingStore(), mock.NewCarRepository(), mock.NewUserRepository())
+	companyStore := mock.NewCompanyRepository()
+	carStore := mock.NewCarRepository(companyStore)
+	bookingService := NewBookingService(mock.NewBookingStore(carStore, companyStore), carStore, mock.NewUserRepository(), companyStore, notify.NewLogNotifier(log.Default()))
 
 	for i := 1; i <= 5; i++ {
 		err := bookingService.userStore.Create(context.Background(), &types.User{
@@ -25,6 +33,11 @@ func TestBookingService(t *testing.T) {
 		}
 	}
 
+	// cars below belong to the company of user 1
+	if err := bookingService.companyStore.Create(context.Background(), &types.Company{OwnerID: 1, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}); err != nil {
+		t.Fatalf("failed to create company: %v", err)
+	}
+
 	for i := 1; i <= 5; i++ {
 		err := bookingService.carStore.Create(context.Background(), &types.Car{
 			ID:             i,
@@ -160,4 +173,1079 @@ func TestBookingService(t *testing.T) {
 			t.Fatalf("expected booking deleted, got: %v", book)
 		}
 	})
+
+	t.Run("BookingSeries", func(t *testing.T) {
+		// two weeks of monday-friday, starting next week
+		monday := today().AddDate(0, 0, 7)
+		for monday.Weekday() != time.Monday {
+			monday = monday.AddDate(0, 0, 1)
+		}
+		lastFriday := monday.AddDate(0, 0, 11)
+		payload := &types.CreateBookingSeriesPayload{
+			CarID:     4,
+			StartDate: monday.Format(time.DateOnly),
+			EndDate:   lastFriday.Format(time.DateOnly),
+			Weekdays:  []int{1, 2, 3, 4, 5},
+		}
+
+		// wednesday of the first week is already taken
+		wednesday := monday.AddDate(0, 0, 2).Format(time.DateOnly)
+		if err := bookingService.Create(2, &types.CreateBookingPayload{CarID: 4, StartDate: wednesday, EndDate: wednesday}); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		if _, err := bookingService.CreateSeries(2, payload); err == nil {
+			t.Fatalf("expected conflict error, got nil")
+		}
+
+		payload.SkipConflicts = true
+		result, err := bookingService.CreateSeries(2, payload)
+		if err != nil {
+			t.Fatalf("failed to create series: %v", err)
+		}
+		if len(result.Bookings) != 9 {
+			t.Fatalf("expected 9 occurrences, got %v", len(result.Bookings))
+		}
+		if len(result.Conflicts) != 1 || result.Conflicts[0] != wednesday {
+			t.Fatalf("expected conflict on %s, got %v", wednesday, result.Conflicts)
+		}
+		seriesID := result.Series.ID
+
+		// the car can't take new occurrences once retired
+		car, err := bookingService.carStore.GetByID(context.Background(), 4)
+		if err != nil {
+			t.Fatalf("failed to get car: %v", err)
+		}
+		car.Status = types.CarStatusRetired
+		if err := bookingService.carStore.Update(context.Background(), car.ID, car); err != nil {
+			t.Fatalf("failed to retire car: %v", err)
+		}
+		if _, err := bookingService.UpdateSeries(seriesID, &types.UpdateBookingSeriesPayload{Weekdays: []int{1}}); err == nil {
+			t.Fatalf("expected error updating series of a retired car")
+		}
+		car.Status = types.CarStatusAvailable
+		if err := bookingService.carStore.Update(context.Background(), car.ID, car); err != nil {
+			t.Fatalf("failed to restore car: %v", err)
+		}
+
+		// mondays only from now on
+		result, err = bookingService.UpdateSeries(seriesID, &types.UpdateBookingSeriesPayload{Weekdays: []int{1}})
+		if err != nil {
+			t.Fatalf("failed to update series: %v", err)
+		}
+		active := make([]*types.Booking, 0)
+		for _, b := range result.Bookings {
+			if b.Status != types.BookingStatusCancelled {
+				active = append(active, b)
+			}
+		}
+		if len(active) != 2 {
+			t.Fatalf("expected 2 active occurrences, got %v", len(active))
+		}
+
+		if err := bookingService.CancelOccurrence(seriesID, active[0].ID); err != nil {
+			t.Fatalf("failed to cancel occurrence: %v", err)
+		}
+		if err := bookingService.CancelOccurrence(seriesID, active[0].ID); err == nil {
+			t.Fatalf("expected error cancelling already cancelled occurrence")
+		}
+
+		if err := bookingService.CancelSeries(seriesID); err != nil {
+			t.Fatalf("failed to cancel series: %v", err)
+		}
+		result, err = bookingService.GetSeries(seriesID)
+		if err != nil {
+			t.Fatalf("failed to get series: %v", err)
+		}
+		for _, b := range result.Bookings {
+			if b.Status != types.BookingStatusCancelled {
+				t.Fatalf("expected all occurrences cancelled, booking %d is %v", b.ID, b.Status)
+			}
+		}
+
+		// cancelled days are free again
+		if err := bookingService.Create(2, &types.CreateBookingPayload{CarID: 4, StartDate: payload.StartDate, EndDate: payload.StartDate}); err != nil {
+			t.Fatalf("expected car to be available after cancelling series: %v", err)
+		}
+
+		if _, err := bookingService.GetSeries(seriesID + 100); err == nil || err.(types.ApiError).StatusCode != http.StatusNotFound {
+			t.Errorf("expected not found for missing series, got %v", err)
+		}
+		if err := bookingService.CancelOccurrence(seriesID, 10000); err == nil || err.(types.ApiError).StatusCode != http.StatusNotFound {
+			t.Errorf("expected not found for missing occurrence, got %v", err)
+		}
+	})
+
+	t.Run("DriverEligibility", func(t *testing.T) {
+		carID := 5
+		err := bookingService.companyStore.SaveRentalRules(context.Background(), &types.RentalRules{
+			CompanyID:            1,
+			CarID:                &carID,
+			MinAge:               21,
+			MinYearsLicensed:     2,
+			YoungDriverAge:       25,
+			YoungDriverSurcharge: 10,
+			LicenseCategory:      "B",
+		})
+		if err != nil {
+			t.Fatalf("failed to save rental rules: %v", err)
+		}
+
+		start := today().AddDate(0, 1, 0)
+		profile := func(userID, age, yearsLicensed int, expiry time.Time) {
+			err := bookingService.userStore.SaveDriverProfile(context.Background(), &types.DriverProfile{
+				UserID:          userID,
+				DateOfBirth:     start.AddDate(-age, 0, -1),
+				LicenseNumber:   utils.GenerateUniqueString("license"),
+				LicenseCountry:  "PL",
+				LicenseCategory: "AM,B",
+				LicenseIssued:   start.AddDate(-yearsLicensed, 0, -1),
+				LicenseExpiry:   expiry,
+			})
+			if err != nil {
+				t.Fatalf("failed to save driver profile: %v", err)
+			}
+		}
+		profile(2, 19, 1, start.AddDate(5, 0, 0))
+		profile(4, 23, 3, start.AddDate(5, 0, 0))
+		profile(5, 40, 20, start.AddDate(0, 0, 2))
+
+		tests := []struct {
+			name        string
+			userID      int
+			expectError bool
+		}{
+			{name: "missing driver profile", userID: 3, expectError: true},
+			{name: "driver too young", userID: 2, expectError: true},
+			{name: "license expires during rental", userID: 5, expectError: true},
+			{name: "young driver", userID: 4, expectError: false},
+		}
+
+		payload := &types.CreateBookingPayload{
+			CarID:     carID,
+			StartDate: start.Format(time.DateOnly),
+			EndDate:   start.AddDate(0, 0, 4).Format(time.DateOnly),
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				err := bookingService.Create(tt.userID, payload)
+
+				if tt.expectError && err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				if !tt.expectError && err != nil {
+					t.Errorf("expected no error, got: %v", err)
+				}
+			})
+		}
+
+		books, err := bookingService.GetByUserID(4)
+		if err != nil || len(books) != 1 {
+			t.Fatalf("expected 1 booking, got %v, err: %v", len(books), err)
+		}
+		// 4 days of price and young driver surcharge
+		if books[0].Total != 440 {
+			t.Fatalf("expected total price 440, got %v", books[0].Total)
+		}
+	})
+
+	t.Run("AdditionalDrivers", func(t *testing.T) {
+		ctx := context.Background()
+		carID := 3
+		err := bookingService.companyStore.SaveRentalRules(ctx, &types.RentalRules{
+			CompanyID:           1,
+			CarID:               &carID,
+			MinAge:              21,
+			AdditionalDriverFee: 5,
+		})
+		if err != nil {
+			t.Fatalf("failed to save rental rules: %v", err)
+		}
+
+		start := today().AddDate(0, 2, 0)
+		driver := &types.User{Username: utils.GenerateUniqueString("driver"), Email: utils.GenerateUniqueString("driver@bllabal.com")}
+		if err := bookingService.userStore.Create(ctx, driver); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		err = bookingService.userStore.SaveDriverProfile(ctx, &types.DriverProfile{
+			UserID:        driver.ID,
+			DateOfBirth:   start.AddDate(-30, 0, 0),
+			LicenseIssued: start.AddDate(-10, 0, 0),
+			LicenseExpiry: start.AddDate(10, 0, 0),
+		})
+		if err != nil {
+			t.Fatalf("failed to save driver profile: %v", err)
+		}
+
+		payload := &types.CreateBookingPayload{
+			CarID:             carID,
+			StartDate:         start.Format(time.DateOnly),
+			EndDate:           start.AddDate(0, 0, 4).Format(time.DateOnly),
+			AdditionalDrivers: []*types.AdditionalDriverPayload{{UserID: 2}},
+		}
+		if err := bookingService.Create(4, payload); err == nil {
+			t.Fatalf("expected too young additional driver to be rejected")
+		}
+
+		invited := "invited@bllabal.com"
+		payload.AdditionalDrivers = []*types.AdditionalDriverPayload{{UserID: driver.ID}, {Email: invited}}
+		if err := bookingService.Create(4, payload); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		books, err := bookingService.GetByUserID(driver.ID)
+		if err != nil || len(books) != 1 {
+			t.Fatalf("expected booking visible for the driver, got %v, err: %v", len(books), err)
+		}
+		book, err := bookingService.GetByID(books[0].ID)
+		if err != nil {
+			t.Fatalf("failed to get booking: %v", err)
+		}
+		// 4 days of price and additional driver fee, invited driver is free until he accepts
+		if book.Total != 420 || len(book.Drivers) != 2 {
+			t.Fatalf("expected total 420 with 2 drivers, got %v with %v", book.Total, len(book.Drivers))
+		}
+
+		newUser := &types.User{Username: utils.GenerateUniqueString("invited"), Email: invited}
+		if err := bookingService.userStore.Create(ctx, newUser); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		if err := bookingService.AcceptDriverInvitation(book.ID, newUser.ID); err == nil {
+			t.Fatalf("expected error accepting invitation without driver profile")
+		}
+		err = bookingService.userStore.SaveDriverProfile(ctx, &types.DriverProfile{
+			UserID:        newUser.ID,
+			DateOfBirth:   start.AddDate(-25, 0, 0),
+			LicenseIssued: start.AddDate(-5, 0, 0),
+			LicenseExpiry: start.AddDate(5, 0, 0),
+		})
+		if err != nil {
+			t.Fatalf("failed to save driver profile: %v", err)
+		}
+		if err := bookingService.AcceptDriverInvitation(book.ID, newUser.ID); err != nil {
+			t.Fatalf("failed to accept invitation: %v", err)
+		}
+
+		if err := bookingService.RemoveDriver(book.ID, book.Drivers[0].ID); err != nil {
+			t.Fatalf("failed to remove driver: %v", err)
+		}
+		book, err = bookingService.GetByID(book.ID)
+		if err != nil {
+			t.Fatalf("failed to get booking: %v", err)
+		}
+		if book.Total != 420 || len(book.Drivers) != 1 || book.Drivers[0].Status != types.BookingDriverAccepted {
+			t.Fatalf("expected total 420 with 1 accepted driver, got %v with %v", book.Total, book.Drivers)
+		}
+	})
+
+	t.Run("NoShow", func(t *testing.T) {
+		ctx := context.Background()
+		carID := 4
+		err := bookingService.companyStore.SaveRentalRules(ctx, &types.RentalRules{
+			CompanyID:        1,
+			CarID:            &carID,
+			NoShowGraceHours: 2,
+			NoShowFee:        30,
+		})
+		if err != nil {
+			t.Fatalf("failed to save rental rules: %v", err)
+		}
+
+		start := time.Date(2030, 3, 1, 0, 0, 0, 0, time.UTC)
+		pickedUp := start.AddDate(0, 0, -5)
+		taken := &types.Booking{CarID: carID, UserID: 1, StartDate: pickedUp, EndDate: start.AddDate(0, 0, -1), Total: 500,
+			Status: types.BookingStatusPickedUp, PickedUp: &pickedUp}
+		missed := &types.Booking{CarID: carID, UserID: 1, StartDate: start, EndDate: start.AddDate(0, 0, 4), Total: 500}
+		for _, b := range []*types.Booking{taken, missed} {
+			if err := bookingService.bookingStore.Create(ctx, b); err != nil {
+				t.Fatalf("failed to create booking: %v", err)
+			}
+		}
+
+		if err := bookingService.ProcessNoShows(start.Add(time.Hour)); err != nil {
+			t.Fatalf("failed to process no-shows: %v", err)
+		}
+		if missed.Status != types.BookingStatusConfirmed {
+			t.Fatalf("expected booking within grace period to stay confirmed, got %v", missed.Status)
+		}
+
+		if err := bookingService.ProcessNoShows(start.Add(3 * time.Hour)); err != nil {
+			t.Fatalf("failed to process no-shows: %v", err)
+		}
+		// no-shows are saved as copies read together with the rules, the created bookings are stale
+		reload := func(b *types.Booking) *types.Booking {
+			saved, err := bookingService.bookingStore.GetByID(ctx, b.ID)
+			if err != nil {
+				t.Fatalf("failed to get booking %d: %v", b.ID, err)
+			}
+			return saved
+		}
+		missed = reload(missed)
+		if missed.Status != types.BookingStatusNoShow || missed.Total != 30 {
+			t.Fatalf("expected no-show with fee 30, got status %v with total %v", missed.Status, missed.Total)
+		}
+		if taken.Status != types.BookingStatusPickedUp || taken.Total != 500 {
+			t.Fatalf("expected picked up booking untouched, got status %v with total %v", taken.Status, taken.Total)
+		}
+		if !bookingService.bookingStore.CheckDateAvailability(ctx, carID, start.AddDate(0, 0, 1), missed.EndDate) {
+			t.Fatalf("expected car to be available after no-show")
+		}
+
+		if err := bookingService.PickUp(missed.ID, 1); err == nil {
+			t.Fatalf("expected error picking up no-show booking")
+		}
+
+		// without the grace period bookings are left alone, without the fee the total stays due,
+		// car 1 has no rules, car 5 has rules without the grace period
+		noFeeCarID := 3
+		noFeeRules, err := bookingService.companyStore.GetRentalRules(ctx, 1, &noFeeCarID)
+		if err != nil || noFeeRules == nil {
+			t.Fatalf("failed to get rental rules: %v", err)
+		}
+		noFeeRules.NoShowGraceHours, noFeeRules.NoShowFee = 2, 0
+		if err := bookingService.companyStore.SaveRentalRules(ctx, noFeeRules); err != nil {
+			t.Fatalf("failed to save rental rules: %v", err)
+		}
+		noRules := &types.Booking{CarID: 1, UserID: 1, StartDate: start, EndDate: start.AddDate(0, 0, 4), Total: 500}
+		noGrace := &types.Booking{CarID: 5, UserID: 1, StartDate: start, EndDate: start.AddDate(0, 0, 4), Total: 500}
+		noFee := &types.Booking{CarID: noFeeCarID, UserID: 1, StartDate: start, EndDate: start.AddDate(0, 0, 4), Total: 500}
+		for _, b := range []*types.Booking{noRules, noGrace, noFee} {
+			if err := bookingService.bookingStore.Create(ctx, b); err != nil {
+				t.Fatalf("failed to create booking: %v", err)
+			}
+		}
+		if err := bookingService.ProcessNoShows(start.AddDate(0, 0, 3)); err != nil {
+			t.Fatalf("failed to process no-shows: %v", err)
+		}
+		noRules, noGrace, noFee = reload(noRules), reload(noGrace), reload(noFee)
+		for _, b := range []*types.Booking{noRules, noGrace} {
+			if b.Status != types.BookingStatusConfirmed || b.Total != 500 {
+				t.Errorf("expected booking of car %d without grace period untouched, got status %v with total %v", b.CarID, b.Status, b.Total)
+			}
+		}
+		if noFee.Status != types.BookingStatusNoShow || noFee.Total != 500 {
+			t.Errorf("expected no-show keeping total 500, got status %v with total %v", noFee.Status, noFee.Total)
+		}
+	})
+
+	t.Run("Transfer", func(t *testing.T) {
+		payload := &types.CreateBookingPayload{
+			CarID:     2,
+			StartDate: "2031-05-01",
+			EndDate:   "2031-05-03",
+		}
+		if err := bookingService.Create(1, payload); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		books, err := bookingService.GetByUserID(1)
+		if err != nil {
+			t.Fatalf("failed to get bookings: %v", err)
+		}
+		var bookingID int
+		for _, b := range books {
+			if b.CarID == 2 && b.StartDate.Format(time.DateOnly) == payload.StartDate {
+				bookingID = b.ID
+			}
+		}
+
+		if _, err := bookingService.Transfer(bookingID, 2, &types.TransferBookingPayload{UserID: 3}); err == nil {
+			t.Fatalf("expected error transferring booking of another user")
+		}
+		if _, err := bookingService.Transfer(bookingID, 1, &types.TransferBookingPayload{UserID: 2}); err != nil {
+			t.Fatalf("failed to transfer booking: %v", err)
+		}
+		if err := bookingService.AcceptTransfer(bookingID, 3); err == nil {
+			t.Fatalf("expected error accepting transfer meant for another user")
+		}
+		// renter changes his mind and offers it again
+		if err := bookingService.DeclineTransfer(bookingID, 1); err != nil {
+			t.Fatalf("failed to withdraw transfer: %v", err)
+		}
+		if _, err := bookingService.Transfer(bookingID, 1, &types.TransferBookingPayload{UserID: 2}); err != nil {
+			t.Fatalf("failed to transfer booking: %v", err)
+		}
+
+		// new renter keeps the price the car was booked for
+		booked, err := bookingService.GetByID(bookingID)
+		if err != nil {
+			t.Fatalf("failed to get booking: %v", err)
+		}
+		total := booked.Total
+		car, err := bookingService.carStore.GetByID(context.Background(), payload.CarID)
+		if err != nil {
+			t.Fatalf("failed to get car: %v", err)
+		}
+		price := car.PricePerDay
+		if err := bookingService.carStore.UpdatePrice(context.Background(), car.ID, price*2); err != nil {
+			t.Fatalf("failed to update price: %v", err)
+		}
+		if err := bookingService.AcceptTransfer(bookingID, 2); err != nil {
+			t.Fatalf("failed to accept transfer: %v", err)
+		}
+		if err := bookingService.carStore.UpdatePrice(context.Background(), car.ID, price); err != nil {
+			t.Fatalf("failed to update price: %v", err)
+		}
+
+		book, err := bookingService.GetByID(bookingID)
+		if err != nil {
+			t.Fatalf("failed to get booking: %v", err)
+		}
+		if book.Total != total {
+			t.Fatalf("expected total %.2f booked before the price change, got %.2f", total, book.Total)
+		}
+		if book.UserID != 2 || book.CreatedBy == nil || *book.CreatedBy != 1 {
+			t.Fatalf("expected booking of user 2 created by user 1, got %v created by %v", book.UserID, book.CreatedBy)
+		}
+		if len(book.Transfers) != 2 || book.Transfers[0].Status != types.BookingTransferDeclined || book.Transfers[1].Status != types.BookingTransferAccepted {
+			t.Fatalf("expected declined and accepted transfer in history, got %v", book.Transfers)
+		}
+
+		// booking closed after the transfer was requested can't change hands
+		if _, err := bookingService.Transfer(bookingID, 2, &types.TransferBookingPayload{UserID: 3}); err != nil {
+			t.Fatalf("failed to transfer booking: %v", err)
+		}
+		book.Status = types.BookingStatusCancelled
+		if err := bookingService.bookingStore.Update(context.Background(), book); err != nil {
+			t.Fatalf("failed to cancel booking: %v", err)
+		}
+		if err := bookingService.AcceptTransfer(bookingID, 3); err == nil {
+			t.Fatalf("expected error accepting transfer of cancelled booking")
+		}
+		if err := bookingService.Delete(bookingID); err != nil {
+			t.Fatalf("failed to delete booking: %v", err)
+		}
+		transfers, err := bookingService.bookingStore.GetTransfers(context.Background(), bookingID)
+		if err != nil {
+			t.Fatalf("failed to get transfers: %v", err)
+		}
+		if len(transfers) != 3 || transfers[2].Status != types.BookingTransferDeclined {
+			t.Fatalf("expected pending transfer declined with the booking, got %v", transfers)
+		}
+	})
+
+	t.Run("CategoryBooking", func(t *testing.T) {
+		ctx := context.Background()
+		van := 4
+		cheap := &types.Car{Make: "Ford", Model: "Transit", Year: 2024, RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 80, CompanyID: 1, CategoryID: &van}
+		pricey := &types.Car{Make: "Mercedes", Model: "Vito", Year: 2024, RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 120, CompanyID: 1, CategoryID: &van}
+		for _, c := range []*types.Car{pricey, cheap} {
+			if err := bookingService.carStore.Create(ctx, c); err != nil {
+				t.Fatalf("failed to create car: %v", err)
+			}
+		}
+
+		payload := &types.CreateBookingPayload{CategoryID: van, StartDate: "2031-07-01", EndDate: "2031-07-02"}
+		for i := 0; i < 2; i++ {
+			if err := bookingService.Create(5, payload); err != nil {
+				t.Fatalf("failed to book by category: %v", err)
+			}
+		}
+		if err := bookingService.Create(5, payload); err == nil {
+			t.Fatalf("expected error when every car of the category is taken")
+		}
+
+		books, err := bookingService.GetByUserID(5)
+		if err != nil {
+			t.Fatalf("failed to get bookings: %v", err)
+		}
+		var first, second *types.Booking
+		for _, b := range books {
+			if b.CategoryID == nil {
+				continue
+			} else if b.CarID == cheap.ID {
+				first = b
+			} else if b.CarID == pricey.ID {
+				second = b
+			}
+		}
+		if first == nil || second == nil || first.Total != 80 {
+			t.Fatalf("expected cheaper van assigned first, got %v and %v", first, second)
+		}
+
+		if err := bookingService.AssignCar(first.ID, pricey.ID, 1); err == nil {
+			t.Fatalf("expected error assigning car that is already taken")
+		}
+		if err := bookingService.AssignCar(first.ID, 1, 1); err == nil {
+			t.Fatalf("expected error assigning car from another category")
+		}
+		second.Status = types.BookingStatusCancelled
+		if err := bookingService.bookingStore.Update(ctx, second); err != nil {
+			t.Fatalf("failed to cancel booking: %v", err)
+		}
+		if err := bookingService.AssignCar(first.ID, pricey.ID, 1); err != nil {
+			t.Fatalf("failed to assign car: %v", err)
+		}
+		if first.CarID != pricey.ID || first.Total != 80 {
+			t.Fatalf("expected swapped car with unchanged total, got car %v with total %v", first.CarID, first.Total)
+		}
+	})
+
+	t.Run("Maintenance", func(t *testing.T) {
+		// maintenance is scheduled on the car, bookings of the same stores are blocked by it
+		carService := NewCarService(bookingService.carStore, bookingService.bookingStore, bookingService.companyStore, bookingService.userStore, bookingService.notifier, nil)
+
+		if err := bookingService.Create(3, &types.CreateBookingPayload{CarID: 1, StartDate: "2032-03-10", EndDate: "2032-03-12"}); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		service := &types.CreateMaintenancePayload{Type: string(types.MaintenanceService), StartDate: "2032-03-11", EndDate: "2032-03-15"}
+		if _, err := carService.ScheduleMaintenance(1, 3, service); err == nil {
+			t.Fatalf("expected error scheduling maintenance by the renter")
+		}
+		scheduled, err := carService.ScheduleMaintenance(1, 1, service)
+		if err != nil {
+			t.Fatalf("failed to schedule maintenance: %v", err)
+		}
+		if len(scheduled.Conflicts) != 1 || scheduled.Conflicts[0].UserID != 3 {
+			t.Fatalf("expected the booking of user 3 as conflict, got %v", scheduled.Conflicts)
+		}
+
+		if _, err := carService.ScheduleMaintenance(1, 1, &types.CreateMaintenancePayload{
+			Type: string(types.MaintenanceInspection), StartDate: "2032-03-15", EndDate: "2032-03-16",
+		}); err == nil {
+			t.Fatalf("expected error for overlapping maintenance")
+		}
+
+		payload := &types.CreateBookingPayload{CarID: 1, StartDate: "2032-03-14", EndDate: "2032-03-20"}
+		if err := bookingService.Create(4, payload); err == nil {
+			t.Fatalf("expected error booking the car during maintenance")
+		}
+
+		if err := carService.CancelMaintenance(1, scheduled.Maintenance.ID, 1); err != nil {
+			t.Fatalf("failed to cancel maintenance: %v", err)
+		}
+		if err := bookingService.Create(4, payload); err != nil {
+			t.Fatalf("failed to book after maintenance was cancelled: %v", err)
+		}
+	})
+
+	t.Run("PriceChange", func(t *testing.T) {
+		ctx := context.Background()
+		price := &types.CarPrice{CarID: 2, PricePerDay: 150, EffectiveFrom: time.Date(2033, 1, 3, 0, 0, 0, 0, time.UTC)}
+		if err := bookingService.carStore.AddPrice(ctx, price); err != nil {
+			t.Fatalf("failed to add price: %v", err)
+		}
+
+		if err := bookingService.Create(5, &types.CreateBookingPayload{CarID: 2, StartDate: "2033-01-01", EndDate: "2033-01-04"}); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+		books, err := bookingService.GetByUserID(5)
+		if err != nil {
+			t.Fatalf("failed to get bookings: %v", err)
+		}
+		idx := slices.IndexFunc(books, func(b *types.Booking) bool { return b.CarID == 2 && b.StartDate.Year() == 2033 })
+		// two days for 100 before the change and one for 150, the return day isn't charged
+		if idx < 0 || books[idx].Total != 350 {
+			t.Fatalf("expected total 350 for booking over the price change, got %v", books)
+		}
+	})
+
+	t.Run("DynamicPricing", func(t *testing.T) {
+		ctx := context.Background()
+		carService := NewCarService(bookingService.carStore, bookingService.bookingStore, bookingService.companyStore, bookingService.userStore, bookingService.notifier, nil)
+		// company with a fleet of two cars, one of them booked for two days
+		company := &types.Company{OwnerID: 2, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}
+		if err := bookingService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		fleet := make([]*types.Car, 2)
+		for i := range fleet {
+			fleet[i] = &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100, CompanyID: company.ID}
+			if err := bookingService.carStore.Create(ctx, fleet[i]); err != nil {
+				t.Fatalf("failed to create car: %v", err)
+			}
+		}
+		start := time.Date(2034, 2, 1, 0, 0, 0, 0, time.UTC)
+		if err := bookingService.bookingStore.Create(ctx, &types.Booking{CarID: fleet[0].ID, UserID: 4, StartDate: start, EndDate: start.AddDate(0, 0, 1)}); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		if _, err := carService.SimulatePricing(fleet[1].ID, 2, 0); err == nil {
+			t.Errorf("expected an error for simulation without dynamic pricing")
+		}
+
+		pricing := &types.DynamicPricing{CompanyID: company.ID, TargetUtilisation: 0.2, UtilisationFactor: 1, Floor: 0.9, Ceiling: 1.25}
+		if err := bookingService.companyStore.SaveDynamicPricing(ctx, pricing); err != nil {
+			t.Fatalf("failed to save dynamic pricing: %v", err)
+		}
+		// not enabled, only simulated
+		days, err := bookingService.dailyPrices(ctx, fleet[1], start, start.AddDate(0, 0, 2))
+		if err != nil {
+			t.Fatalf("failed to get daily prices: %v", err)
+		}
+		if total := bookingTotal(days, 0); total != 300 {
+			t.Errorf("expected base total 300 while dynamic pricing is disabled, got %v", total)
+		}
+		if _, err := carService.SimulatePricing(fleet[1].ID, 3, 0); err == nil {
+			t.Errorf("expected an error for simulation by user of another company")
+		}
+		prices, err := carService.SimulatePricing(fleet[1].ID, 2, 0)
+		if err != nil {
+			t.Fatalf("failed to simulate pricing: %v", err)
+		}
+		if len(prices) != defaultSimulationDays || !prices[0].Date.Equal(today()) {
+			t.Errorf("expected %d days from today, got %d", defaultSimulationDays, len(prices))
+		}
+
+		pricing.Enabled = true
+		if err := bookingService.companyStore.SaveDynamicPricing(ctx, pricing); err != nil {
+			t.Fatalf("failed to save dynamic pricing: %v", err)
+		}
+		days, err = bookingService.dailyPrices(ctx, fleet[1], start, start.AddDate(0, 0, 2))
+		if err != nil {
+			t.Fatalf("failed to get daily prices: %v", err)
+		}
+		// half of the fleet booked would raise the price by 30% and empty fleet lower it by 20%,
+		// both are kept between the floor and the ceiling
+		for i, expected := range []float64{125, 125, 90} {
+			if days[i].Price != expected {
+				t.Errorf("expected price %v on %s, got %v (utilisation %v)", expected, days[i].Date.Format(time.DateOnly), days[i].Price, days[i].Utilisation)
+			}
+		}
+	})
+
+	t.Run("CarTransfer", func(t *testing.T) {
+		ctx := context.Background()
+		carService := NewCarService(bookingService.carStore, bookingService.bookingStore, bookingService.companyStore, bookingService.userStore, bookingService.notifier, nil)
+		companies := make([]*types.Company, 2)
+		for i := range companies {
+			companies[i] = &types.Company{OwnerID: i + 1, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}
+			if err := bookingService.companyStore.Create(ctx, companies[i]); err != nil {
+				t.Fatalf("failed to create company: %v", err)
+			}
+		}
+		car := &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100, CompanyID: companies[0].ID}
+		if err := bookingService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+		branches := make([]*types.Branch, 2)
+		for i := range branches {
+			branches[i] = &types.Branch{CompanyID: companies[i].ID, Name: "centre", Address: "main street 1", TimeZone: "UTC"}
+			if err := bookingService.companyStore.CreateBranch(ctx, branches[i]); err != nil {
+				t.Fatalf("failed to create branch: %v", err)
+			}
+		}
+		start := time.Date(2035, 3, 1, 0, 0, 0, 0, time.UTC)
+		pickup := "10:00"
+		book := &types.Booking{CarID: car.ID, UserID: 3, StartDate: start, EndDate: start.AddDate(0, 0, 2),
+			BranchID: &branches[0].ID, PickupTime: &pickup, ReturnTime: &pickup}
+		if err := bookingService.bookingStore.Create(ctx, book); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		payload := &types.TransferCarPayload{CompanyID: companies[1].ID}
+		if _, err := carService.TransferCar(car.ID, 2, payload); err == nil {
+			t.Errorf("expected an error for transfer by user not owning the car")
+		}
+		if _, err := carService.TransferCar(car.ID, 1, payload); err == nil {
+			t.Errorf("expected an error for transfer of car with future bookings")
+		}
+
+		payload.MigrateBookings = true
+		if _, err := carService.TransferCar(car.ID, 1, payload); err != nil {
+			t.Fatalf("failed to transfer car: %v", err)
+		}
+		if _, err := carService.TransferCar(car.ID, 1, payload); err == nil {
+			t.Errorf("expected an error for second pending transfer")
+		}
+		// only the receiving company accepts
+		if _, err := carService.AcceptCarTransfer(car.ID, 1); err == nil {
+			t.Errorf("expected an error for transfer accepted by the current owner")
+		}
+		transfer, err := carService.AcceptCarTransfer(car.ID, 2)
+		if err != nil {
+			t.Fatalf("failed to accept car transfer: %v", err)
+		}
+		if transfer.MigratedBookings != 1 {
+			t.Errorf("expected 1 migrated booking, got %d", transfer.MigratedBookings)
+		}
+
+		moved, err := bookingService.carStore.GetByID(ctx, car.ID)
+		if err != nil {
+			t.Fatalf("failed to get car: %v", err)
+		}
+		if moved.CompanyID != companies[1].ID {
+			t.Errorf("expected car of company %d, got %d", companies[1].ID, moved.CompanyID)
+		}
+		transfers, err := carService.GetCarTransfers(car.ID, 2)
+		if err != nil {
+			t.Fatalf("failed to get car transfers: %v", err)
+		}
+		if len(transfers) != 1 || transfers[0].Status != types.CarTransferAccepted {
+			t.Errorf("expected one accepted transfer, got %v", transfers)
+		}
+		// branch of the previous company can't be the pickup place anymore
+		if book.BranchID != nil || book.PickupTime != nil {
+			t.Errorf("expected migrated booking without branch, got branch %v", book.BranchID)
+		}
+
+		// moving between branches of the same company needs no approval
+		if _, err := carService.TransferCar(car.ID, 2, &types.TransferCarPayload{BranchID: branches[0].ID, MigrateBookings: true}); err == nil {
+			t.Errorf("expected an error for branch of another company")
+		}
+		airport := &types.Branch{CompanyID: companies[1].ID, Name: "airport", Address: "airport 1", TimeZone: "UTC"}
+		if err := bookingService.companyStore.CreateBranch(ctx, airport); err != nil {
+			t.Fatalf("failed to create branch: %v", err)
+		}
+		book.BranchID, book.PickupTime, book.ReturnTime = &airport.ID, &pickup, &pickup
+		if err := bookingService.bookingStore.Update(ctx, book); err != nil {
+			t.Fatalf("failed to update booking: %v", err)
+		}
+		transfer, err = carService.TransferCar(car.ID, 2, &types.TransferCarPayload{BranchID: branches[1].ID, MigrateBookings: true})
+		if err != nil {
+			t.Fatalf("failed to move car to branch: %v", err)
+		}
+		if transfer.Status != types.CarTransferAccepted || transfer.ToCompanyID != companies[1].ID || *transfer.ToBranchID != branches[1].ID {
+			t.Errorf("expected accepted move to branch %d, got %+v", branches[1].ID, transfer)
+		}
+		moved, err = bookingService.carStore.GetByID(ctx, car.ID)
+		if err != nil {
+			t.Fatalf("failed to get car: %v", err)
+		}
+		if moved.BranchID == nil || *moved.BranchID != branches[1].ID {
+			t.Errorf("expected car kept at branch %d, got %v", branches[1].ID, moved.BranchID)
+		}
+		if book.BranchID == nil || *book.BranchID != branches[1].ID || book.PickupTime == nil {
+			t.Errorf("expected migrated booking picked up at branch %d, got %v", branches[1].ID, book.BranchID)
+		}
+		if _, err := carService.TransferCar(car.ID, 2, &types.TransferCarPayload{BranchID: branches[1].ID, MigrateBookings: true}); err == nil {
+			t.Errorf("expected an error for move to the branch the car is already kept at")
+		}
+		transfers, err = carService.GetCarTransfers(car.ID, 2)
+		if err != nil || len(transfers) != 2 {
+			t.Fatalf("expected both transfers in history, got %v, err: %v", transfers, err)
+		}
+	})
+
+	t.Run("CarDocuments", func(t *testing.T) {
+		ctx := context.Background()
+		company := &types.Company{OwnerID: 1, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}
+		if err := bookingService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		expiry := time.Date(2036, 6, 10, 0, 0, 0, 0, time.UTC)
+		car := &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100,
+			CompanyID: company.ID, Status: types.CarStatusAvailable, CarDocuments: types.CarDocuments{InsuranceExpiry: &expiry}}
+		if err := bookingService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		// valid through the expiry day
+		if err := bookingService.Create(2, &types.CreateBookingPayload{CarID: car.ID, StartDate: "2036-06-08", EndDate: "2036-06-11"}); err == nil {
+			t.Errorf("expected an error for rental past the insurance expiry")
+		}
+		if err := bookingService.Create(2, &types.CreateBookingPayload{CarID: car.ID, StartDate: "2036-06-08", EndDate: "2036-06-10"}); err != nil {
+			t.Errorf("expected no error for rental until the expiry, got: %v", err)
+		}
+
+		for _, tt := range []struct {
+			now    time.Time
+			status types.CarStatus
+		}{
+			{now: expiry, status: types.CarStatusAvailable},
+			{now: expiry.AddDate(0, 0, 1), status: types.CarStatusInService},
+		} {
+			if err := bookingService.CheckCarDocuments(tt.now); err != nil {
+				t.Fatalf("failed to check car documents: %v", err)
+			}
+			checked, err := bookingService.carStore.GetByID(ctx, car.ID)
+			if err != nil {
+				t.Fatalf("failed to get car: %v", err)
+			}
+			if checked.Status != tt.status {
+				t.Errorf("expected status %s on %s, got %s", tt.status, tt.now.Format(time.DateOnly), checked.Status)
+			}
+		}
+	})
+
+	t.Run("Reviews", func(t *testing.T) {
+		ctx := context.Background()
+		companyService := NewCompanyService(bookingService.companyStore, bookingService.carStore, bookingService.bookingStore, bookingService.userStore, bookingService.notifier, nil)
+		company := &types.Company{OwnerID: 3, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}
+		if err := bookingService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		car := &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100,
+			CompanyID: company.ID, Status: types.CarStatusAvailable}
+		if err := bookingService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		start := today().AddDate(0, 0, -5)
+		books := []*types.Booking{
+			{CarID: car.ID, UserID: 2, StartDate: start, EndDate: start.AddDate(0, 0, 2), Status: types.BookingStatusPickedUp},
+			{CarID: car.ID, UserID: 4, StartDate: start.AddDate(0, 0, 3), EndDate: start.AddDate(0, 0, 4), Status: types.BookingStatusPickedUp},
+			{CarID: car.ID, UserID: 2, StartDate: today().AddDate(0, 0, 10), EndDate: today().AddDate(0, 0, 12), Status: types.BookingStatusConfirmed},
+		}
+		for _, book := range books {
+			if err := bookingService.bookingStore.Create(ctx, book); err != nil {
+				t.Fatalf("failed to create booking: %v", err)
+			}
+		}
+
+		if _, err := companyService.CreateReview(books[2].ID, 2, &types.CreateReviewPayload{CarRating: 5, CompanyRating: 5}); err == nil {
+			t.Errorf("expected an error for review of a booking that isn't completed")
+		}
+		if _, err := companyService.CreateReview(books[0].ID, 4, &types.CreateReviewPayload{CarRating: 5, CompanyRating: 5}); err == nil {
+			t.Errorf("expected an error for review by someone else than the renter")
+		}
+
+		first, err := companyService.CreateReview(books[0].ID, 2, &types.CreateReviewPayload{CarRating: 5, CompanyRating: 4, Comment: "great"})
+		if err != nil {
+			t.Fatalf("failed to create review: %v", err)
+		}
+		if _, err := companyService.CreateReview(books[0].ID, 2, &types.CreateReviewPayload{CarRating: 1, CompanyRating: 1}); err == nil {
+			t.Errorf("expected an error for second review of the booking")
+		}
+		second, err := companyService.CreateReview(books[1].ID, 4, &types.CreateReviewPayload{CarRating: 2, CompanyRating: 1, Comment: "dirty"})
+		if err != nil {
+			t.Fatalf("failed to create review: %v", err)
+		}
+
+		checkRating := func(want types.Rating) {
+			t.Helper()
+			rated, err := bookingService.carStore.GetByID(ctx, car.ID)
+			if err != nil {
+				t.Fatalf("failed to get car: %v", err)
+			}
+			if rated.Rating != want {
+				t.Errorf("expected car rating %+v, got %+v", want, rated.Rating)
+			}
+		}
+		checkRating(types.Rating{Rating: 3.5, RatingCount: 2})
+
+		if _, err := companyService.ReplyToReview(second.ID, 2, &types.ReviewReplyPayload{Reply: "sorry"}); err == nil {
+			t.Errorf("expected an error for reply by someone else than the company owner")
+		}
+		if _, err := companyService.ReplyToReview(second.ID, 3, &types.ReviewReplyPayload{Reply: "sorry"}); err != nil {
+			t.Errorf("failed to reply to review: %v", err)
+		}
+
+		if err := companyService.ReportReview(second.ID, &types.ReportReviewPayload{Reason: "not true"}); err != nil {
+			t.Fatalf("failed to report review: %v", err)
+		}
+		reported, err := companyService.GetReportedReviews(nil)
+		if err != nil {
+			t.Fatalf("failed to get reported reviews: %v", err)
+		}
+		if len(reported) != 1 || reported[0].ID != second.ID {
+			t.Errorf("expected review %d in the moderation queue, got %v", second.ID, reported)
+		}
+
+		if _, err := companyService.ModerateReview(second.ID, &types.ModerateReviewPayload{Status: types.ReviewStatusHidden}); err != nil {
+			t.Fatalf("failed to moderate review: %v", err)
+		}
+		checkRating(types.Rating{Rating: 5, RatingCount: 1})
+
+		reviews, err := companyService.GetCompanyReviews(company.ID, nil)
+		if err != nil {
+			t.Fatalf("failed to get company reviews: %v", err)
+		}
+		if len(reviews) != 1 || reviews[0].ID != first.ID {
+			t.Errorf("expected only review %d to be listed, got %v", first.ID, reviews)
+		}
+	})
+
+	t.Run("Favorites", func(t *testing.T) {
+		ctx := context.Background()
+		userService := NewUserService(bookingService.userStore, bookingService.carStore, bookingService.companyStore, bookingService.notifier)
+		company := &types.Company{OwnerID: 3, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}
+		if err := bookingService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		newCar := func(carMake string, price float64) *types.Car {
+			t.Helper()
+			car := &types.Car{Make: carMake, Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: price,
+				CompanyID: company.ID, Status: types.CarStatusAvailable}
+			if err := bookingService.carStore.Create(ctx, car); err != nil {
+				t.Fatalf("failed to create car: %v", err)
+			}
+			return car
+		}
+		carMake := utils.GenerateUniqueString("make")
+		old := newCar(carMake, 100)
+
+		if _, err := userService.SaveSearch(4, &types.SavedSearchPayload{Name: "status", Query: "status=available"}); err == nil {
+			t.Errorf("expected an error for saved search by status")
+		}
+		if _, err := userService.SaveSearch(4, &types.SavedSearchPayload{Name: "unknown", Query: "engine=v8"}); err == nil {
+			t.Errorf("expected an error for saved search by unknown field")
+		}
+		search, err := userService.SaveSearch(4, &types.SavedSearchPayload{Name: "cheap", Query: "?make=" + carMake + "&price_per_day[lte]=150", Notify: true})
+		if err != nil {
+			t.Fatalf("failed to save search: %v", err)
+		}
+		if search.LastCarID != old.ID {
+			t.Errorf("expected existing car %d to be known already, got %d", old.ID, search.LastCarID)
+		}
+
+		newCar(carMake, 200)
+		cheap := newCar(carMake, 120)
+		if err := userService.NotifySavedSearches(); err != nil {
+			t.Fatalf("failed to notify saved searches: %v", err)
+		}
+		if search.LastCarID != cheap.ID {
+			t.Errorf("expected new matching car %d to be notified, last known is %d", cheap.ID, search.LastCarID)
+		}
+
+		favorite, err := userService.AddFavorite(old.ID, 4, &types.FavoriteCarPayload{Notify: true})
+		if err != nil {
+			t.Fatalf("failed to add favorite: %v", err)
+		}
+		for _, price := range []float64{80, 90} {
+			old.PricePerDay = price
+			if err := bookingService.carStore.Update(ctx, old.ID, old); err != nil {
+				t.Fatalf("failed to update car: %v", err)
+			}
+			if err := userService.NotifyPriceDrops(); err != nil {
+				t.Fatalf("failed to notify price drops: %v", err)
+			}
+			if favorite.Price != price {
+				t.Errorf("expected favorite price %.2f, got %.2f", price, favorite.Price)
+			}
+		}
+
+		favorites, err := userService.GetFavorites(4)
+		if err != nil {
+			t.Fatalf("failed to get favorites: %v", err)
+		}
+		if len(favorites) != 1 || favorites[0].Car == nil || favorites[0].Car.ID != old.ID {
+			t.Errorf("expected car %d as the only favorite, got %v", old.ID, favorites)
+		}
+		if err := userService.RemoveFavorite(old.ID, 4); err != nil {
+			t.Errorf("failed to remove favorite: %v", err)
+		}
+		if err := userService.RemoveFavorite(old.ID, 4); err == nil {
+			t.Errorf("expected an error for removing car that isn't a favorite")
+		}
+	})
+
+	t.Run("Branches", func(t *testing.T) {
+		ctx := context.Background()
+		companyService := NewCompanyService(bookingService.companyStore, bookingService.carStore, bookingService.bookingStore, bookingService.userStore, bookingService.notifier, storage.NewLocalStorage(t.TempDir(), "/uploads/"))
+		company := &types.Company{OwnerID: 3, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusVerified}
+		if err := bookingService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		car := &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100,
+			CompanyID: company.ID, Status: types.CarStatusAvailable}
+		if err := bookingService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		payload := &types.BranchPayload{Name: "airport", Address: "airport 1", TimeZone: "Europe/Warsaw",
+			Closures: []*types.BranchClosurePayload{{Date: "2036-06-10", Reason: "holiday"}}}
+		for day := 1; day <= 5; day++ {
+			payload.OpeningHours = append(payload.OpeningHours, &types.OpeningHoursPayload{Weekday: day, Opens: "08:00", Closes: "18:00"})
+		}
+		if _, err := companyService.CreateBranch(company.ID, 2, payload); err == nil {
+			t.Errorf("expected an error for branch created by someone else than the owner")
+		}
+		branch, err := companyService.CreateBranch(company.ID, 3, payload)
+		if err != nil {
+			t.Fatalf("failed to create branch: %v", err)
+		}
+		other, err := companyService.CreateBranch(1, 1, payload)
+		if err != nil {
+			t.Fatalf("failed to create branch: %v", err)
+		}
+
+		book := func(branchID int, start, pickup, end, ret string) error {
+			return bookingService.Create(2, &types.CreateBookingPayload{CarID: car.ID, StartDate: start, EndDate: end,
+				BranchID: branchID, PickupTime: pickup, ReturnTime: ret})
+		}
+		for _, tt := range []struct {
+			name                    string
+			branchID                int
+			start, pickup, end, ret string
+		}{
+			{"branch of another company", other.ID, "2036-06-09", "09:00", "2036-06-11", "17:00"},
+			{"pickup before opening", branch.ID, "2036-06-09", "07:00", "2036-06-11", "17:00"},
+			{"return on a closure", branch.ID, "2036-06-09", "09:00", "2036-06-10", "17:00"},
+			{"return before pickup", branch.ID, "2036-06-09", "12:00", "2036-06-09", "10:00"},
+			{"return on saturday", branch.ID, "2036-06-12", "09:00", "2036-06-14", "10:00"},
+		} {
+			if err := book(tt.branchID, tt.start, tt.pickup, tt.end, tt.ret); err == nil {
+				t.Errorf("%s: expected an error", tt.name)
+			}
+		}
+		if err := book(branch.ID, "2036-06-09", "09:00", "2036-06-11", "18:00"); err != nil {
+			t.Errorf("expected no error for booking within opening hours, got: %v", err)
+		}
+
+		fee := 25.0
+		payload.OutOfHoursFee = &fee
+		if _, err := companyService.UpdateBranch(company.ID, branch.ID, 3, payload); err != nil {
+			t.Fatalf("failed to update branch: %v", err)
+		}
+		if err := book(branch.ID, "2036-06-12", "19:00", "2036-06-14", "10:00"); err != nil {
+			t.Fatalf("expected no error for booking out of hours, got: %v", err)
+		}
+
+		books, err := bookingService.bookingStore.GetOverlapping(ctx, car.ID, time.Date(2036, 6, 12, 0, 0, 0, 0, time.UTC), time.Date(2036, 6, 14, 0, 0, 0, 0, time.UTC))
+		if err != nil || len(books) != 1 {
+			t.Fatalf("failed to get the booking: %v", err)
+		}
+		if books[0].Total != 250 {
+			t.Errorf("expected total 250 with two out of hours fees, got %.2f", books[0].Total)
+		}
+
+		// new renter picks up and returns at the same hours, so he pays the same fees
+		if _, err := bookingService.Transfer(books[0].ID, 2, &types.TransferBookingPayload{UserID: 4}); err != nil {
+			t.Fatalf("failed to transfer booking: %v", err)
+		}
+		if err := bookingService.AcceptTransfer(books[0].ID, 4); err != nil {
+			t.Fatalf("failed to accept transfer: %v", err)
+		}
+		transferred, err := bookingService.GetByID(books[0].ID)
+		if err != nil {
+			t.Fatalf("failed to get booking: %v", err)
+		}
+		if transferred.Total != 250 {
+			t.Errorf("expected total 250 after the transfer, got %.2f", transferred.Total)
+		}
+	})
+
+	t.Run("UnverifiedCompany", func(t *testing.T) {
+		ctx := context.Background()
+		company := &types.Company{OwnerID: 2, Name: utils.GenerateUniqueString("company"), Status: types.CompanyStatusPending}
+		if err := bookingService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		car := &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100,
+			CompanyID: company.ID, Status: types.CarStatusAvailable}
+		if err := bookingService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		if err := bookingService.Create(1, &types.CreateBookingPayload{CarID: car.ID, StartDate: "2025-03-01", EndDate: "2025-03-02"}); err == nil {
+			t.Errorf("expected an error booking car of pending company")
+		}
+		_, err := bookingService.CreateSeries(1, &types.CreateBookingSeriesPayload{CarID: car.ID, StartDate: "2025-03-01", EndDate: "2025-03-10", Weekdays: []int{1}})
+		if err == nil {
+			t.Errorf("expected an error booking series of car of pending company")
+		}
+	})
+
+	t.Run("BilledDays", func(t *testing.T) {
+		ctx := context.Background()
+		car := &types.Car{Make: "make", Model: "model", RegistrationNo: utils.GenerateUniqueString("regno"), PricePerDay: 100,
+			CompanyID: 1, Status: types.CarStatusAvailable}
+		if err := bookingService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		// return day isn't charged, unlike the single day occurrences of a series
+		if err := bookingService.Create(1, &types.CreateBookingPayload{CarID: car.ID, StartDate: "2024-01-01", EndDate: "2024-01-03"}); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+		books, err := bookingService.bookingStore.GetOverlapping(ctx, car.ID, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC))
+		if err != nil || len(books) != 1 {
+			t.Fatalf("failed to get the booking: %v", err)
+		}
+		if books[0].Total != 200 {
+			t.Errorf("expected total 200 for 2 days, got %.2f", books[0].Total)
+		}
+	})
 }
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func pendingTransfer(book *types.Booking) *types.BookingTransfer {
+	for _, t := range book.Transfers {
+		if t.Status == types.BookingTransferPending {
+			return t
+		}
+	}
+	return nil
+}
+
+// renter offers the booking to another user, nothing changes until he accepts
+func (s *BookingService) Transfer(bookingId int, userId int, payload *types.TransferBookingPayload) (*types.BookingTransfer, error) {
+	ctx := context.Background()
+
+	book, err := s.GetByID(bookingId)
+	if err != nil {
+		return nil, err
+	}
+
+	if book.UserID != userId {
+		return nil, types.Unauthorized("only the renter can transfer the booking")
+	}
+	if book.Status != types.BookingStatusConfirmed {
+		return nil, types.BadRequest("only confirmed bookings can be transferred")
+	}
+	if pendingTransfer(book) != nil {
+		return nil, types.Conflict("booking already has a pending transfer")
+	}
+
+	var target *types.User
+	if payload.UserID != 0 {
+		target, err = s.userStore.GetByID(ctx, payload.UserID)
+	} else {
+		target, err = s.userStore.GetByEmail(ctx, payload.Email)
+	}
+	if err != nil || target == nil {
+		return nil, types.NotFound("user to transfer the booking to")
+	}
+	if target.ID == book.UserID {
+		return nil, types.BadRequest("booking cannot be transferred to its renter")
+	}
+
+	transfer := &types.BookingTransfer{
+		BookingID:  book.ID,
+		FromUserID: book.UserID,
+		ToUserID:   target.ID,
+		Status:     types.BookingTransferPending,
+	}
+	if err := s.bookingStore.CreateTransfer(ctx, transfer); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	_ = s.notifier.Notify(ctx, target.Email, "Booking transfer",
+		fmt.Sprintf("booking %d (%s - %s) is being transferred to you, accept it to become the renter",
+			book.ID, book.StartDate.Format(time.DateOnly), book.EndDate.Format(time.DateOnly)))
+
+	return transfer, nil
+}
+
+// new renter has to pass the rental rules, the booked car price stays and only his
+// driver surcharge and the fees of remaining drivers replace the ones of the previous renter
+func (s *BookingService) AcceptTransfer(bookingId int, userId int) error {
+	ctx := context.Background()
+
+	book, err := s.GetByID(bookingId)
+	if err != nil {
+		return err
+	}
+
+	transfer := pendingTransfer(book)
+	if transfer == nil || transfer.ToUserID != userId {
+		return types.NotFound("pending transfer for the user")
+	}
+	// booking could have been picked up or closed since the transfer was requested
+	if book.Status != types.BookingStatusConfirmed {
+		return types.BadRequest("only confirmed bookings can be transferred")
+	}
+
+	user, err := s.userStore.GetByID(ctx, userId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	car, err := s.carStore.GetByID(ctx, book.CarID)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	rules, err := s.rentalRules(ctx, car)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	surcharge, errors, err := s.evaluateDriver(ctx, rules, userId, book.StartDate, book.EndDate)
+	if err != nil {
+		return err
+	} else if len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+	previous, err := s.renterSurcharge(ctx, rules, book.UserID, book.StartDate)
+	if err != nil {
+		return err
+	}
+
+	// renter can't be his own additional driver
+	for _, d := range book.Drivers {
+		if (d.UserID != nil && *d.UserID == userId) || strings.EqualFold(d.Email, user.Email) {
+			if err := s.bookingStore.DeleteDriver(ctx, d.ID); err != nil {
+				return types.DatabaseError(err)
+			}
+		}
+	}
+
+	drivers, err := s.bookingStore.GetDrivers(ctx, book.ID)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	billed := float64(billedDays(book.StartDate, book.EndDate))
+	book.Total += billed*(surcharge-previous) + driverFees(drivers) - driverFees(book.Drivers)
+	book.UserID = userId
+	if err := s.bookingStore.Update(ctx, book); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	if err := s.resolveTransfer(ctx, transfer, types.BookingTransferAccepted); err != nil {
+		return err
+	}
+
+	if from, err := s.userStore.GetByID(ctx, transfer.FromUserID); err == nil && from != nil {
+		_ = s.notifier.Notify(ctx, from.Email, "Booking transfer accepted",
+			fmt.Sprintf("booking %d was accepted by %s, you are no longer its renter", book.ID, user.Username))
+	}
+
+	return nil
+}
+
+// daily surcharge the renter paid when booking, his eligibility was already checked then
+func (s *BookingService) renterSurcharge(ctx context.Context, rules *types.RentalRules, userId int, startDate time.Time) (float64, error) {
+	if rules == nil {
+		return 0, nil
+	}
+	profile, err := s.userStore.GetDriverProfile(ctx, userId)
+	if err != nil {
+		return 0, types.DatabaseError(err)
+	}
+	if profile == nil {
+		return 0, nil
+	}
+	return driverSurcharge(profile, rules, startDate), nil
+}
+
+// declined by the recipient or withdrawn by the renter
+func (s *BookingService) DeclineTransfer(bookingId int, userId int) error {
+	book, err := s.GetByID(bookingId)
+	if err != nil {
+		return err
+	}
+
+	transfer := pendingTransfer(book)
+	if transfer == nil || (transfer.ToUserID != userId && transfer.FromUserID != userId) {
+		return types.NotFound("pending transfer for the user")
+	}
+
+	return s.resolveTransfer(context.Background(), transfer, types.BookingTransferDeclined)
+}
+
+// pending transfer can't be accepted once the booking is picked up, closed or deleted
+func (s *BookingService) declinePendingTransfer(ctx context.Context, bookingId int) error {
+	transfers, err := s.bookingStore.GetTransfers(ctx, bookingId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	for _, t := range transfers {
+		if t.Status == types.BookingTransferPending {
+			return s.resolveTransfer(ctx, t, types.BookingTransferDeclined)
+		}
+	}
+	return nil
+}
+
+func (s *BookingService) resolveTransfer(ctx context.Context, transfer *types.BookingTransfer, status types.BookingTransferStatus) error {
+	now := time.Now().UTC()
+	transfer.Status = status
+	transfer.Resolved = &now
+	if err := s.bookingStore.UpdateTransfer(ctx, transfer); err != nil {
+		return types.DatabaseError(err)
+	}
+	return nil
+}
@@ -3,23 +3,73 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/mwdev22/CarRental/internal/notify"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store"
 	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
 )
 
 type CarService struct {
-	carStore store.CarStore
+	carStore     store.CarStore
+	bookingStore store.BookingStore
+	companyStore store.CompanyStore
+	userStore    store.UserStore
+	notifier     notify.Notifier
+	files        storage.Storage
 }
 
-func NewCarService(carStore store.CarStore) *CarService {
+func NewCarService(carStore store.CarStore, bookingStore store.BookingStore, companyStore store.CompanyStore, userStore store.UserStore,
+	notifier notify.Notifier, files storage.Storage) *CarService {
 	return &CarService{
-		carStore: carStore,
+		carStore:     carStore,
+		bookingStore: bookingStore,
+		companyStore: companyStore,
+		userStore:    userStore,
+		notifier:     notifier,
+		files:        files,
 	}
 }
 
-func (s *CarService) CreateCar(payload *types.CreateCarPayload) error {
+// car of a company the user works for with the permission
+func (s *CarService) companyCar(ctx context.Context, carId int, userId int, perm types.CompanyPermission) (*types.Car, error) {
+	car, err := s.carStore.GetByID(ctx, carId)
+	if err != nil {
+		return nil, types.NotFound(fmt.Sprintf("car %d", carId))
+	}
+	if err := authorizeMember(ctx, s.companyStore, s.userStore, car.CompanyID, userId, perm); err != nil {
+		return nil, err
+	}
+	return car, nil
+}
+
+// statuses the car can move to from the current one, retired is final
+var carStatusTransitions = map[types.CarStatus][]types.CarStatus{
+	types.CarStatusAvailable: {types.CarStatusInService, types.CarStatusRetired},
+	types.CarStatusInService: {types.CarStatusAvailable, types.CarStatusRetired},
+}
+
+func (s *CarService) CreateCar(userId int, payload *types.CreateCarPayload) error {
+	if err := authorizeMember(context.Background(), s.companyStore, s.userStore, payload.CompanyID, userId, types.CompanyPermFleet); err != nil {
+		return err
+	}
+	if err := s.checkCategory(payload.CategoryID); err != nil {
+		return err
+	}
+
+	car := newCar(payload)
+	if err := s.carStore.Create(context.Background(), car); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to create car: %v", err))
+	}
+
+	return s.recordPrice(context.Background(), car)
+}
+
+func newCar(payload *types.CreateCarPayload) *types.Car {
 	car := &types.Car{
 		Make:           payload.Make,
 		Model:          payload.Model,
@@ -28,12 +78,47 @@ func (s *CarService) CreateCar(payload *types.CreateCarPayload) error {
 		RegistrationNo: payload.RegistrationNo,
 		PricePerDay:    payload.PricePerDay,
 		CompanyID:      payload.CompanyID,
+		CategoryID:     payload.CategoryID,
+		Status:         types.CarStatusAvailable,
+
+		ServiceIntervalKm:     payload.ServiceIntervalKm,
+		ServiceIntervalMonths: payload.ServiceIntervalMonths,
+
+		Latitude:  payload.Latitude,
+		Longitude: payload.Longitude,
+		VIN:       normalizeVIN(payload.VIN),
 	}
-	if err := s.carStore.Create(context.Background(), car); err != nil {
-		return types.DatabaseError(fmt.Errorf("failed to create car: %v", err))
+	if payload.Features != nil {
+		car.CarFeatures = types.CarFeatures(*payload.Features)
+	}
+	if payload.Documents != nil {
+		car.CarDocuments = carDocuments(payload.Documents)
 	}
+	return car
+}
 
-	return nil
+func normalizeVIN(vin string) *string {
+	if vin == "" {
+		return nil
+	}
+	vin = strings.ToUpper(vin)
+	return &vin
+}
+
+// dates are already validated, empty ones aren't tracked
+func carDocuments(payload *types.CarDocumentsPayload) types.CarDocuments {
+	expiry := func(date string) *time.Time {
+		if date == "" {
+			return nil
+		}
+		t, _ := time.Parse(time.DateOnly, date)
+		return &t
+	}
+	return types.CarDocuments{
+		RegistrationExpiry:   expiry(payload.RegistrationExpiry),
+		InsuranceExpiry:      expiry(payload.InsuranceExpiry),
+		RoadworthinessExpiry: expiry(payload.RoadworthinessExpiry),
+	}
 }
 
 func (s *CarService) GetByID(id int) (*types.Car, error) {
@@ -41,13 +126,18 @@ func (s *CarService) GetByID(id int) (*types.Car, error) {
 	if err != nil {
 		return nil, types.DatabaseError(fmt.Errorf("failed to get car by id: %v", err))
 	}
+
+	car.Position, err = s.carStore.GetLastPosition(context.Background(), id)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get car position: %v", err))
+	}
 	return car, nil
 }
 
-func (s *CarService) UpdateCar(id int, payload *types.UpdateCarPayload) error {
-	car, err := s.carStore.GetByID(context.Background(), id)
+func (s *CarService) UpdateCar(id int, userId int, payload *types.UpdateCarPayload) error {
+	car, err := s.companyCar(context.Background(), id, userId, types.CompanyPermFleet)
 	if err != nil {
-		return types.DatabaseError(fmt.Errorf("failed to get car by id: %v", err))
+		return err
 	}
 
 	car.Make = payload.Make
@@ -55,27 +145,168 @@ func (s *CarService) UpdateCar(id int, payload *types.UpdateCarPayload) error {
 	car.Year = payload.Year
 	car.Color = payload.Color
 	car.RegistrationNo = payload.RegistrationNo
-	car.PricePerDay = payload.PricePerDay
+	// changed right away, the old price stays in the history
+	priceChanged := payload.PricePerDay != 0 && payload.PricePerDay != car.PricePerDay
+	if priceChanged {
+		car.PricePerDay = payload.PricePerDay
+	}
+	if payload.CategoryID != nil {
+		if err := s.checkCategory(payload.CategoryID); err != nil {
+			return err
+		}
+		car.CategoryID = payload.CategoryID
+	}
+	if payload.Features != nil {
+		car.CarFeatures = types.CarFeatures(*payload.Features)
+	}
+	if payload.VIN != "" {
+		car.VIN = normalizeVIN(payload.VIN)
+	}
+	if payload.Documents != nil {
+		car.CarDocuments = carDocuments(payload.Documents)
+	}
+	if payload.ServiceIntervalKm != 0 {
+		car.ServiceIntervalKm = payload.ServiceIntervalKm
+	}
+	if payload.ServiceIntervalMonths != 0 {
+		car.ServiceIntervalMonths = payload.ServiceIntervalMonths
+	}
+	if payload.Latitude != nil {
+		car.Latitude, car.Longitude = payload.Latitude, payload.Longitude
+	}
 	car.Updated = time.Now()
 
 	if err := s.carStore.Update(context.Background(), id, car); err != nil {
 		return types.DatabaseError(fmt.Errorf("failed to update car: %v", err))
 	}
 
+	if priceChanged {
+		return s.recordPrice(context.Background(), car)
+	}
 	return nil
 }
 
-func (s *CarService) Delete(id int) error {
+// cars that were ever booked are retired instead of removed so the bookings stay in the history
+func (s *CarService) Delete(id int, userId int) (retired bool, err error) {
+	if _, err := s.companyCar(context.Background(), id, userId, types.CompanyPermFleet); err != nil {
+		return false, err
+	}
+
+	booked, err := s.bookingStore.CountByCarID(context.Background(), id)
+	if err != nil {
+		return false, types.DatabaseError(err)
+	}
+	if booked > 0 {
+		return true, s.setStatus(id, types.CarStatusRetired)
+	}
+
 	if err := s.carStore.Delete(context.Background(), id); err != nil {
-		return types.DatabaseError(fmt.Errorf("failed to delete car: %v", err))
+		return false, types.DatabaseError(fmt.Errorf("failed to delete car: %v", err))
+	}
+	return false, nil
+}
+
+func (s *CarService) SetStatus(id int, userId int, status types.CarStatus) error {
+	if _, err := s.companyCar(context.Background(), id, userId, types.CompanyPermMaintenance); err != nil {
+		return err
+	}
+	return s.setStatus(id, status)
+}
+
+func (s *CarService) setStatus(id int, status types.CarStatus) error {
+	ctx := context.Background()
+
+	car, err := s.carStore.GetByID(ctx, id)
+	if err != nil {
+		return types.NotFound(fmt.Sprintf("car %d", id))
+	}
+	if car.Status == status {
+		return nil
+	}
+	if !slices.Contains(carStatusTransitions[car.Status], status) {
+		return types.BadRequest(fmt.Sprintf("car cannot change status from %s to %s", car.Status, status))
+	}
+
+	if status == types.CarStatusRetired {
+		today := time.Now().Truncate(24 * time.Hour)
+		upcoming, err := s.bookingStore.GetOverlapping(ctx, id, today, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			return types.DatabaseError(err)
+		}
+		if len(upcoming) > 0 {
+			return types.Conflict(fmt.Sprintf("car has %d upcoming bookings, they have to be moved or cancelled before retiring it", len(upcoming)))
+		}
+	}
+
+	car.Status = status
+	car.Updated = time.Now()
+	if err := s.carStore.Update(ctx, id, car); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to update car: %v", err))
 	}
 	return nil
 }
 
+// listings show only cars of verified companies that can be rented unless filtered by status
 func (s *CarService) GetBatch(filters []*types.QueryFilter, opts *types.QueryOptions) ([]types.Car, error) {
-	cars, err := s.carStore.GetBatch(context.Background(), filters, opts)
+	cars, err := s.carStore.GetBatch(context.Background(), listedFilters(filters), opts)
 	if err != nil {
 		return nil, types.DatabaseError(fmt.Errorf("failed to get batch of cars: %v", err))
 	}
 	return cars, nil
 }
+
+// counts for the same cars GetBatch lists, most common values first, price buckets cheapest first
+func (s *CarService) GetFacets(filters []*types.QueryFilter, opts *types.QueryOptions) (*types.CarFacets, error) {
+	facets, err := s.carStore.GetFacets(context.Background(), listedFilters(filters), opts)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get car facets: %v", err))
+	}
+
+	for _, counts := range [][]types.FacetCount{facets.Make, facets.Category, facets.Transmission, facets.Company} {
+		slices.SortFunc(counts, func(a, b types.FacetCount) int {
+			if a.Count != b.Count {
+				return b.Count - a.Count
+			}
+			return strings.Compare(a.Value, b.Value)
+		})
+	}
+	buckets := types.PriceBucketLabels()
+	slices.SortFunc(facets.Price, func(a, b types.FacetCount) int {
+		return slices.Index(buckets, a.Value) - slices.Index(buckets, b.Value)
+	})
+	return facets, nil
+}
+
+// only available cars are listed unless filtered by status, cars of unverified companies never are
+func listedFilters(filters []*types.QueryFilter) []*types.QueryFilter {
+	if !slices.ContainsFunc(filters, func(f *types.QueryFilter) bool { return f.Field == "status" }) {
+		filters = append(slices.Clip(filters), &types.QueryFilter{Field: "status", Operator: "=", Value: string(types.CarStatusAvailable)})
+	}
+	return append(slices.Clip(filters), verifiedCompanies())
+}
+
+func (s *CarService) checkCategory(categoryID *int) error {
+	if categoryID == nil {
+		return nil
+	}
+	if _, err := s.carStore.GetCategoryByID(context.Background(), *categoryID); err != nil {
+		return types.BadRequest(fmt.Sprintf("car category %d doesn't exist", *categoryID))
+	}
+	return nil
+}
+
+func (s *CarService) GetCategories() ([]types.CarCategory, error) {
+	categories, err := s.carStore.GetCategories(context.Background())
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get car categories: %v", err))
+	}
+	return categories, nil
+}
+
+func (s *CarService) DecodeVIN(vin string) (*types.VINInfo, error) {
+	info := utils.DecodeVIN(vin)
+	if info == nil {
+		return nil, types.BadRequest("invalid vin")
+	}
+	return info, nil
+}
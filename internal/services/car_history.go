@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+const (
+	defaultServiceIntervalKm     = 15000
+	defaultServiceIntervalMonths = 12
+	// service is reported as due this close to the interval
+	serviceDueKm   = 1000
+	serviceDueDays = 30
+	// readings returned with the history
+	historyReadings = 100
+)
+
+func (s *CarService) AddReading(carId int, userId int, payload *types.CarReadingPayload) (*types.CarReading, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermMaintenance); err != nil {
+		return nil, err
+	}
+
+	recorded := time.Now().UTC()
+	if payload.RecordedAt != "" {
+		t, err := time.Parse(time.RFC3339, payload.RecordedAt)
+		if err != nil {
+			return nil, types.InternalServerError(err.Error())
+		}
+		recorded = t.UTC()
+	}
+
+	if payload.BookingID != nil {
+		book, err := s.bookingStore.GetByID(ctx, *payload.BookingID)
+		if err != nil || book.CarID != carId {
+			return nil, types.BadRequest(fmt.Sprintf("booking %d is not a booking of car %d", *payload.BookingID, carId))
+		}
+	}
+
+	reading := &types.CarReading{
+		CarID:     carId,
+		BookingID: payload.BookingID,
+		Odometer:  payload.Odometer,
+		FuelLevel: payload.FuelLevel,
+		Source:    types.ReadingSource(payload.Source),
+		Recorded:  recorded,
+	}
+	if err := s.recordReading(ctx, reading); err != nil {
+		return nil, err
+	}
+	return reading, nil
+}
+
+// odometer can't go back, older readings coming in late are only stored
+func (s *CarService) recordReading(ctx context.Context, reading *types.CarReading) error {
+	latest, err := s.carStore.GetReadings(ctx, reading.CarID, 1)
+	if err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to get car readings: %v", err))
+	}
+	if len(latest) > 0 && !reading.Recorded.Before(latest[0].Recorded) && reading.Odometer < latest[0].Odometer {
+		return types.BadRequest(fmt.Sprintf("odometer cannot go back, last reading was %d km", latest[0].Odometer))
+	}
+
+	if err := s.carStore.AddReading(ctx, reading); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to save car reading: %v", err))
+	}
+	return nil
+}
+
+func (s *CarService) AddServiceRecord(carId int, userId int, payload *types.ServiceRecordPayload) (*types.ServiceRecord, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermMaintenance); err != nil {
+		return nil, err
+	}
+
+	performed, err := time.Parse(time.DateOnly, payload.PerformedAt)
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+	if performed.After(time.Now()) {
+		return nil, types.BadRequest("service cannot be recorded in the future, schedule a maintenance instead")
+	}
+
+	record := &types.ServiceRecord{
+		CarID:       carId,
+		Description: payload.Description,
+		Cost:        payload.Cost,
+		Odometer:    payload.Odometer,
+		Performed:   performed,
+	}
+	if err := s.carStore.AddServiceRecord(ctx, record); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to save service record: %v", err))
+	}
+	return record, nil
+}
+
+func (s *CarService) GetHistory(carId int, userId int) (*types.CarHistory, error) {
+	ctx := context.Background()
+
+	car, err := s.companyCar(ctx, carId, userId, types.CompanyPermMaintenance)
+	if err != nil {
+		return nil, err
+	}
+
+	readings, err := s.carStore.GetReadings(ctx, carId, historyReadings)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get car readings: %v", err))
+	}
+	services, err := s.carStore.GetServiceRecords(ctx, carId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get service records: %v", err))
+	}
+
+	return &types.CarHistory{
+		Readings: readings,
+		Services: services,
+		Service:  serviceStatus(car, readings, services, time.Now()),
+	}, nil
+}
+
+// cars of the company that are close to or past their service interval
+func (s *CarService) GetServiceDue(companyId int, userId int) ([]*types.ServiceStatus, error) {
+	ctx := context.Background()
+
+	if err := authorizeMember(ctx, s.companyStore, s.userStore, companyId, userId, types.CompanyPermMaintenance); err != nil {
+		return nil, err
+	}
+
+	filters := []*types.QueryFilter{
+		{Field: "company_id", Operator: "=", Value: companyId},
+		{Field: "status", Operator: "!=", Value: string(types.CarStatusRetired)},
+	}
+	cars, err := s.carStore.GetBatch(ctx, filters, nil)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get company cars: %v", err))
+	}
+
+	due := make([]*types.ServiceStatus, 0)
+	for i := range cars {
+		readings, err := s.carStore.GetReadings(ctx, cars[i].ID, 1)
+		if err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to get car readings: %v", err))
+		}
+		services, err := s.carStore.GetServiceRecords(ctx, cars[i].ID)
+		if err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to get service records: %v", err))
+		}
+		if status := serviceStatus(&cars[i], readings, services, time.Now()); status.Due {
+			due = append(due, status)
+		}
+	}
+	return due, nil
+}
+
+// readings and services are expected newest first
+func serviceStatus(car *types.Car, readings []*types.CarReading, services []*types.ServiceRecord, now time.Time) *types.ServiceStatus {
+	intervalKm, intervalMonths := car.ServiceIntervalKm, car.ServiceIntervalMonths
+	if intervalKm == 0 {
+		intervalKm = defaultServiceIntervalKm
+	}
+	if intervalMonths == 0 {
+		intervalMonths = defaultServiceIntervalMonths
+	}
+
+	lastKm, lastDate := 0, car.Created
+	if len(services) > 0 {
+		lastKm, lastDate = services[0].Odometer, services[0].Performed
+	}
+
+	odometer := lastKm
+	if len(readings) > 0 && readings[0].Odometer > odometer {
+		odometer = readings[0].Odometer
+	}
+
+	status := &types.ServiceStatus{
+		CarID:           car.ID,
+		Odometer:        odometer,
+		NextServiceKm:   lastKm + intervalKm,
+		NextServiceDate: lastDate.AddDate(0, intervalMonths, 0),
+	}
+	status.KmLeft = status.NextServiceKm - odometer
+	status.DaysLeft = int(status.NextServiceDate.Sub(now).Hours() / 24)
+	status.Due = status.KmLeft <= serviceDueKm || status.DaysLeft <= serviceDueDays
+	return status
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
+)
+
+const (
+	MaxImageSize  = 5 << 20 // 5MB
+	thumbnailSide = 320
+)
+
+// accepted uploads with their file extensions
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+func (s *CarService) withURLs(images []*types.CarImage) []*types.CarImage {
+	for _, image := range images {
+		image.URL = s.files.URL(image.Key)
+		image.ThumbnailURL = s.files.URL(image.ThumbnailKey)
+	}
+	return images
+}
+
+func (s *CarService) GetImages(carId int) ([]*types.CarImage, error) {
+	images, err := s.carStore.GetImages(context.Background(), carId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get car images: %v", err))
+	}
+	return s.withURLs(images), nil
+}
+
+// image of a car of the user's company
+func (s *CarService) getImage(carId int, imageId int, userId int) ([]*types.CarImage, *types.CarImage, error) {
+	if _, err := s.companyCar(context.Background(), carId, userId, types.CompanyPermFleet); err != nil {
+		return nil, nil, err
+	}
+	images, err := s.GetImages(carId)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, image := range images {
+		if image.ID == imageId {
+			return images, image, nil
+		}
+	}
+	return nil, nil, types.NotFound(fmt.Sprintf("image %d of car %d", imageId, carId))
+}
+
+// type is detected from the content, not trusted from the request,
+// first image of the car becomes the primary one
+func (s *CarService) AddImage(carId int, userId int, file io.Reader) (*types.CarImage, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxImageSize+1))
+	if err != nil {
+		return nil, types.BadRequest("failed to read the image")
+	} else if len(data) > MaxImageSize {
+		return nil, types.BadRequest(fmt.Sprintf("image cannot be larger than %d MB", MaxImageSize>>20))
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, types.BadRequest(fmt.Sprintf("unsupported image type %s, use jpeg, png or gif", contentType))
+	}
+
+	thumbnail, err := utils.Thumbnail(bytes.NewReader(data), thumbnailSide)
+	if err != nil {
+		return nil, types.BadRequest("image is corrupted")
+	}
+
+	images, err := s.GetImages(carId)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := randomName()
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+
+	image := &types.CarImage{
+		CarID:        carId,
+		Key:          fmt.Sprintf("cars/%d/%s%s", carId, name, ext),
+		ThumbnailKey: fmt.Sprintf("cars/%d/%s_thumb.jpg", carId, name),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Position:     len(images) + 1,
+		Primary:      len(images) == 0,
+	}
+
+	if err := s.files.Save(ctx, image.Key, bytes.NewReader(data)); err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+	if err := s.files.Save(ctx, image.ThumbnailKey, bytes.NewReader(thumbnail)); err != nil {
+		_ = s.files.Delete(ctx, image.Key)
+		return nil, types.InternalServerError(err.Error())
+	}
+
+	if err := s.carStore.AddImage(ctx, image); err != nil {
+		_ = s.files.Delete(ctx, image.Key)
+		_ = s.files.Delete(ctx, image.ThumbnailKey)
+		return nil, types.DatabaseError(fmt.Errorf("failed to save car image: %v", err))
+	}
+
+	return s.withURLs([]*types.CarImage{image})[0], nil
+}
+
+func (s *CarService) SetPrimaryImage(carId int, imageId int, userId int) error {
+	images, primary, err := s.getImage(carId, imageId, userId)
+	if err != nil {
+		return err
+	}
+
+	// unset the old one first, only one primary image is allowed
+	for _, image := range images {
+		if image.Primary && image.ID != primary.ID {
+			image.Primary = false
+			if err := s.carStore.UpdateImage(context.Background(), image); err != nil {
+				return types.DatabaseError(fmt.Errorf("failed to update car image: %v", err))
+			}
+		}
+	}
+
+	primary.Primary = true
+	if err := s.carStore.UpdateImage(context.Background(), primary); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to update car image: %v", err))
+	}
+	return nil
+}
+
+// ids have to contain every image of the car exactly once
+func (s *CarService) ReorderImages(carId int, userId int, imageIds []int) ([]*types.CarImage, error) {
+	if _, err := s.companyCar(context.Background(), carId, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+	images, err := s.GetImages(carId)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[int]*types.CarImage, len(images))
+	for _, image := range images {
+		byID[image.ID] = image
+	}
+	if len(imageIds) != len(images) {
+		return nil, types.BadRequest("order has to contain all images of the car")
+	}
+
+	ordered := make([]*types.CarImage, 0, len(images))
+	for i, id := range imageIds {
+		image, ok := byID[id]
+		if !ok {
+			return nil, types.BadRequest(fmt.Sprintf("image %d is not an image of car %d or is repeated", id, carId))
+		}
+		delete(byID, id)
+		image.Position = i + 1
+		ordered = append(ordered, image)
+	}
+
+	for _, image := range ordered {
+		if err := s.carStore.UpdateImage(context.Background(), image); err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to update car image: %v", err))
+		}
+	}
+	return ordered, nil
+}
+
+// removes the files too, next image takes over when primary one is deleted
+func (s *CarService) DeleteImage(carId int, imageId int, userId int) error {
+	ctx := context.Background()
+
+	images, image, err := s.getImage(carId, imageId, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.carStore.DeleteImage(ctx, image.ID); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to delete car image: %v", err))
+	}
+	// file leftovers don't break anything, the record is already gone
+	_ = s.files.Delete(ctx, image.Key)
+	_ = s.files.Delete(ctx, image.ThumbnailKey)
+
+	position := 1
+	for _, other := range images {
+		if other.ID == image.ID {
+			continue
+		}
+		other.Position = position
+		position++
+		if image.Primary && other.Position == 1 {
+			other.Primary = true
+		}
+		if err := s.carStore.UpdateImage(ctx, other); err != nil {
+			return types.DatabaseError(fmt.Errorf("failed to update car image: %v", err))
+		}
+	}
+
+	return nil
+}
+
+func randomName() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate file name: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
+)
+
+const (
+	MaxImportSize = 2 << 20
+	maxImportRows = 1000
+)
+
+// columns of the fleet csv, export adds id and status in front and import skips them
+var carCSVColumns = []string{
+	"make", "model", "year", "color", "registration_no", "price_per_day", "category_id",
+	"transmission", "fuel_type", "seats", "doors", "air_conditioning", "navigation", "electric_range", "towbar",
+	"service_interval_km", "service_interval_months", "latitude", "longitude",
+	"vin", "registration_expiry", "insurance_expiry", "roadworthiness_expiry",
+}
+
+var carCSVReadOnly = []string{"id", "status"}
+
+// validates every row first, cars are created in one transaction only when all rows are valid,
+// dry run only reports the errors
+func (s *CarService) ImportCars(companyId int, userId int, file io.Reader, dryRun bool) (*types.CarImportResult, error) {
+	ctx := context.Background()
+
+	if err := authorizeMember(ctx, s.companyStore, s.userStore, companyId, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, types.BadRequest("missing csv header")
+	}
+	columns, err := importColumns(header)
+	if err != nil {
+		return nil, err
+	}
+	// rows are reported even when they have a different number of fields
+	reader.FieldsPerRecord = -1
+
+	result := &types.CarImportResult{DryRun: dryRun, Errors: make([]types.CarImportError, 0)}
+	cars := make([]*types.Car, 0)
+	lines := make(map[string]int)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, types.BadRequest(fmt.Sprintf("invalid csv: %v", err))
+		}
+		if result.Rows++; result.Rows > maxImportRows {
+			return nil, types.BadRequest(fmt.Sprintf("import is limited to %d cars", maxImportRows))
+		}
+
+		payload, rowErrors := parseCarRecord(columns, record)
+		payload.CompanyID = companyId
+		for field, tag := range utils.ValidateStruct(payload) {
+			if _, ok := rowErrors[field]; !ok {
+				rowErrors[field] = tag
+			}
+		}
+		if _, ok := rowErrors["CategoryID"]; !ok && s.checkCategory(payload.CategoryID) != nil {
+			rowErrors["CategoryID"] = "unknown category"
+		}
+		if first, ok := lines[payload.RegistrationNo]; ok && payload.RegistrationNo != "" {
+			rowErrors["RegistrationNo"] = fmt.Sprintf("duplicate of line %d", first)
+		} else {
+			lines[payload.RegistrationNo] = line
+		}
+
+		if len(rowErrors) > 0 {
+			result.Errors = append(result.Errors, types.CarImportError{Line: line, Errors: rowErrors})
+			continue
+		}
+		cars = append(cars, newCar(payload))
+	}
+
+	// registration numbers are unique across all companies
+	registrations := make([]string, 0, len(lines))
+	for reg := range lines {
+		registrations = append(registrations, reg)
+	}
+	if len(registrations) > 0 {
+		existing, err := s.carStore.GetBatch(ctx, []*types.QueryFilter{{Field: "registration_no", Operator: "IN", Value: registrations}}, nil)
+		if err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to check registration numbers: %v", err))
+		}
+		for _, car := range existing {
+			result.Errors = append(result.Errors, types.CarImportError{
+				Line:   lines[car.RegistrationNo],
+				Errors: map[string]string{"RegistrationNo": "already registered"},
+			})
+		}
+	}
+	slices.SortFunc(result.Errors, func(a, b types.CarImportError) int { return a.Line - b.Line })
+
+	if dryRun || len(result.Errors) > 0 || len(cars) == 0 {
+		return result, nil
+	}
+	prices := make([]*types.CarPrice, len(cars))
+	for i, car := range cars {
+		prices[i] = currentPrice(car)
+	}
+	if err := s.carStore.CreateBatch(ctx, cars, prices); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to import cars: %v", err))
+	}
+	result.Created = len(cars)
+	return result, nil
+}
+
+// index of every known column, header names are case insensitive
+func importColumns(header []string) (map[string]int, error) {
+	columns := make(map[string]int)
+	for i, name := range header {
+		// excel puts byte order mark in front of the file
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if slices.Contains(carCSVReadOnly, name) {
+			continue
+		}
+		if !slices.Contains(carCSVColumns, name) {
+			return nil, types.BadRequest(fmt.Sprintf("unknown column %s", name))
+		}
+		if _, ok := columns[name]; ok {
+			return nil, types.BadRequest(fmt.Sprintf("duplicate column %s", name))
+		}
+		columns[name] = i
+	}
+	return columns, nil
+}
+
+// values that can't be parsed are reported under the payload field name like validation errors
+func parseCarRecord(columns map[string]int, record []string) (*types.CreateCarPayload, map[string]string) {
+	rowErrors := make(map[string]string)
+	value := func(column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(column, field string) int {
+		if value(column) == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(value(column))
+		if err != nil {
+			rowErrors[field] = "number"
+		}
+		return n
+	}
+	decimal := func(column, field string) *float64 {
+		if value(column) == "" {
+			return nil
+		}
+		n, err := strconv.ParseFloat(value(column), 64)
+		if err != nil {
+			rowErrors[field] = "number"
+			return nil
+		}
+		return &n
+	}
+	boolean := func(column, field string) bool {
+		if value(column) == "" {
+			return false
+		}
+		b, err := strconv.ParseBool(value(column))
+		if err != nil {
+			rowErrors[field] = "boolean"
+		}
+		return b
+	}
+
+	payload := &types.CreateCarPayload{
+		Make:                  value("make"),
+		Model:                 value("model"),
+		Year:                  number("year", "Year"),
+		Color:                 value("color"),
+		RegistrationNo:        value("registration_no"),
+		ServiceIntervalKm:     number("service_interval_km", "ServiceIntervalKm"),
+		ServiceIntervalMonths: number("service_interval_months", "ServiceIntervalMonths"),
+		Latitude:              decimal("latitude", "Latitude"),
+		Longitude:             decimal("longitude", "Longitude"),
+		VIN:                   value("vin"),
+		Features: &types.CarFeaturesPayload{
+			Transmission:    value("transmission"),
+			FuelType:        value("fuel_type"),
+			Seats:           number("seats", "Seats"),
+			Doors:           number("doors", "Doors"),
+			AirConditioning: boolean("air_conditioning", "AirConditioning"),
+			Navigation:      boolean("navigation", "Navigation"),
+			ElectricRange:   number("electric_range", "ElectricRange"),
+			Towbar:          boolean("towbar", "Towbar"),
+		},
+		// checked by the datetime validation
+		Documents: &types.CarDocumentsPayload{
+			RegistrationExpiry:   value("registration_expiry"),
+			InsuranceExpiry:      value("insurance_expiry"),
+			RoadworthinessExpiry: value("roadworthiness_expiry"),
+		},
+	}
+	if price := decimal("price_per_day", "PricePerDay"); price != nil {
+		payload.PricePerDay = *price
+	}
+	if value("category_id") != "" {
+		category := number("category_id", "CategoryID")
+		payload.CategoryID = &category
+	}
+	return payload, rowErrors
+}
+
+// the whole fleet of the company including retired cars, in the import format
+func (s *CarService) ExportCars(companyId int, userId int, w io.Writer) error {
+	if err := authorizeMember(context.Background(), s.companyStore, s.userStore, companyId, userId, types.CompanyPermFleet); err != nil {
+		return err
+	}
+
+	cars, err := s.carStore.GetBatch(context.Background(), []*types.QueryFilter{{Field: "company_id", Operator: "=", Value: companyId}}, nil)
+	if err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to get company cars: %v", err))
+	}
+	slices.SortFunc(cars, func(a, b types.Car) int { return a.ID - b.ID })
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append(slices.Clone(carCSVReadOnly), carCSVColumns...)); err != nil {
+		return types.InternalServerError(err.Error())
+	}
+	for _, car := range cars {
+		category := ""
+		if car.CategoryID != nil {
+			category = strconv.Itoa(*car.CategoryID)
+		}
+		decimal := func(n *float64) string {
+			if n == nil {
+				return ""
+			}
+			return strconv.FormatFloat(*n, 'f', -1, 64)
+		}
+		date := func(t *time.Time) string {
+			if t == nil {
+				return ""
+			}
+			return t.Format(time.DateOnly)
+		}
+		vin := ""
+		if car.VIN != nil {
+			vin = *car.VIN
+		}
+		record := []string{
+			strconv.Itoa(car.ID), string(car.Status),
+			car.Make, car.Model, strconv.Itoa(car.Year), car.Color, car.RegistrationNo, decimal(&car.PricePerDay), category,
+			car.Transmission, car.FuelType, strconv.Itoa(car.Seats), strconv.Itoa(car.Doors), strconv.FormatBool(car.AirConditioning),
+			strconv.FormatBool(car.Navigation), strconv.Itoa(car.ElectricRange), strconv.FormatBool(car.Towbar),
+			strconv.Itoa(car.ServiceIntervalKm), strconv.Itoa(car.ServiceIntervalMonths), decimal(car.Latitude), decimal(car.Longitude),
+			vin, date(car.RegistrationExpiry), date(car.InsuranceExpiry), date(car.RoadworthinessExpiry),
+		}
+		if err := writer.Write(record); err != nil {
+			return types.InternalServerError(err.Error())
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return types.InternalServerError(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// window is created even when it overlaps bookings, they are returned
+// so the company can move them to other cars
+func (s *CarService) ScheduleMaintenance(carId int, userId int, payload *types.CreateMaintenancePayload) (*types.ScheduledMaintenance, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermMaintenance); err != nil {
+		return nil, err
+	}
+
+	startDate, err := time.Parse(time.DateOnly, payload.StartDate)
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+	endDate, err := time.Parse(time.DateOnly, payload.EndDate)
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+
+	if startDate.After(endDate) {
+		return nil, types.BadRequest("start date cannot be after end date")
+	}
+
+	scheduled, err := s.bookingStore.GetMaintenance(ctx, carId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	for _, m := range scheduled {
+		if !m.StartDate.After(endDate) && !m.EndDate.Before(startDate) {
+			return nil, types.Conflict(fmt.Sprintf("car already has %s scheduled from %s to %s",
+				m.Type, m.StartDate.Format(time.DateOnly), m.EndDate.Format(time.DateOnly)))
+		}
+	}
+
+	conflicts, err := s.bookingStore.GetOverlapping(ctx, carId, startDate, endDate)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	m := &types.Maintenance{
+		CarID:     carId,
+		Type:      types.MaintenanceType(payload.Type),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Note:      payload.Note,
+	}
+	if err := s.bookingStore.CreateMaintenance(ctx, m); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	if conflicts == nil {
+		conflicts = []*types.Booking{}
+	}
+	return &types.ScheduledMaintenance{Maintenance: m, Conflicts: conflicts}, nil
+}
+
+func (s *CarService) GetMaintenance(carId int, userId int) ([]*types.Maintenance, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermMaintenance); err != nil {
+		return nil, err
+	}
+
+	maintenance, err := s.bookingStore.GetMaintenance(ctx, carId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	return maintenance, nil
+}
+
+func (s *CarService) CancelMaintenance(carId int, maintenanceId int, userId int) error {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermMaintenance); err != nil {
+		return err
+	}
+
+	m, err := s.bookingStore.GetMaintenanceByID(ctx, maintenanceId)
+	if err != nil || m.CarID != carId {
+		return types.NotFound(fmt.Sprintf("maintenance %d of car %d", maintenanceId, carId))
+	}
+
+	if err := s.bookingStore.DeleteMaintenance(ctx, maintenanceId); err != nil {
+		return types.DatabaseError(err)
+	}
+	return nil
+}
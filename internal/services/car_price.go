@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+const (
+	defaultSimulationDays = 60
+	maxSimulationDays     = 365
+)
+
+// price change from a future date, the car price is switched by the daily job
+func (s *CarService) SchedulePrice(carId int, userId int, payload *types.CarPricePayload) (*types.CarPrice, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+
+	effective, err := time.Parse(time.DateOnly, payload.EffectiveFrom)
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+	if !effective.After(today()) {
+		return nil, types.BadRequest("price change can be scheduled from tomorrow on, update the car to change the price now")
+	}
+
+	price := &types.CarPrice{
+		CarID:         carId,
+		PricePerDay:   payload.PricePerDay,
+		EffectiveFrom: effective,
+	}
+	if err := s.carStore.AddPrice(ctx, price); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to save price change: %v", err))
+	}
+	return price, nil
+}
+
+// past prices and scheduled changes, oldest first
+func (s *CarService) GetPrices(carId int, userId int) ([]*types.CarPrice, error) {
+	if _, err := s.companyCar(context.Background(), carId, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+
+	prices, err := s.carStore.GetPrices(context.Background(), carId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get car prices: %v", err))
+	}
+	return prices, nil
+}
+
+// only changes that didn't take effect yet can be cancelled
+func (s *CarService) CancelPrice(carId int, priceId int, userId int) error {
+	prices, err := s.GetPrices(carId, userId)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range prices {
+		if p.ID != priceId {
+			continue
+		}
+		if p.Applied {
+			return types.BadRequest("price is already in effect")
+		}
+		if err := s.carStore.DeletePrice(context.Background(), priceId); err != nil {
+			return types.DatabaseError(fmt.Errorf("failed to cancel price change: %v", err))
+		}
+		return nil
+	}
+	return types.NotFound(fmt.Sprintf("price %d of car %d", priceId, carId))
+}
+
+// sets the car prices to changes that take effect today, returns how many were applied
+func (s *CarService) ApplyScheduledPrices(now time.Time) (int, error) {
+	ctx := context.Background()
+
+	pending, err := s.carStore.GetPendingPrices(ctx, now.UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return 0, types.DatabaseError(fmt.Errorf("failed to get scheduled prices: %v", err))
+	}
+
+	// ordered by effective date, the newest change of the car is applied last
+	for _, p := range pending {
+		if err := s.carStore.UpdatePrice(ctx, p.CarID, p.PricePerDay); err != nil {
+			return 0, types.DatabaseError(fmt.Errorf("failed to update car price: %v", err))
+		}
+		if err := s.carStore.SetPriceApplied(ctx, p.ID); err != nil {
+			return 0, types.DatabaseError(fmt.Errorf("failed to mark price applied: %v", err))
+		}
+	}
+	return len(pending), nil
+}
+
+// price set directly on the car, in effect from today
+func currentPrice(car *types.Car) *types.CarPrice {
+	return &types.CarPrice{
+		CarID:         car.ID,
+		PricePerDay:   car.PricePerDay,
+		EffectiveFrom: today(),
+		Applied:       true,
+	}
+}
+
+// keeps the history of prices set directly on the car
+func (s *CarService) recordPrice(ctx context.Context, car *types.Car) error {
+	if err := s.carStore.AddPrice(ctx, currentPrice(car)); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to save car price: %v", err))
+	}
+	return nil
+}
+
+// price of the latest change effective on the day, prices are expected oldest first,
+// cars without any history cost their current price
+func priceOn(car *types.Car, prices []*types.CarPrice, day time.Time) float64 {
+	price := car.PricePerDay
+	for _, p := range prices {
+		if p.EffectiveFrom.After(day) {
+			break
+		}
+		price = p.PricePerDay
+	}
+	return price
+}
+
+// what the car would cost on each of the next days with company's dynamic pricing,
+// works also when it isn't enabled yet so it can be tried out first
+func (s *CarService) SimulatePricing(carId int, userId int, days int) ([]*types.DailyPrice, error) {
+	ctx := context.Background()
+
+	if days == 0 {
+		days = defaultSimulationDays
+	} else if days < 0 || days > maxSimulationDays {
+		return nil, types.BadRequest(fmt.Sprintf("days has to be between 1 and %d", maxSimulationDays))
+	}
+
+	car, err := s.companyCar(ctx, carId, userId, types.CompanyPermManage)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.companyStore.GetDynamicPricing(ctx, car.CompanyID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	} else if rules == nil {
+		return nil, types.BadRequest("company has no dynamic pricing set up")
+	}
+
+	start := today()
+	return quote(ctx, s.carStore, s.bookingStore, car, rules, start, start.AddDate(0, 0, days-1))
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+const (
+	// older points are deleted by the retention job and not accepted at all
+	TelemetryRetention = 30 * 24 * time.Hour
+	// tolerated difference between device and server clock
+	telemetryClockSkew = 5 * time.Minute
+)
+
+// stores the points and keeps the odometer history up to date with the newest reading of every car
+func (s *CarService) IngestTelemetry(payload *types.TelemetryPayload) (*types.TelemetryResult, error) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	result := &types.TelemetryResult{}
+	points := make([]*types.TelemetryPoint, 0, len(payload.Points))
+	latest := make(map[int]*types.TelemetryPoint)
+	positions := make(map[int]*types.TelemetryPoint)
+	cars := make(map[int]bool)
+
+	for i, p := range payload.Points {
+		recorded, err := time.Parse(time.RFC3339, p.RecordedAt)
+		if err != nil {
+			return nil, types.InternalServerError(err.Error())
+		}
+		recorded = recorded.UTC()
+		if recorded.After(now.Add(telemetryClockSkew)) {
+			return nil, types.BadRequest(fmt.Sprintf("point %d is recorded in the future", i))
+		}
+		if recorded.Before(now.Add(-TelemetryRetention)) {
+			result.Skipped++
+			continue
+		}
+
+		if _, ok := cars[p.CarID]; !ok {
+			_, err := s.carStore.GetByID(ctx, p.CarID)
+			cars[p.CarID] = err == nil
+		}
+		if !cars[p.CarID] {
+			return nil, types.BadRequest(fmt.Sprintf("point %d is for unknown car %d", i, p.CarID))
+		}
+
+		point := &types.TelemetryPoint{
+			CarID:        p.CarID,
+			Recorded:     recorded,
+			Latitude:     p.Latitude,
+			Longitude:    p.Longitude,
+			Odometer:     p.Odometer,
+			FuelLevel:    p.FuelLevel,
+			BatteryLevel: p.BatteryLevel,
+			Ignition:     p.Ignition,
+		}
+		points = append(points, point)
+		if point.Odometer != nil && (latest[p.CarID] == nil || recorded.After(latest[p.CarID].Recorded)) {
+			latest[p.CarID] = point
+		}
+		if point.Latitude != nil && point.Longitude != nil && (positions[p.CarID] == nil || recorded.After(positions[p.CarID].Recorded)) {
+			positions[p.CarID] = point
+		}
+	}
+
+	if len(points) == 0 {
+		return result, nil
+	}
+	if err := s.carStore.AddTelemetry(ctx, points); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to save telemetry: %v", err))
+	}
+	result.Accepted = len(points)
+
+	for carID, point := range latest {
+		fuel := point.FuelLevel
+		if fuel == nil {
+			fuel = point.BatteryLevel
+		}
+		reading := &types.CarReading{
+			CarID:     carID,
+			Odometer:  *point.Odometer,
+			FuelLevel: fuel,
+			Source:    types.ReadingTelemetry,
+			Recorded:  point.Recorded,
+		}
+		// odometer going back is a device problem, the points are already stored
+		if err := s.recordReading(ctx, reading); err != nil {
+			if e, ok := err.(types.ApiError); !ok || e.StatusCode != http.StatusBadRequest {
+				return nil, err
+			}
+		}
+	}
+
+	// keeps the car location used by the near search up to date
+	for carID, point := range positions {
+		if err := s.carStore.UpdateLocation(ctx, carID, *point.Latitude, *point.Longitude); err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to update car location: %v", err))
+		}
+	}
+
+	return result, nil
+}
+
+// deletes points older than the retention period
+func (s *CarService) PurgeTelemetry(now time.Time) (int64, error) {
+	deleted, err := s.carStore.DeleteTelemetryBefore(context.Background(), now.Add(-TelemetryRetention))
+	if err != nil {
+		return 0, types.DatabaseError(fmt.Errorf("failed to delete old telemetry: %v", err))
+	}
+	return deleted, nil
+}
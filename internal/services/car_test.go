@@ -1,17 +1,54 @@
 package services
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mwdev22/CarRental/internal/notify"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store/mock"
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 func TestCarService(t *testing.T) {
-	carService := NewCarService(mock.NewCarRepository())
+	uploadDir := t.TempDir()
+	companyStore := mock.NewCompanyRepository()
+	carStore := mock.NewCarRepository(companyStore)
+	carService := NewCarService(carStore, mock.NewBookingStore(carStore, companyStore), companyStore, mock.NewUserRepository(), notify.NewLogNotifier(log.Default()), storage.NewLocalStorage(uploadDir, "/uploads/"))
+
+	// admin manages cars of every company, other users only of the companies they work for
+	admin := &types.User{Username: "admin", Role: types.UserTypeAdmin}
+	user := &types.User{Username: "user", Role: types.UserTypeCompanyOwner}
+	for _, u := range []*types.User{admin, user} {
+		if err := carService.userStore.Create(context.Background(), u); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	adminId := admin.ID
+
+	// only cars of verified companies are listed, the last one is still pending
+	for i := 1; i <= 8; i++ {
+		company := &types.Company{OwnerID: adminId, Name: fmt.Sprintf("company%d", i), Status: types.CompanyStatusVerified}
+		if i == 8 {
+			company.Status = types.CompanyStatusPending
+		}
+		if err := carService.companyStore.Create(context.Background(), company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+	}
 
 	t.Run("CreateCar", func(t *testing.T) {
+		unknownCategory := 99
 		tests := []struct {
 			name        string
 			payload     *types.CreateCarPayload
@@ -43,11 +80,25 @@ func TestCarService(t *testing.T) {
 				},
 				expectError: true,
 			},
+			{
+				name: "unknown category",
+				payload: &types.CreateCarPayload{
+					Make:           "Toyota",
+					Model:          "Corolla",
+					Year:           2021,
+					Color:          "Red",
+					RegistrationNo: "ABC124",
+					PricePerDay:    100,
+					CompanyID:      1,
+					CategoryID:     &unknownCategory,
+				},
+				expectError: true,
+			},
 		}
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-				err := carService.CreateCar(tt.payload)
+				err := carService.CreateCar(adminId, tt.payload)
 
 				if tt.expectError && err == nil {
 					t.Errorf("expected an error, got nil")
@@ -57,6 +108,11 @@ func TestCarService(t *testing.T) {
 				}
 			})
 		}
+
+		outsider := &types.CreateCarPayload{Make: "Toyota", Model: "Yaris", Year: 2021, RegistrationNo: "ABC125", PricePerDay: 60, CompanyID: 1}
+		if err := carService.CreateCar(user.ID, outsider); err == nil {
+			t.Errorf("expected an error for user outside the company")
+		}
 	})
 
 	t.Run("GetByID", func(t *testing.T) {
@@ -69,7 +125,7 @@ func TestCarService(t *testing.T) {
 			PricePerDay:    80,
 			CompanyID:      2,
 		}
-		err := carService.CreateCar(createPayload)
+		err := carService.CreateCar(adminId, createPayload)
 		if err != nil {
 			t.Fatalf("failed to create car: %v", err)
 		}
@@ -118,7 +174,7 @@ func TestCarService(t *testing.T) {
 			PricePerDay:    150,
 			CompanyID:      3,
 		}
-		err := carService.CreateCar(createPayload)
+		err := carService.CreateCar(adminId, createPayload)
 		if err != nil {
 			t.Fatalf("failed to create car: %v", err)
 		}
@@ -154,7 +210,7 @@ func TestCarService(t *testing.T) {
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-				err := carService.UpdateCar(tt.carID, tt.payload)
+				err := carService.UpdateCar(tt.carID, adminId, tt.payload)
 
 				if tt.expectError && err == nil {
 					t.Errorf("expected an error, got nil")
@@ -190,11 +246,30 @@ func TestCarService(t *testing.T) {
 				PricePerDay:    300,
 				CompanyID:      4,
 			}
-			err := carService.CreateCar(payload)
+			err := carService.CreateCar(adminId, payload)
 			if err != nil {
 				t.Fatalf("failed to create car: %v", err)
 			}
 		}
+		err := carService.CreateCar(adminId, &types.CreateCarPayload{
+			Make:           "Ford",
+			Model:          "Tourneo",
+			Year:           2023,
+			Color:          "Grey",
+			RegistrationNo: "FRD1",
+			PricePerDay:    150,
+			CompanyID:      4,
+			Features:       &types.CarFeaturesPayload{Transmission: "automatic", FuelType: "diesel", Seats: 8, Towbar: true},
+		})
+		if err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+		err = carService.CreateCar(adminId, &types.CreateCarPayload{
+			Make: "Tesla", Model: "Model Y", Year: 2023, RegistrationNo: "TESPENDING", PricePerDay: 350, CompanyID: 8,
+		})
+		if err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
 
 		tests := []struct {
 			name        string
@@ -212,6 +287,37 @@ func TestCarService(t *testing.T) {
 				expectCount: 5,
 				expectError: false,
 			},
+			{
+				name: "feature filters",
+				filters: []*types.QueryFilter{
+					{Field: "seats", Operator: ">=", Value: "7"},
+					{Field: "transmission", Operator: "=", Value: "automatic"},
+					{Field: "towbar", Operator: "=", Value: "true"},
+				},
+				opts:        &types.QueryOptions{Limit: 10},
+				expectCount: 1,
+				expectError: false,
+			},
+			{
+				name: "in filter",
+				filters: []*types.QueryFilter{
+					{Field: "company_id", Operator: "=", Value: "4"},
+					{Field: "fuel_type", Operator: "IN", Value: []string{"electric", "diesel"}},
+				},
+				opts:        &types.QueryOptions{Limit: 10},
+				expectCount: 1,
+				expectError: false,
+			},
+			{
+				name: "unverified company",
+				filters: []*types.QueryFilter{
+					{Field: "make", Operator: "=", Value: "Tesla"},
+					{Field: "company_id", Operator: "=", Value: "8"},
+				},
+				opts:        &types.QueryOptions{Limit: 10},
+				expectCount: 0,
+				expectError: false,
+			},
 		}
 
 		for _, tt := range tests {
@@ -249,7 +355,7 @@ func TestCarService(t *testing.T) {
 
 		for _, tt := range tests {
 			t.Run(tt.name, func(t *testing.T) {
-				err := carService.Delete(1)
+				_, err := carService.Delete(1, adminId)
 
 				if tt.expectError && err == nil {
 					t.Errorf("expected an error, got nil")
@@ -267,4 +373,287 @@ func TestCarService(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("Images", func(t *testing.T) {
+		car := &types.Car{Make: "Skoda", Model: "Octavia", Year: 2022, RegistrationNo: "IMG1", PricePerDay: 90, CompanyID: 1}
+		if err := carService.carStore.Create(context.Background(), car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		var picture bytes.Buffer
+		if err := png.Encode(&picture, image.NewRGBA(image.Rect(0, 0, 800, 400))); err != nil {
+			t.Fatalf("failed to encode picture: %v", err)
+		}
+
+		if _, err := carService.AddImage(car.ID, adminId, strings.NewReader("definitely not an image")); err == nil {
+			t.Fatalf("expected error uploading text file")
+		}
+
+		first, err := carService.AddImage(car.ID, adminId, bytes.NewReader(picture.Bytes()))
+		if err != nil {
+			t.Fatalf("failed to add image: %v", err)
+		}
+		if !first.Primary || first.Position != 1 || first.ContentType != "image/png" {
+			t.Fatalf("expected first png image to be primary, got %+v", first)
+		}
+
+		thumb, err := os.Open(filepath.Join(uploadDir, filepath.FromSlash(first.ThumbnailKey)))
+		if err != nil {
+			t.Fatalf("failed to open thumbnail: %v", err)
+		}
+		defer thumb.Close()
+		cfg, _, err := image.DecodeConfig(thumb)
+		if err != nil || cfg.Width != 320 || cfg.Height != 160 {
+			t.Fatalf("expected 320x160 thumbnail, got %vx%v, err: %v", cfg.Width, cfg.Height, err)
+		}
+
+		second, err := carService.AddImage(car.ID, adminId, bytes.NewReader(picture.Bytes()))
+		if err != nil {
+			t.Fatalf("failed to add image: %v", err)
+		}
+
+		if _, err := carService.ReorderImages(car.ID, adminId, []int{second.ID, second.ID}); err == nil {
+			t.Fatalf("expected error reordering with repeated image")
+		}
+		images, err := carService.ReorderImages(car.ID, adminId, []int{second.ID, first.ID})
+		if err != nil || images[0].ID != second.ID || images[0].Position != 1 {
+			t.Fatalf("expected second image first, got %v, err: %v", images, err)
+		}
+
+		if err := carService.SetPrimaryImage(car.ID, second.ID, adminId); err != nil {
+			t.Fatalf("failed to set primary image: %v", err)
+		}
+		if err := carService.DeleteImage(car.ID, second.ID, adminId); err != nil {
+			t.Fatalf("failed to delete image: %v", err)
+		}
+
+		images, err = carService.GetImages(car.ID)
+		if err != nil || len(images) != 1 || !images[0].Primary || images[0].Position != 1 {
+			t.Fatalf("expected remaining image to become primary, got %v, err: %v", images, err)
+		}
+		if _, err := os.Stat(filepath.Join(uploadDir, filepath.FromSlash(second.Key))); !os.IsNotExist(err) {
+			t.Fatalf("expected deleted image file to be removed, got %v", err)
+		}
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		ctx := context.Background()
+		car := &types.Car{Make: "Opel", Model: "Astra", Year: 2020, RegistrationNo: "STATUS1", PricePerDay: 70, CompanyID: 1}
+		if err := carService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+		booking := &types.Booking{CarID: car.ID, UserID: 1, StartDate: time.Now().AddDate(0, 1, 0), EndDate: time.Now().AddDate(0, 1, 3)}
+		if err := carService.bookingStore.Create(ctx, booking); err != nil {
+			t.Fatalf("failed to create booking: %v", err)
+		}
+
+		if err := carService.SetStatus(car.ID, adminId, types.CarStatusInService); err != nil {
+			t.Fatalf("failed to move car to service: %v", err)
+		}
+		listed := func(filters []*types.QueryFilter) bool {
+			cars, err := carService.GetBatch(filters, nil)
+			if err != nil {
+				t.Fatalf("failed to get cars: %v", err)
+			}
+			return slices.ContainsFunc(cars, func(c types.Car) bool { return c.ID == car.ID })
+		}
+		if listed(nil) {
+			t.Fatalf("expected car in service to be hidden from listings")
+		}
+		if !listed([]*types.QueryFilter{{Field: "status", Operator: "=", Value: "in_service"}}) {
+			t.Fatalf("expected car in service to be listed when filtered by status")
+		}
+
+		if err := carService.SetStatus(car.ID, adminId, types.CarStatusRetired); err == nil {
+			t.Fatalf("expected error retiring car with upcoming booking")
+		}
+		booking.Status = types.BookingStatusCancelled
+		retired, err := carService.Delete(car.ID, adminId)
+		if err != nil || !retired {
+			t.Fatalf("expected booked car to be retired instead of deleted, got %v, %v", retired, err)
+		}
+		if _, err := carService.GetByID(car.ID); err != nil {
+			t.Fatalf("expected retired car to be kept: %v", err)
+		}
+		if err := carService.SetStatus(car.ID, adminId, types.CarStatusAvailable); err == nil {
+			t.Fatalf("expected error bringing back retired car")
+		}
+	})
+
+	t.Run("History", func(t *testing.T) {
+		ctx := context.Background()
+		car := &types.Car{Make: "Dacia", Model: "Logan", Year: 2021, RegistrationNo: "HIST1", PricePerDay: 40, CompanyID: 7, ServiceIntervalKm: 10000}
+		if err := carService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		fuel := 80
+		if _, err := carService.AddReading(car.ID, adminId, &types.CarReadingPayload{Odometer: 5000, FuelLevel: &fuel, Source: "check_out"}); err != nil {
+			t.Fatalf("failed to add reading: %v", err)
+		}
+		if _, err := carService.AddReading(car.ID, adminId, &types.CarReadingPayload{Odometer: 4000, Source: "manual"}); err == nil {
+			t.Fatalf("expected error when odometer goes back")
+		}
+		if _, err := carService.AddReading(car.ID, adminId, &types.CarReadingPayload{Odometer: 9200, Source: "check_in"}); err != nil {
+			t.Fatalf("failed to add reading: %v", err)
+		}
+
+		due, err := carService.GetServiceDue(7, adminId)
+		if err != nil {
+			t.Fatalf("failed to get cars due for service: %v", err)
+		}
+		if len(due) != 1 || due[0].CarID != car.ID || due[0].KmLeft != 800 {
+			t.Fatalf("expected car with 800 km left to be due for service, got %v", due)
+		}
+
+		today := time.Now().Format(time.DateOnly)
+		if _, err := carService.AddServiceRecord(car.ID, adminId, &types.ServiceRecordPayload{Description: "oil change", Cost: 350, Odometer: 9300, PerformedAt: today}); err != nil {
+			t.Fatalf("failed to add service record: %v", err)
+		}
+
+		history, err := carService.GetHistory(car.ID, adminId)
+		if err != nil {
+			t.Fatalf("failed to get history: %v", err)
+		}
+		if len(history.Readings) != 2 || history.Readings[0].Odometer != 9200 || len(history.Services) != 1 {
+			t.Fatalf("expected 2 readings newest first and 1 service, got %v and %v", history.Readings, history.Services)
+		}
+		if history.Service.Due || history.Service.NextServiceKm != 19300 {
+			t.Fatalf("expected next service at 19300 km, got %+v", history.Service)
+		}
+	})
+
+	t.Run("Telemetry", func(t *testing.T) {
+		ctx := context.Background()
+		car := &types.Car{Make: "Tesla", Model: "Model 3", Year: 2023, RegistrationNo: "TELE1", PricePerDay: 150, CompanyID: 1}
+		if err := carService.carStore.Create(ctx, car); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+
+		lat, lng, odometer, battery := 52.2297, 21.0122, 12000, 76
+		now := time.Now().UTC()
+		payload := &types.TelemetryPayload{Points: []*types.TelemetryPointPayload{
+			{CarID: car.ID, RecordedAt: now.Add(-2 * time.Minute).Format(time.RFC3339), Latitude: &lat, Longitude: &lng},
+			{CarID: car.ID, RecordedAt: now.Add(-time.Minute).Format(time.RFC3339), Odometer: &odometer, BatteryLevel: &battery},
+			{CarID: car.ID, RecordedAt: now.Add(-40 * 24 * time.Hour).Format(time.RFC3339), Latitude: &lng, Longitude: &lat},
+		}}
+		result, err := carService.IngestTelemetry(payload)
+		if err != nil {
+			t.Fatalf("failed to ingest telemetry: %v", err)
+		}
+		if result.Accepted != 2 || result.Skipped != 1 {
+			t.Fatalf("expected 2 accepted and 1 skipped point, got %+v", result)
+		}
+
+		payload.Points[0].CarID = 999
+		if _, err := carService.IngestTelemetry(payload); err == nil {
+			t.Fatalf("expected error for unknown car")
+		}
+
+		got, err := carService.GetByID(car.ID)
+		if err != nil {
+			t.Fatalf("failed to get car: %v", err)
+		}
+		if got.Position == nil || *got.Position.Latitude != lat {
+			t.Fatalf("expected last known position, got %v", got.Position)
+		}
+		if got.Latitude == nil || *got.Latitude != lat || *got.Longitude != lng {
+			t.Fatalf("expected car location to follow telemetry, got %v, %v", got.Latitude, got.Longitude)
+		}
+
+		history, err := carService.GetHistory(car.ID, adminId)
+		if err != nil {
+			t.Fatalf("failed to get history: %v", err)
+		}
+		if len(history.Readings) != 1 || history.Readings[0].Source != types.ReadingTelemetry || *history.Readings[0].FuelLevel != battery {
+			t.Fatalf("expected odometer reading from telemetry, got %v", history.Readings)
+		}
+
+		deleted, err := carService.PurgeTelemetry(now.Add(TelemetryRetention))
+		if err != nil || deleted != 2 {
+			t.Fatalf("expected 2 points purged, got %v, %v", deleted, err)
+		}
+	})
+
+	t.Run("Near", func(t *testing.T) {
+		// the telemetry car is parked at 52.2297, 21.0122
+		locations := map[string][2]float64{"NEAR1": {52.2400, 21.0300}, "NEAR2": {50.0647, 19.9450}}
+		for reg, loc := range locations {
+			payload := &types.CreateCarPayload{Make: "Skoda", Model: "Fabia", Year: 2022, RegistrationNo: reg, PricePerDay: 60, CompanyID: 1,
+				Latitude: &loc[0], Longitude: &loc[1]}
+			if err := carService.CreateCar(adminId, payload); err != nil {
+				t.Fatalf("failed to create car: %v", err)
+			}
+		}
+
+		opts := &types.QueryOptions{Limit: 10, SortField: "distance_km", SortDiretion: "asc",
+			Near: &types.GeoFilter{Latitude: 52.2300, Longitude: 21.0120, RadiusKm: 10}}
+		cars, err := carService.GetBatch(nil, opts)
+		if err != nil {
+			t.Fatalf("failed to get cars near point: %v", err)
+		}
+		if len(cars) != 2 || cars[0].RegistrationNo != "TELE1" || cars[1].RegistrationNo != "NEAR1" {
+			t.Fatalf("expected 2 cars within 10 km closest first, got %v", cars)
+		}
+		if *cars[0].Distance > 0.1 || *cars[1].Distance < 1 || *cars[1].Distance > 3 {
+			t.Fatalf("unexpected distances %v and %v", *cars[0].Distance, *cars[1].Distance)
+		}
+	})
+
+	t.Run("Prices", func(t *testing.T) {
+		payload := &types.CreateCarPayload{Make: "Kia", Model: "Ceed", Year: 2022, Color: "Grey", RegistrationNo: "PRICE1", PricePerDay: 100, CompanyID: 1}
+		if err := carService.CreateCar(adminId, payload); err != nil {
+			t.Fatalf("failed to create car: %v", err)
+		}
+		cars, err := carService.GetBatch([]*types.QueryFilter{{Field: "registration_no", Operator: "=", Value: "PRICE1"}}, nil)
+		if err != nil || len(cars) != 1 {
+			t.Fatalf("failed to get created car: %v", err)
+		}
+		carId := cars[0].ID
+
+		update := &types.UpdateCarPayload{Make: "Kia", Model: "Ceed", Year: 2022, Color: "Grey", RegistrationNo: "PRICE1", PricePerDay: 90}
+		if err := carService.UpdateCar(carId, adminId, update); err != nil {
+			t.Fatalf("failed to update car: %v", err)
+		}
+
+		today := time.Now().UTC().Format(time.DateOnly)
+		if _, err := carService.SchedulePrice(carId, adminId, &types.CarPricePayload{PricePerDay: 120, EffectiveFrom: today}); err == nil {
+			t.Fatalf("expected error scheduling price from today")
+		}
+		tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+		scheduled, err := carService.SchedulePrice(carId, adminId, &types.CarPricePayload{PricePerDay: 120, EffectiveFrom: tomorrow})
+		if err != nil {
+			t.Fatalf("failed to schedule price: %v", err)
+		}
+
+		prices, err := carService.GetPrices(carId, adminId)
+		if err != nil {
+			t.Fatalf("failed to get prices: %v", err)
+		}
+		if len(prices) != 3 || prices[0].PricePerDay != 100 || prices[1].PricePerDay != 90 || prices[2].Applied {
+			t.Fatalf("expected both past prices and the scheduled one, got %v", prices)
+		}
+
+		if applied, err := carService.ApplyScheduledPrices(time.Now()); err != nil || applied != 0 {
+			t.Fatalf("expected nothing to apply today, got %v, %v", applied, err)
+		}
+		if applied, err := carService.ApplyScheduledPrices(time.Now().AddDate(0, 0, 1)); err != nil || applied != 1 {
+			t.Fatalf("expected scheduled price applied tomorrow, got %v, %v", applied, err)
+		}
+		car, err := carService.GetByID(carId)
+		if err != nil || car.PricePerDay != 120 {
+			t.Fatalf("expected car price 120, got %v", car)
+		}
+
+		if err := carService.CancelPrice(carId, scheduled.ID, adminId); err == nil {
+			t.Fatalf("expected error cancelling price already in effect")
+		}
+		next, err := carService.SchedulePrice(carId, adminId, &types.CarPricePayload{PricePerDay: 130, EffectiveFrom: time.Now().AddDate(0, 1, 0).Format(time.DateOnly)})
+		if err != nil {
+			t.Fatalf("failed to schedule price: %v", err)
+		}
+		if err := carService.CancelPrice(carId, next.ID, adminId); err != nil {
+			t.Fatalf("failed to cancel price change: %v", err)
+		}
+	})
 }
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func pendingCarTransfer(transfers []*types.CarTransfer) *types.CarTransfer {
+	for _, t := range transfers {
+		if t.Status == types.CarTransferPending {
+			return t
+		}
+	}
+	return nil
+}
+
+// far enough to cover every booking ending after today
+const maxBookingYears = 100
+
+// bookings of the car that didn't end yet, including the ongoing one
+func (s *CarService) futureBookings(ctx context.Context, carId int) ([]*types.Booking, error) {
+	start := today()
+	books, err := s.bookingStore.GetOverlapping(ctx, carId, start, start.AddDate(maxBookingYears, 0, 0))
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	return books, nil
+}
+
+func checkFutureBookings(books []*types.Booking, migrate bool) error {
+	if len(books) > 0 && !migrate {
+		return types.Conflict(fmt.Sprintf("car has %d future bookings, cancel them or migrate them with the car", len(books)))
+	}
+	return nil
+}
+
+// bookings going with the car are picked up at its new branch, bookings at a branch of the previous
+// company lose it when the car doesn't move to a branch
+func migrateBookings(books []*types.Booking, branchId *int) {
+	for _, book := range books {
+		if book.BranchID == nil {
+			continue
+		}
+		book.BranchID = branchId
+		if branchId == nil {
+			book.PickupTime = nil
+			book.ReturnTime = nil
+		}
+	}
+}
+
+// branch of the company the car moves to, nil when none was chosen
+func (s *CarService) transferBranch(ctx context.Context, companyId int, branchId int) (*int, error) {
+	if branchId == 0 {
+		return nil, nil
+	}
+	branch, err := s.companyStore.GetBranch(ctx, branchId)
+	if err != nil || branch.CompanyID != companyId {
+		return nil, types.NotFound(fmt.Sprintf("branch %d of company %d", branchId, companyId))
+	}
+	return &branch.ID, nil
+}
+
+// member of the car's company moves it to another of its branches right away, or offers it
+// to another company and the car moves after that company accepts
+func (s *CarService) TransferCar(carId int, userId int, payload *types.TransferCarPayload) (*types.CarTransfer, error) {
+	ctx := context.Background()
+
+	car, err := s.companyCar(ctx, carId, userId, types.CompanyPermFleet)
+	if err != nil {
+		return nil, err
+	}
+	if car.Status == types.CarStatusRetired {
+		return nil, types.BadRequest("retired cars cannot be transferred")
+	}
+
+	companyId := payload.CompanyID
+	if companyId == 0 {
+		companyId = car.CompanyID
+	}
+	if companyId == car.CompanyID && payload.BranchID == 0 {
+		return nil, types.BadRequest("car already belongs to the company")
+	}
+
+	target, err := s.companyStore.GetByID(ctx, companyId)
+	if err != nil {
+		return nil, types.NotFound("company to transfer the car to")
+	}
+	branchId, err := s.transferBranch(ctx, target.ID, payload.BranchID)
+	if err != nil {
+		return nil, err
+	}
+	if companyId == car.CompanyID && car.BranchID != nil && *car.BranchID == *branchId {
+		return nil, types.BadRequest("car is already kept at the branch")
+	}
+
+	transfers, err := s.carStore.GetTransfers(ctx, carId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	if pendingCarTransfer(transfers) != nil {
+		return nil, types.Conflict("car already has a pending transfer")
+	}
+
+	books, err := s.futureBookings(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFutureBookings(books, payload.MigrateBookings); err != nil {
+		return nil, err
+	}
+
+	transfer := &types.CarTransfer{
+		CarID:           carId,
+		FromCompanyID:   car.CompanyID,
+		ToCompanyID:     target.ID,
+		FromBranchID:    car.BranchID,
+		ToBranchID:      branchId,
+		Status:          types.CarTransferPending,
+		MigrateBookings: payload.MigrateBookings,
+		RequestedBy:     userId,
+	}
+
+	// the company doesn't have to agree with itself
+	if companyId == car.CompanyID {
+		if err := s.completeCarTransfer(ctx, car, transfer, books, userId); err != nil {
+			return nil, err
+		}
+		return transfer, nil
+	}
+
+	if err := s.carStore.CreateTransfer(ctx, transfer); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	if owner, err := s.userStore.GetByID(ctx, target.OwnerID); err == nil && owner != nil {
+		_ = s.notifier.Notify(ctx, owner.Email, "Car transfer",
+			fmt.Sprintf("car %s %s (%s) is being transferred to %s with %d future bookings, accept it to add it to your fleet",
+				car.Make, car.Model, car.RegistrationNo, target.Name, len(books)))
+	}
+
+	return transfer, nil
+}
+
+// owner of the receiving company takes over the car, bookings made meanwhile
+// are checked again and go with the car only if migration was requested
+func (s *CarService) AcceptCarTransfer(carId int, userId int) (*types.CarTransfer, error) {
+	ctx := context.Background()
+
+	car, err := s.carStore.GetByID(ctx, carId)
+	if err != nil {
+		return nil, types.NotFound(fmt.Sprintf("car %d", carId))
+	}
+
+	transfers, err := s.carStore.GetTransfers(ctx, carId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	transfer := pendingCarTransfer(transfers)
+	if transfer == nil {
+		return nil, types.NotFound("pending transfer of the car")
+	}
+	if err := authorizeMember(ctx, s.companyStore, s.userStore, transfer.ToCompanyID, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+
+	books, err := s.futureBookings(ctx, carId)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFutureBookings(books, transfer.MigrateBookings); err != nil {
+		return nil, err
+	}
+
+	if err := s.completeCarTransfer(ctx, car, transfer, books, userId); err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// moves the car with its bookings, renters keep the booking and its price,
+// they only get to know who rents the car now and where to pick it up
+func (s *CarService) completeCarTransfer(ctx context.Context, car *types.Car, transfer *types.CarTransfer, books []*types.Booking, userId int) error {
+	now := time.Now().UTC()
+	transfer.Status = types.CarTransferAccepted
+	transfer.MigratedBookings = len(books)
+	transfer.ResolvedBy = &userId
+	transfer.Resolved = &now
+
+	migrateBookings(books, transfer.ToBranchID)
+	if err := s.carStore.CompleteTransfer(ctx, transfer, books); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	target, err := s.companyStore.GetByID(ctx, transfer.ToCompanyID)
+	if err != nil {
+		return nil
+	}
+	place := target.Name
+	if transfer.ToBranchID != nil {
+		if branch, err := s.companyStore.GetBranch(ctx, *transfer.ToBranchID); err == nil {
+			place = fmt.Sprintf("%s at %s, %s", target.Name, branch.Name, branch.Address)
+		}
+	}
+	for _, book := range books {
+		if renter, err := s.userStore.GetByID(ctx, book.UserID); err == nil && renter != nil {
+			_ = s.notifier.Notify(ctx, renter.Email, "Booking moved",
+				fmt.Sprintf("car %s %s of booking %d (%s - %s) is now rented by %s", car.Make, car.Model, book.ID,
+					book.StartDate.Format(time.DateOnly), book.EndDate.Format(time.DateOnly), place))
+		}
+	}
+	return nil
+}
+
+// declined by the receiving company or withdrawn by the current one
+func (s *CarService) DeclineCarTransfer(carId int, userId int) error {
+	ctx := context.Background()
+
+	transfers, err := s.carStore.GetTransfers(ctx, carId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	transfer := pendingCarTransfer(transfers)
+	if transfer == nil {
+		return types.NotFound("pending transfer of the car")
+	}
+	if err := authorizeMember(ctx, s.companyStore, s.userStore, transfer.ToCompanyID, userId, types.CompanyPermFleet); err != nil {
+		if err := authorizeMember(ctx, s.companyStore, s.userStore, transfer.FromCompanyID, userId, types.CompanyPermFleet); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().UTC()
+	transfer.Status = types.CarTransferDeclined
+	transfer.ResolvedBy = &userId
+	transfer.Resolved = &now
+	if err := s.carStore.UpdateTransfer(ctx, transfer); err != nil {
+		return types.DatabaseError(err)
+	}
+	return nil
+}
+
+// history of the car's companies, visible to its current owner
+func (s *CarService) GetCarTransfers(carId int, userId int) ([]*types.CarTransfer, error) {
+	ctx := context.Background()
+
+	if _, err := s.companyCar(ctx, carId, userId, types.CompanyPermFleet); err != nil {
+		return nil, err
+	}
+
+	transfers, err := s.carStore.GetTransfers(ctx, carId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	if transfers == nil {
+		transfers = make([]*types.CarTransfer, 0)
+	}
+	return transfers, nil
+}
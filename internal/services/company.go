@@ -4,21 +4,36 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 
+	"github.com/mwdev22/CarRental/internal/notify"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store"
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 type CompanyService struct {
 	companyStore store.CompanyStore
+	carStore     store.CarStore
+	bookingStore store.BookingStore
+	userStore    store.UserStore
+	notifier     notify.Notifier
+	documents    storage.Storage // private, documents are served only through the api
 }
 
-func NewCompanyService(companyStore store.CompanyStore) *CompanyService {
+func NewCompanyService(companyStore store.CompanyStore, carStore store.CarStore, bookingStore store.BookingStore, userStore store.UserStore,
+	notifier notify.Notifier, documents storage.Storage) *CompanyService {
 	return &CompanyService{
 		companyStore: companyStore,
+		carStore:     carStore,
+		bookingStore: bookingStore,
+		userStore:    userStore,
+		notifier:     notifier,
+		documents:    documents,
 	}
 }
 
+// new companies wait for an admin to verify their documents
 func (s *CompanyService) Create(payload *types.CreateCompanyPayload, ownerId int) error {
 	company := &types.Company{
 		Name:    payload.Name,
@@ -26,6 +41,11 @@ func (s *CompanyService) Create(payload *types.CreateCompanyPayload, ownerId int
 		Email:   payload.Email,
 		Phone:   payload.Phone,
 		Address: payload.Address,
+
+		Latitude:  payload.Latitude,
+		Longitude: payload.Longitude,
+
+		Status: types.CompanyStatusPending,
 	}
 
 	if err := s.companyStore.Create(context.Background(), company); err != nil {
@@ -41,23 +61,31 @@ func (s *CompanyService) GetByID(id int) (*types.Company, error) {
 		return nil, err
 	}
 
+	company.Branches, err = s.companyStore.GetBranches(context.Background(), id)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get branches, %v", err))
+	}
+
 	return company, nil
 }
 
 func (s *CompanyService) Update(id int, userId int, payload *types.UpdateCompanyPayload) error {
-	company, err := s.companyStore.GetByID(context.Background(), id)
-	if err != nil {
+	if err := s.authorize(id, userId, types.CompanyPermManage); err != nil {
 		return err
 	}
 
-	if company.OwnerID != userId {
-		return types.Unauthorized(fmt.Sprintf("user isnt the owner of the company with id %v", id))
+	company, err := s.companyStore.GetByID(context.Background(), id)
+	if err != nil {
+		return err
 	}
 
 	company.Name = payload.Name
 	company.Email = payload.Email
 	company.Phone = payload.Phone
 	company.Address = payload.Address
+	if payload.Latitude != nil {
+		company.Latitude, company.Longitude = payload.Latitude, payload.Longitude
+	}
 
 	if err := s.companyStore.Update(context.Background(), company); err != nil {
 		return err
@@ -66,14 +94,24 @@ func (s *CompanyService) Update(id int, userId int, payload *types.UpdateCompany
 	return nil
 }
 
+// bookings keep their cars, so companies whose cars were ever booked can only retire them
 func (cs *CompanyService) Delete(id int, userId int) error {
-	company, err := cs.companyStore.GetByID(context.Background(), id)
-	if err != nil {
+	if err := cs.authorize(id, userId, types.CompanyPermOwner); err != nil {
 		return err
 	}
 
-	if company.OwnerID != userId {
-		return types.Unauthorized(fmt.Sprintf("user isnt the owner of the company with id %v", id))
+	cars, err := cs.carStore.GetBatch(context.Background(), []*types.QueryFilter{{Field: "company_id", Operator: "=", Value: id}}, nil)
+	if err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to get company cars, %v", err))
+	}
+	for _, car := range cars {
+		booked, err := cs.bookingStore.CountByCarID(context.Background(), car.ID)
+		if err != nil {
+			return types.DatabaseError(err)
+		}
+		if booked > 0 {
+			return types.Conflict(fmt.Sprintf("car %d of the company has bookings, retire the cars instead of deleting the company", car.ID))
+		}
 	}
 
 	if err := cs.companyStore.Delete(context.Background(), id); err != nil {
@@ -94,3 +132,87 @@ func (s *CompanyService) GetBatch(filters []*types.QueryFilter, opts *types.Quer
 
 	return companies, nil
 }
+
+// rules set for the car, or company wide ones when car id is nil
+func (s *CompanyService) GetRentalRules(companyId int, carId *int) (*types.RentalRules, error) {
+	rules, err := s.companyStore.GetRentalRules(context.Background(), companyId, carId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get rental rules, %v", err))
+	} else if rules == nil {
+		return nil, types.NotFound("rental rules")
+	}
+	return rules, nil
+}
+
+func (s *CompanyService) SetRentalRules(companyId int, userId int, payload *types.RentalRulesPayload) error {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return err
+	}
+	// rules of a single car can only be set by the company renting it
+	if payload.CarID != nil {
+		car, err := s.carStore.GetByID(context.Background(), *payload.CarID)
+		if err != nil || car.CompanyID != companyId {
+			return types.NotFound(fmt.Sprintf("car %d of company %d", *payload.CarID, companyId))
+		}
+	}
+
+	rules, err := s.companyStore.GetRentalRules(context.Background(), companyId, payload.CarID)
+	if err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to get rental rules, %v", err))
+	} else if rules == nil {
+		rules = &types.RentalRules{CompanyID: companyId, CarID: payload.CarID}
+	}
+
+	rules.MinAge = payload.MinAge
+	rules.MinYearsLicensed = payload.MinYearsLicensed
+	rules.YoungDriverAge = payload.YoungDriverAge
+	rules.YoungDriverSurcharge = payload.YoungDriverSurcharge
+	rules.LicenseCategory = strings.ToUpper(payload.LicenseCategory)
+	rules.AdditionalDriverFee = payload.AdditionalDriverFee
+	rules.NoShowGraceHours = payload.NoShowGraceHours
+	rules.NoShowFee = payload.NoShowFee
+
+	if err := s.companyStore.SaveRentalRules(context.Background(), rules); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to save rental rules, %v", err))
+	}
+
+	return nil
+}
+
+func (s *CompanyService) GetDynamicPricing(companyId int, userId int) (*types.DynamicPricing, error) {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return nil, err
+	}
+
+	pricing, err := s.companyStore.GetDynamicPricing(context.Background(), companyId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get dynamic pricing, %v", err))
+	} else if pricing == nil {
+		return nil, types.NotFound("dynamic pricing")
+	}
+	return pricing, nil
+}
+
+func (s *CompanyService) SetDynamicPricing(companyId int, userId int, payload *types.DynamicPricingPayload) error {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return err
+	}
+
+	pricing := &types.DynamicPricing{
+		CompanyID:          companyId,
+		Enabled:            payload.Enabled,
+		TargetUtilisation:  payload.TargetUtilisation,
+		UtilisationFactor:  payload.UtilisationFactor,
+		LastMinuteDays:     payload.LastMinuteDays,
+		LastMinuteFactor:   payload.LastMinuteFactor,
+		EarlyBookingDays:   payload.EarlyBookingDays,
+		EarlyBookingFactor: payload.EarlyBookingFactor,
+		Floor:              payload.Floor,
+		Ceiling:            payload.Ceiling,
+	}
+	if err := s.companyStore.SaveDynamicPricing(context.Background(), pricing); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to save dynamic pricing, %v", err))
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+func branchFromPayload(branch *types.Branch, payload *types.BranchPayload) error {
+	branch.Name = payload.Name
+	branch.Address = payload.Address
+	branch.Latitude = payload.Latitude
+	branch.Longitude = payload.Longitude
+	branch.TimeZone = payload.TimeZone
+	branch.OutOfHoursFee = payload.OutOfHoursFee
+
+	branch.OpeningHours = make([]*types.OpeningHours, len(payload.OpeningHours))
+	for i, h := range payload.OpeningHours {
+		if h.Opens >= h.Closes {
+			return types.BadRequest(fmt.Sprintf("opening hours %s - %s: branch has to open before it closes", h.Opens, h.Closes))
+		}
+		branch.OpeningHours[i] = &types.OpeningHours{Weekday: time.Weekday(h.Weekday), Opens: h.Opens, Closes: h.Closes}
+	}
+
+	branch.Closures = make([]*types.BranchClosure, len(payload.Closures))
+	for i, c := range payload.Closures {
+		date, err := time.Parse(time.DateOnly, c.Date)
+		if err != nil {
+			return types.InternalServerError(err.Error())
+		}
+		branch.Closures[i] = &types.BranchClosure{Date: date, Reason: c.Reason}
+	}
+	return nil
+}
+
+func (s *CompanyService) CreateBranch(companyId int, userId int, payload *types.BranchPayload) (*types.Branch, error) {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return nil, err
+	}
+
+	branch := &types.Branch{CompanyID: companyId}
+	if err := branchFromPayload(branch, payload); err != nil {
+		return nil, err
+	}
+	if err := s.companyStore.CreateBranch(context.Background(), branch); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to create branch, %v", err))
+	}
+	return branch, nil
+}
+
+func (s *CompanyService) GetBranches(companyId int) ([]*types.Branch, error) {
+	if _, err := s.companyStore.GetByID(context.Background(), companyId); err != nil {
+		return nil, err
+	}
+
+	branches, err := s.companyStore.GetBranches(context.Background(), companyId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get branches, %v", err))
+	}
+	if branches == nil {
+		branches = make([]*types.Branch, 0)
+	}
+	return branches, nil
+}
+
+// branch of the company, not found for branches of other companies
+func (s *CompanyService) GetBranch(companyId int, branchId int) (*types.Branch, error) {
+	branch, err := s.companyStore.GetBranch(context.Background(), branchId)
+	if err != nil {
+		return nil, err
+	}
+	if branch.CompanyID != companyId {
+		return nil, types.NotFound(fmt.Sprintf("branch %d of company %d", branchId, companyId))
+	}
+	return branch, nil
+}
+
+// replaces the branch details including all opening hours and closures,
+// existing bookings keep their times
+func (s *CompanyService) UpdateBranch(companyId int, branchId int, userId int, payload *types.BranchPayload) (*types.Branch, error) {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return nil, err
+	}
+
+	branch, err := s.GetBranch(companyId, branchId)
+	if err != nil {
+		return nil, err
+	}
+	if err := branchFromPayload(branch, payload); err != nil {
+		return nil, err
+	}
+	if err := s.companyStore.UpdateBranch(context.Background(), branch); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to update branch, %v", err))
+	}
+	return branch, nil
+}
+
+func (s *CompanyService) DeleteBranch(companyId int, branchId int, userId int) error {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return err
+	}
+
+	if _, err := s.GetBranch(companyId, branchId); err != nil {
+		return err
+	}
+	if err := s.companyStore.DeleteBranch(context.Background(), branchId); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to delete branch, %v", err))
+	}
+	return nil
+}
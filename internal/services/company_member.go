@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/store"
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// active members whose role has the permission manage the company, admins manage every company
+func authorizeMember(ctx context.Context, companies store.CompanyStore, users store.UserStore, companyId int, userId int, perm types.CompanyPermission) error {
+	member, err := companies.GetMembership(ctx, companyId, userId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	if member != nil && member.Role.Can(perm) {
+		return nil
+	}
+
+	if user, err := users.GetByID(ctx, userId); err == nil && user != nil && user.Role == types.UserTypeAdmin {
+		return nil
+	}
+	if member != nil {
+		return types.Unauthorized(fmt.Sprintf("%s of the company with id %v isnt allowed to do that", member.Role, companyId))
+	}
+	return types.Unauthorized(fmt.Sprintf("user isnt a member of the company with id %v", companyId))
+}
+
+// checks the user works with the permission for the company renting the car
+func (s *BookingService) AuthorizeCar(carId int, userId int, perm types.CompanyPermission) error {
+	car, err := s.carStore.GetByID(context.Background(), carId)
+	if err != nil {
+		return types.NotFound(fmt.Sprintf("car %d", carId))
+	}
+	return authorizeMember(context.Background(), s.companyStore, s.userStore, car.CompanyID, userId, perm)
+}
+
+func (s *CompanyService) authorize(companyId int, userId int, perm types.CompanyPermission) error {
+	if _, err := s.companyStore.GetByID(context.Background(), companyId); err != nil {
+		return err
+	}
+	return authorizeMember(context.Background(), s.companyStore, s.userStore, companyId, userId, perm)
+}
+
+// owners and managers are added and removed only by owners
+func memberPermission(role types.CompanyRole) types.CompanyPermission {
+	if role == types.CompanyRoleOwner || role == types.CompanyRoleManager {
+		return types.CompanyPermOwner
+	}
+	return types.CompanyPermMembers
+}
+
+func (s *CompanyService) GetMembers(companyId int, userId int) ([]*types.CompanyMember, error) {
+	if err := s.authorize(companyId, userId, types.CompanyPermMembers); err != nil {
+		return nil, err
+	}
+
+	members, err := s.companyStore.GetMembers(context.Background(), companyId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get company members, %v", err))
+	}
+	if members == nil {
+		members = make([]*types.CompanyMember, 0)
+	}
+	return members, nil
+}
+
+// invitation is sent by email, the person joins after accepting it with the account of that email
+func (s *CompanyService) InviteMember(companyId int, userId int, payload *types.InviteMemberPayload) (*types.CompanyMember, error) {
+	ctx := context.Background()
+
+	if err := s.authorize(companyId, userId, memberPermission(payload.Role)); err != nil {
+		return nil, err
+	}
+
+	members, err := s.companyStore.GetMembers(ctx, companyId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get company members, %v", err))
+	}
+	email := strings.ToLower(payload.Email)
+	invitee, err := s.userStore.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	for _, m := range members {
+		if strings.EqualFold(m.Email, email) || (invitee != nil && m.UserID != nil && *m.UserID == invitee.ID) {
+			return nil, types.Conflict(fmt.Sprintf("%s is already %s as %s", email, m.Status, m.Role))
+		}
+	}
+
+	member := &types.CompanyMember{
+		CompanyID: companyId,
+		Email:     email,
+		Role:      payload.Role,
+		Status:    types.CompanyMemberInvited,
+		InvitedBy: &userId,
+	}
+	if err := s.companyStore.CreateMember(ctx, member); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to invite company member, %v", err))
+	}
+
+	if company, err := s.companyStore.GetByID(ctx, companyId); err == nil {
+		_ = s.notifier.Notify(ctx, email, fmt.Sprintf("Invitation to %s", company.Name),
+			fmt.Sprintf("you have been invited to join %s as %s, log in or register with this email and accept the invitation to company %d",
+				company.Name, member.Role, companyId))
+	}
+	return member, nil
+}
+
+// invitation of the company sent to the email of the user
+func (s *CompanyService) AcceptInvitation(companyId int, userId int) (*types.CompanyMember, error) {
+	ctx := context.Background()
+
+	user, err := s.userStore.GetByID(ctx, userId)
+	if err != nil || user == nil {
+		return nil, types.NotFound("user")
+	}
+
+	members, err := s.companyStore.GetMembers(ctx, companyId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get company members, %v", err))
+	}
+	for _, m := range members {
+		if m.Status != types.CompanyMemberInvited || !strings.EqualFold(m.Email, user.Email) {
+			continue
+		}
+
+		now := time.Now().UTC()
+		m.UserID = &user.ID
+		m.Status = types.CompanyMemberActive
+		m.Joined = &now
+		if err := s.companyStore.UpdateMember(ctx, m); err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to accept the invitation, %v", err))
+		}
+		return m, nil
+	}
+	return nil, types.NotFound("company invitation for the user")
+}
+
+// members can leave on their own, the last owner has to stay so the company isn't left without one
+func (s *CompanyService) RemoveMember(companyId int, memberId int, userId int) error {
+	ctx := context.Background()
+
+	member, err := s.companyStore.GetMember(ctx, memberId)
+	if err != nil {
+		return err
+	}
+	if member.CompanyID != companyId {
+		return types.NotFound(fmt.Sprintf("member %d of company %d", memberId, companyId))
+	}
+
+	if member.UserID == nil || *member.UserID != userId {
+		if err := s.authorize(companyId, userId, memberPermission(member.Role)); err != nil {
+			return err
+		}
+	}
+
+	if member.Role == types.CompanyRoleOwner && member.Status == types.CompanyMemberActive {
+		members, err := s.companyStore.GetMembers(ctx, companyId)
+		if err != nil {
+			return types.DatabaseError(fmt.Errorf("failed to get company members, %v", err))
+		}
+		owners := 0
+		for _, m := range members {
+			if m.Role == types.CompanyRoleOwner && m.Status == types.CompanyMemberActive {
+				owners++
+			}
+		}
+		if owners == 1 {
+			return types.BadRequest("the last owner cannot be removed from the company")
+		}
+	}
+
+	if err := s.companyStore.DeleteMember(ctx, memberId); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to remove company member, %v", err))
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// picked up bookings are done after their last day, there is no separate return yet
+func bookingCompleted(book *types.Booking) bool {
+	return book.Status == types.BookingStatusPickedUp && !today().Before(book.EndDate)
+}
+
+// renter rates the car and the company once the booking is over
+func (s *CompanyService) CreateReview(bookingId int, userId int, payload *types.CreateReviewPayload) (*types.Review, error) {
+	ctx := context.Background()
+
+	book, err := s.bookingStore.GetByID(ctx, bookingId)
+	if err != nil {
+		return nil, types.NotFound(fmt.Sprintf("booking %d", bookingId))
+	}
+	if book.UserID != userId {
+		return nil, types.Unauthorized("only the renter can review the booking")
+	}
+	if !bookingCompleted(book) {
+		return nil, types.BadRequest("only completed bookings can be reviewed")
+	}
+
+	existing, err := s.bookingStore.GetReviewByBooking(ctx, bookingId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	} else if existing != nil {
+		return nil, types.Conflict("booking is already reviewed")
+	}
+
+	car, err := s.carStore.GetByID(ctx, book.CarID)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	review := &types.Review{
+		BookingID:     bookingId,
+		UserID:        userId,
+		CarID:         car.ID,
+		CompanyID:     car.CompanyID,
+		CarRating:     payload.CarRating,
+		CompanyRating: payload.CompanyRating,
+		Comment:       payload.Comment,
+		Status:        types.ReviewStatusPublished,
+	}
+	if err := s.bookingStore.CreateReview(ctx, review); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	if err := s.updateRatings(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// hidden reviews are left out, reported ones stay visible until moderated
+func visibleReviews(field string, id int) []*types.QueryFilter {
+	return []*types.QueryFilter{
+		{Field: field, Operator: "=", Value: id},
+		{Field: "status", Operator: "!=", Value: types.ReviewStatusHidden},
+	}
+}
+
+func (s *CompanyService) GetCarReviews(carId int, opts *types.QueryOptions) ([]*types.Review, error) {
+	return s.getReviews(visibleReviews("car_id", carId), opts)
+}
+
+func (s *CompanyService) GetCompanyReviews(companyId int, opts *types.QueryOptions) ([]*types.Review, error) {
+	return s.getReviews(visibleReviews("company_id", companyId), opts)
+}
+
+// moderation queue for admins
+func (s *CompanyService) GetReportedReviews(opts *types.QueryOptions) ([]*types.Review, error) {
+	return s.getReviews([]*types.QueryFilter{{Field: "status", Operator: "=", Value: types.ReviewStatusReported}}, opts)
+}
+
+func (s *CompanyService) getReviews(filters []*types.QueryFilter, opts *types.QueryOptions) ([]*types.Review, error) {
+	reviews, err := s.bookingStore.GetReviews(context.Background(), filters, opts)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	if reviews == nil {
+		reviews = make([]*types.Review, 0)
+	}
+	return reviews, nil
+}
+
+// staff of the reviewed company answers publicly, the reply can be edited
+func (s *CompanyService) ReplyToReview(reviewId int, userId int, payload *types.ReviewReplyPayload) (*types.Review, error) {
+	ctx := context.Background()
+
+	review, err := s.bookingStore.GetReview(ctx, reviewId)
+	if err != nil {
+		return nil, types.NotFound(fmt.Sprintf("review %d", reviewId))
+	}
+	if err := s.authorize(review.CompanyID, userId, types.CompanyPermBookings); err != nil {
+		return nil, err
+	}
+	if review.Status == types.ReviewStatusHidden {
+		return nil, types.BadRequest("hidden reviews cannot be replied to")
+	}
+
+	now := time.Now().UTC()
+	review.Reply = &payload.Reply
+	review.Replied = &now
+	if err := s.bookingStore.UpdateReview(ctx, review); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	return review, nil
+}
+
+// anyone can report a review, it goes to the moderation queue
+func (s *CompanyService) ReportReview(reviewId int, payload *types.ReportReviewPayload) error {
+	ctx := context.Background()
+
+	review, err := s.bookingStore.GetReview(ctx, reviewId)
+	if err != nil {
+		return types.NotFound(fmt.Sprintf("review %d", reviewId))
+	}
+	if review.Status != types.ReviewStatusPublished {
+		return types.BadRequest(fmt.Sprintf("review is already %s", review.Status))
+	}
+
+	review.Status = types.ReviewStatusReported
+	review.ReportReason = &payload.Reason
+	if err := s.bookingStore.UpdateReview(ctx, review); err != nil {
+		return types.DatabaseError(err)
+	}
+	return nil
+}
+
+// admin keeps the review published or hides it, hidden reviews don't count to the ratings
+func (s *CompanyService) ModerateReview(reviewId int, payload *types.ModerateReviewPayload) (*types.Review, error) {
+	ctx := context.Background()
+
+	review, err := s.bookingStore.GetReview(ctx, reviewId)
+	if err != nil {
+		return nil, types.NotFound(fmt.Sprintf("review %d", reviewId))
+	}
+
+	review.Status = payload.Status
+	if err := s.bookingStore.UpdateReview(ctx, review); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	if err := s.updateRatings(ctx, review); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
+
+// averages of visible reviews stored on the car and the company, so listings can sort by them
+func (s *CompanyService) updateRatings(ctx context.Context, review *types.Review) error {
+	carReviews, err := s.bookingStore.GetReviews(ctx, visibleReviews("car_id", review.CarID), nil)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	if err := s.carStore.SetRating(ctx, review.CarID, averageRating(carReviews, func(r *types.Review) int { return r.CarRating })); err != nil {
+		return types.DatabaseError(err)
+	}
+
+	companyReviews, err := s.bookingStore.GetReviews(ctx, visibleReviews("company_id", review.CompanyID), nil)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	if err := s.companyStore.SetRating(ctx, review.CompanyID, averageRating(companyReviews, func(r *types.Review) int { return r.CompanyRating })); err != nil {
+		return types.DatabaseError(err)
+	}
+	return nil
+}
+
+func averageRating(reviews []*types.Review, rating func(*types.Review) int) types.Rating {
+	if len(reviews) == 0 {
+		return types.Rating{}
+	}
+	sum := 0
+	for _, r := range reviews {
+		sum += rating(r)
+	}
+	return types.Rating{
+		Rating:      math.Round(float64(sum)/float64(len(reviews))*100) / 100,
+		RatingCount: len(reviews),
+	}
+}
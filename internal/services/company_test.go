@@ -1,14 +1,24 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
 	"testing"
 
+	"github.com/mwdev22/CarRental/internal/notify"
+	"github.com/mwdev22/CarRental/internal/storage"
 	"github.com/mwdev22/CarRental/internal/store/mock"
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 func TestCompanyService(t *testing.T) {
-	companyService := NewCompanyService(mock.NewCompanyRepository())
+	companyStore := mock.NewCompanyRepository()
+	carStore := mock.NewCarRepository(companyStore)
+	companyService := NewCompanyService(companyStore, carStore, mock.NewBookingStore(carStore, companyStore), mock.NewUserRepository(), notify.NewLogNotifier(log.Default()),
+		storage.NewLocalStorage(t.TempDir(), "/uploads/"))
 	companyOwnerID := 1
 
 	t.Run("CreateCompany", func(t *testing.T) {
@@ -47,6 +57,186 @@ func TestCompanyService(t *testing.T) {
 		}
 	})
 
+	t.Run("Members", func(t *testing.T) {
+		ctx := context.Background()
+		users := make([]*types.User, 3)
+		for i, name := range []string{"owner", "manager", "agent"} {
+			users[i] = &types.User{Username: name, Email: name + "@blabla.com", Role: types.UserTypeUser}
+			if err := companyService.userStore.Create(ctx, users[i]); err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+		}
+		owner, manager, agent := users[0], users[1], users[2]
+
+		company := &types.Company{OwnerID: owner.ID, Name: "membercompany"}
+		if err := companyService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+
+		invite := func(userId int, email string, role types.CompanyRole) error {
+			_, err := companyService.InviteMember(company.ID, userId, &types.InviteMemberPayload{Email: email, Role: role})
+			return err
+		}
+		if err := invite(manager.ID, agent.Email, types.CompanyRoleAgent); err == nil {
+			t.Errorf("expected an error for invitation by user outside the company")
+		}
+		if err := invite(owner.ID, manager.Email, types.CompanyRoleManager); err != nil {
+			t.Fatalf("failed to invite manager: %v", err)
+		}
+		if err := invite(owner.ID, "MANAGER@blabla.com", types.CompanyRoleAgent); err == nil {
+			t.Errorf("expected an error for second invitation of the same email")
+		}
+		// invited managers can't do anything before they accept
+		if err := companyService.SetRentalRules(company.ID, manager.ID, &types.RentalRulesPayload{MinAge: 21}); err == nil {
+			t.Errorf("expected an error for rules set by invited manager")
+		}
+		if _, err := companyService.AcceptInvitation(company.ID, agent.ID); err == nil {
+			t.Errorf("expected an error accepting invitation sent to another email")
+		}
+		if _, err := companyService.AcceptInvitation(company.ID, manager.ID); err != nil {
+			t.Fatalf("failed to accept invitation: %v", err)
+		}
+		if err := companyService.SetRentalRules(company.ID, manager.ID, &types.RentalRulesPayload{MinAge: 21}); err != nil {
+			t.Errorf("failed to set rules as manager: %v", err)
+		}
+
+		// rules of a single car only for cars of the company
+		own := &types.Car{Make: "make", Model: "model", RegistrationNo: "MEMBER1", CompanyID: company.ID}
+		foreign := &types.Car{Make: "make", Model: "model", RegistrationNo: "MEMBER2", CompanyID: company.ID + 1}
+		for _, car := range []*types.Car{own, foreign} {
+			if err := companyService.carStore.Create(ctx, car); err != nil {
+				t.Fatalf("failed to create car: %v", err)
+			}
+		}
+		if err := companyService.SetRentalRules(company.ID, manager.ID, &types.RentalRulesPayload{CarID: &foreign.ID, MinAge: 23}); err == nil {
+			t.Errorf("expected an error for rules of car of another company")
+		}
+		if err := companyService.SetRentalRules(company.ID, manager.ID, &types.RentalRulesPayload{CarID: &own.ID, MinAge: 23}); err != nil {
+			t.Errorf("failed to set rules of company car: %v", err)
+		}
+
+		// managers invite staff but not other managers or owners
+		if err := invite(manager.ID, "boss@blabla.com", types.CompanyRoleOwner); err == nil {
+			t.Errorf("expected an error for owner invited by manager")
+		}
+		if err := invite(manager.ID, agent.Email, types.CompanyRoleAgent); err != nil {
+			t.Fatalf("failed to invite agent: %v", err)
+		}
+		if _, err := companyService.AcceptInvitation(company.ID, agent.ID); err != nil {
+			t.Fatalf("failed to accept invitation: %v", err)
+		}
+		if err := companyService.SetRentalRules(company.ID, agent.ID, &types.RentalRulesPayload{MinAge: 25}); err == nil {
+			t.Errorf("expected an error for rules set by agent")
+		}
+		if _, err := companyService.GetMembers(company.ID, agent.ID); err == nil {
+			t.Errorf("expected an error for members listed by agent")
+		}
+
+		members, err := companyService.GetMembers(company.ID, manager.ID)
+		if err != nil {
+			t.Fatalf("failed to get members: %v", err)
+		}
+		if len(members) != 3 {
+			t.Fatalf("expected owner, manager and agent, got %v", members)
+		}
+		if err := companyService.RemoveMember(company.ID, members[1].ID, agent.ID); err == nil {
+			t.Errorf("expected an error for manager removed by agent")
+		}
+		if err := companyService.RemoveMember(company.ID, members[0].ID, owner.ID); err == nil {
+			t.Errorf("expected an error for removing the last owner")
+		}
+		// agent leaves on his own
+		if err := companyService.RemoveMember(company.ID, members[2].ID, agent.ID); err != nil {
+			t.Errorf("failed to leave the company: %v", err)
+		}
+		if err := companyService.RemoveMember(company.ID, members[1].ID, owner.ID); err != nil {
+			t.Errorf("failed to remove manager: %v", err)
+		}
+	})
+
+	t.Run("Verification", func(t *testing.T) {
+		ctx := context.Background()
+		owner := &types.User{Username: "verifyowner", Email: "verifyowner@blabla.com", Role: types.UserTypeCompanyOwner}
+		if err := companyService.userStore.Create(ctx, owner); err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+		company := &types.Company{OwnerID: owner.ID, Name: "verifycompany", Status: types.CompanyStatusPending}
+		if err := companyService.companyStore.Create(ctx, company); err != nil {
+			t.Fatalf("failed to create company: %v", err)
+		}
+		verify := func(status types.CompanyStatus, reason string) (*types.Company, error) {
+			return companyService.Verify(company.ID, &types.VerifyCompanyPayload{Status: status, Reason: reason})
+		}
+
+		if _, err := verify(types.CompanyStatusVerified, ""); err == nil {
+			t.Errorf("expected an error verifying company without documents")
+		}
+		if _, err := companyService.AddDocument(company.ID, owner.ID, "registration", strings.NewReader("plain text")); err == nil {
+			t.Errorf("expected an error for unsupported document type")
+		}
+		if _, err := companyService.AddDocument(company.ID, 999, "registration", strings.NewReader("%PDF-1.4")); err == nil {
+			t.Errorf("expected an error for document uploaded by user outside the company")
+		}
+		document, err := companyService.AddDocument(company.ID, owner.ID, "registration", strings.NewReader("%PDF-1.4"))
+		if err != nil {
+			t.Fatalf("failed to add document: %v", err)
+		}
+		if document.ContentType != "application/pdf" || document.URL != fmt.Sprintf("/company/%d/documents/%d", company.ID, document.ID) {
+			t.Errorf("expected pdf document served by the api, got %+v", document)
+		}
+		if _, _, err := companyService.OpenDocument(company.ID, document.ID, 999); err == nil {
+			t.Errorf("expected an error for document opened by user outside the company")
+		}
+		_, file, err := companyService.OpenDocument(company.ID, document.ID, owner.ID)
+		if err != nil {
+			t.Fatalf("failed to open document: %v", err)
+		}
+		content, _ := io.ReadAll(file)
+		file.Close()
+		if string(content) != "%PDF-1.4" {
+			t.Errorf("expected uploaded content, got %q", content)
+		}
+
+		awaiting, err := companyService.GetAwaitingVerification()
+		if err != nil || len(awaiting) != 1 || awaiting[0].ID != company.ID {
+			t.Errorf("expected company %d awaiting verification, got %v, %v", company.ID, awaiting, err)
+		}
+
+		rejected, err := verify(types.CompanyStatusRejected, "registration is unreadable")
+		if err != nil {
+			t.Fatalf("failed to reject company: %v", err)
+		}
+		if rejected.RejectionReason == nil || *rejected.RejectionReason != "registration is unreadable" {
+			t.Errorf("expected rejection reason, got %v", rejected.RejectionReason)
+		}
+		if _, err := verify(types.CompanyStatusVerified, ""); err == nil {
+			t.Errorf("expected an error verifying rejected company before it uploads another document")
+		}
+
+		// another document sends the company back to the queue
+		if _, err := companyService.AddDocument(company.ID, owner.ID, "registration", strings.NewReader("%PDF-1.4 readable")); err != nil {
+			t.Fatalf("failed to add document: %v", err)
+		}
+		if c, _ := companyService.GetByID(company.ID); c.Status != types.CompanyStatusPending || c.RejectionReason != nil {
+			t.Errorf("expected pending company without rejection reason, got %s %v", c.Status, c.RejectionReason)
+		}
+
+		verified, err := verify(types.CompanyStatusVerified, "")
+		if err != nil {
+			t.Fatalf("failed to verify company: %v", err)
+		}
+		if verified.Status != types.CompanyStatusVerified || verified.Verified == nil {
+			t.Errorf("expected verified company, got %+v", verified)
+		}
+		if err := companyService.DeleteDocument(company.ID, document.ID, owner.ID); err == nil {
+			t.Errorf("expected an error deleting document of verified company")
+		}
+		documents, err := companyService.GetDocuments(company.ID, owner.ID)
+		if err != nil || len(documents) != 2 {
+			t.Errorf("expected 2 documents, got %v, %v", documents, err)
+		}
+	})
+
 	t.Run("DeleteCompany", func(t *testing.T) {
 		tests := []struct {
 			name        string
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/store"
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+const MaxDocumentSize = 10 << 20 // 10MB
+
+// accepted business documents with their file extensions
+var documentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// filter limiting cars to the ones of verified companies
+func verifiedCompanies() *types.QueryFilter {
+	return &types.QueryFilter{Field: types.CompanyStatusFilter, Operator: "=", Value: types.CompanyStatusVerified}
+}
+
+// cars of pending and rejected companies can't be booked
+func checkVerified(ctx context.Context, companies store.CompanyStore, companyId int) error {
+	company, err := companies.GetByID(ctx, companyId)
+	if err != nil {
+		return err
+	}
+	if company.Status != types.CompanyStatusVerified {
+		return types.BadRequest("company renting the car isn't verified yet")
+	}
+	return nil
+}
+
+// documents are private, they are downloaded through the api with authorization
+func withDocumentURLs(documents []*types.CompanyDocument) []*types.CompanyDocument {
+	for _, document := range documents {
+		document.URL = fmt.Sprintf("/company/%d/documents/%d", document.CompanyID, document.ID)
+	}
+	return documents
+}
+
+func (s *CompanyService) GetDocuments(companyId int, userId int) ([]*types.CompanyDocument, error) {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return nil, err
+	}
+
+	documents, err := s.companyStore.GetDocuments(context.Background(), companyId)
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get company documents, %v", err))
+	}
+	if documents == nil {
+		documents = make([]*types.CompanyDocument, 0)
+	}
+	return withDocumentURLs(documents), nil
+}
+
+// document with its content, the caller closes it
+func (s *CompanyService) OpenDocument(companyId int, documentId int, userId int) (*types.CompanyDocument, io.ReadCloser, error) {
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return nil, nil, err
+	}
+
+	document, err := s.companyDocument(companyId, documentId)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := s.documents.Open(context.Background(), document.Key)
+	if err != nil {
+		return nil, nil, types.InternalServerError(err.Error())
+	}
+	return document, file, nil
+}
+
+func (s *CompanyService) companyDocument(companyId int, documentId int) (*types.CompanyDocument, error) {
+	document, err := s.companyStore.GetDocument(context.Background(), documentId)
+	if err != nil {
+		return nil, err
+	}
+	if document.CompanyID != companyId {
+		return nil, types.NotFound(fmt.Sprintf("document %d of company %d", documentId, companyId))
+	}
+	return document, nil
+}
+
+// type is detected from the content, rejected companies go back to the verification queue
+// with the new document
+func (s *CompanyService) AddDocument(companyId int, userId int, name string, file io.Reader) (*types.CompanyDocument, error) {
+	ctx := context.Background()
+
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return nil, types.BadRequest("document name is required and cannot be longer than 100 characters")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, MaxDocumentSize+1))
+	if err != nil {
+		return nil, types.BadRequest("failed to read the document")
+	} else if len(data) > MaxDocumentSize {
+		return nil, types.BadRequest(fmt.Sprintf("document cannot be larger than %d MB", MaxDocumentSize>>20))
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := documentTypes[contentType]
+	if !ok {
+		return nil, types.BadRequest(fmt.Sprintf("unsupported document type %s, use pdf, jpeg or png", contentType))
+	}
+
+	fileName, err := randomName()
+	if err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+
+	document := &types.CompanyDocument{
+		CompanyID:   companyId,
+		Name:        name,
+		Key:         fmt.Sprintf("companies/%d/%s%s", companyId, fileName, ext),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+	}
+	if err := s.documents.Save(ctx, document.Key, bytes.NewReader(data)); err != nil {
+		return nil, types.InternalServerError(err.Error())
+	}
+	if err := s.companyStore.AddDocument(ctx, document); err != nil {
+		_ = s.documents.Delete(ctx, document.Key)
+		return nil, types.DatabaseError(fmt.Errorf("failed to save company document, %v", err))
+	}
+
+	company, err := s.companyStore.GetByID(ctx, companyId)
+	if err != nil {
+		return nil, err
+	}
+	if company.Status == types.CompanyStatusRejected {
+		company.Status = types.CompanyStatusPending
+		company.RejectionReason = nil
+		if err := s.companyStore.SetVerification(ctx, company); err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to update company verification, %v", err))
+		}
+	}
+
+	return withDocumentURLs([]*types.CompanyDocument{document})[0], nil
+}
+
+// documents of verified companies are kept as the proof they were verified with
+func (s *CompanyService) DeleteDocument(companyId int, documentId int, userId int) error {
+	ctx := context.Background()
+
+	if err := s.authorize(companyId, userId, types.CompanyPermManage); err != nil {
+		return err
+	}
+
+	document, err := s.companyDocument(companyId, documentId)
+	if err != nil {
+		return err
+	}
+
+	company, err := s.companyStore.GetByID(ctx, companyId)
+	if err != nil {
+		return err
+	}
+	if company.Status == types.CompanyStatusVerified {
+		return types.BadRequest("documents of verified companies cannot be deleted")
+	}
+
+	if err := s.companyStore.DeleteDocument(ctx, documentId); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to delete company document, %v", err))
+	}
+	// file leftovers don't break anything, the record is already gone
+	_ = s.documents.Delete(ctx, document.Key)
+	return nil
+}
+
+// verification queue of the admins, oldest companies first
+func (s *CompanyService) GetAwaitingVerification() ([]types.Company, error) {
+	companies, err := s.companyStore.GetAwaitingVerification(context.Background())
+	if err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to get companies awaiting verification, %v", err))
+	}
+	if companies == nil {
+		companies = make([]types.Company, 0)
+	}
+	return companies, nil
+}
+
+// pending companies with documents can be verified, verified ones can still be rejected later,
+// which hides their cars again
+func (s *CompanyService) Verify(companyId int, payload *types.VerifyCompanyPayload) (*types.Company, error) {
+	ctx := context.Background()
+
+	company, err := s.companyStore.GetByID(ctx, companyId)
+	if err != nil {
+		return nil, err
+	}
+	if company.Status == payload.Status {
+		return nil, types.BadRequest(fmt.Sprintf("company is already %s", company.Status))
+	}
+
+	switch payload.Status {
+	case types.CompanyStatusVerified:
+		if company.Status != types.CompanyStatusPending {
+			return nil, types.BadRequest("rejected company has to upload another document before it can be verified")
+		}
+		documents, err := s.companyStore.GetDocuments(ctx, companyId)
+		if err != nil {
+			return nil, types.DatabaseError(fmt.Errorf("failed to get company documents, %v", err))
+		}
+		if len(documents) == 0 {
+			return nil, types.BadRequest("company hasn't uploaded any documents")
+		}
+		now := time.Now().UTC()
+		company.Verified = &now
+		company.RejectionReason = nil
+	case types.CompanyStatusRejected:
+		reason := payload.Reason
+		company.Verified = nil
+		company.RejectionReason = &reason
+	}
+	company.Status = payload.Status
+
+	if err := s.companyStore.SetVerification(ctx, company); err != nil {
+		return nil, types.DatabaseError(fmt.Errorf("failed to update company verification, %v", err))
+	}
+
+	message := fmt.Sprintf("%s was verified, its cars are now listed and can be booked", company.Name)
+	if company.Status == types.CompanyStatusRejected {
+		message = fmt.Sprintf("verification of %s was rejected: %s\nupload another document to have it reviewed again", company.Name, payload.Reason)
+	}
+	_ = s.notifier.Notify(ctx, company.Email, fmt.Sprintf("Verification of %s", company.Name), message)
+
+	return company, nil
+}
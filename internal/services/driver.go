@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// full years passed between from and at
+func yearsBetween(from, at time.Time) int {
+	years := at.Year() - from.Year()
+	// days of the year shift after february in leap years, so month and day are compared
+	if at.Month() < from.Month() || (at.Month() == from.Month() && at.Day() < from.Day()) {
+		years--
+	}
+	return years
+}
+
+func hasLicenseCategory(categories string, required string) bool {
+	for _, c := range strings.Split(categories, ",") {
+		if strings.EqualFold(strings.TrimSpace(c), required) {
+			return true
+		}
+	}
+	return false
+}
+
+// checks the driver against rental rules for the whole rental period,
+// returns validation errors keyed by the failing field
+func checkEligibility(profile *types.DriverProfile, rules *types.RentalRules, startDate, endDate time.Time) map[string]string {
+	errors := make(map[string]string)
+	if profile == nil {
+		errors["DriverProfile"] = "driver profile with license details is required to rent this car"
+		return errors
+	}
+
+	if age := yearsBetween(profile.DateOfBirth, startDate); age < rules.MinAge {
+		errors["DateOfBirth"] = fmt.Sprintf("driver must be at least %d years old, is %d", rules.MinAge, age)
+	}
+	if years := yearsBetween(profile.LicenseIssued, startDate); years < rules.MinYearsLicensed {
+		errors["LicenseIssued"] = fmt.Sprintf("license must be held for at least %d years, is held for %d", rules.MinYearsLicensed, years)
+	}
+	if profile.LicenseExpiry.Before(endDate) {
+		errors["LicenseExpiry"] = fmt.Sprintf("license expires on %s, before the end of the rental", profile.LicenseExpiry.Format(time.DateOnly))
+	}
+	if rules.LicenseCategory != "" && !hasLicenseCategory(profile.LicenseCategory, rules.LicenseCategory) {
+		errors["LicenseCategory"] = fmt.Sprintf("license category %s is required", rules.LicenseCategory)
+	}
+
+	return errors
+}
+
+// daily surcharge for the driver, rules are expected to be already checked
+func driverSurcharge(profile *types.DriverProfile, rules *types.RentalRules, startDate time.Time) float64 {
+	if yearsBetween(profile.DateOfBirth, startDate) < rules.YoungDriverAge {
+		return rules.YoungDriverSurcharge
+	}
+	return 0
+}
+
+func parseDriverProfile(userId int, payload *types.UpdateDriverProfilePayload) (*types.DriverProfile, map[string]string) {
+	errors := make(map[string]string)
+	profile := &types.DriverProfile{
+		UserID:          userId,
+		LicenseNumber:   payload.LicenseNumber,
+		LicenseCountry:  strings.ToUpper(payload.LicenseCountry),
+		LicenseCategory: strings.ToUpper(payload.LicenseCategory),
+	}
+
+	// dates are already validated by the payload
+	profile.DateOfBirth, _ = time.Parse(time.DateOnly, payload.DateOfBirth)
+	profile.LicenseIssued, _ = time.Parse(time.DateOnly, payload.LicenseIssued)
+	profile.LicenseExpiry, _ = time.Parse(time.DateOnly, payload.LicenseExpiry)
+
+	if !profile.DateOfBirth.Before(profile.LicenseIssued) {
+		errors["LicenseIssued"] = "license cannot be issued before date of birth"
+	}
+	if !profile.LicenseIssued.Before(profile.LicenseExpiry) {
+		errors["LicenseExpiry"] = "license cannot expire before it was issued"
+	}
+
+	return profile, errors
+}
@@ -0,0 +1,31 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestYearsBetween(t *testing.T) {
+	tests := []struct {
+		from, at string
+		years    int
+	}{
+		{from: "2004-03-01", at: "2022-02-28", years: 17},
+		{from: "2004-03-01", at: "2022-03-01", years: 18},
+		{from: "2004-02-29", at: "2022-02-28", years: 17},
+		{from: "2004-02-29", at: "2022-03-01", years: 18},
+		{from: "2003-03-01", at: "2024-02-29", years: 20},
+		{from: "2006-12-31", at: "2024-12-31", years: 18},
+		{from: "2006-12-31", at: "2024-12-30", years: 17},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" "+tt.at, func(t *testing.T) {
+			from, _ := time.Parse(time.DateOnly, tt.from)
+			at, _ := time.Parse(time.DateOnly, tt.at)
+			if years := yearsBetween(from, at); years != tt.years {
+				t.Errorf("expected %d years, got %d", tt.years, years)
+			}
+		})
+	}
+}
@@ -7,18 +7,25 @@ import (
 
 	"github.com/golang-jwt/jwt"
 	"github.com/mwdev22/CarRental/internal/config"
+	"github.com/mwdev22/CarRental/internal/notify"
 	"github.com/mwdev22/CarRental/internal/store"
 	"github.com/mwdev22/CarRental/internal/types"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	userStore store.UserStore
+	userStore    store.UserStore
+	carStore     store.CarStore
+	companyStore store.CompanyStore
+	notifier     notify.Notifier
 }
 
-func NewUserService(userStore store.UserStore) *UserService {
+func NewUserService(userStore store.UserStore, carStore store.CarStore, companyStore store.CompanyStore, notifier notify.Notifier) *UserService {
 	return &UserService{
-		userStore: userStore,
+		userStore:    userStore,
+		carStore:     carStore,
+		companyStore: companyStore,
+		notifier:     notifier,
 	}
 }
 
@@ -116,3 +123,26 @@ func (s *UserService) Update(id int, payload *types.UpdateUserPayload) error {
 
 	return nil
 }
+
+func (s *UserService) GetDriverProfile(id int) (*types.DriverProfile, error) {
+	profile, err := s.userStore.GetDriverProfile(context.Background(), id)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	} else if profile == nil {
+		return nil, types.NotFound("driver profile")
+	}
+	return profile, nil
+}
+
+func (s *UserService) UpdateDriverProfile(id int, payload *types.UpdateDriverProfilePayload) error {
+	profile, errors := parseDriverProfile(id, payload)
+	if len(errors) > 0 {
+		return types.ValidationError(errors)
+	}
+
+	if err := s.userStore.SaveDriverProfile(context.Background(), profile); err != nil {
+		return types.DatabaseError(fmt.Errorf("failed to save driver profile: %v", err))
+	}
+
+	return nil
+}
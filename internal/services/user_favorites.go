@@ -0,0 +1,244 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
+)
+
+// most cars listed in one notification, the rest comes with the next run
+const maxSavedSearchCars = 50
+
+// filters and location of the saved query, checked the same way as GET /car/batch,
+// paging and sorting of the query are ignored
+func savedSearchFilters(query string) ([]*types.QueryFilter, *types.QueryOptions, error) {
+	r := &http.Request{URL: &url.URL{RawQuery: strings.TrimPrefix(query, "?")}}
+	filters, err := utils.ParseQueryFilters(r, types.CarQueryFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, f := range filters {
+		if f.Field == "status" {
+			return nil, nil, types.BadRequest("saved searches cannot filter by status")
+		}
+	}
+
+	opts, err := utils.ParseQueryOptions(r, types.CarQueryFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filters, &types.QueryOptions{Near: opts.Near, SortField: "id", SortDiretion: "asc", Limit: maxSavedSearchCars}, nil
+}
+
+// cars matching the saved search that are newer than the last one the user knows about,
+// newest first when sorted desc
+func (s *UserService) savedSearchCars(ctx context.Context, search *types.SavedSearch, direction string, extra ...*types.QueryFilter) ([]types.Car, error) {
+	filters, opts, err := savedSearchFilters(search.Query)
+	if err != nil {
+		return nil, err
+	}
+	opts.SortDiretion = direction
+	filters = append(filters, &types.QueryFilter{Field: "id", Operator: ">", Value: search.LastCarID})
+	filters = append(filters, extra...)
+	// cars of unverified companies aren't listed, so they aren't reported either
+	filters = append(filters, verifiedCompanies())
+
+	cars, err := s.carStore.GetBatch(ctx, filters, opts)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	return cars, nil
+}
+
+func lastCarID(cars []types.Car, id int) int {
+	for _, car := range cars {
+		id = max(id, car.ID)
+	}
+	return id
+}
+
+// cars matching the search already are not reported, only the ones added later
+func (s *UserService) SaveSearch(userId int, payload *types.SavedSearchPayload) (*types.SavedSearch, error) {
+	ctx := context.Background()
+
+	search := &types.SavedSearch{
+		UserID: userId,
+		Name:   payload.Name,
+		Query:  strings.TrimPrefix(payload.Query, "?"),
+		Notify: payload.Notify,
+	}
+	cars, err := s.savedSearchCars(ctx, search, "desc")
+	if err != nil {
+		return nil, err
+	}
+	search.LastCarID = lastCarID(cars, 0)
+
+	if err := s.userStore.CreateSavedSearch(ctx, search); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	return search, nil
+}
+
+func (s *UserService) GetSavedSearches(userId int) ([]*types.SavedSearch, error) {
+	searches, err := s.userStore.GetSavedSearches(context.Background(), userId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	if searches == nil {
+		searches = make([]*types.SavedSearch, 0)
+	}
+	return searches, nil
+}
+
+func (s *UserService) DeleteSavedSearch(userId int, searchId int) error {
+	searches, err := s.GetSavedSearches(userId)
+	if err != nil {
+		return err
+	}
+	for _, search := range searches {
+		if search.ID == searchId {
+			if err := s.userStore.DeleteSavedSearch(context.Background(), searchId); err != nil {
+				return types.DatabaseError(err)
+			}
+			return nil
+		}
+	}
+	return types.NotFound(fmt.Sprintf("saved search %d", searchId))
+}
+
+// emails users about available cars added to their saved searches since the last run
+func (s *UserService) NotifySavedSearches() error {
+	ctx := context.Background()
+
+	searches, err := s.userStore.GetNotifiedSavedSearches(ctx)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	// one broken search shouldn't stop the others
+	var errs []error
+	for _, search := range searches {
+		if err := s.notifySavedSearch(ctx, search); err != nil {
+			errs = append(errs, fmt.Errorf("saved search %d: %w", search.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *UserService) notifySavedSearch(ctx context.Context, search *types.SavedSearch) error {
+	cars, err := s.savedSearchCars(ctx, search, "asc", &types.QueryFilter{Field: "status", Operator: "=", Value: types.CarStatusAvailable})
+	if err != nil || len(cars) == 0 {
+		return err
+	}
+
+	lines := make([]string, len(cars))
+	for i, car := range cars {
+		lines[i] = fmt.Sprintf("%s %s (%d) for %.2f per day, car id %d", car.Make, car.Model, car.Year, car.PricePerDay, car.ID)
+	}
+	if user, err := s.userStore.GetByID(ctx, search.UserID); err == nil && user != nil {
+		_ = s.notifier.Notify(ctx, user.Email, fmt.Sprintf("New cars for %s", search.Name),
+			fmt.Sprintf("%d new cars match your saved search:\n%s", len(cars), strings.Join(lines, "\n")))
+	}
+
+	search.LastCarID = lastCarID(cars, search.LastCarID)
+	return s.userStore.UpdateSavedSearch(ctx, search)
+}
+
+// adding the car again only changes notify, price drops are counted from the current price
+func (s *UserService) AddFavorite(carId int, userId int, payload *types.FavoriteCarPayload) (*types.FavoriteCar, error) {
+	ctx := context.Background()
+
+	car, err := s.carStore.GetByID(ctx, carId)
+	if err != nil {
+		return nil, types.NotFound(fmt.Sprintf("car %d", carId))
+	}
+
+	favorite := &types.FavoriteCar{UserID: userId, CarID: carId, Notify: payload.Notify, Price: car.PricePerDay}
+	if err := s.userStore.SaveFavorite(ctx, favorite); err != nil {
+		return nil, types.DatabaseError(err)
+	}
+	favorite.Car = car
+	return favorite, nil
+}
+
+func (s *UserService) RemoveFavorite(carId int, userId int) error {
+	favorites, err := s.userStore.GetFavorites(context.Background(), userId)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+	for _, f := range favorites {
+		if f.CarID == carId {
+			if err := s.userStore.DeleteFavorite(context.Background(), userId, carId); err != nil {
+				return types.DatabaseError(err)
+			}
+			return nil
+		}
+	}
+	return types.NotFound(fmt.Sprintf("favorite car %d", carId))
+}
+
+// favourites with their cars, oldest first
+func (s *UserService) GetFavorites(userId int) ([]*types.FavoriteCar, error) {
+	ctx := context.Background()
+
+	favorites, err := s.userStore.GetFavorites(ctx, userId)
+	if err != nil {
+		return nil, types.DatabaseError(err)
+	}
+
+	result := make([]*types.FavoriteCar, 0, len(favorites))
+	for _, f := range favorites {
+		car, err := s.carStore.GetByID(ctx, f.CarID)
+		if err != nil {
+			continue
+		}
+		f.Car = car
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// emails users whose favourite cars got cheaper since they last heard about them
+func (s *UserService) NotifyPriceDrops() error {
+	ctx := context.Background()
+
+	favorites, err := s.userStore.GetNotifiedFavorites(ctx)
+	if err != nil {
+		return types.DatabaseError(err)
+	}
+
+	var errs []error
+	for _, f := range favorites {
+		if err := s.notifyPriceDrop(ctx, f); err != nil {
+			errs = append(errs, fmt.Errorf("favorite car %d of user %d: %w", f.CarID, f.UserID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *UserService) notifyPriceDrop(ctx context.Context, f *types.FavoriteCar) error {
+	car, err := s.carStore.GetByID(ctx, f.CarID)
+	if err != nil {
+		return err
+	}
+	if car.PricePerDay == f.Price {
+		return nil
+	}
+
+	// raises are only remembered, so the next drop is counted from the higher price
+	if car.PricePerDay < f.Price {
+		if user, err := s.userStore.GetByID(ctx, f.UserID); err == nil && user != nil {
+			_ = s.notifier.Notify(ctx, user.Email, "Price drop of your favourite car",
+				fmt.Sprintf("%s %s (%s) now costs %.2f per day instead of %.2f", car.Make, car.Model, car.RegistrationNo, car.PricePerDay, f.Price))
+		}
+	}
+
+	f.Price = car.PricePerDay
+	return s.userStore.SaveFavorite(ctx, f)
+}
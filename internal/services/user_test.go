@@ -1,14 +1,17 @@
 package services
 
 import (
+	"log"
 	"testing"
 
+	"github.com/mwdev22/CarRental/internal/notify"
 	"github.com/mwdev22/CarRental/internal/store/mock"
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 func TestUserService(t *testing.T) {
-	userService := NewUserService(mock.NewUserRepository())
+	companyStore := mock.NewCompanyRepository()
+	userService := NewUserService(mock.NewUserRepository(), mock.NewCarRepository(companyStore), companyStore, notify.NewLogNotifier(log.Default()))
 
 	t.Run("RegisterUser", func(t *testing.T) {
 		tests := []struct {
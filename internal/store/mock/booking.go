@@ -2,29 +2,67 @@ package mock
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 type BookingStore struct {
-	books map[int]*types.Booking
+	mu             sync.RWMutex
+	cars           *CarRepository
+	companies      *CompanyRepository
+	books          map[int]*types.Booking
+	series         map[int]*types.BookingSeries
+	drivers        map[int]*types.BookingDriver
+	transfers      map[int]*types.BookingTransfer
+	maintenance    map[int]*types.Maintenance
+	reviews        map[int]*types.Review
+	nextID         int
+	nextSeriesID   int
+	nextDriverID   int
+	nextTransferID int
+	nextMaintID    int
+	nextReviewID   int
 }
 
-func NewBookingStore() *BookingStore {
+// cars and companies stand in for the joined tables, no-shows are found with the rental rules of the car
+func NewBookingStore(cars *CarRepository, companies *CompanyRepository) *BookingStore {
 	return &BookingStore{
-		books: make(map[int]*types.Booking),
+		cars:           cars,
+		companies:      companies,
+		books:          make(map[int]*types.Booking),
+		series:         make(map[int]*types.BookingSeries),
+		drivers:        make(map[int]*types.BookingDriver),
+		transfers:      make(map[int]*types.BookingTransfer),
+		maintenance:    make(map[int]*types.Maintenance),
+		reviews:        make(map[int]*types.Review),
+		nextID:         1,
+		nextSeriesID:   1,
+		nextDriverID:   1,
+		nextTransferID: 1,
+		nextMaintID:    1,
+		nextReviewID:   1,
 	}
 }
 
 func (bs *BookingStore) Create(ctx context.Context, booking *types.Booking) error {
-	id := len(bs.books) + 1
-	booking.ID = id
-	bs.books[id] = booking
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	booking.ID = bs.nextID
+	bs.nextID++
+	bs.books[booking.ID] = booking
 	return nil
 }
 
 func (bs *BookingStore) GetByID(ctx context.Context, id int) (*types.Booking, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
 	if booking, ok := bs.books[id]; ok {
 		return booking, nil
 	}
@@ -32,9 +70,19 @@ func (bs *BookingStore) GetByID(ctx context.Context, id int) (*types.Booking, er
 }
 
 func (bs *BookingStore) GetByUserID(ctx context.Context, userID int) ([]*types.Booking, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	driving := make(map[int]bool)
+	for _, driver := range bs.drivers {
+		if driver.UserID != nil && *driver.UserID == userID && driver.Status == types.BookingDriverAccepted {
+			driving[driver.BookingID] = true
+		}
+	}
+
 	var books []*types.Booking
 	for _, booking := range bs.books {
-		if booking.UserID == userID {
+		if booking.UserID == userID || driving[booking.ID] {
 			books = append(books, booking)
 		}
 	}
@@ -42,6 +90,9 @@ func (bs *BookingStore) GetByUserID(ctx context.Context, userID int) ([]*types.B
 }
 
 func (bs *BookingStore) Update(ctx context.Context, booking *types.Booking) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
 	if _, ok := bs.books[booking.ID]; !ok {
 		return types.NotFound("booking")
 	}
@@ -50,6 +101,9 @@ func (bs *BookingStore) Update(ctx context.Context, booking *types.Booking) erro
 }
 
 func (bs *BookingStore) Delete(ctx context.Context, id int) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
 	if _, ok := bs.books[id]; !ok {
 		return types.NotFound("booking")
 	}
@@ -57,13 +111,414 @@ func (bs *BookingStore) Delete(ctx context.Context, id int) error {
 	return nil
 }
 
+// ranges are inclusive, they overlap unless one ends before the other starts
+func overlaps(start, end, startDate, endDate time.Time) bool {
+	return !start.After(endDate) && !end.Before(startDate)
+}
+
 func (bs *BookingStore) CheckDateAvailability(ctx context.Context, carID int, startDate, endDate time.Time) bool {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	for _, m := range bs.maintenance {
+		if m.CarID == carID && overlaps(m.StartDate, m.EndDate, startDate, endDate) {
+			return false
+		}
+	}
+	return len(bs.overlapping(carID, startDate, endDate)) == 0
+}
+
+func (bs *BookingStore) CountByCarID(ctx context.Context, carID int) (int, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	count := 0
 	for _, booking := range bs.books {
 		if booking.CarID == carID {
-			if (booking.StartDate.Before(startDate) || booking.StartDate.Equal(startDate)) && (booking.EndDate.After(startDate) || booking.EndDate.Equal(startDate)) {
-				return false
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (bs *BookingStore) GetOverlapping(ctx context.Context, carID int, startDate, endDate time.Time) ([]*types.Booking, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	books := bs.overlapping(carID, startDate, endDate)
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].StartDate.Before(books[j].StartDate)
+	})
+	return books, nil
+}
+
+func (bs *BookingStore) GetOverlappingCars(ctx context.Context, carIDs []int, startDate, endDate time.Time) ([]*types.Booking, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var books []*types.Booking
+	for _, carID := range carIDs {
+		books = append(books, bs.overlapping(carID, startDate, endDate)...)
+	}
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].StartDate.Before(books[j].StartDate)
+	})
+	return books, nil
+}
+
+func (bs *BookingStore) overlapping(carID int, startDate, endDate time.Time) []*types.Booking {
+	var books []*types.Booking
+	for _, booking := range bs.books {
+		if booking.CarID != carID || booking.Status == types.BookingStatusCancelled || booking.Status == types.BookingStatusNoShow {
+			continue
+		}
+		if overlaps(booking.StartDate, booking.EndDate, startDate, endDate) {
+			books = append(books, booking)
+		}
+	}
+	return books
+}
+
+func (bs *BookingStore) GetNoShowsDue(ctx context.Context, now time.Time) ([]*types.NoShowDue, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var due []*types.NoShowDue
+	for _, booking := range bs.books {
+		if booking.Status != types.BookingStatusConfirmed || booking.PickedUp != nil {
+			continue
+		}
+		rules, err := bs.noShowRules(ctx, booking.CarID)
+		if err != nil {
+			return nil, err
+		}
+		if rules == nil || rules.NoShowGraceHours <= 0 {
+			continue
+		}
+		d := &types.NoShowDue{Booking: *booking, NoShowGraceHours: rules.NoShowGraceHours, NoShowFee: rules.NoShowFee}
+		if !d.Deadline().After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].StartDate.Before(due[j].StartDate)
+	})
+	return due, nil
+}
+
+// rules of the car or of its company when the car has none, like the lateral join in postgres
+func (bs *BookingStore) noShowRules(ctx context.Context, carID int) (*types.RentalRules, error) {
+	car, err := bs.cars.GetByID(ctx, carID)
+	if err != nil {
+		return nil, nil
+	}
+	rules, err := bs.companies.GetRentalRules(ctx, car.CompanyID, &car.ID)
+	if err != nil || rules != nil {
+		return rules, err
+	}
+	return bs.companies.GetRentalRules(ctx, car.CompanyID, nil)
+}
+
+func (bs *BookingStore) CreateSeries(ctx context.Context, series *types.BookingSeries, bookings []*types.Booking) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	series.ID = bs.nextSeriesID
+	bs.nextSeriesID++
+	series.Created = time.Now()
+	bs.series[series.ID] = series
+	bs.addOccurrences(series, bookings)
+	return nil
+}
+
+func (bs *BookingStore) addOccurrences(series *types.BookingSeries, bookings []*types.Booking) {
+	for _, booking := range bookings {
+		booking.ID = bs.nextID
+		bs.nextID++
+		booking.SeriesID = &series.ID
+		bs.books[booking.ID] = booking
+	}
+}
+
+func (bs *BookingStore) GetSeriesByID(ctx context.Context, id int) (*types.BookingSeries, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	if series, ok := bs.series[id]; ok {
+		return series, nil
+	}
+	return nil, types.NotFound("booking series")
+}
+
+func (bs *BookingStore) UpdateSeries(ctx context.Context, series *types.BookingSeries, added []*types.Booking, cancelled []*types.Booking) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, ok := bs.series[series.ID]; !ok {
+		return types.NotFound("booking series")
+	}
+	bs.series[series.ID] = series
+	for _, b := range cancelled {
+		if stored, ok := bs.books[b.ID]; ok {
+			stored.Status = types.BookingStatusCancelled
+		}
+		b.Status = types.BookingStatusCancelled
+	}
+	bs.addOccurrences(series, added)
+	return nil
+}
+
+func (bs *BookingStore) GetBySeriesID(ctx context.Context, seriesID int) ([]*types.Booking, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var books []*types.Booking
+	for _, booking := range bs.books {
+		if booking.SeriesID != nil && *booking.SeriesID == seriesID {
+			books = append(books, booking)
+		}
+	}
+	sort.Slice(books, func(i, j int) bool {
+		return books[i].StartDate.Before(books[j].StartDate)
+	})
+	return books, nil
+}
+
+func (bs *BookingStore) AddDriver(ctx context.Context, driver *types.BookingDriver) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	for _, d := range bs.drivers {
+		if d.BookingID == driver.BookingID && strings.EqualFold(d.Email, driver.Email) {
+			return fmt.Errorf("driver %s already added to booking %d", driver.Email, driver.BookingID)
+		}
+	}
+
+	driver.ID = bs.nextDriverID
+	bs.nextDriverID++
+	driver.Created = time.Now()
+	bs.drivers[driver.ID] = driver
+	return nil
+}
+
+func (bs *BookingStore) GetDrivers(ctx context.Context, bookingID int) ([]*types.BookingDriver, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var drivers []*types.BookingDriver
+	for _, d := range bs.drivers {
+		if d.BookingID == bookingID {
+			drivers = append(drivers, d)
+		}
+	}
+	sort.Slice(drivers, func(i, j int) bool {
+		return drivers[i].ID < drivers[j].ID
+	})
+	return drivers, nil
+}
+
+func (bs *BookingStore) UpdateDriver(ctx context.Context, driver *types.BookingDriver) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, ok := bs.drivers[driver.ID]; !ok {
+		return types.NotFound("booking driver")
+	}
+	bs.drivers[driver.ID] = driver
+	return nil
+}
+
+func (bs *BookingStore) DeleteDriver(ctx context.Context, id int) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, ok := bs.drivers[id]; !ok {
+		return types.NotFound("booking driver")
+	}
+	delete(bs.drivers, id)
+	return nil
+}
+
+func (bs *BookingStore) CreateTransfer(ctx context.Context, transfer *types.BookingTransfer) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	for _, t := range bs.transfers {
+		if t.BookingID == transfer.BookingID && t.Status == types.BookingTransferPending {
+			return fmt.Errorf("booking %d already has a pending transfer", transfer.BookingID)
+		}
+	}
+
+	transfer.ID = bs.nextTransferID
+	bs.nextTransferID++
+	transfer.Created = time.Now()
+	bs.transfers[transfer.ID] = transfer
+	return nil
+}
+
+func (bs *BookingStore) GetTransfers(ctx context.Context, bookingID int) ([]*types.BookingTransfer, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var transfers []*types.BookingTransfer
+	for _, t := range bs.transfers {
+		if t.BookingID == bookingID {
+			transfers = append(transfers, t)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ID < transfers[j].ID
+	})
+	return transfers, nil
+}
+
+func (bs *BookingStore) UpdateTransfer(ctx context.Context, transfer *types.BookingTransfer) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, ok := bs.transfers[transfer.ID]; !ok {
+		return types.NotFound("booking transfer")
+	}
+	bs.transfers[transfer.ID] = transfer
+	return nil
+}
+
+func (bs *BookingStore) CreateMaintenance(ctx context.Context, m *types.Maintenance) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	m.ID = bs.nextMaintID
+	bs.nextMaintID++
+	m.Created = time.Now()
+	bs.maintenance[m.ID] = m
+	return nil
+}
+
+func (bs *BookingStore) GetMaintenanceByID(ctx context.Context, id int) (*types.Maintenance, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	if m, ok := bs.maintenance[id]; ok {
+		return m, nil
+	}
+	return nil, types.NotFound("maintenance")
+}
+
+func (bs *BookingStore) GetMaintenance(ctx context.Context, carID int) ([]*types.Maintenance, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var maintenance []*types.Maintenance
+	for _, m := range bs.maintenance {
+		if m.CarID == carID {
+			maintenance = append(maintenance, m)
+		}
+	}
+	sort.Slice(maintenance, func(i, j int) bool {
+		return maintenance[i].StartDate.Before(maintenance[j].StartDate)
+	})
+	return maintenance, nil
+}
+
+func (bs *BookingStore) DeleteMaintenance(ctx context.Context, id int) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, ok := bs.maintenance[id]; !ok {
+		return types.NotFound("maintenance")
+	}
+	delete(bs.maintenance, id)
+	return nil
+}
+
+func (bs *BookingStore) CreateReview(ctx context.Context, review *types.Review) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	for _, r := range bs.reviews {
+		if r.BookingID == review.BookingID {
+			return fmt.Errorf("review of booking %d already exists", review.BookingID)
+		}
+	}
+	review.ID = bs.nextReviewID
+	bs.nextReviewID++
+	review.Created = time.Now()
+	bs.reviews[review.ID] = review
+	return nil
+}
+
+func (bs *BookingStore) GetReview(ctx context.Context, id int) (*types.Review, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	if r, ok := bs.reviews[id]; ok {
+		return r, nil
+	}
+	return nil, types.NotFound("review")
+}
+
+func (bs *BookingStore) GetReviewByBooking(ctx context.Context, bookingID int) (*types.Review, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	for _, r := range bs.reviews {
+		if r.BookingID == bookingID {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
+
+func (bs *BookingStore) GetReviews(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]*types.Review, error) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	var reviews []*types.Review
+	for _, r := range bs.reviews {
+		if matchFilters(r, filters) {
+			reviews = append(reviews, r)
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		a, b := reviews[i], reviews[j]
+		if opts != nil && opts.SortDiretion == "desc" {
+			a, b = b, a
+		}
+		if opts != nil {
+			switch opts.SortField {
+			case "car_rating":
+				if a.CarRating != b.CarRating {
+					return a.CarRating < b.CarRating
+				}
+			case "company_rating":
+				if a.CompanyRating != b.CompanyRating {
+					return a.CompanyRating < b.CompanyRating
+				}
 			}
 		}
+		return a.ID < b.ID
+	})
+
+	if opts != nil && opts.Limit > 0 {
+		if opts.Offset > len(reviews) {
+			return nil, nil
+		}
+		end := opts.Offset + opts.Limit
+		if end > len(reviews) {
+			end = len(reviews)
+		}
+		reviews = reviews[opts.Offset:end]
 	}
-	return true
+	return reviews, nil
+}
+
+func (bs *BookingStore) UpdateReview(ctx context.Context, review *types.Review) error {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if _, ok := bs.reviews[review.ID]; !ok {
+		return types.NotFound("review")
+	}
+	bs.reviews[review.ID] = review
+	return nil
 }
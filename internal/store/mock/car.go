@@ -3,22 +3,50 @@ package mock
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
 )
 
 type CarRepository struct {
-	mu     sync.RWMutex
-	cars   map[int]types.Car
-	nextID int
+	mu         sync.RWMutex
+	cars       map[int]types.Car
+	companies  *CompanyRepository
+	categories []types.CarCategory
+	images     map[int]types.CarImage
+	readings   []*types.CarReading
+	services   []*types.ServiceRecord
+	prices     []*types.CarPrice
+	telemetry  []*types.TelemetryPoint
+	transfers  []*types.CarTransfer
+	nextID     int
+	nextImage  int
+	nextPrice  int
 }
 
-func NewCarRepository() *CarRepository {
+// companies stand in for the company table, cars are filtered by the status of their company
+func NewCarRepository(companies *CompanyRepository) *CarRepository {
 	return &CarRepository{
-		cars:   make(map[int]types.Car),
-		nextID: 1,
+		cars:      make(map[int]types.Car),
+		companies: companies,
+		// same as seeded by the migration
+		categories: []types.CarCategory{
+			{ID: 1, Name: "economy", Seats: 4, Doors: 3, Transmission: "manual", FuelType: "petrol", Luggage: 1},
+			{ID: 2, Name: "compact", Seats: 5, Doors: 5, Transmission: "manual", FuelType: "petrol", Luggage: 2},
+			{ID: 3, Name: "suv", Seats: 5, Doors: 5, Transmission: "automatic", FuelType: "diesel", Luggage: 3},
+			{ID: 4, Name: "van", Seats: 9, Doors: 4, Transmission: "manual", FuelType: "diesel", Luggage: 6},
+			{ID: 5, Name: "luxury", Seats: 5, Doors: 4, Transmission: "automatic", FuelType: "petrol", Luggage: 3},
+		},
+		images:    make(map[int]types.CarImage),
+		nextID:    1,
+		nextImage: 1,
+		nextPrice: 1,
 	}
 }
 
@@ -37,11 +65,49 @@ func (r *CarRepository) Create(ctx context.Context, car *types.Car) error {
 
 	car.Created = time.Now()
 	car.Updated = time.Now()
+	if car.Status == "" {
+		car.Status = types.CarStatusAvailable
+	}
 
 	r.cars[car.ID] = *car
 	return nil
 }
 
+func (r *CarRepository) CreateBatch(ctx context.Context, cars []*types.Car, prices []*types.CarPrice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	registrations := make(map[string]bool)
+	for _, existingCar := range r.cars {
+		registrations[existingCar.RegistrationNo] = true
+	}
+	for _, car := range cars {
+		if registrations[car.RegistrationNo] {
+			return fmt.Errorf("car with registration number %s already exists", car.RegistrationNo)
+		}
+		registrations[car.RegistrationNo] = true
+	}
+
+	for i, car := range cars {
+		car.ID = r.nextID
+		r.nextID++
+		car.Created = time.Now()
+		car.Updated = time.Now()
+		if car.Status == "" {
+			car.Status = types.CarStatusAvailable
+		}
+		r.cars[car.ID] = *car
+
+		price := prices[i]
+		price.CarID = car.ID
+		price.ID = r.nextPrice
+		r.nextPrice++
+		price.Created = time.Now()
+		r.prices = append(r.prices, price)
+	}
+	return nil
+}
+
 func (r *CarRepository) GetByID(ctx context.Context, id int) (*types.Car, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -64,7 +130,7 @@ func (r *CarRepository) Update(ctx context.Context, id int, car *types.Car) erro
 	}
 
 	for _, existingCar := range r.cars {
-		if existingCar.RegistrationNo == car.RegistrationNo {
+		if existingCar.ID != id && existingCar.RegistrationNo == car.RegistrationNo {
 			return fmt.Errorf("car with registration number %s already exists", car.RegistrationNo)
 		}
 	}
@@ -90,11 +156,29 @@ func (r *CarRepository) GetBatch(ctx context.Context, filters []*types.QueryFilt
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	var cars []types.Car
-
-	for _, car := range r.cars {
-		cars = append(cars, car)
-	}
+	cars := r.matching(filters, opts)
+	// map order is random, keep pages stable
+	sort.Slice(cars, func(i, j int) bool {
+		if opts != nil && opts.Near != nil && opts.SortField == "distance_km" {
+			return byDistance(cars[i].Distance, cars[j].Distance, cars[i].ID, cars[j].ID, opts.SortDiretion)
+		}
+		if opts != nil && len(opts.Search) > 0 && opts.SortField == "relevance" && *cars[i].Relevance != *cars[j].Relevance {
+			if opts.SortDiretion == "asc" {
+				return *cars[i].Relevance < *cars[j].Relevance
+			}
+			return *cars[i].Relevance > *cars[j].Relevance
+		}
+		if opts != nil && opts.SortField == "rating" && cars[i].Rating.Rating != cars[j].Rating.Rating {
+			if opts.SortDiretion == "desc" {
+				return cars[i].Rating.Rating > cars[j].Rating.Rating
+			}
+			return cars[i].Rating.Rating < cars[j].Rating.Rating
+		}
+		if opts != nil && opts.SortField == "id" && opts.SortDiretion == "desc" {
+			return cars[i].ID > cars[j].ID
+		}
+		return cars[i].ID < cars[j].ID
+	})
 
 	if opts != nil && opts.Limit > 0 {
 		end := opts.Offset + opts.Limit
@@ -106,3 +190,500 @@ func (r *CarRepository) GetBatch(ctx context.Context, filters []*types.QueryFilt
 
 	return cars, nil
 }
+
+func (r *CarRepository) GetFacets(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) (*types.CarFacets, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]map[string]int)
+	add := func(facet, value string) {
+		if value == "" {
+			return
+		}
+		if counts[facet] == nil {
+			counts[facet] = make(map[string]int)
+		}
+		counts[facet][value]++
+	}
+	for _, car := range r.matching(filters, opts) {
+		add("make", car.Make)
+		if car.CategoryID != nil {
+			add("category", strconv.Itoa(*car.CategoryID))
+		}
+		add("transmission", car.Transmission)
+		add("price", types.PriceBucket(car.PricePerDay))
+		add("company", strconv.Itoa(car.CompanyID))
+	}
+
+	list := func(facet string) []types.FacetCount {
+		values := make([]types.FacetCount, 0, len(counts[facet]))
+		for value, count := range counts[facet] {
+			values = append(values, types.FacetCount{Value: value, Count: count})
+		}
+		return values
+	}
+	return &types.CarFacets{
+		Make:         list("make"),
+		Category:     list("category"),
+		Transmission: list("transmission"),
+		Price:        list("price"),
+		Company:      list("company"),
+	}, nil
+}
+
+// cars matching the filters, near and text search, caller holds the lock
+func (r *CarRepository) matching(filters []*types.QueryFilter, opts *types.QueryOptions) []types.Car {
+	var cars []types.Car
+	for _, car := range r.cars {
+		if opts != nil && opts.Near != nil {
+			distance, ok := withinRadius(car.Latitude, car.Longitude, opts.Near)
+			if !ok {
+				continue
+			}
+			car.Distance = distance
+		}
+		if opts != nil && len(opts.Search) > 0 {
+			relevance, ok := searchRelevance(&car, opts.Search)
+			if !ok {
+				continue
+			}
+			car.Relevance = &relevance
+		}
+		if matchFilters(&car, filters) && r.matchCompanyStatus(&car, filters) {
+			cars = append(cars, car)
+		}
+	}
+	return cars
+}
+
+// same as the subquery on the company table in postgres
+func (r *CarRepository) matchCompanyStatus(car *types.Car, filters []*types.QueryFilter) bool {
+	for _, f := range filters {
+		if f.Field != types.CompanyStatusFilter {
+			continue
+		}
+		company, err := r.companies.GetByID(context.Background(), car.CompanyID)
+		if err != nil || !matchFilters(company, []*types.QueryFilter{{Field: "status", Operator: f.Operator, Value: f.Value}}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *CarRepository) GetByCategory(ctx context.Context, categoryID int) ([]types.Car, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var cars []types.Car
+	for _, car := range r.cars {
+		if car.CategoryID != nil && *car.CategoryID == categoryID {
+			cars = append(cars, car)
+		}
+	}
+	sort.Slice(cars, func(i, j int) bool {
+		if cars[i].PricePerDay != cars[j].PricePerDay {
+			return cars[i].PricePerDay < cars[j].PricePerDay
+		}
+		return cars[i].ID < cars[j].ID
+	})
+	return cars, nil
+}
+
+func (r *CarRepository) GetExpiringDocuments(ctx context.Context, until time.Time) ([]types.Car, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var cars []types.Car
+	for _, car := range r.cars {
+		if expiry, _ := car.FirstExpiry(); car.Status != types.CarStatusRetired && expiry != nil && !expiry.After(until) {
+			cars = append(cars, car)
+		}
+	}
+	sort.Slice(cars, func(i, j int) bool {
+		return cars[i].ID < cars[j].ID
+	})
+	return cars, nil
+}
+
+func (r *CarRepository) GetCategories(ctx context.Context) ([]types.CarCategory, error) {
+	return r.categories, nil
+}
+
+func (r *CarRepository) GetCategoryByID(ctx context.Context, id int) (*types.CarCategory, error) {
+	for _, c := range r.categories {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, fmt.Errorf("car category with id %d not found", id)
+}
+
+func (r *CarRepository) AddReading(ctx context.Context, reading *types.CarReading) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reading.ID = len(r.readings) + 1
+	r.readings = append(r.readings, reading)
+	return nil
+}
+
+func (r *CarRepository) GetReadings(ctx context.Context, carID int, limit int) ([]*types.CarReading, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	readings := make([]*types.CarReading, 0)
+	for _, reading := range r.readings {
+		if reading.CarID == carID {
+			readings = append(readings, reading)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool {
+		if !readings[i].Recorded.Equal(readings[j].Recorded) {
+			return readings[i].Recorded.After(readings[j].Recorded)
+		}
+		return readings[i].ID > readings[j].ID
+	})
+	if len(readings) > limit {
+		readings = readings[:limit]
+	}
+	return readings, nil
+}
+
+func (r *CarRepository) AddPrice(ctx context.Context, price *types.CarPrice) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	price.ID = r.nextPrice
+	r.nextPrice++
+	price.Created = time.Now()
+	r.prices = append(r.prices, price)
+	return nil
+}
+
+func (r *CarRepository) GetPrices(ctx context.Context, carID int) ([]*types.CarPrice, error) {
+	return r.filterPrices(func(p *types.CarPrice) bool { return p.CarID == carID }), nil
+}
+
+func (r *CarRepository) GetPendingPrices(ctx context.Context, until time.Time) ([]*types.CarPrice, error) {
+	return r.filterPrices(func(p *types.CarPrice) bool { return !p.Applied && !p.EffectiveFrom.After(until) }), nil
+}
+
+// copies ordered by effective date like the sql store
+func (r *CarRepository) filterPrices(match func(p *types.CarPrice) bool) []*types.CarPrice {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	prices := make([]*types.CarPrice, 0)
+	for _, p := range r.prices {
+		if match(p) {
+			price := *p
+			prices = append(prices, &price)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		if !prices[i].EffectiveFrom.Equal(prices[j].EffectiveFrom) {
+			return prices[i].EffectiveFrom.Before(prices[j].EffectiveFrom)
+		}
+		return prices[i].ID < prices[j].ID
+	})
+	return prices
+}
+
+func (r *CarRepository) SetPriceApplied(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range r.prices {
+		if p.ID == id {
+			p.Applied = true
+		}
+	}
+	return nil
+}
+
+func (r *CarRepository) DeletePrice(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.prices = slices.DeleteFunc(r.prices, func(p *types.CarPrice) bool { return p.ID == id })
+	return nil
+}
+
+func (r *CarRepository) AddServiceRecord(ctx context.Context, record *types.ServiceRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record.ID = len(r.services) + 1
+	record.Created = time.Now()
+	r.services = append(r.services, record)
+	return nil
+}
+
+func (r *CarRepository) GetServiceRecords(ctx context.Context, carID int) ([]*types.ServiceRecord, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	records := make([]*types.ServiceRecord, 0)
+	for _, record := range r.services {
+		if record.CarID == carID {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Performed.Equal(records[j].Performed) {
+			return records[i].Performed.After(records[j].Performed)
+		}
+		return records[i].ID > records[j].ID
+	})
+	return records, nil
+}
+
+func (r *CarRepository) AddTelemetry(ctx context.Context, points []*types.TelemetryPoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, p := range points {
+		p.ID = len(r.telemetry) + 1
+		r.telemetry = append(r.telemetry, p)
+	}
+	return nil
+}
+
+func (r *CarRepository) GetLastPosition(ctx context.Context, carID int) (*types.TelemetryPoint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var last *types.TelemetryPoint
+	for _, p := range r.telemetry {
+		if p.CarID == carID && p.Latitude != nil && (last == nil || p.Recorded.After(last.Recorded)) {
+			last = p
+		}
+	}
+	return last, nil
+}
+
+func (r *CarRepository) DeleteTelemetryBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.telemetry[:0]
+	for _, p := range r.telemetry {
+		if !p.Recorded.Before(before) {
+			kept = append(kept, p)
+		}
+	}
+	deleted := int64(len(r.telemetry) - len(kept))
+	r.telemetry = kept
+	return deleted, nil
+}
+
+func (r *CarRepository) AddImage(ctx context.Context, image *types.CarImage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	image.ID = r.nextImage
+	r.nextImage++
+	image.Created = time.Now()
+	r.images[image.ID] = *image
+	return nil
+}
+
+func (r *CarRepository) GetImages(ctx context.Context, carID int) ([]*types.CarImage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	images := make([]*types.CarImage, 0)
+	for _, image := range r.images {
+		if image.CarID == carID {
+			images = append(images, &image)
+		}
+	}
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].Position != images[j].Position {
+			return images[i].Position < images[j].Position
+		}
+		return images[i].ID < images[j].ID
+	})
+	return images, nil
+}
+
+func (r *CarRepository) UpdateImage(ctx context.Context, image *types.CarImage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.images[image.ID]; !exists {
+		return fmt.Errorf("car image with id %d not found", image.ID)
+	}
+	r.images[image.ID] = *image
+	return nil
+}
+
+func (r *CarRepository) DeleteImage(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.images[id]; !exists {
+		return fmt.Errorf("car image with id %d not found", id)
+	}
+	delete(r.images, id)
+	return nil
+}
+
+// weights of the fields like in the search_vector column
+var searchWeights = []float64{1, 0.4, 0.2}
+
+// every term has to be a prefix of some word of the car, relevance is the sum
+// of weights of the best fields the terms were found in
+func searchRelevance(car *types.Car, terms []string) (float64, bool) {
+	features := car.Transmission + " " + car.FuelType
+	if car.AirConditioning {
+		features += " air conditioning"
+	}
+	if car.Navigation {
+		features += " navigation"
+	}
+	if car.Towbar {
+		features += " towbar"
+	}
+	fields := [][]string{
+		utils.Tokenize(car.Make + " " + car.Model),
+		utils.Tokenize(car.Color + " " + strconv.Itoa(car.Year)),
+		utils.Tokenize(features),
+	}
+
+	relevance := 0.0
+	for _, term := range terms {
+		found := false
+		for i, words := range fields {
+			if slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, term) }) {
+				relevance += searchWeights[i]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return relevance, true
+}
+
+func (r *CarRepository) CreateTransfer(ctx context.Context, transfer *types.CarTransfer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	transfer.ID = len(r.transfers) + 1
+	transfer.Created = time.Now()
+	saved := *transfer
+	r.transfers = append(r.transfers, &saved)
+	return nil
+}
+
+func (r *CarRepository) GetTransfers(ctx context.Context, carID int) ([]*types.CarTransfer, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var transfers []*types.CarTransfer
+	for _, t := range r.transfers {
+		if t.CarID == carID {
+			transfer := *t
+			transfers = append(transfers, &transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (r *CarRepository) UpdateTransfer(ctx context.Context, transfer *types.CarTransfer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.updateTransfer(transfer)
+}
+
+// bookings are kept by the booking store, they already point to their new branch
+func (r *CarRepository) CompleteTransfer(ctx context.Context, transfer *types.CarTransfer, bookings []*types.Booking) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, exists := r.cars[transfer.CarID]
+	if !exists {
+		return fmt.Errorf("car with id %d not found", transfer.CarID)
+	}
+	if transfer.ID == 0 {
+		transfer.ID = len(r.transfers) + 1
+		transfer.Created = time.Now()
+		saved := *transfer
+		r.transfers = append(r.transfers, &saved)
+	} else if err := r.updateTransfer(transfer); err != nil {
+		return err
+	}
+	car.CompanyID = transfer.ToCompanyID
+	car.BranchID = transfer.ToBranchID
+	r.cars[car.ID] = car
+	return nil
+}
+
+func (r *CarRepository) updateTransfer(transfer *types.CarTransfer) error {
+	for i, t := range r.transfers {
+		if t.ID == transfer.ID {
+			saved := *transfer
+			r.transfers[i] = &saved
+			return nil
+		}
+	}
+	return fmt.Errorf("car transfer with id %d not found", transfer.ID)
+}
+
+func (r *CarRepository) SetRating(ctx context.Context, carID int, rating types.Rating) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, exists := r.cars[carID]
+	if !exists {
+		return fmt.Errorf("car with id %d not found", carID)
+	}
+	car.Rating = rating
+	r.cars[carID] = car
+	return nil
+}
+
+func (r *CarRepository) UpdateStatus(ctx context.Context, carID int, status types.CarStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, exists := r.cars[carID]
+	if !exists {
+		return fmt.Errorf("car with id %d not found", carID)
+	}
+	car.Status = status
+	car.Updated = time.Now()
+	r.cars[carID] = car
+	return nil
+}
+
+func (r *CarRepository) UpdatePrice(ctx context.Context, carID int, price float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, exists := r.cars[carID]
+	if !exists {
+		return fmt.Errorf("car with id %d not found", carID)
+	}
+	car.PricePerDay = price
+	car.Updated = time.Now()
+	r.cars[carID] = car
+	return nil
+}
+
+func (r *CarRepository) UpdateLocation(ctx context.Context, carID int, latitude, longitude float64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	car, exists := r.cars[carID]
+	if !exists {
+		return fmt.Errorf("car with id %d not found", carID)
+	}
+	car.Latitude, car.Longitude = &latitude, &longitude
+	r.cars[carID] = car
+	return nil
+}
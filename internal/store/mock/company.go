@@ -3,6 +3,7 @@ package mock
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -11,14 +12,31 @@ import (
 
 type CompanyRepository struct {
 	companies map[int]types.Company
+	rules     map[int]types.RentalRules
+	pricing   map[int]types.DynamicPricing
+	branches  map[int]types.Branch
+	members   map[int]types.CompanyMember
+	documents map[int]types.CompanyDocument
 	mu        sync.RWMutex
 	nextID    int
+	// branch ids are separate from company ids
+	nextBranchID   int
+	nextMemberID   int
+	nextDocumentID int
 }
 
 func NewCompanyRepository() *CompanyRepository {
 	return &CompanyRepository{
-		companies: make(map[int]types.Company),
-		nextID:    1,
+		companies:      make(map[int]types.Company),
+		rules:          make(map[int]types.RentalRules),
+		pricing:        make(map[int]types.DynamicPricing),
+		branches:       make(map[int]types.Branch),
+		members:        make(map[int]types.CompanyMember),
+		documents:      make(map[int]types.CompanyDocument),
+		nextID:         1,
+		nextBranchID:   1,
+		nextMemberID:   1,
+		nextDocumentID: 1,
 	}
 }
 
@@ -38,6 +56,19 @@ func (r *CompanyRepository) Create(ctx context.Context, company *types.Company)
 	company.Updated = company.Created
 
 	r.companies[company.ID] = *company
+
+	// users live in another store, so the owner has no email here
+	ownerID, joined := company.OwnerID, company.Created
+	r.members[r.nextMemberID] = types.CompanyMember{
+		ID:        r.nextMemberID,
+		CompanyID: company.ID,
+		UserID:    &ownerID,
+		Role:      types.CompanyRoleOwner,
+		Status:    types.CompanyMemberActive,
+		Created:   company.Created,
+		Joined:    &joined,
+	}
+	r.nextMemberID++
 	return nil
 }
 
@@ -80,6 +111,16 @@ func (r *CompanyRepository) Delete(ctx context.Context, id int) error {
 	}
 
 	delete(r.companies, id)
+	for memberID, m := range r.members {
+		if m.CompanyID == id {
+			delete(r.members, memberID)
+		}
+	}
+	for documentID, d := range r.documents {
+		if d.CompanyID == id {
+			delete(r.documents, documentID)
+		}
+	}
 	return nil
 }
 
@@ -90,8 +131,24 @@ func (r *CompanyRepository) GetBatch(ctx context.Context, filters []*types.Query
 	var companies []types.Company
 
 	for _, c := range r.companies {
-		companies = append(companies, c)
+		if opts != nil && opts.Near != nil {
+			distance, ok := withinRadius(c.Latitude, c.Longitude, opts.Near)
+			if !ok {
+				continue
+			}
+			c.Distance = distance
+		}
+		if matchFilters(&c, filters) {
+			companies = append(companies, c)
+		}
 	}
+	// map order is random, keep pages stable
+	sort.Slice(companies, func(i, j int) bool {
+		if opts != nil && opts.Near != nil && opts.SortField == "distance_km" {
+			return byDistance(companies[i].Distance, companies[j].Distance, companies[i].ID, companies[j].ID, opts.SortDiretion)
+		}
+		return companies[i].ID < companies[j].ID
+	})
 
 	if opts != nil && opts.Limit > 0 && opts.Offset < len(companies) {
 		end := opts.Offset + opts.Limit
@@ -103,3 +160,298 @@ func (r *CompanyRepository) GetBatch(ctx context.Context, filters []*types.Query
 
 	return companies, nil
 }
+
+func (r *CompanyRepository) GetRentalRules(ctx context.Context, companyID int, carID *int) (*types.RentalRules, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rules := range r.rules {
+		if rules.CompanyID != companyID {
+			continue
+		}
+		if (rules.CarID == nil && carID == nil) || (rules.CarID != nil && carID != nil && *rules.CarID == *carID) {
+			return &rules, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *CompanyRepository) SaveRentalRules(ctx context.Context, rules *types.RentalRules) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rules.ID == 0 {
+		rules.ID = len(r.rules) + 1
+	} else if _, exists := r.rules[rules.ID]; !exists {
+		return types.NotFound("rental rules not found")
+	}
+
+	r.rules[rules.ID] = *rules
+	return nil
+}
+
+func (r *CompanyRepository) GetDynamicPricing(ctx context.Context, companyID int) (*types.DynamicPricing, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	pricing, ok := r.pricing[companyID]
+	if !ok {
+		return nil, nil
+	}
+	return &pricing, nil
+}
+
+func (r *CompanyRepository) SaveDynamicPricing(ctx context.Context, p *types.DynamicPricing) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pricing[p.CompanyID] = *p
+	return nil
+}
+
+func (r *CompanyRepository) SetRating(ctx context.Context, companyID int, rating types.Rating) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	company, exists := r.companies[companyID]
+	if !exists {
+		return types.NotFound("company not found")
+	}
+	company.Rating = rating
+	r.companies[companyID] = company
+	return nil
+}
+
+func (r *CompanyRepository) CreateBranch(ctx context.Context, b *types.Branch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.companies[b.CompanyID]; !exists {
+		return types.NotFound("company not found")
+	}
+	b.ID = r.nextBranchID
+	r.nextBranchID++
+	r.branches[b.ID] = *b
+	return nil
+}
+
+func (r *CompanyRepository) GetBranch(ctx context.Context, id int) (*types.Branch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	branch, exists := r.branches[id]
+	if !exists {
+		return nil, types.NotFound("branch not found")
+	}
+	return &branch, nil
+}
+
+func (r *CompanyRepository) GetBranches(ctx context.Context, companyID int) ([]*types.Branch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var branches []*types.Branch
+	for _, b := range r.branches {
+		if b.CompanyID == companyID {
+			branch := b
+			branches = append(branches, &branch)
+		}
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].ID < branches[j].ID
+	})
+	return branches, nil
+}
+
+func (r *CompanyRepository) UpdateBranch(ctx context.Context, b *types.Branch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.branches[b.ID]; !exists {
+		return types.NotFound("branch not found")
+	}
+	r.branches[b.ID] = *b
+	return nil
+}
+
+func (r *CompanyRepository) DeleteBranch(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.branches[id]; !exists {
+		return types.NotFound("branch not found")
+	}
+	delete(r.branches, id)
+	return nil
+}
+
+func (r *CompanyRepository) CreateMember(ctx context.Context, m *types.CompanyMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.companies[m.CompanyID]; !exists {
+		return types.NotFound("company not found")
+	}
+	for _, existing := range r.members {
+		if existing.CompanyID == m.CompanyID && existing.Email != "" && existing.Email == m.Email {
+			return fmt.Errorf("member with email already exists")
+		}
+	}
+	m.ID = r.nextMemberID
+	r.nextMemberID++
+	m.Created = time.Now()
+	r.members[m.ID] = *m
+	return nil
+}
+
+func (r *CompanyRepository) GetMember(ctx context.Context, id int) (*types.CompanyMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	member, exists := r.members[id]
+	if !exists {
+		return nil, types.NotFound("company member not found")
+	}
+	return &member, nil
+}
+
+func (r *CompanyRepository) GetMembers(ctx context.Context, companyID int) ([]*types.CompanyMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []*types.CompanyMember
+	for _, m := range r.members {
+		if m.CompanyID == companyID {
+			member := m
+			members = append(members, &member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID < members[j].ID
+	})
+	return members, nil
+}
+
+func (r *CompanyRepository) GetMembership(ctx context.Context, companyID int, userID int) (*types.CompanyMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, m := range r.members {
+		if m.CompanyID == companyID && m.UserID != nil && *m.UserID == userID && m.Status == types.CompanyMemberActive {
+			return &m, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *CompanyRepository) UpdateMember(ctx context.Context, m *types.CompanyMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.members[m.ID]; !exists {
+		return types.NotFound("company member not found")
+	}
+	r.members[m.ID] = *m
+	return nil
+}
+
+func (r *CompanyRepository) DeleteMember(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.members[id]; !exists {
+		return types.NotFound("company member not found")
+	}
+	delete(r.members, id)
+	return nil
+}
+
+func (r *CompanyRepository) SetVerification(ctx context.Context, c *types.Company) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	company, exists := r.companies[c.ID]
+	if !exists {
+		return types.NotFound("company not found")
+	}
+	company.Status = c.Status
+	company.RejectionReason = c.RejectionReason
+	company.Verified = c.Verified
+	r.companies[c.ID] = company
+	return nil
+}
+
+func (r *CompanyRepository) GetAwaitingVerification(ctx context.Context) ([]types.Company, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var companies []types.Company
+	for _, c := range r.companies {
+		if c.Status != types.CompanyStatusPending {
+			continue
+		}
+		for _, d := range r.documents {
+			if d.CompanyID == c.ID {
+				companies = append(companies, c)
+				break
+			}
+		}
+	}
+	sort.Slice(companies, func(i, j int) bool {
+		return companies[i].ID < companies[j].ID
+	})
+	return companies, nil
+}
+
+func (r *CompanyRepository) AddDocument(ctx context.Context, d *types.CompanyDocument) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.companies[d.CompanyID]; !exists {
+		return types.NotFound("company not found")
+	}
+	d.ID = r.nextDocumentID
+	r.nextDocumentID++
+	d.Created = time.Now()
+	r.documents[d.ID] = *d
+	return nil
+}
+
+func (r *CompanyRepository) GetDocument(ctx context.Context, id int) (*types.CompanyDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	document, exists := r.documents[id]
+	if !exists {
+		return nil, types.NotFound("company document not found")
+	}
+	return &document, nil
+}
+
+func (r *CompanyRepository) GetDocuments(ctx context.Context, companyID int) ([]*types.CompanyDocument, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var documents []*types.CompanyDocument
+	for _, d := range r.documents {
+		if d.CompanyID == companyID {
+			document := d
+			documents = append(documents, &document)
+		}
+	}
+	sort.Slice(documents, func(i, j int) bool {
+		return documents[i].ID < documents[j].ID
+	})
+	return documents, nil
+}
+
+func (r *CompanyRepository) DeleteDocument(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.documents[id]; !exists {
+		return types.NotFound("company document not found")
+	}
+	delete(r.documents, id)
+	return nil
+}
@@ -0,0 +1,150 @@
+package mock
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
+)
+
+// checks the item against filters the same way sql would, fields are matched by db tags
+// including embedded structs, filters on unknown fields are ignored
+func matchFilters(item any, filters []*types.QueryFilter) bool {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	for _, f := range filters {
+		field, ok := fieldByTag(v, f.Field)
+		if !ok {
+			continue
+		}
+		if !matchFilter(field, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldByTag(v reflect.Value, tag string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			if field, ok := fieldByTag(v.Field(i), tag); ok {
+				return field, true
+			}
+			continue
+		}
+		if sf.Tag.Get("db") == tag {
+			return reflect.Indirect(v.Field(i)), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func matchFilter(field reflect.Value, f *types.QueryFilter) bool {
+	// null columns never match, like in sql
+	if !field.IsValid() {
+		return false
+	}
+
+	if f.Operator == "IN" {
+		values, _ := f.Value.([]string)
+		for _, value := range values {
+			if c, ok := compare(field, value); ok && c == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	value := fmt.Sprint(f.Value)
+	if f.Operator == "LIKE" {
+		return like(fmt.Sprint(field.Interface()), value)
+	}
+
+	c, ok := compare(field, value)
+	if !ok {
+		return false
+	}
+	switch f.Operator {
+	case "=", "":
+		return c == 0
+	case "!=":
+		return c != 0
+	case ">":
+		return c > 0
+	case "<":
+		return c < 0
+	case ">=":
+		return c >= 0
+	case "<=":
+		return c <= 0
+	}
+	return false
+}
+
+// compares field with the value parsed to field's type, false if value can't be parsed
+func compare(field reflect.Value, value string) (int, bool) {
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return cmp(field.Int(), n), err == nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		return cmp(field.Float(), n), err == nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if b == field.Bool() {
+			return 0, err == nil
+		}
+		return 1, err == nil
+	}
+	return strings.Compare(fmt.Sprint(field.Interface()), value), true
+}
+
+func cmp[T int64 | float64](a, b T) int {
+	if a < b {
+		return -1
+	} else if a > b {
+		return 1
+	}
+	return 0
+}
+
+// supports only patterns produced by query parsing: prefix%, %suffix and %contains%
+func like(s, pattern string) bool {
+	prefix := strings.HasPrefix(pattern, "%")
+	suffix := strings.HasSuffix(pattern, "%")
+	p := strings.Trim(pattern, "%")
+	switch {
+	case prefix && suffix:
+		return strings.Contains(s, p)
+	case prefix:
+		return strings.HasSuffix(s, p)
+	case suffix:
+		return strings.HasPrefix(s, p)
+	}
+	return s == p
+}
+
+// distance from the searched point, false for items without location or outside the radius
+func withinRadius(lat, lng *float64, near *types.GeoFilter) (*float64, bool) {
+	if lat == nil || lng == nil {
+		return nil, false
+	}
+	distance := utils.DistanceKm(near.Latitude, near.Longitude, *lat, *lng)
+	return &distance, distance <= near.RadiusKm
+}
+
+// same ordering as ORDER BY distance_km, ties broken by id
+func byDistance(a, b *float64, idA, idB int, direction string) bool {
+	if *a != *b {
+		if direction == "desc" {
+			return *a > *b
+		}
+		return *a < *b
+	}
+	return idA < idB
+}
@@ -3,21 +3,33 @@ package mock
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 type UserRepo struct {
-	users  map[int]types.User
-	mu     sync.RWMutex
-	nextID int
+	users    map[int]types.User
+	profiles map[int]types.DriverProfile
+	searches map[int]*types.SavedSearch
+	// favourites by user and car
+	favorites    map[[2]int]*types.FavoriteCar
+	mu           sync.RWMutex
+	nextID       int
+	nextSearchID int
 }
 
 func NewUserRepository() *UserRepo {
 	return &UserRepo{
-		users:  make(map[int]types.User),
-		nextID: 1,
+		users:        make(map[int]types.User),
+		profiles:     make(map[int]types.DriverProfile),
+		searches:     make(map[int]*types.SavedSearch),
+		favorites:    make(map[[2]int]*types.FavoriteCar),
+		nextID:       1,
+		nextSearchID: 1,
 	}
 }
 
@@ -50,6 +62,19 @@ func (r *UserRepo) GetByUsername(ctx context.Context, username string) (*types.U
 	return nil, fmt.Errorf("user with username %s not found", username)
 }
 
+func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*types.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return &user, nil
+		}
+	}
+
+	return nil, nil
+}
+
 func (r *UserRepo) GetByID(ctx context.Context, id int) (*types.User, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -85,3 +110,137 @@ func (r *UserRepo) Update(ctx context.Context, u *types.User) error {
 	r.users[u.ID] = *u
 	return nil
 }
+
+func (r *UserRepo) GetDriverProfile(ctx context.Context, userID int) (*types.DriverProfile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	profile, exists := r.profiles[userID]
+	if !exists {
+		return nil, nil
+	}
+	return &profile, nil
+}
+
+func (r *UserRepo) SaveDriverProfile(ctx context.Context, p *types.DriverProfile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.users[p.UserID]; !exists {
+		return fmt.Errorf("user with id %d not found", p.UserID)
+	}
+
+	p.Updated = time.Now()
+	r.profiles[p.UserID] = *p
+	return nil
+}
+
+func (r *UserRepo) CreateSavedSearch(ctx context.Context, s *types.SavedSearch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s.ID = r.nextSearchID
+	r.nextSearchID++
+	s.Created = time.Now()
+	r.searches[s.ID] = s
+	return nil
+}
+
+func (r *UserRepo) GetSavedSearches(ctx context.Context, userID int) ([]*types.SavedSearch, error) {
+	return r.savedSearches(func(s *types.SavedSearch) bool { return s.UserID == userID }), nil
+}
+
+func (r *UserRepo) GetNotifiedSavedSearches(ctx context.Context) ([]*types.SavedSearch, error) {
+	return r.savedSearches(func(s *types.SavedSearch) bool { return s.Notify }), nil
+}
+
+func (r *UserRepo) savedSearches(match func(*types.SavedSearch) bool) []*types.SavedSearch {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var searches []*types.SavedSearch
+	for _, s := range r.searches {
+		if match(s) {
+			searches = append(searches, s)
+		}
+	}
+	sort.Slice(searches, func(i, j int) bool {
+		return searches[i].ID < searches[j].ID
+	})
+	return searches
+}
+
+func (r *UserRepo) UpdateSavedSearch(ctx context.Context, s *types.SavedSearch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.searches[s.ID]; !exists {
+		return types.NotFound("saved search")
+	}
+	r.searches[s.ID] = s
+	return nil
+}
+
+func (r *UserRepo) DeleteSavedSearch(ctx context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.searches[id]; !exists {
+		return types.NotFound("saved search")
+	}
+	delete(r.searches, id)
+	return nil
+}
+
+func (r *UserRepo) SaveFavorite(ctx context.Context, f *types.FavoriteCar) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]int{f.UserID, f.CarID}
+	if existing, exists := r.favorites[key]; exists {
+		f.Created = existing.Created
+	} else {
+		f.Created = time.Now()
+	}
+	r.favorites[key] = f
+	return nil
+}
+
+func (r *UserRepo) GetFavorites(ctx context.Context, userID int) ([]*types.FavoriteCar, error) {
+	return r.favoriteCars(func(f *types.FavoriteCar) bool { return f.UserID == userID }), nil
+}
+
+func (r *UserRepo) GetNotifiedFavorites(ctx context.Context) ([]*types.FavoriteCar, error) {
+	return r.favoriteCars(func(f *types.FavoriteCar) bool { return f.Notify }), nil
+}
+
+func (r *UserRepo) favoriteCars(match func(*types.FavoriteCar) bool) []*types.FavoriteCar {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var favorites []*types.FavoriteCar
+	for _, f := range r.favorites {
+		if match(f) {
+			favorites = append(favorites, f)
+		}
+	}
+	sort.Slice(favorites, func(i, j int) bool {
+		if favorites[i].CarID != favorites[j].CarID {
+			return favorites[i].CarID < favorites[j].CarID
+		}
+		return favorites[i].UserID < favorites[j].UserID
+	})
+	return favorites
+}
+
+func (r *UserRepo) DeleteFavorite(ctx context.Context, userID int, carID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]int{userID, carID}
+	if _, exists := r.favorites[key]; !exists {
+		return types.NotFound("favorite car")
+	}
+	delete(r.favorites, key)
+	return nil
+}
@@ -2,11 +2,14 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mwdev22/CarRental/internal/types"
+	"github.com/mwdev22/CarRental/internal/utils"
 )
 
 type BookingRepositorySQL struct {
@@ -20,8 +23,11 @@ func NewBookingRepository(db *sqlx.DB) *BookingRepositorySQL {
 }
 
 func (bs *BookingRepositorySQL) Create(ctx context.Context, booking *types.Booking) error {
-	query := `INSERT INTO booking (user_id, car_id, start_date, end_date, status) VALUES ($1, $2, $3, $4, $5) RETURNING id`
-	_, err := bs.db.Exec(query, booking.UserID, booking.CarID, booking.StartDate, booking.EndDate, booking.Total)
+	query := `INSERT INTO booking (user_id, car_id, start_date, end_date, total, status, series_id, created_by, category_id,
+		branch_id, pickup_time, return_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+	err := bs.db.QueryRowx(query, booking.UserID, booking.CarID, booking.StartDate, booking.EndDate, booking.Total, booking.Status,
+		booking.SeriesID, booking.CreatedBy, booking.CategoryID, booking.BranchID, booking.PickupTime, booking.ReturnTime).Scan(&booking.ID)
 
 	if err != nil {
 		return fmt.Errorf("error creating booking: %w", err)
@@ -30,18 +36,23 @@ func (bs *BookingRepositorySQL) Create(ctx context.Context, booking *types.Booki
 }
 
 func (bs *BookingRepositorySQL) GetByID(ctx context.Context, id int) (*types.Booking, error) {
-	query := `SELECT id, user_id, car_id, start_date, end_date, total  FROM booking WHERE id = $1`
+	query := `SELECT id, user_id, car_id, start_date, end_date, total, status, series_id, picked_up, created_by, category_id, branch_id, pickup_time, return_time FROM booking WHERE id = $1`
 	var booking types.Booking
 	err := bs.db.Get(&booking, query, id)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.NotFound("booking")
+		}
 		return nil, fmt.Errorf("error getting booking: %w", err)
 	}
 	return &booking, nil
 }
 
 func (bs *BookingRepositorySQL) Update(ctx context.Context, booking *types.Booking) error {
-	query := `UPDATE booking SET user_id=$1, car_id=$2, start_date=$3, end_date=$4, total=$5 WHERE id=$6`
-	_, err := bs.db.Exec(query, booking.UserID, booking.CarID, booking.StartDate, booking.EndDate, booking.Total, booking.ID)
+	query := `UPDATE booking SET user_id=$1, car_id=$2, start_date=$3, end_date=$4, total=$5, status=$6, picked_up=$7,
+		branch_id=$8, pickup_time=$9, return_time=$10 WHERE id=$11`
+	_, err := bs.db.Exec(query, booking.UserID, booking.CarID, booking.StartDate, booking.EndDate, booking.Total, booking.Status, booking.PickedUp,
+		booking.BranchID, booking.PickupTime, booking.ReturnTime, booking.ID)
 	if err != nil {
 		return fmt.Errorf("error updating bookings: %w", err)
 	}
@@ -58,24 +69,67 @@ func (bs *BookingRepositorySQL) Delete(ctx context.Context, id int) error {
 }
 
 func (bs *BookingRepositorySQL) GetByUserID(ctx context.Context, userID int) ([]*types.Booking, error) {
-	query := `SELECT id, user_id, car_id, start_date, end_date, total FROM booking WHERE user_id = $1`
+	query := `SELECT id, user_id, car_id, start_date, end_date, total, status, series_id, picked_up, created_by, category_id, branch_id, pickup_time, return_time FROM booking
+		WHERE user_id = $1 OR id IN (SELECT booking_id FROM booking_driver WHERE user_id = $1 AND status = $2)`
 	var booking []*types.Booking
-	err := bs.db.Select(&booking, query, userID)
+	err := bs.db.Select(&booking, query, userID, types.BookingDriverAccepted)
 	if err != nil {
 		return nil, fmt.Errorf("error getting bookings: %w", err)
 	}
 	return booking, nil
 }
 
+// car is available when no active booking or maintenance overlaps the given range, no-shows release the car
 func (bs *BookingRepositorySQL) CheckDateAvailability(ctx context.Context, carID int, startDate, endDate time.Time) bool {
-	query := `SELECT id FROM booking WHERE car_id = $1 AND start_date <= $2 AND end_date >= $3`
-	var booking types.Booking
-	err := bs.db.Get(&booking, query, carID, startDate, endDate)
-	return err != nil
+	query := `SELECT EXISTS (SELECT 1 FROM booking WHERE car_id = $1 AND status NOT IN ($2, $3) AND start_date <= $4 AND end_date >= $5)
+		OR EXISTS (SELECT 1 FROM maintenance WHERE car_id = $1 AND start_date <= $4 AND end_date >= $5)`
+	var taken bool
+	err := bs.db.Get(&taken, query, carID, types.BookingStatusCancelled, types.BookingStatusNoShow, endDate, startDate)
+	return err == nil && !taken
+}
+
+func (bs *BookingRepositorySQL) CountByCarID(ctx context.Context, carID int) (int, error) {
+	query := `SELECT COUNT(*) FROM booking WHERE car_id = $1`
+	var count int
+	err := bs.db.Get(&count, query, carID)
+	if err != nil {
+		return 0, fmt.Errorf("error counting bookings: %w", err)
+	}
+	return count, nil
+}
+
+func (bs *BookingRepositorySQL) GetOverlapping(ctx context.Context, carID int, startDate, endDate time.Time) ([]*types.Booking, error) {
+	query := `SELECT id, user_id, car_id, start_date, end_date, total, status, series_id, picked_up, created_by, category_id, branch_id, pickup_time, return_time FROM booking
+		WHERE car_id = $1 AND status NOT IN ($2, $3) AND start_date <= $4 AND end_date >= $5 ORDER BY start_date`
+	var bookings []*types.Booking
+	err := bs.db.Select(&bookings, query, carID, types.BookingStatusCancelled, types.BookingStatusNoShow, endDate, startDate)
+	if err != nil {
+		return nil, fmt.Errorf("error getting overlapping bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+func (bs *BookingRepositorySQL) GetOverlappingCars(ctx context.Context, carIDs []int, startDate, endDate time.Time) ([]*types.Booking, error) {
+	if len(carIDs) == 0 {
+		return nil, nil
+	}
+	query, args, err := sqlx.In(`SELECT id, user_id, car_id, start_date, end_date, total, status, series_id, picked_up, created_by, category_id, branch_id, pickup_time, return_time FROM booking
+		WHERE car_id IN (?) AND status NOT IN (?, ?) AND start_date <= ? AND end_date >= ? ORDER BY start_date`,
+		carIDs, types.BookingStatusCancelled, types.BookingStatusNoShow, endDate, startDate)
+	if err != nil {
+		return nil, err
+	}
+
+	var bookings []*types.Booking
+	err = bs.db.Select(&bookings, bs.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting overlapping bookings: %w", err)
+	}
+	return bookings, nil
 }
 
 func (bs *BookingRepositorySQL) GetCurrent(ctx context.Context) ([]*types.Booking, error) {
-	query := `SELECT id, user_id, car_id, start_date, end_date, total FROM booking WHERE start_date <= $1 AND end_date >= $2`
+	query := `SELECT id, user_id, car_id, start_date, end_date, total, status, series_id, picked_up, created_by, category_id, branch_id, pickup_time, return_time FROM booking WHERE start_date <= $1 AND end_date >= $2`
 	var booking []*types.Booking
 	err := bs.db.Select(&booking, query, time.Now(), time.Now())
 	if err != nil {
@@ -83,3 +137,256 @@ func (bs *BookingRepositorySQL) GetCurrent(ctx context.Context) ([]*types.Bookin
 	}
 	return booking, nil
 }
+
+func (bs *BookingRepositorySQL) GetNoShowsDue(ctx context.Context, now time.Time) ([]*types.NoShowDue, error) {
+	query := `SELECT b.id, b.user_id, b.car_id, b.start_date, b.end_date, b.total, b.status, b.series_id, b.picked_up, b.created_by, b.category_id,
+		b.branch_id, b.pickup_time, b.return_time, r.no_show_grace_hours, r.no_show_fee FROM booking b
+		JOIN car c ON c.id = b.car_id
+		JOIN LATERAL (
+			SELECT no_show_grace_hours, no_show_fee FROM rental_rules
+			WHERE company_id = c.company_id AND (car_id = b.car_id OR car_id IS NULL) ORDER BY car_id NULLS LAST LIMIT 1
+		) r ON TRUE
+		WHERE b.status = $1 AND b.picked_up IS NULL AND r.no_show_grace_hours > 0
+			AND b.start_date + r.no_show_grace_hours * INTERVAL '1 hour' <= $2
+		ORDER BY b.start_date`
+	var due []*types.NoShowDue
+	err := bs.db.Select(&due, query, types.BookingStatusConfirmed, now)
+	if err != nil {
+		return nil, fmt.Errorf("error getting bookings due as no-shows: %w", err)
+	}
+	return due, nil
+}
+
+func (bs *BookingRepositorySQL) CreateSeries(ctx context.Context, series *types.BookingSeries, bookings []*types.Booking) error {
+	tx, err := bs.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO booking_series (user_id, car_id, start_date, end_date, weekdays) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err = tx.QueryRowx(query, series.UserID, series.CarID, series.StartDate, series.EndDate, series.Weekdays).Scan(&series.ID)
+	if err != nil {
+		return fmt.Errorf("error creating booking series: %w", err)
+	}
+	if err := insertOccurrences(tx, series, bookings); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertOccurrences(tx *sqlx.Tx, series *types.BookingSeries, bookings []*types.Booking) error {
+	query := `INSERT INTO booking (user_id, car_id, start_date, end_date, total, status, series_id, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+	for _, b := range bookings {
+		b.SeriesID = &series.ID
+		err := tx.QueryRowx(query, b.UserID, b.CarID, b.StartDate, b.EndDate, b.Total, b.Status, b.SeriesID, b.CreatedBy).Scan(&b.ID)
+		if err != nil {
+			return fmt.Errorf("error creating booking of series: %w", err)
+		}
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) GetSeriesByID(ctx context.Context, id int) (*types.BookingSeries, error) {
+	query := `SELECT id, user_id, car_id, start_date, end_date, weekdays, created FROM booking_series WHERE id = $1`
+	var series types.BookingSeries
+	err := bs.db.Get(&series, query, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.NotFound("booking series")
+		}
+		return nil, fmt.Errorf("error getting booking series: %w", err)
+	}
+	return &series, nil
+}
+
+func (bs *BookingRepositorySQL) UpdateSeries(ctx context.Context, series *types.BookingSeries, added []*types.Booking, cancelled []*types.Booking) error {
+	tx, err := bs.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE booking_series SET end_date=$1, weekdays=$2 WHERE id=$3`
+	if _, err := tx.Exec(query, series.EndDate, series.Weekdays, series.ID); err != nil {
+		return fmt.Errorf("error updating booking series: %w", err)
+	}
+	for _, b := range cancelled {
+		if _, err := tx.Exec(`UPDATE booking SET status = $1 WHERE id = $2`, types.BookingStatusCancelled, b.ID); err != nil {
+			return fmt.Errorf("error cancelling series occurrence: %w", err)
+		}
+		b.Status = types.BookingStatusCancelled
+	}
+	if err := insertOccurrences(tx, series, added); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (bs *BookingRepositorySQL) GetBySeriesID(ctx context.Context, seriesID int) ([]*types.Booking, error) {
+	query := `SELECT id, user_id, car_id, start_date, end_date, total, status, series_id, picked_up, created_by, category_id, branch_id, pickup_time, return_time FROM booking WHERE series_id = $1 ORDER BY start_date`
+	var bookings []*types.Booking
+	err := bs.db.Select(&bookings, query, seriesID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting series bookings: %w", err)
+	}
+	return bookings, nil
+}
+
+func (bs *BookingRepositorySQL) AddDriver(ctx context.Context, driver *types.BookingDriver) error {
+	query := `INSERT INTO booking_driver (booking_id, user_id, email, status, fee) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	err := bs.db.QueryRowx(query, driver.BookingID, driver.UserID, driver.Email, driver.Status, driver.Fee).Scan(&driver.ID)
+	if err != nil {
+		return fmt.Errorf("error adding booking driver: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) GetDrivers(ctx context.Context, bookingID int) ([]*types.BookingDriver, error) {
+	query := `SELECT id, booking_id, user_id, email, status, fee, created FROM booking_driver WHERE booking_id = $1 ORDER BY id`
+	var drivers []*types.BookingDriver
+	err := bs.db.Select(&drivers, query, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting booking drivers: %w", err)
+	}
+	return drivers, nil
+}
+
+func (bs *BookingRepositorySQL) UpdateDriver(ctx context.Context, driver *types.BookingDriver) error {
+	query := `UPDATE booking_driver SET user_id=$1, status=$2, fee=$3 WHERE id=$4`
+	_, err := bs.db.Exec(query, driver.UserID, driver.Status, driver.Fee, driver.ID)
+	if err != nil {
+		return fmt.Errorf("error updating booking driver: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) DeleteDriver(ctx context.Context, id int) error {
+	query := `DELETE FROM booking_driver WHERE id=$1`
+	_, err := bs.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting booking driver: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) CreateTransfer(ctx context.Context, transfer *types.BookingTransfer) error {
+	query := `INSERT INTO booking_transfer (booking_id, from_user_id, to_user_id, status) VALUES ($1, $2, $3, $4) RETURNING id, created`
+	err := bs.db.QueryRowx(query, transfer.BookingID, transfer.FromUserID, transfer.ToUserID, transfer.Status).Scan(&transfer.ID, &transfer.Created)
+	if err != nil {
+		return fmt.Errorf("error creating booking transfer: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) GetTransfers(ctx context.Context, bookingID int) ([]*types.BookingTransfer, error) {
+	query := `SELECT id, booking_id, from_user_id, to_user_id, status, created, resolved FROM booking_transfer WHERE booking_id = $1 ORDER BY id`
+	var transfers []*types.BookingTransfer
+	err := bs.db.Select(&transfers, query, bookingID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting booking transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+func (bs *BookingRepositorySQL) UpdateTransfer(ctx context.Context, transfer *types.BookingTransfer) error {
+	query := `UPDATE booking_transfer SET status=$1, resolved=$2 WHERE id=$3`
+	_, err := bs.db.Exec(query, transfer.Status, transfer.Resolved, transfer.ID)
+	if err != nil {
+		return fmt.Errorf("error updating booking transfer: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) CreateMaintenance(ctx context.Context, m *types.Maintenance) error {
+	query := `INSERT INTO maintenance (car_id, type, start_date, end_date, note) VALUES ($1, $2, $3, $4, $5) RETURNING id, created`
+	err := bs.db.QueryRowx(query, m.CarID, m.Type, m.StartDate, m.EndDate, m.Note).Scan(&m.ID, &m.Created)
+	if err != nil {
+		return fmt.Errorf("error creating maintenance: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) GetMaintenanceByID(ctx context.Context, id int) (*types.Maintenance, error) {
+	query := `SELECT id, car_id, type, start_date, end_date, note, created FROM maintenance WHERE id = $1`
+	var m types.Maintenance
+	err := bs.db.Get(&m, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting maintenance: %w", err)
+	}
+	return &m, nil
+}
+
+func (bs *BookingRepositorySQL) GetMaintenance(ctx context.Context, carID int) ([]*types.Maintenance, error) {
+	query := `SELECT id, car_id, type, start_date, end_date, note, created FROM maintenance WHERE car_id = $1 ORDER BY start_date`
+	var maintenance []*types.Maintenance
+	err := bs.db.Select(&maintenance, query, carID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting maintenance: %w", err)
+	}
+	return maintenance, nil
+}
+
+func (bs *BookingRepositorySQL) DeleteMaintenance(ctx context.Context, id int) error {
+	query := `DELETE FROM maintenance WHERE id=$1`
+	_, err := bs.db.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error deleting maintenance: %w", err)
+	}
+	return nil
+}
+
+const reviewColumns = `id, booking_id, user_id, car_id, company_id, car_rating, company_rating, comment, reply, replied, status, report_reason, created`
+
+func (bs *BookingRepositorySQL) CreateReview(ctx context.Context, review *types.Review) error {
+	query := `INSERT INTO review (booking_id, user_id, car_id, company_id, car_rating, company_rating, comment, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, created`
+	err := bs.db.QueryRowx(query, review.BookingID, review.UserID, review.CarID, review.CompanyID, review.CarRating, review.CompanyRating,
+		review.Comment, review.Status).Scan(&review.ID, &review.Created)
+	if err != nil {
+		return fmt.Errorf("error creating review: %w", err)
+	}
+	return nil
+}
+
+func (bs *BookingRepositorySQL) GetReview(ctx context.Context, id int) (*types.Review, error) {
+	var review types.Review
+	err := bs.db.Get(&review, `SELECT `+reviewColumns+` FROM review WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("error getting review: %w", err)
+	}
+	return &review, nil
+}
+
+func (bs *BookingRepositorySQL) GetReviewByBooking(ctx context.Context, bookingID int) (*types.Review, error) {
+	var review types.Review
+	err := bs.db.Get(&review, `SELECT `+reviewColumns+` FROM review WHERE booking_id = $1`, bookingID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting review: %w", err)
+	}
+	return &review, nil
+}
+
+func (bs *BookingRepositorySQL) GetReviews(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]*types.Review, error) {
+	query, args := utils.BuildBatchQuery(`SELECT `+reviewColumns+` FROM review WHERE 1 = 1`, filters, opts)
+
+	var reviews []*types.Review
+	err := bs.db.Select(&reviews, bs.db.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+func (bs *BookingRepositorySQL) UpdateReview(ctx context.Context, review *types.Review) error {
+	query := `UPDATE review SET reply = $1, replied = $2, status = $3, report_reason = $4 WHERE id = $5`
+	_, err := bs.db.Exec(query, review.Reply, review.Replied, review.Status, review.ReportReason, review.ID)
+	if err != nil {
+		return fmt.Errorf("error updating review: %w", err)
+	}
+	return nil
+}
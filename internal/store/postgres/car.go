@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mwdev22/CarRental/internal/types"
@@ -19,18 +20,48 @@ func NewCarRepository(db *sqlx.DB) *CarRepositorySQL {
 	}
 }
 
+const insertCarQuery = `INSERT INTO car (company_id, make, model, year, color, registration_no, price_per_day, category_id, status,
+	transmission, fuel_type, seats, doors, air_conditioning, navigation, electric_range, towbar, service_interval_km, service_interval_months,
+	latitude, longitude, vin, registration_expiry, insurance_expiry, roadworthiness_expiry)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25) RETURNING id`
+
+func insertCarArgs(car *types.Car) []interface{} {
+	return []interface{}{car.CompanyID, car.Make, car.Model, car.Year, car.Color, car.RegistrationNo, car.PricePerDay, car.CategoryID, car.Status,
+		car.Transmission, car.FuelType, car.Seats, car.Doors, car.AirConditioning, car.Navigation, car.ElectricRange, car.Towbar,
+		car.ServiceIntervalKm, car.ServiceIntervalMonths, car.Latitude, car.Longitude,
+		car.VIN, car.RegistrationExpiry, car.InsuranceExpiry, car.RoadworthinessExpiry}
+}
+
 func (r *CarRepositorySQL) Create(ctx context.Context, car *types.Car) error {
-	query := `INSERT INTO car (company_id, make, model, year, color, registration_no, price_per_day) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := r.DB.Exec(query, car.CompanyID, car.Make, car.Model, car.Year, car.Color, car.RegistrationNo, car.PricePerDay)
+	return r.DB.QueryRowx(insertCarQuery, insertCarArgs(car)...).Scan(&car.ID)
+}
+
+func (r *CarRepositorySQL) CreateBatch(ctx context.Context, cars []*types.Car, prices []*types.CarPrice) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	for i, car := range cars {
+		if err := tx.QueryRowx(insertCarQuery, insertCarArgs(car)...).Scan(&car.ID); err != nil {
+			return fmt.Errorf("car %s: %v", car.RegistrationNo, err)
+		}
+		price := prices[i]
+		price.CarID = car.ID
+		if err := tx.QueryRowx(insertPriceQuery, price.CarID, price.PricePerDay, price.EffectiveFrom, price.Applied).Scan(&price.ID, &price.Created); err != nil {
+			return fmt.Errorf("price of car %s: %v", car.RegistrationNo, err)
+		}
+	}
+	return tx.Commit()
 }
 
 func (r *CarRepositorySQL) GetByID(ctx context.Context, id int) (*types.Car, error) {
 	var car types.Car
-	query := `SELECT id, make, model, year, color, registration_no, price_per_day, created_at, updated_at FROM car WHERE id = $1`
+	query := `SELECT id, company_id, make, model, year, color, registration_no, price_per_day, category_id, branch_id, status, created_at, updated_at,
+		transmission, fuel_type, seats, doors, air_conditioning, navigation, electric_range, towbar,
+		service_interval_km, service_interval_months, latitude, longitude,
+		vin, registration_expiry, insurance_expiry, roadworthiness_expiry, rating, rating_count FROM car WHERE id = $1`
 	err := r.DB.Get(&car, query, id)
 	if err != nil {
 		return nil, err
@@ -39,8 +70,14 @@ func (r *CarRepositorySQL) GetByID(ctx context.Context, id int) (*types.Car, err
 }
 
 func (r *CarRepositorySQL) Update(ctx context.Context, id int, car *types.Car) error {
-	query := `UPDATE car SET make = $1, model = $2, year = $3, color = $4, registration_no = $5, price_per_day = $6, updated = CURRENT_TIMESTAMP WHERE id = $7`
-	_, err := r.DB.Exec(query, car.Make, car.Model, car.Year, car.Color, car.RegistrationNo, car.PricePerDay, id)
+	query := `UPDATE car SET make = $1, model = $2, year = $3, color = $4, registration_no = $5, price_per_day = $6, category_id = $7, status = $8,
+		transmission = $9, fuel_type = $10, seats = $11, doors = $12, air_conditioning = $13, navigation = $14, electric_range = $15, towbar = $16,
+		service_interval_km = $17, service_interval_months = $18, latitude = $19, longitude = $20,
+		vin = $21, registration_expiry = $22, insurance_expiry = $23, roadworthiness_expiry = $24, updated = CURRENT_TIMESTAMP WHERE id = $25`
+	_, err := r.DB.Exec(query, car.Make, car.Model, car.Year, car.Color, car.RegistrationNo, car.PricePerDay, car.CategoryID, car.Status,
+		car.Transmission, car.FuelType, car.Seats, car.Doors, car.AirConditioning, car.Navigation, car.ElectricRange, car.Towbar,
+		car.ServiceIntervalKm, car.ServiceIntervalMonths, car.Latitude, car.Longitude,
+		car.VIN, car.RegistrationExpiry, car.InsuranceExpiry, car.RoadworthinessExpiry, id)
 	if err != nil {
 		return err
 	}
@@ -48,10 +85,22 @@ func (r *CarRepositorySQL) Update(ctx context.Context, id int, car *types.Car) e
 }
 
 func (r *CarRepositorySQL) GetBatch(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]types.Car, error) {
-	query := `SELECT id, make, model, year, color, registration_no, price_per_day, created_at, updated_at FROM car WHERE 1 = 1`
+	columns := `id, company_id, make, model, year, color, registration_no, price_per_day, category_id, branch_id, status, created_at, updated_at,
+		transmission, fuel_type, seats, doors, air_conditioning, navigation, electric_range, towbar,
+		service_interval_km, service_interval_months, latitude, longitude,
+		vin, registration_expiry, insurance_expiry, roadworthiness_expiry, rating, rating_count`
+	if opts != nil && opts.Near != nil {
+		columns += ", distance_km"
+	}
+	if opts != nil && len(opts.Search) > 0 {
+		columns += ", relevance"
+	}
+	from, fromArgs, filters := batchSource(filters, opts)
+	where, whereArgs, filters := companyStatusWhere(filters)
 
-	query, args := utils.BuildBatchQuery(query, filters, opts)
+	query, args := utils.BuildBatchQuery(fmt.Sprintf("SELECT %s FROM %s %s", columns, from, where), filters, opts)
 	query = r.DB.Rebind(query)
+	args = append(append(fromArgs, whereArgs...), args...)
 
 	var cars []types.Car
 	err := r.DB.Select(&cars, query, args...)
@@ -61,6 +110,74 @@ func (r *CarRepositorySQL) GetBatch(ctx context.Context, filters []*types.QueryF
 	return cars, nil
 }
 
+// car table wrapped for near and text search, with the filters they add
+func batchSource(filters []*types.QueryFilter, opts *types.QueryOptions) (string, []interface{}, []*types.QueryFilter) {
+	from := "car"
+	var args []interface{}
+	if opts != nil && opts.Near != nil {
+		from, args, filters = utils.WithDistance("car", opts.Near, filters)
+	}
+	if opts != nil && len(opts.Search) > 0 {
+		var searchArgs []interface{}
+		from, searchArgs = utils.WithSearch(from, "car", opts.Search)
+		args = append(args, searchArgs...)
+	}
+	return from, args, filters
+}
+
+// company_status isn't a car column, cars are matched with the status of their company in a subquery
+//
+//	company_status = verified --> WHERE 1 = 1 AND company_id IN (SELECT id FROM company WHERE status = ?)
+func companyStatusWhere(filters []*types.QueryFilter) (string, []interface{}, []*types.QueryFilter) {
+	where := "WHERE 1 = 1"
+	var args []interface{}
+	rest := make([]*types.QueryFilter, 0, len(filters))
+	for _, f := range filters {
+		if f.Field != types.CompanyStatusFilter {
+			rest = append(rest, f)
+			continue
+		}
+		where += fmt.Sprintf(" AND company_id IN (SELECT id FROM company WHERE status %s ?)", f.Operator)
+		args = append(args, f.Value)
+	}
+	return where, args, rest
+}
+
+// counts over all cars matching the filters, pagination and sorting are ignored
+func (r *CarRepositorySQL) GetFacets(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) (*types.CarFacets, error) {
+	from, fromArgs, filters := batchSource(filters, opts)
+	statusWhere, statusArgs, filters := companyStatusWhere(filters)
+	where, whereArgs := utils.BuildFilters(statusWhere, filters)
+	args := append(append(fromArgs, statusArgs...), whereArgs...)
+
+	facets := &types.CarFacets{}
+	for expr, counts := range map[string]*[]types.FacetCount{
+		"make":                  &facets.Make,
+		"category_id::text":     &facets.Category,
+		"transmission":          &facets.Transmission,
+		priceBucketExpression(): &facets.Price,
+		"company_id::text":      &facets.Company,
+	} {
+		// null and empty values aren't counted
+		query := fmt.Sprintf(`SELECT %s AS value, COUNT(*) AS count FROM %s %s AND %s != '' GROUP BY 1`, expr, from, where, expr)
+		*counts = make([]types.FacetCount, 0)
+		if err := r.DB.Select(counts, r.DB.Rebind(query), args...); err != nil {
+			return nil, err
+		}
+	}
+	return facets, nil
+}
+
+// same buckets as types.PriceBucket
+func priceBucketExpression() string {
+	labels := types.PriceBucketLabels()
+	expr := "CASE"
+	for i, upper := range types.PriceBuckets {
+		expr += fmt.Sprintf(" WHEN price_per_day < %g THEN '%s'", upper, labels[i])
+	}
+	return expr + fmt.Sprintf(" ELSE '%s' END", labels[len(labels)-1])
+}
+
 func (r *CarRepositorySQL) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM car WHERE id = $1`
 	res, err := r.DB.Exec(query, id)
@@ -77,3 +194,275 @@ func (r *CarRepositorySQL) Delete(ctx context.Context, id int) error {
 
 	return nil
 }
+
+func (r *CarRepositorySQL) GetByCategory(ctx context.Context, categoryID int) ([]types.Car, error) {
+	query := `SELECT id, company_id, make, model, year, color, registration_no, price_per_day, category_id, branch_id, status, created_at, updated_at,
+		transmission, fuel_type, seats, doors, air_conditioning, navigation, electric_range, towbar,
+		service_interval_km, service_interval_months, latitude, longitude,
+		vin, registration_expiry, insurance_expiry, roadworthiness_expiry, rating, rating_count FROM car
+		WHERE category_id = $1 ORDER BY price_per_day, id`
+
+	var cars []types.Car
+	err := r.DB.Select(&cars, query, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+func (r *CarRepositorySQL) GetExpiringDocuments(ctx context.Context, until time.Time) ([]types.Car, error) {
+	query := `SELECT id, company_id, make, model, year, color, registration_no, price_per_day, category_id, branch_id, status, created_at, updated_at,
+		transmission, fuel_type, seats, doors, air_conditioning, navigation, electric_range, towbar,
+		service_interval_km, service_interval_months, latitude, longitude,
+		vin, registration_expiry, insurance_expiry, roadworthiness_expiry, rating, rating_count FROM car
+		WHERE status != $1 AND LEAST(registration_expiry, insurance_expiry, roadworthiness_expiry) <= $2 ORDER BY id`
+
+	var cars []types.Car
+	err := r.DB.Select(&cars, query, types.CarStatusRetired, until)
+	if err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+func (r *CarRepositorySQL) GetCategories(ctx context.Context) ([]types.CarCategory, error) {
+	query := `SELECT id, name, seats, doors, transmission, fuel_type, luggage FROM car_category ORDER BY id`
+
+	var categories []types.CarCategory
+	err := r.DB.Select(&categories, query)
+	if err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func (r *CarRepositorySQL) GetCategoryByID(ctx context.Context, id int) (*types.CarCategory, error) {
+	query := `SELECT id, name, seats, doors, transmission, fuel_type, luggage FROM car_category WHERE id = $1`
+
+	var category types.CarCategory
+	err := r.DB.Get(&category, query, id)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *CarRepositorySQL) AddReading(ctx context.Context, reading *types.CarReading) error {
+	query := `INSERT INTO car_reading (car_id, booking_id, odometer, fuel_level, source, recorded) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	return r.DB.QueryRowx(query, reading.CarID, reading.BookingID, reading.Odometer, reading.FuelLevel, reading.Source,
+		reading.Recorded).Scan(&reading.ID)
+}
+
+func (r *CarRepositorySQL) GetReadings(ctx context.Context, carID int, limit int) ([]*types.CarReading, error) {
+	query := `SELECT id, car_id, booking_id, odometer, fuel_level, source, recorded FROM car_reading
+		WHERE car_id = $1 ORDER BY recorded DESC, id DESC LIMIT $2`
+
+	var readings []*types.CarReading
+	err := r.DB.Select(&readings, query, carID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return readings, nil
+}
+
+func (r *CarRepositorySQL) AddServiceRecord(ctx context.Context, record *types.ServiceRecord) error {
+	query := `INSERT INTO service_record (car_id, description, cost, odometer, performed) VALUES ($1, $2, $3, $4, $5) RETURNING id, created`
+	return r.DB.QueryRowx(query, record.CarID, record.Description, record.Cost, record.Odometer,
+		record.Performed).Scan(&record.ID, &record.Created)
+}
+
+func (r *CarRepositorySQL) GetServiceRecords(ctx context.Context, carID int) ([]*types.ServiceRecord, error) {
+	query := `SELECT id, car_id, description, cost, odometer, performed, created FROM service_record
+		WHERE car_id = $1 ORDER BY performed DESC, id DESC`
+
+	var records []*types.ServiceRecord
+	err := r.DB.Select(&records, query, carID)
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+const insertPriceQuery = `INSERT INTO car_price (car_id, price_per_day, effective_from, applied) VALUES ($1, $2, $3, $4) RETURNING id, created`
+
+func (r *CarRepositorySQL) AddPrice(ctx context.Context, price *types.CarPrice) error {
+	return r.DB.QueryRowx(insertPriceQuery, price.CarID, price.PricePerDay, price.EffectiveFrom, price.Applied).Scan(&price.ID, &price.Created)
+}
+
+func (r *CarRepositorySQL) GetPrices(ctx context.Context, carID int) ([]*types.CarPrice, error) {
+	query := `SELECT id, car_id, price_per_day, effective_from, applied, created FROM car_price
+		WHERE car_id = $1 ORDER BY effective_from, id`
+
+	var prices []*types.CarPrice
+	err := r.DB.Select(&prices, query, carID)
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (r *CarRepositorySQL) GetPendingPrices(ctx context.Context, until time.Time) ([]*types.CarPrice, error) {
+	query := `SELECT id, car_id, price_per_day, effective_from, applied, created FROM car_price
+		WHERE NOT applied AND effective_from <= $1 ORDER BY effective_from, id`
+
+	var prices []*types.CarPrice
+	err := r.DB.Select(&prices, query, until)
+	if err != nil {
+		return nil, err
+	}
+	return prices, nil
+}
+
+func (r *CarRepositorySQL) SetPriceApplied(ctx context.Context, id int) error {
+	query := `UPDATE car_price SET applied = TRUE WHERE id = $1`
+	_, err := r.DB.Exec(query, id)
+	return err
+}
+
+func (r *CarRepositorySQL) DeletePrice(ctx context.Context, id int) error {
+	query := `DELETE FROM car_price WHERE id = $1`
+	_, err := r.DB.Exec(query, id)
+	return err
+}
+
+func (r *CarRepositorySQL) AddTelemetry(ctx context.Context, points []*types.TelemetryPoint) error {
+	query := `INSERT INTO telemetry (car_id, recorded, latitude, longitude, odometer, fuel_level, battery_level, ignition)
+		VALUES (:car_id, :recorded, :latitude, :longitude, :odometer, :fuel_level, :battery_level, :ignition)`
+	_, err := r.DB.NamedExecContext(ctx, query, points)
+	return err
+}
+
+func (r *CarRepositorySQL) GetLastPosition(ctx context.Context, carID int) (*types.TelemetryPoint, error) {
+	query := `SELECT id, car_id, recorded, latitude, longitude, odometer, fuel_level, battery_level, ignition FROM telemetry
+		WHERE car_id = $1 AND latitude IS NOT NULL ORDER BY recorded DESC LIMIT 1`
+
+	var points []*types.TelemetryPoint
+	err := r.DB.Select(&points, query, carID)
+	if err != nil || len(points) == 0 {
+		return nil, err
+	}
+	return points[0], nil
+}
+
+func (r *CarRepositorySQL) DeleteTelemetryBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `DELETE FROM telemetry WHERE recorded < $1`
+	res, err := r.DB.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (r *CarRepositorySQL) AddImage(ctx context.Context, image *types.CarImage) error {
+	query := `INSERT INTO car_image (car_id, key, thumbnail_key, content_type, size, position, is_primary)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created`
+	return r.DB.QueryRowx(query, image.CarID, image.Key, image.ThumbnailKey, image.ContentType, image.Size,
+		image.Position, image.Primary).Scan(&image.ID, &image.Created)
+}
+
+func (r *CarRepositorySQL) GetImages(ctx context.Context, carID int) ([]*types.CarImage, error) {
+	query := `SELECT id, car_id, key, thumbnail_key, content_type, size, position, is_primary, created FROM car_image
+		WHERE car_id = $1 ORDER BY position, id`
+
+	var images []*types.CarImage
+	err := r.DB.Select(&images, query, carID)
+	if err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (r *CarRepositorySQL) UpdateImage(ctx context.Context, image *types.CarImage) error {
+	query := `UPDATE car_image SET position = $1, is_primary = $2 WHERE id = $3`
+	_, err := r.DB.Exec(query, image.Position, image.Primary, image.ID)
+	return err
+}
+
+func (r *CarRepositorySQL) DeleteImage(ctx context.Context, id int) error {
+	query := `DELETE FROM car_image WHERE id = $1`
+	_, err := r.DB.Exec(query, id)
+	return err
+}
+
+func (r *CarRepositorySQL) CreateTransfer(ctx context.Context, transfer *types.CarTransfer) error {
+	return createTransfer(r.DB, transfer)
+}
+
+func createTransfer(db sqlx.Queryer, transfer *types.CarTransfer) error {
+	query := `INSERT INTO car_transfer (car_id, from_company_id, to_company_id, from_branch_id, to_branch_id, status, migrate_bookings,
+		migrated_bookings, requested_by, resolved_by, resolved)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id, created`
+	return db.QueryRowx(query, transfer.CarID, transfer.FromCompanyID, transfer.ToCompanyID, transfer.FromBranchID, transfer.ToBranchID,
+		transfer.Status, transfer.MigrateBookings, transfer.MigratedBookings, transfer.RequestedBy, transfer.ResolvedBy, transfer.Resolved).
+		Scan(&transfer.ID, &transfer.Created)
+}
+
+func (r *CarRepositorySQL) GetTransfers(ctx context.Context, carID int) ([]*types.CarTransfer, error) {
+	var transfers []*types.CarTransfer
+	query := `SELECT id, car_id, from_company_id, to_company_id, from_branch_id, to_branch_id, status, migrate_bookings, migrated_bookings,
+		requested_by, resolved_by, created, resolved
+		FROM car_transfer WHERE car_id = $1 ORDER BY id`
+	err := r.DB.Select(&transfers, query, carID)
+	if err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+func (r *CarRepositorySQL) UpdateTransfer(ctx context.Context, transfer *types.CarTransfer) error {
+	return updateTransfer(r.DB, transfer)
+}
+
+func (r *CarRepositorySQL) CompleteTransfer(ctx context.Context, transfer *types.CarTransfer, bookings []*types.Booking) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE car SET company_id = $1, branch_id = $2, updated = CURRENT_TIMESTAMP WHERE id = $3`,
+		transfer.ToCompanyID, transfer.ToBranchID, transfer.CarID); err != nil {
+		return err
+	}
+	for _, b := range bookings {
+		if _, err := tx.Exec(`UPDATE booking SET branch_id = $1, pickup_time = $2, return_time = $3 WHERE id = $4`,
+			b.BranchID, b.PickupTime, b.ReturnTime, b.ID); err != nil {
+			return fmt.Errorf("booking %d: %v", b.ID, err)
+		}
+	}
+	if transfer.ID == 0 {
+		err = createTransfer(tx, transfer)
+	} else {
+		err = updateTransfer(tx, transfer)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateTransfer(db sqlx.Execer, transfer *types.CarTransfer) error {
+	query := `UPDATE car_transfer SET status = $1, migrated_bookings = $2, resolved_by = $3, resolved = $4 WHERE id = $5`
+	_, err := db.Exec(query, transfer.Status, transfer.MigratedBookings, transfer.ResolvedBy, transfer.Resolved, transfer.ID)
+	return err
+}
+
+func (r *CarRepositorySQL) SetRating(ctx context.Context, carID int, rating types.Rating) error {
+	_, err := r.DB.Exec(`UPDATE car SET rating = $1, rating_count = $2 WHERE id = $3`, rating.Rating, rating.RatingCount, carID)
+	return err
+}
+
+func (r *CarRepositorySQL) UpdateStatus(ctx context.Context, carID int, status types.CarStatus) error {
+	_, err := r.DB.Exec(`UPDATE car SET status = $1, updated = CURRENT_TIMESTAMP WHERE id = $2`, status, carID)
+	return err
+}
+
+func (r *CarRepositorySQL) UpdatePrice(ctx context.Context, carID int, price float64) error {
+	_, err := r.DB.Exec(`UPDATE car SET price_per_day = $1, updated = CURRENT_TIMESTAMP WHERE id = $2`, price, carID)
+	return err
+}
+
+func (r *CarRepositorySQL) UpdateLocation(ctx context.Context, carID int, latitude, longitude float64) error {
+	_, err := r.DB.Exec(`UPDATE car SET latitude = $1, longitude = $2 WHERE id = $3`, latitude, longitude, carID)
+	return err
+}
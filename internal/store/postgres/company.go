@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mwdev22/CarRental/internal/types"
@@ -21,19 +22,32 @@ func NewCompanyRepository(db *sqlx.DB) *CompanyRepository {
 }
 
 func (r *CompanyRepository) Create(ctx context.Context, company *types.Company) error {
-	query := `INSERT INTO company (owner_id, name, email, phone, address) VALUES ($1, $2, $3, $4, $5)`
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := r.DB.Exec(query, company.OwnerID, company.Name, company.Email, company.Phone, company.Address)
+	query := `INSERT INTO company (owner_id, name, email, phone, address, latitude, longitude, status) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	err = tx.QueryRowx(query, company.OwnerID, company.Name, company.Email, company.Phone, company.Address, company.Latitude, company.Longitude, company.Status).Scan(&company.ID)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	query = `INSERT INTO company_member (company_id, user_id, email, role, status, joined)
+		SELECT $1, id, email, $2, $3, CURRENT_TIMESTAMP FROM users WHERE id = $4`
+	if _, err := tx.Exec(query, company.ID, types.CompanyRoleOwner, types.CompanyMemberActive, company.OwnerID); err != nil {
+		return fmt.Errorf("error adding company owner: %w", err)
+	}
+
+	return tx.Commit()
 }
 
 func (r *CompanyRepository) GetByID(ctx context.Context, id int) (*types.Company, error) {
 	var company types.Company
-	query := `SELECT id, owner_id, name, email, phone, address FROM company WHERE id = $1`
+	query := `SELECT id, owner_id, name, email, phone, address, latitude, longitude, rating, rating_count, status, rejection_reason, verified_at
+		FROM company WHERE id = $1`
 
 	err := r.DB.Get(&company, query, id)
 	if err != nil {
@@ -47,9 +61,9 @@ func (r *CompanyRepository) GetByID(ctx context.Context, id int) (*types.Company
 }
 
 func (r *CompanyRepository) Update(ctx context.Context, company *types.Company) error {
-	query := `UPDATE company SET name = $1, email = $2, phone = $3, address = $4 WHERE id = $5`
+	query := `UPDATE company SET name = $1, email = $2, phone = $3, address = $4, latitude = $5, longitude = $6 WHERE id = $7`
 
-	rows, err := r.DB.Exec(query, company.Name, company.Email, company.Phone, company.Address, company.ID)
+	rows, err := r.DB.Exec(query, company.Name, company.Email, company.Phone, company.Address, company.Latitude, company.Longitude, company.ID)
 	if err != nil {
 		return err
 	}
@@ -77,10 +91,16 @@ func (r *CompanyRepository) Delete(ctx context.Context, id int) error {
 }
 
 func (r *CompanyRepository) GetBatch(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]types.Company, error) {
-	query := `SELECT id, owner_id, name, email, phone, address FROM company WHERE 1 = 1`
+	columns, from := "id, owner_id, name, email, phone, address, latitude, longitude, rating, rating_count, status, rejection_reason, verified_at", "company"
+	var geoArgs []interface{}
+	if opts != nil && opts.Near != nil {
+		from, geoArgs, filters = utils.WithDistance("company", opts.Near, filters)
+		columns += ", distance_km"
+	}
 
-	query, args := utils.BuildBatchQuery(query, filters, opts)
+	query, args := utils.BuildBatchQuery(fmt.Sprintf("SELECT %s FROM %s WHERE 1 = 1", columns, from), filters, opts)
 	query = r.DB.Rebind(query)
+	args = append(geoArgs, args...)
 
 	var companies []types.Company
 	rows, err := r.DB.Queryx(query, args...)
@@ -98,3 +118,349 @@ func (r *CompanyRepository) GetBatch(ctx context.Context, filters []*types.Query
 
 	return companies, nil
 }
+
+func (r *CompanyRepository) GetRentalRules(ctx context.Context, companyID int, carID *int) (*types.RentalRules, error) {
+	var rules types.RentalRules
+	query := `SELECT id, company_id, car_id, min_age, min_years_licensed, young_driver_age, young_driver_surcharge, license_category, additional_driver_fee,
+		no_show_grace_hours, no_show_fee FROM rental_rules WHERE company_id = $1 AND car_id IS NOT DISTINCT FROM $2::int`
+
+	err := r.DB.Get(&rules, query, companyID, carID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+func (r *CompanyRepository) SaveRentalRules(ctx context.Context, rules *types.RentalRules) error {
+	if rules.ID == 0 {
+		query := `INSERT INTO rental_rules (company_id, car_id, min_age, min_years_licensed, young_driver_age, young_driver_surcharge, license_category, additional_driver_fee,
+			no_show_grace_hours, no_show_fee) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
+		return r.DB.QueryRowx(query, rules.CompanyID, rules.CarID, rules.MinAge, rules.MinYearsLicensed, rules.YoungDriverAge,
+			rules.YoungDriverSurcharge, rules.LicenseCategory, rules.AdditionalDriverFee, rules.NoShowGraceHours, rules.NoShowFee).Scan(&rules.ID)
+	}
+
+	query := `UPDATE rental_rules SET min_age = $1, min_years_licensed = $2, young_driver_age = $3, young_driver_surcharge = $4,
+		license_category = $5, additional_driver_fee = $6, no_show_grace_hours = $7, no_show_fee = $8, updated = CURRENT_TIMESTAMP WHERE id = $9`
+	rows, err := r.DB.Exec(query, rules.MinAge, rules.MinYearsLicensed, rules.YoungDriverAge, rules.YoungDriverSurcharge,
+		rules.LicenseCategory, rules.AdditionalDriverFee, rules.NoShowGraceHours, rules.NoShowFee, rules.ID)
+	if err != nil {
+		return err
+	}
+
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("rental rules not found")
+	}
+
+	return nil
+}
+
+func (r *CompanyRepository) GetDynamicPricing(ctx context.Context, companyID int) (*types.DynamicPricing, error) {
+	var pricing types.DynamicPricing
+	query := `SELECT company_id, enabled, target_utilisation, utilisation_factor, last_minute_days, last_minute_factor,
+		early_booking_days, early_booking_factor, floor, ceiling FROM dynamic_pricing WHERE company_id = $1`
+
+	err := r.DB.Get(&pricing, query, companyID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &pricing, nil
+}
+
+func (r *CompanyRepository) SaveDynamicPricing(ctx context.Context, p *types.DynamicPricing) error {
+	query := `INSERT INTO dynamic_pricing (company_id, enabled, target_utilisation, utilisation_factor, last_minute_days, last_minute_factor,
+		early_booking_days, early_booking_factor, floor, ceiling) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (company_id) DO UPDATE SET enabled = $2, target_utilisation = $3, utilisation_factor = $4, last_minute_days = $5,
+		last_minute_factor = $6, early_booking_days = $7, early_booking_factor = $8, floor = $9, ceiling = $10, updated = CURRENT_TIMESTAMP`
+
+	_, err := r.DB.Exec(query, p.CompanyID, p.Enabled, p.TargetUtilisation, p.UtilisationFactor, p.LastMinuteDays, p.LastMinuteFactor,
+		p.EarlyBookingDays, p.EarlyBookingFactor, p.Floor, p.Ceiling)
+	return err
+}
+
+func (r *CompanyRepository) SetRating(ctx context.Context, companyID int, rating types.Rating) error {
+	_, err := r.DB.Exec(`UPDATE company SET rating = $1, rating_count = $2 WHERE id = $3`, rating.Rating, rating.RatingCount, companyID)
+	return err
+}
+
+func (r *CompanyRepository) CreateBranch(ctx context.Context, b *types.Branch) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO branch (company_id, name, address, latitude, longitude, time_zone, out_of_hours_fee)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err = tx.QueryRowx(query, b.CompanyID, b.Name, b.Address, b.Latitude, b.Longitude, b.TimeZone, b.OutOfHoursFee).Scan(&b.ID)
+	if err != nil {
+		return fmt.Errorf("error creating branch: %w", err)
+	}
+	if err := saveBranchSchedule(tx, b); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *CompanyRepository) GetBranch(ctx context.Context, id int) (*types.Branch, error) {
+	var branch types.Branch
+	query := `SELECT id, company_id, name, address, latitude, longitude, time_zone, out_of_hours_fee FROM branch WHERE id = $1`
+	if err := r.DB.Get(&branch, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.NotFound("branch not found")
+		}
+		return nil, err
+	}
+
+	if err := r.loadBranchSchedules(ctx, []*types.Branch{&branch}); err != nil {
+		return nil, err
+	}
+	return &branch, nil
+}
+
+func (r *CompanyRepository) GetBranches(ctx context.Context, companyID int) ([]*types.Branch, error) {
+	var branches []*types.Branch
+	query := `SELECT id, company_id, name, address, latitude, longitude, time_zone, out_of_hours_fee FROM branch WHERE company_id = $1 ORDER BY id`
+	if err := r.DB.Select(&branches, query, companyID); err != nil {
+		return nil, fmt.Errorf("error getting branches: %w", err)
+	}
+
+	if err := r.loadBranchSchedules(ctx, branches); err != nil {
+		return nil, err
+	}
+	return branches, nil
+}
+
+// opening hours and closures of all the branches with two queries
+func (r *CompanyRepository) loadBranchSchedules(ctx context.Context, branches []*types.Branch) error {
+	if len(branches) == 0 {
+		return nil
+	}
+	byID := make(map[int]*types.Branch, len(branches))
+	ids := make([]int, len(branches))
+	for i, b := range branches {
+		b.OpeningHours = make([]*types.OpeningHours, 0)
+		b.Closures = make([]*types.BranchClosure, 0)
+		byID[b.ID] = b
+		ids[i] = b.ID
+	}
+
+	query, args, err := sqlx.In(`SELECT branch_id, weekday, opens, closes FROM branch_hours WHERE branch_id IN (?) ORDER BY weekday, opens`, ids)
+	if err != nil {
+		return err
+	}
+	var hours []*types.OpeningHours
+	if err := r.DB.Select(&hours, r.DB.Rebind(query), args...); err != nil {
+		return fmt.Errorf("error getting opening hours: %w", err)
+	}
+	for _, h := range hours {
+		byID[h.BranchID].OpeningHours = append(byID[h.BranchID].OpeningHours, h)
+	}
+
+	query, args, err = sqlx.In(`SELECT branch_id, date, reason FROM branch_closure WHERE branch_id IN (?) ORDER BY date`, ids)
+	if err != nil {
+		return err
+	}
+	var closures []*types.BranchClosure
+	if err := r.DB.Select(&closures, r.DB.Rebind(query), args...); err != nil {
+		return fmt.Errorf("error getting branch closures: %w", err)
+	}
+	for _, c := range closures {
+		byID[c.BranchID].Closures = append(byID[c.BranchID].Closures, c)
+	}
+	return nil
+}
+
+func (r *CompanyRepository) UpdateBranch(ctx context.Context, b *types.Branch) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE branch SET name = $1, address = $2, latitude = $3, longitude = $4, time_zone = $5, out_of_hours_fee = $6 WHERE id = $7`
+	rows, err := tx.Exec(query, b.Name, b.Address, b.Latitude, b.Longitude, b.TimeZone, b.OutOfHoursFee, b.ID)
+	if err != nil {
+		return fmt.Errorf("error updating branch: %w", err)
+	}
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("branch not found")
+	}
+
+	for _, table := range []string{"branch_hours", "branch_closure"} {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE branch_id = $1`, b.ID); err != nil {
+			return fmt.Errorf("error updating branch: %w", err)
+		}
+	}
+	if err := saveBranchSchedule(tx, b); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveBranchSchedule(tx *sqlx.Tx, b *types.Branch) error {
+	for _, h := range b.OpeningHours {
+		h.BranchID = b.ID
+		if _, err := tx.Exec(`INSERT INTO branch_hours (branch_id, weekday, opens, closes) VALUES ($1, $2, $3, $4)`,
+			h.BranchID, h.Weekday, h.Opens, h.Closes); err != nil {
+			return fmt.Errorf("error saving opening hours: %w", err)
+		}
+	}
+	for _, c := range b.Closures {
+		c.BranchID = b.ID
+		if _, err := tx.Exec(`INSERT INTO branch_closure (branch_id, date, reason) VALUES ($1, $2, $3)`,
+			c.BranchID, c.Date, c.Reason); err != nil {
+			return fmt.Errorf("error saving branch closure: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *CompanyRepository) DeleteBranch(ctx context.Context, id int) error {
+	rows, err := r.DB.Exec(`DELETE FROM branch WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting branch: %w", err)
+	}
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("branch not found")
+	}
+	return nil
+}
+
+func (r *CompanyRepository) CreateMember(ctx context.Context, m *types.CompanyMember) error {
+	query := `INSERT INTO company_member (company_id, user_id, email, role, status, invited_by, joined)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created`
+	err := r.DB.QueryRowx(query, m.CompanyID, m.UserID, m.Email, m.Role, m.Status, m.InvitedBy, m.Joined).Scan(&m.ID, &m.Created)
+	if err != nil {
+		return fmt.Errorf("error creating company member: %w", err)
+	}
+	return nil
+}
+
+func (r *CompanyRepository) GetMember(ctx context.Context, id int) (*types.CompanyMember, error) {
+	var member types.CompanyMember
+	query := `SELECT id, company_id, user_id, email, role, status, invited_by, created, joined FROM company_member WHERE id = $1`
+	if err := r.DB.Get(&member, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.NotFound("company member not found")
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *CompanyRepository) GetMembers(ctx context.Context, companyID int) ([]*types.CompanyMember, error) {
+	var members []*types.CompanyMember
+	query := `SELECT id, company_id, user_id, email, role, status, invited_by, created, joined FROM company_member WHERE company_id = $1 ORDER BY id`
+	if err := r.DB.Select(&members, query, companyID); err != nil {
+		return nil, fmt.Errorf("error getting company members: %w", err)
+	}
+	return members, nil
+}
+
+func (r *CompanyRepository) GetMembership(ctx context.Context, companyID int, userID int) (*types.CompanyMember, error) {
+	var member types.CompanyMember
+	query := `SELECT id, company_id, user_id, email, role, status, invited_by, created, joined FROM company_member
+		WHERE company_id = $1 AND user_id = $2 AND status = $3`
+	if err := r.DB.Get(&member, query, companyID, userID, types.CompanyMemberActive); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *CompanyRepository) UpdateMember(ctx context.Context, m *types.CompanyMember) error {
+	query := `UPDATE company_member SET user_id = $1, role = $2, status = $3, joined = $4 WHERE id = $5`
+	rows, err := r.DB.Exec(query, m.UserID, m.Role, m.Status, m.Joined, m.ID)
+	if err != nil {
+		return fmt.Errorf("error updating company member: %w", err)
+	}
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("company member not found")
+	}
+	return nil
+}
+
+func (r *CompanyRepository) DeleteMember(ctx context.Context, id int) error {
+	rows, err := r.DB.Exec(`DELETE FROM company_member WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting company member: %w", err)
+	}
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("company member not found")
+	}
+	return nil
+}
+
+func (r *CompanyRepository) SetVerification(ctx context.Context, c *types.Company) error {
+	query := `UPDATE company SET status = $1, rejection_reason = $2, verified_at = $3 WHERE id = $4`
+	rows, err := r.DB.Exec(query, c.Status, c.RejectionReason, c.Verified, c.ID)
+	if err != nil {
+		return fmt.Errorf("error updating company verification: %w", err)
+	}
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("company not found")
+	}
+	return nil
+}
+
+func (r *CompanyRepository) GetAwaitingVerification(ctx context.Context) ([]types.Company, error) {
+	var companies []types.Company
+	query := `SELECT id, owner_id, name, email, phone, address, latitude, longitude, rating, rating_count, status, rejection_reason, verified_at
+		FROM company WHERE status = $1 AND EXISTS (SELECT 1 FROM company_document WHERE company_document.company_id = company.id)
+		ORDER BY id`
+	if err := r.DB.Select(&companies, query, types.CompanyStatusPending); err != nil {
+		return nil, fmt.Errorf("error getting companies awaiting verification: %w", err)
+	}
+	return companies, nil
+}
+
+func (r *CompanyRepository) AddDocument(ctx context.Context, d *types.CompanyDocument) error {
+	query := `INSERT INTO company_document (company_id, name, key, content_type, size) VALUES ($1, $2, $3, $4, $5) RETURNING id, created`
+	err := r.DB.QueryRowx(query, d.CompanyID, d.Name, d.Key, d.ContentType, d.Size).Scan(&d.ID, &d.Created)
+	if err != nil {
+		return fmt.Errorf("error adding company document: %w", err)
+	}
+	return nil
+}
+
+func (r *CompanyRepository) GetDocument(ctx context.Context, id int) (*types.CompanyDocument, error) {
+	var document types.CompanyDocument
+	query := `SELECT id, company_id, name, key, content_type, size, created FROM company_document WHERE id = $1`
+	if err := r.DB.Get(&document, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, types.NotFound("company document not found")
+		}
+		return nil, err
+	}
+	return &document, nil
+}
+
+func (r *CompanyRepository) GetDocuments(ctx context.Context, companyID int) ([]*types.CompanyDocument, error) {
+	var documents []*types.CompanyDocument
+	query := `SELECT id, company_id, name, key, content_type, size, created FROM company_document WHERE company_id = $1 ORDER BY id`
+	if err := r.DB.Select(&documents, query, companyID); err != nil {
+		return nil, fmt.Errorf("error getting company documents: %w", err)
+	}
+	return documents, nil
+}
+
+func (r *CompanyRepository) DeleteDocument(ctx context.Context, id int) error {
+	rows, err := r.DB.Exec(`DELETE FROM company_document WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("error deleting company document: %w", err)
+	}
+	if count, _ := rows.RowsAffected(); count == 0 {
+		return types.NotFound("company document not found")
+	}
+	return nil
+}
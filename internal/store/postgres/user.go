@@ -2,6 +2,8 @@ package postgres
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 
 	"github.com/jmoiron/sqlx"
@@ -37,6 +39,19 @@ func (r *UserRepositorySQL) GetByUsername(ctx context.Context, username string)
 	return &user, nil
 }
 
+func (r *UserRepositorySQL) GetByEmail(ctx context.Context, email string) (*types.User, error) {
+	var user types.User
+	query := `SELECT id, username, email, role FROM users WHERE LOWER(email) = LOWER($1)`
+	err := r.DB.Get(&user, query, email)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user by email: %v", err)
+	}
+	return &user, nil
+}
+
 func (r *UserRepositorySQL) GetByID(ctx context.Context, id int) (*types.User, error) {
 	var user types.User
 	query := `SELECT id, username, email, role FROM users WHERE id = $1`
@@ -74,3 +89,112 @@ func (r *UserRepositorySQL) Update(ctx context.Context, u *types.User) error {
 	}
 	return nil
 }
+
+func (r *UserRepositorySQL) GetDriverProfile(ctx context.Context, userID int) (*types.DriverProfile, error) {
+	var profile types.DriverProfile
+	query := `SELECT user_id, date_of_birth, license_number, license_country, license_category, license_issued, license_expiry, updated FROM driver_profile WHERE user_id = $1`
+	err := r.DB.Get(&profile, query, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get driver profile: %v", err)
+	}
+	return &profile, nil
+}
+
+func (r *UserRepositorySQL) SaveDriverProfile(ctx context.Context, p *types.DriverProfile) error {
+	query := `INSERT INTO driver_profile (user_id, date_of_birth, license_number, license_country, license_category, license_issued, license_expiry)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET date_of_birth = EXCLUDED.date_of_birth, license_number = EXCLUDED.license_number,
+			license_country = EXCLUDED.license_country, license_category = EXCLUDED.license_category,
+			license_issued = EXCLUDED.license_issued, license_expiry = EXCLUDED.license_expiry, updated = CURRENT_TIMESTAMP`
+	_, err := r.DB.Exec(query, p.UserID, p.DateOfBirth, p.LicenseNumber, p.LicenseCountry, p.LicenseCategory, p.LicenseIssued, p.LicenseExpiry)
+	if err != nil {
+		return fmt.Errorf("failed to save driver profile: %v", err)
+	}
+	return nil
+}
+
+const savedSearchColumns = `id, user_id, name, query, notify, last_car_id, created`
+
+func (r *UserRepositorySQL) CreateSavedSearch(ctx context.Context, s *types.SavedSearch) error {
+	query := `INSERT INTO saved_search (user_id, name, query, notify, last_car_id) VALUES ($1, $2, $3, $4, $5) RETURNING id, created`
+	err := r.DB.QueryRowx(query, s.UserID, s.Name, s.Query, s.Notify, s.LastCarID).Scan(&s.ID, &s.Created)
+	if err != nil {
+		return fmt.Errorf("failed to create saved search: %v", err)
+	}
+	return nil
+}
+
+func (r *UserRepositorySQL) GetSavedSearches(ctx context.Context, userID int) ([]*types.SavedSearch, error) {
+	var searches []*types.SavedSearch
+	err := r.DB.Select(&searches, `SELECT `+savedSearchColumns+` FROM saved_search WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved searches: %v", err)
+	}
+	return searches, nil
+}
+
+func (r *UserRepositorySQL) GetNotifiedSavedSearches(ctx context.Context) ([]*types.SavedSearch, error) {
+	var searches []*types.SavedSearch
+	err := r.DB.Select(&searches, `SELECT `+savedSearchColumns+` FROM saved_search WHERE notify ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get saved searches: %v", err)
+	}
+	return searches, nil
+}
+
+func (r *UserRepositorySQL) UpdateSavedSearch(ctx context.Context, s *types.SavedSearch) error {
+	query := `UPDATE saved_search SET name = $1, query = $2, notify = $3, last_car_id = $4 WHERE id = $5`
+	_, err := r.DB.Exec(query, s.Name, s.Query, s.Notify, s.LastCarID, s.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update saved search: %v", err)
+	}
+	return nil
+}
+
+func (r *UserRepositorySQL) DeleteSavedSearch(ctx context.Context, id int) error {
+	_, err := r.DB.Exec(`DELETE FROM saved_search WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved search: %v", err)
+	}
+	return nil
+}
+
+func (r *UserRepositorySQL) SaveFavorite(ctx context.Context, f *types.FavoriteCar) error {
+	query := `INSERT INTO favorite_car (user_id, car_id, notify, price) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, car_id) DO UPDATE SET notify = EXCLUDED.notify, price = EXCLUDED.price
+		RETURNING created`
+	err := r.DB.QueryRowx(query, f.UserID, f.CarID, f.Notify, f.Price).Scan(&f.Created)
+	if err != nil {
+		return fmt.Errorf("failed to save favorite car: %v", err)
+	}
+	return nil
+}
+
+func (r *UserRepositorySQL) GetFavorites(ctx context.Context, userID int) ([]*types.FavoriteCar, error) {
+	var favorites []*types.FavoriteCar
+	err := r.DB.Select(&favorites, `SELECT user_id, car_id, notify, price, created FROM favorite_car WHERE user_id = $1 ORDER BY created`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite cars: %v", err)
+	}
+	return favorites, nil
+}
+
+func (r *UserRepositorySQL) GetNotifiedFavorites(ctx context.Context) ([]*types.FavoriteCar, error) {
+	var favorites []*types.FavoriteCar
+	err := r.DB.Select(&favorites, `SELECT user_id, car_id, notify, price, created FROM favorite_car WHERE notify ORDER BY car_id, user_id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get favorite cars: %v", err)
+	}
+	return favorites, nil
+}
+
+func (r *UserRepositorySQL) DeleteFavorite(ctx context.Context, userID int, carID int) error {
+	_, err := r.DB.Exec(`DELETE FROM favorite_car WHERE user_id = $1 AND car_id = $2`, userID, carID)
+	if err != nil {
+		return fmt.Errorf("failed to delete favorite car: %v", err)
+	}
+	return nil
+}
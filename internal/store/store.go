@@ -12,8 +12,27 @@ type UserStore interface {
 	Create(ctx context.Context, u *types.User) error
 	GetByID(ctx context.Context, id int) (*types.User, error)
 	GetByUsername(ctx context.Context, username string) (*types.User, error)
+	// nil without error if no user has the email
+	GetByEmail(ctx context.Context, email string) (*types.User, error)
 	Delete(ctx context.Context, id int) error
 	Update(ctx context.Context, u *types.User) error
+
+	// driver profile, nil without error if user has none
+	GetDriverProfile(ctx context.Context, userID int) (*types.DriverProfile, error)
+	SaveDriverProfile(ctx context.Context, p *types.DriverProfile) error
+
+	// saved car searches, notified ones are checked for new cars by the background job
+	CreateSavedSearch(ctx context.Context, s *types.SavedSearch) error
+	GetSavedSearches(ctx context.Context, userID int) ([]*types.SavedSearch, error)
+	GetNotifiedSavedSearches(ctx context.Context) ([]*types.SavedSearch, error)
+	UpdateSavedSearch(ctx context.Context, s *types.SavedSearch) error
+	DeleteSavedSearch(ctx context.Context, id int) error
+
+	// favourite cars, saving an existing favourite updates it
+	SaveFavorite(ctx context.Context, f *types.FavoriteCar) error
+	GetFavorites(ctx context.Context, userID int) ([]*types.FavoriteCar, error)
+	GetNotifiedFavorites(ctx context.Context) ([]*types.FavoriteCar, error)
+	DeleteFavorite(ctx context.Context, userID int, carID int) error
 }
 
 type CompanyStore interface {
@@ -22,14 +41,99 @@ type CompanyStore interface {
 	Update(ctx context.Context, c *types.Company) error
 	Delete(ctx context.Context, id int) error
 	GetBatch(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]types.Company, error)
+
+	// rules for exactly given company and car (nil for company wide), nil without error if none are set
+	GetRentalRules(ctx context.Context, companyID int, carID *int) (*types.RentalRules, error)
+	SaveRentalRules(ctx context.Context, r *types.RentalRules) error
+
+	// nil without error if the company never set it up
+	GetDynamicPricing(ctx context.Context, companyID int) (*types.DynamicPricing, error)
+	SaveDynamicPricing(ctx context.Context, p *types.DynamicPricing) error
+
+	SetRating(ctx context.Context, companyID int, rating types.Rating) error
+
+	// branches come with their opening hours and closures, saving replaces both
+	CreateBranch(ctx context.Context, b *types.Branch) error
+	GetBranch(ctx context.Context, id int) (*types.Branch, error)
+	GetBranches(ctx context.Context, companyID int) ([]*types.Branch, error)
+	UpdateBranch(ctx context.Context, b *types.Branch) error
+	DeleteBranch(ctx context.Context, id int) error
+
+	// creating the company adds its owner as the first active member
+	CreateMember(ctx context.Context, m *types.CompanyMember) error
+	GetMember(ctx context.Context, id int) (*types.CompanyMember, error)
+	GetMembers(ctx context.Context, companyID int) ([]*types.CompanyMember, error)
+	// active membership of the user, nil without error if the user doesn't work for the company
+	GetMembership(ctx context.Context, companyID int, userID int) (*types.CompanyMember, error)
+	UpdateMember(ctx context.Context, m *types.CompanyMember) error
+	DeleteMember(ctx context.Context, id int) error
+
+	// saves only the status, rejection reason and verification time of the company
+	SetVerification(ctx context.Context, c *types.Company) error
+	// pending companies that uploaded at least one document, oldest first
+	GetAwaitingVerification(ctx context.Context) ([]types.Company, error)
+	AddDocument(ctx context.Context, d *types.CompanyDocument) error
+	GetDocument(ctx context.Context, id int) (*types.CompanyDocument, error)
+	GetDocuments(ctx context.Context, companyID int) ([]*types.CompanyDocument, error)
+	DeleteDocument(ctx context.Context, id int) error
 }
 
 type CarStore interface {
 	Create(ctx context.Context, car *types.Car) error
+	// all the cars with their first prices or none of them, prices[i] belongs to cars[i]
+	// and gets its car id once the car is saved
+	CreateBatch(ctx context.Context, cars []*types.Car, prices []*types.CarPrice) error
 	GetByID(ctx context.Context, id int) (*types.Car, error)
 	Update(ctx context.Context, id int, car *types.Car) error
 	Delete(ctx context.Context, id int) error
 	GetBatch(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]types.Car, error)
+	// value counts of all cars matching the filters, unordered
+	GetFacets(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) (*types.CarFacets, error)
+	// cars of the category, cheapest first
+	GetByCategory(ctx context.Context, categoryID int) ([]types.Car, error)
+	// cars in use with any document expiring until the date, already expired ones included
+	GetExpiringDocuments(ctx context.Context, until time.Time) ([]types.Car, error)
+	SetRating(ctx context.Context, carID int, rating types.Rating) error
+	// only the status, for jobs taking cars out of service without touching the rest
+	UpdateStatus(ctx context.Context, carID int, status types.CarStatus) error
+	// only the position, so telemetry doesn't overwrite changes made to the car meanwhile
+	UpdateLocation(ctx context.Context, carID int, latitude, longitude float64) error
+
+	GetCategories(ctx context.Context) ([]types.CarCategory, error)
+	GetCategoryByID(ctx context.Context, id int) (*types.CarCategory, error)
+
+	// odometer and fuel readings and service records, newest first
+	AddReading(ctx context.Context, reading *types.CarReading) error
+	GetReadings(ctx context.Context, carID int, limit int) ([]*types.CarReading, error)
+	AddServiceRecord(ctx context.Context, record *types.ServiceRecord) error
+	GetServiceRecords(ctx context.Context, carID int) ([]*types.ServiceRecord, error)
+
+	// price changes ordered by effective date, pending ones weren't set as the car price yet
+	AddPrice(ctx context.Context, price *types.CarPrice) error
+	GetPrices(ctx context.Context, carID int) ([]*types.CarPrice, error)
+	GetPendingPrices(ctx context.Context, until time.Time) ([]*types.CarPrice, error)
+	SetPriceApplied(ctx context.Context, id int) error
+	// only the current price of the car, the rest of it is left as it is
+	UpdatePrice(ctx context.Context, carID int, price float64) error
+	DeletePrice(ctx context.Context, id int) error
+
+	// telemetry from tracking devices, last position is nil without error if the car never sent one
+	AddTelemetry(ctx context.Context, points []*types.TelemetryPoint) error
+	GetLastPosition(ctx context.Context, carID int) (*types.TelemetryPoint, error)
+	DeleteTelemetryBefore(ctx context.Context, before time.Time) (int64, error)
+
+	// gallery, images are ordered by position
+	AddImage(ctx context.Context, image *types.CarImage) error
+	GetImages(ctx context.Context, carID int) ([]*types.CarImage, error)
+	UpdateImage(ctx context.Context, image *types.CarImage) error
+	DeleteImage(ctx context.Context, id int) error
+
+	// transfers to other companies and branches oldest first, completing one moves the car with it
+	// and saves the new branch of the bookings going along, new transfers are saved by it too
+	CreateTransfer(ctx context.Context, transfer *types.CarTransfer) error
+	GetTransfers(ctx context.Context, carID int) ([]*types.CarTransfer, error)
+	UpdateTransfer(ctx context.Context, transfer *types.CarTransfer) error
+	CompleteTransfer(ctx context.Context, transfer *types.CarTransfer, bookings []*types.Booking) error
 }
 
 type BookingStore interface {
@@ -37,6 +141,48 @@ type BookingStore interface {
 	GetByID(ctx context.Context, id int) (*types.Booking, error)
 	Update(ctx context.Context, booking *types.Booking) error
 	Delete(ctx context.Context, id int) error
+	// bookings rented by the user or where he is an additional driver
 	GetByUserID(ctx context.Context, userID int) ([]*types.Booking, error)
+	// false when the range overlaps an active booking or maintenance of the car
 	CheckDateAvailability(ctx context.Context, carID int, startDate, endDate time.Time) bool
+	// all bookings of the car including cancelled ones
+	CountByCarID(ctx context.Context, carID int) (int, error)
+	// active bookings of the car overlapping the range
+	GetOverlapping(ctx context.Context, carID int, startDate, endDate time.Time) ([]*types.Booking, error)
+	// the same for several cars at once, e.g. the fleet of a company
+	GetOverlappingCars(ctx context.Context, carIDs []int, startDate, endDate time.Time) ([]*types.Booking, error)
+	// confirmed bookings whose car wasn't picked up within the no-show grace period by given time,
+	// rules of the car override the ones of its company, without grace period there are no no-shows
+	GetNoShowsDue(ctx context.Context, now time.Time) ([]*types.NoShowDue, error)
+
+	// recurring bookings, series are saved together with their new occurrences,
+	// updates cancel the occurrences that no longer match in the same transaction
+	CreateSeries(ctx context.Context, series *types.BookingSeries, bookings []*types.Booking) error
+	GetSeriesByID(ctx context.Context, id int) (*types.BookingSeries, error)
+	UpdateSeries(ctx context.Context, series *types.BookingSeries, added []*types.Booking, cancelled []*types.Booking) error
+	GetBySeriesID(ctx context.Context, seriesID int) ([]*types.Booking, error)
+
+	// additional drivers
+	AddDriver(ctx context.Context, driver *types.BookingDriver) error
+	GetDrivers(ctx context.Context, bookingID int) ([]*types.BookingDriver, error)
+	UpdateDriver(ctx context.Context, driver *types.BookingDriver) error
+	DeleteDriver(ctx context.Context, id int) error
+
+	// handing bookings over to other users
+	CreateTransfer(ctx context.Context, transfer *types.BookingTransfer) error
+	GetTransfers(ctx context.Context, bookingID int) ([]*types.BookingTransfer, error)
+	UpdateTransfer(ctx context.Context, transfer *types.BookingTransfer) error
+
+	// maintenance windows block the car like bookings do
+	CreateMaintenance(ctx context.Context, m *types.Maintenance) error
+	GetMaintenanceByID(ctx context.Context, id int) (*types.Maintenance, error)
+	GetMaintenance(ctx context.Context, carID int) ([]*types.Maintenance, error)
+	DeleteMaintenance(ctx context.Context, id int) error
+
+	// reviews of finished bookings, review of the booking is nil without error if there is none
+	CreateReview(ctx context.Context, review *types.Review) error
+	GetReview(ctx context.Context, id int) (*types.Review, error)
+	GetReviewByBooking(ctx context.Context, bookingID int) (*types.Review, error)
+	GetReviews(ctx context.Context, filters []*types.QueryFilter, opts *types.QueryOptions) ([]*types.Review, error)
+	UpdateReview(ctx context.Context, review *types.Review) error
 }
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"encoding/json"
+	"slices"
+	"time"
+)
+
 type UserRole int
 
 const (
@@ -8,3 +14,159 @@ const (
 	UserTypeCompanyOwner
 	UserTypeUser
 )
+
+type BookingStatus int
+
+const (
+	BookingStatusConfirmed BookingStatus = iota
+	BookingStatusCancelled
+	BookingStatusPickedUp
+	BookingStatusNoShow // car wasn't picked up within the grace period
+)
+
+type BookingDriverStatus int
+
+const (
+	BookingDriverInvited BookingDriverStatus = iota
+	BookingDriverAccepted
+)
+
+type BookingTransferStatus int
+
+const (
+	BookingTransferPending BookingTransferStatus = iota
+	BookingTransferAccepted
+	BookingTransferDeclined
+)
+
+type CarTransferStatus int
+
+const (
+	CarTransferPending CarTransferStatus = iota
+	CarTransferAccepted
+	CarTransferDeclined
+)
+
+// only available cars can be booked, retired ones are kept for booking history
+type CarStatus string
+
+const (
+	CarStatusAvailable CarStatus = "available"
+	CarStatusInService CarStatus = "in_service"
+	CarStatusRetired   CarStatus = "retired"
+)
+
+// where the odometer and fuel reading came from
+type ReadingSource string
+
+const (
+	ReadingCheckOut  ReadingSource = "check_out" // car handed over to the renter
+	ReadingCheckIn   ReadingSource = "check_in"  // car returned
+	ReadingManual    ReadingSource = "manual"
+	ReadingTelemetry ReadingSource = "telemetry"
+)
+
+// reported reviews stay visible until an admin publishes them again or hides them
+type ReviewStatus string
+
+const (
+	ReviewStatusPublished ReviewStatus = "published"
+	ReviewStatusReported  ReviewStatus = "reported"
+	ReviewStatusHidden    ReviewStatus = "hidden"
+)
+
+// new companies are reviewed by admins before their cars are listed and can be booked,
+// rejected companies go back to pending when they upload another document
+type CompanyStatus string
+
+const (
+	CompanyStatusPending  CompanyStatus = "pending"
+	CompanyStatusVerified CompanyStatus = "verified"
+	CompanyStatusRejected CompanyStatus = "rejected"
+)
+
+// people working for the company, the owner who created it is its first member
+type CompanyRole string
+
+const (
+	CompanyRoleOwner    CompanyRole = "owner"
+	CompanyRoleManager  CompanyRole = "manager"
+	CompanyRoleAgent    CompanyRole = "agent"    // handles bookings and renters
+	CompanyRoleMechanic CompanyRole = "mechanic" // looks after the fleet condition
+)
+
+// invited members join after they register and accept the invitation
+type CompanyMemberStatus string
+
+const (
+	CompanyMemberInvited CompanyMemberStatus = "invited"
+	CompanyMemberActive  CompanyMemberStatus = "active"
+)
+
+type CompanyPermission int
+
+const (
+	CompanyPermOwner       CompanyPermission = iota // deleting the company, managing owners and managers
+	CompanyPermManage                               // company details, rental rules, pricing and branches
+	CompanyPermMembers                              // inviting and removing members
+	CompanyPermFleet                                // cars, their prices, images, imports and transfers
+	CompanyPermBookings                             // bookings of company cars and replies to reviews
+	CompanyPermMaintenance                          // maintenance, car status, readings and service records
+)
+
+var companyRolePermissions = map[CompanyRole][]CompanyPermission{
+	CompanyRoleOwner:    {CompanyPermOwner, CompanyPermManage, CompanyPermMembers, CompanyPermFleet, CompanyPermBookings, CompanyPermMaintenance},
+	CompanyRoleManager:  {CompanyPermManage, CompanyPermMembers, CompanyPermFleet, CompanyPermBookings, CompanyPermMaintenance},
+	CompanyRoleAgent:    {CompanyPermBookings},
+	CompanyRoleMechanic: {CompanyPermMaintenance},
+}
+
+func (r CompanyRole) Can(p CompanyPermission) bool {
+	return slices.Contains(companyRolePermissions[r], p)
+}
+
+type MaintenanceType string
+
+const (
+	MaintenanceService    MaintenanceType = "service"
+	MaintenanceTyreChange MaintenanceType = "tyre_change"
+	MaintenanceInspection MaintenanceType = "inspection"
+)
+
+// set of weekdays stored as bits, bit 0 is sunday (same as time.Weekday)
+type WeekdayMask int
+
+func NewWeekdayMask(days []int) WeekdayMask {
+	var m WeekdayMask
+	for _, d := range days {
+		m |= 1 << d
+	}
+	return m
+}
+
+func (m WeekdayMask) Has(day time.Weekday) bool {
+	return m&(1<<int(day)) != 0
+}
+
+func (m WeekdayMask) Days() []int {
+	days := make([]int, 0, 7)
+	for d := 0; d < 7; d++ {
+		if m.Has(time.Weekday(d)) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+func (m WeekdayMask) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Days())
+}
+
+func (m *WeekdayMask) UnmarshalJSON(data []byte) error {
+	var days []int
+	if err := json.Unmarshal(data, &days); err != nil {
+		return err
+	}
+	*m = NewWeekdayMask(days)
+	return nil
+}
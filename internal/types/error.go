@@ -71,3 +71,7 @@ func InternalServerError(msg string) ApiError {
 func NotFound(msg string) ApiError {
 	return newApiError(http.StatusNotFound, fmt.Errorf("not found: %s", msg))
 }
+
+func Conflict(msg any) ApiError {
+	return newApiError(http.StatusConflict, fmt.Errorf("conflict: %s", msg))
+}
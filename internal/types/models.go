@@ -1,6 +1,7 @@
 package types
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -13,15 +14,110 @@ type User struct {
 	Created  time.Time `db:"created" json:"-"`
 }
 
+// driving license details of the user, required by companies with rental rules
+type DriverProfile struct {
+	UserID          int       `json:"user_id" db:"user_id"`
+	DateOfBirth     time.Time `json:"date_of_birth" db:"date_of_birth"`
+	LicenseNumber   string    `json:"license_number" db:"license_number"`
+	LicenseCountry  string    `json:"license_country" db:"license_country"`   // ISO 3166 alpha-2 code of issuing country
+	LicenseCategory string    `json:"license_category" db:"license_category"` // comma separated categories, e.g. "B,C1"
+	LicenseIssued   time.Time `json:"license_issued" db:"license_issued"`
+	LicenseExpiry   time.Time `json:"license_expiry" db:"license_expiry"`
+	Updated         time.Time `json:"updated_at" db:"updated"`
+}
+
+// filters of GET /car/batch saved by the user, with notify on the user is emailed about new matching cars
+type SavedSearch struct {
+	ID        int       `json:"id" db:"id"`
+	UserID    int       `json:"user_id" db:"user_id"`
+	Name      string    `json:"name" db:"name"`
+	Query     string    `json:"query" db:"query"` // e.g. make=Toyota&price_per_day[lte]=150
+	Notify    bool      `json:"notify" db:"notify"`
+	LastCarID int       `json:"-" db:"last_car_id"` // newest matching car the user already knows about
+	Created   time.Time `json:"created_at" db:"created"`
+}
+
+// car marked by the user, with notify on the user is emailed when its price drops
+type FavoriteCar struct {
+	UserID  int       `json:"user_id" db:"user_id"`
+	CarID   int       `json:"car_id" db:"car_id"`
+	Notify  bool      `json:"notify" db:"notify"`
+	Price   float64   `json:"-" db:"price"` // price the user last saw
+	Created time.Time `json:"created_at" db:"created"`
+	Car     *Car      `json:"car,omitempty" db:"-"`
+}
+
 type Company struct {
 	ID      int       `json:"id" db:"id"`             // Unique ID for the company
-	OwnerID int       `json:"owner_id" db:"owner_id"` // ID of the user who owns the company
+	OwnerID int       `json:"owner_id" db:"owner_id"` // ID of the user who created the company, its first owner member
 	Name    string    `json:"name" db:"name"`         // Company name
 	Email   string    `json:"email" db:"email"`       // Contact email
 	Phone   string    `json:"phone" db:"phone"`       // Contact phone number
 	Address string    `json:"address" db:"address"`   // Address of the company
 	Created time.Time `json:"created_at" db:"created_at"`
 	Updated time.Time `json:"updated_at" db:"updated_at"` // Last updated timestamp
+
+	Latitude  *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64 `json:"longitude,omitempty" db:"longitude"`
+	Distance  *float64 `json:"distance_km,omitempty" db:"distance_km"` // set when searching near a point
+
+	Rating
+
+	Status          CompanyStatus `json:"status" db:"status"`                               // only cars of verified companies are listed and can be booked
+	RejectionReason *string       `json:"rejection_reason,omitempty" db:"rejection_reason"` // set when rejected by an admin
+	Verified        *time.Time    `json:"verified_at,omitempty" db:"verified_at"`
+
+	Branches []*Branch `json:"branches,omitempty" db:"-"` // set when getting a single company
+}
+
+// business document uploaded for the verification, visible only to the company staff and admins
+type CompanyDocument struct {
+	ID          int       `json:"id" db:"id"`
+	CompanyID   int       `json:"company_id" db:"company_id"`
+	Name        string    `json:"name" db:"name"` // what the document is, e.g. business registration
+	Key         string    `json:"-" db:"key"`
+	URL         string    `json:"url" db:"-"` // api path the document is downloaded from, it's never served publicly
+	ContentType string    `json:"content_type" db:"content_type"`
+	Size        int64     `json:"size" db:"size"`
+	Created     time.Time `json:"created_at" db:"created"`
+}
+
+// place of the company where cars are picked up and returned
+type Branch struct {
+	ID        int      `json:"id" db:"id"`
+	CompanyID int      `json:"company_id" db:"company_id"`
+	Name      string   `json:"name" db:"name"`
+	Address   string   `json:"address" db:"address"`
+	Latitude  *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64 `json:"longitude,omitempty" db:"longitude"`
+	TimeZone  string   `json:"time_zone" db:"time_zone"` // IANA name, e.g. Europe/Warsaw, opening hours are in local time
+	// charged for every pickup or return outside opening hours,
+	// nil when cars can be picked up and returned only while the branch is open
+	OutOfHoursFee *float64 `json:"out_of_hours_fee,omitempty" db:"out_of_hours_fee"`
+
+	OpeningHours []*OpeningHours  `json:"opening_hours" db:"-"` // days without hours are closed
+	Closures     []*BranchClosure `json:"closures" db:"-"`
+}
+
+// one opening interval of the day, days can have more of them, e.g. with a lunch break
+type OpeningHours struct {
+	BranchID int          `json:"-" db:"branch_id"`
+	Weekday  time.Weekday `json:"weekday" db:"weekday"` // 0 is sunday
+	Opens    string       `json:"opens" db:"opens"`     // 15:04
+	Closes   string       `json:"closes" db:"closes"`
+}
+
+// whole day the branch is closed, e.g. a public holiday, out of hours service isn't available either
+type BranchClosure struct {
+	BranchID int       `json:"-" db:"branch_id"`
+	Date     time.Time `json:"date" db:"date"`
+	Reason   string    `json:"reason,omitempty" db:"reason"`
+}
+
+// average of visible reviews, 0 without any, kept up to date when reviews change
+type Rating struct {
+	Rating      float64 `json:"rating" db:"rating"`
+	RatingCount int     `json:"rating_count" db:"rating_count"`
 }
 
 type Car struct {
@@ -32,16 +128,396 @@ type Car struct {
 	Year           int       `json:"year" db:"year"`             // Year of manufacture
 	Color          string    `json:"color" db:"color"`
 	RegistrationNo string    `json:"registration_no" db:"registration_no"` // Car registration number
+	VIN            *string   `json:"vin,omitempty" db:"vin"`
 	PricePerDay    float64   `json:"price_per_day" db:"price_per_day"`
+	CategoryID     *int      `json:"category_id,omitempty" db:"category_id"` // fleet class, e.g. economy or van
+	BranchID       *int      `json:"branch_id,omitempty" db:"branch_id"`     // where the car is kept, changed only by transfers
+	Status         CarStatus `json:"status" db:"status"`
 	Created        time.Time `json:"created_at" db:"created_at"`
 	Updated        time.Time `json:"updated_at" db:"updated_at"` // Last updated timestamp
+
+	// service is due after whichever comes first, 0 means the default interval
+	ServiceIntervalKm     int `json:"service_interval_km" db:"service_interval_km"`
+	ServiceIntervalMonths int `json:"service_interval_months" db:"service_interval_months"`
+
+	// where the car is parked, kept up to date by telemetry
+	Latitude  *float64 `json:"latitude,omitempty" db:"latitude"`
+	Longitude *float64 `json:"longitude,omitempty" db:"longitude"`
+	Distance  *float64 `json:"distance_km,omitempty" db:"distance_km"` // set when searching near a point
+	Relevance *float64 `json:"relevance,omitempty" db:"relevance"`     // set when searching by text
+
+	CarFeatures  `json:"features"`
+	CarDocuments `json:"documents"`
+	Rating
+
+	Position *TelemetryPoint `json:"position,omitempty" db:"-"` // last known position from telemetry
+}
+
+// expiry dates of documents the car can't be rented out without, nil when not tracked,
+// documents are valid through the expiry day
+type CarDocuments struct {
+	RegistrationExpiry   *time.Time `json:"registration_expiry,omitempty" db:"registration_expiry"`
+	InsuranceExpiry      *time.Time `json:"insurance_expiry,omitempty" db:"insurance_expiry"`
+	RoadworthinessExpiry *time.Time `json:"roadworthiness_expiry,omitempty" db:"roadworthiness_expiry"` // periodic technical inspection
+}
+
+// the document expiring first and its name, nil if none is tracked
+func (d CarDocuments) FirstExpiry() (*time.Time, string) {
+	var first *time.Time
+	var name string
+	for _, doc := range []struct {
+		name    string
+		expires *time.Time
+	}{
+		{"registration", d.RegistrationExpiry},
+		{"insurance", d.InsuranceExpiry},
+		{"roadworthiness certificate", d.RoadworthinessExpiry},
+	} {
+		if doc.expires != nil && (first == nil || doc.expires.Before(*first)) {
+			first, name = doc.expires, doc.name
+		}
+	}
+	return first, name
+}
+
+// equipment of the car, stored as car columns so cars can be filtered by it
+type CarFeatures struct {
+	Transmission    string `json:"transmission" db:"transmission"` // manual or automatic
+	FuelType        string `json:"fuel_type" db:"fuel_type"`       // petrol, diesel, electric, hybrid or lpg
+	Seats           int    `json:"seats" db:"seats"`
+	Doors           int    `json:"doors" db:"doors"`
+	AirConditioning bool   `json:"air_conditioning" db:"air_conditioning"`
+	Navigation      bool   `json:"navigation" db:"navigation"`
+	ElectricRange   int    `json:"electric_range" db:"electric_range"` // in km, 0 for cars without electric drive
+	Towbar          bool   `json:"towbar" db:"towbar"`
+}
+
+// picture of the car, files are kept in the storage under the keys
+type CarImage struct {
+	ID           int       `json:"id" db:"id"`
+	CarID        int       `json:"car_id" db:"car_id"`
+	Key          string    `json:"-" db:"key"`
+	ThumbnailKey string    `json:"-" db:"thumbnail_key"`
+	URL          string    `json:"url" db:"-"`
+	ThumbnailURL string    `json:"thumbnail_url" db:"-"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	Size         int64     `json:"size" db:"size"`
+	Position     int       `json:"position" db:"position"` // order in the gallery, starting from 1
+	Primary      bool      `json:"primary" db:"is_primary"`
+	Created      time.Time `json:"created_at" db:"created"`
+}
+
+// fleet class, attributes are typical for the class, not guaranteed for every car in it
+type CarCategory struct {
+	ID           int    `json:"id" db:"id"`
+	Name         string `json:"name" db:"name"`
+	Seats        int    `json:"seats" db:"seats"`
+	Doors        int    `json:"doors" db:"doors"`
+	Transmission string `json:"transmission" db:"transmission"` // manual or automatic
+	FuelType     string `json:"fuel_type" db:"fuel_type"`
+	Luggage      int    `json:"luggage" db:"luggage"` // number of large suitcases
+}
+
+// driver requirements of the company, rules with car id override company wide ones for that car
+type RentalRules struct {
+	ID                   int     `json:"id" db:"id"`
+	CompanyID            int     `json:"company_id" db:"company_id"`
+	CarID                *int    `json:"car_id,omitempty" db:"car_id"`
+	MinAge               int     `json:"min_age" db:"min_age"`
+	MinYearsLicensed     int     `json:"min_years_licensed" db:"min_years_licensed"`
+	YoungDriverAge       int     `json:"young_driver_age" db:"young_driver_age"`             // drivers younger than that pay the surcharge
+	YoungDriverSurcharge float64 `json:"young_driver_surcharge" db:"young_driver_surcharge"` // per day
+	LicenseCategory      string  `json:"license_category" db:"license_category"`             // required license category, empty if any
+	AdditionalDriverFee  float64 `json:"additional_driver_fee" db:"additional_driver_fee"`   // per day for every additional driver
+	NoShowGraceHours     int     `json:"no_show_grace_hours" db:"no_show_grace_hours"`       // hours after start of the booking to pick up the car, 0 turns no-shows off
+	NoShowFee            float64 `json:"no_show_fee" db:"no_show_fee"`                       // charged instead of the total, 0 keeps the total
+}
+
+// company wide daily price adjustment, the base price is multiplied by
+// 1 + utilisation factor * (utilisation - target) + lead time factor, kept between floor and ceiling
+type DynamicPricing struct {
+	CompanyID          int     `json:"company_id" db:"company_id"`
+	Enabled            bool    `json:"enabled" db:"enabled"`                           // used for bookings, simulation works without it
+	TargetUtilisation  float64 `json:"target_utilisation" db:"target_utilisation"`     // share of the fleet booked at which the price is unchanged
+	UtilisationFactor  float64 `json:"utilisation_factor" db:"utilisation_factor"`     // price change when the whole fleet is booked above the target
+	LastMinuteDays     int     `json:"last_minute_days" db:"last_minute_days"`         // days before the rental counted as last minute, 0 to disable
+	LastMinuteFactor   float64 `json:"last_minute_factor" db:"last_minute_factor"`     // e.g. 0.1 for 10% more
+	EarlyBookingDays   int     `json:"early_booking_days" db:"early_booking_days"`     // days before the rental counted as early, 0 to disable
+	EarlyBookingFactor float64 `json:"early_booking_factor" db:"early_booking_factor"` // e.g. -0.05 for 5% less
+	Floor              float64 `json:"floor" db:"floor"`                               // lowest multiplier of the base price
+	Ceiling            float64 `json:"ceiling" db:"ceiling"`                           // highest multiplier of the base price
+}
+
+// price of the car on a day and what it was made of
+type DailyPrice struct {
+	Date        time.Time `json:"date"`
+	BasePrice   float64   `json:"base_price"`
+	Utilisation float64   `json:"utilisation"` // share of the company fleet booked on the day
+	Multiplier  float64   `json:"multiplier"`
+	Price       float64   `json:"price"`
 }
 
 type Booking struct {
-	ID        int       `json:"id" db:"id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	CarID     int       `json:"car_id" db:"car_id"`
-	StartDate time.Time `json:"start_date" db:"start_date"`
-	EndDate   time.Time `json:"end_date" db:"end_date"`
-	Total     float64   `json:"total" db:"total"`
+	ID        int           `json:"id" db:"id"`
+	UserID    int           `json:"user_id" db:"user_id"`
+	CarID     int           `json:"car_id" db:"car_id"`
+	StartDate time.Time     `json:"start_date" db:"start_date"`
+	EndDate   time.Time     `json:"end_date" db:"end_date"`
+	Total     float64       `json:"total" db:"total"`
+	Status    BookingStatus `json:"status" db:"status"`
+	SeriesID  *int          `json:"series_id,omitempty" db:"series_id"` // set when booking is an occurrence of a series
+	PickedUp  *time.Time    `json:"picked_up,omitempty" db:"picked_up"`
+	CreatedBy *int          `json:"created_by,omitempty" db:"created_by"` // original renter, stays the same after transfers
+	// set when user booked any car of the category, company can swap the car for another one
+	// of the same category until pickup
+	CategoryID *int `json:"category_id,omitempty" db:"category_id"`
+	// branch where the car is picked up and returned, times are in its local time
+	BranchID   *int    `json:"branch_id,omitempty" db:"branch_id"`
+	PickupTime *string `json:"pickup_time,omitempty" db:"pickup_time"`
+	ReturnTime *string `json:"return_time,omitempty" db:"return_time"`
+
+	Drivers   []*BookingDriver   `json:"drivers,omitempty" db:"-"`   // additional drivers
+	Transfers []*BookingTransfer `json:"transfers,omitempty" db:"-"` // history of handing the booking over
+}
+
+// booking past its no-show deadline with the rules of its car, only bookings of companies
+// with a grace period get here
+type NoShowDue struct {
+	Booking
+	NoShowGraceHours int     `db:"no_show_grace_hours"`
+	NoShowFee        float64 `db:"no_show_fee"` // charged instead of the total, 0 keeps the total
+}
+
+// the car is expected to be picked up on the first day of the booking
+func (d *NoShowDue) Deadline() time.Time {
+	return d.StartDate.Add(time.Duration(d.NoShowGraceHours) * time.Hour)
+}
+
+// state of the car sent by its tracking device, fields the device doesn't report are nil
+type TelemetryPoint struct {
+	ID           int       `json:"-" db:"id"`
+	CarID        int       `json:"car_id" db:"car_id"`
+	Recorded     time.Time `json:"recorded_at" db:"recorded"`
+	Latitude     *float64  `json:"latitude,omitempty" db:"latitude"`
+	Longitude    *float64  `json:"longitude,omitempty" db:"longitude"`
+	Odometer     *int      `json:"odometer,omitempty" db:"odometer"`
+	FuelLevel    *int      `json:"fuel_level,omitempty" db:"fuel_level"`
+	BatteryLevel *int      `json:"battery_level,omitempty" db:"battery_level"`
+	Ignition     *bool     `json:"ignition,omitempty" db:"ignition"`
+}
+
+type TelemetryResult struct {
+	Accepted int `json:"accepted"`
+	Skipped  int `json:"skipped"` // older than the retention period
+}
+
+// odometer in km and fuel or battery level in percent at the given time
+type CarReading struct {
+	ID        int           `json:"id" db:"id"`
+	CarID     int           `json:"car_id" db:"car_id"`
+	BookingID *int          `json:"booking_id,omitempty" db:"booking_id"` // set for check-in and check-out
+	Odometer  int           `json:"odometer" db:"odometer"`
+	FuelLevel *int          `json:"fuel_level,omitempty" db:"fuel_level"`
+	Source    ReadingSource `json:"source" db:"source"`
+	Recorded  time.Time     `json:"recorded_at" db:"recorded"`
+}
+
+type ServiceRecord struct {
+	ID          int       `json:"id" db:"id"`
+	CarID       int       `json:"car_id" db:"car_id"`
+	Description string    `json:"description" db:"description"`
+	Cost        float64   `json:"cost" db:"cost"`
+	Odometer    int       `json:"odometer" db:"odometer"`
+	Performed   time.Time `json:"performed_at" db:"performed"`
+	Created     time.Time `json:"created_at" db:"created"`
+}
+
+// when the next service is due, counted from the last service or from when the car was added
+type ServiceStatus struct {
+	CarID           int       `json:"car_id"`
+	Odometer        int       `json:"odometer"`
+	NextServiceKm   int       `json:"next_service_km"`
+	NextServiceDate time.Time `json:"next_service_date"`
+	KmLeft          int       `json:"km_left"`
+	DaysLeft        int       `json:"days_left"`
+	Due             bool      `json:"due"` // close to or past the interval
+}
+
+type CarHistory struct {
+	Readings []*CarReading    `json:"readings"` // newest first
+	Services []*ServiceRecord `json:"services"` // newest first
+	Service  *ServiceStatus   `json:"service"`
+}
+
+// price of the car from the day on until the next change, future ones are scheduled
+type CarPrice struct {
+	ID            int       `json:"id" db:"id"`
+	CarID         int       `json:"car_id" db:"car_id"`
+	PricePerDay   float64   `json:"price_per_day" db:"price_per_day"`
+	EffectiveFrom time.Time `json:"effective_from" db:"effective_from"`
+	Applied       bool      `json:"applied" db:"applied"` // car price was set to it
+	Created       time.Time `json:"created_at" db:"created"`
+}
+
+// how many of the listed cars have each value, for filter sidebars
+type CarFacets struct {
+	Make         []FacetCount `json:"make"`
+	Category     []FacetCount `json:"category"`
+	Transmission []FacetCount `json:"transmission"`
+	Price        []FacetCount `json:"price"` // buckets of price per day, cheapest first
+	Company      []FacetCount `json:"company"`
+}
+
+type FacetCount struct {
+	Value string `json:"value" db:"value"`
+	Count int    `json:"count" db:"count"`
+}
+
+// car list with facets, returned when asked for facets
+type CarBatch struct {
+	Cars   []Car      `json:"cars"`
+	Facets *CarFacets `json:"facets"`
+}
+
+// report of the fleet import, nothing is created when there are errors
+type CarImportResult struct {
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	DryRun  bool             `json:"dry_run"`
+	Errors  []CarImportError `json:"errors"`
+}
+
+type CarImportError struct {
+	Line   int               `json:"line"` // line in the file, header is line 1
+	Errors map[string]string `json:"errors"`
+}
+
+// upper bounds of price per day buckets, the last bucket is open
+var PriceBuckets = []float64{50, 100, 200, 500}
+
+// 0-50, 50-100 ... 500+
+func PriceBucket(price float64) string {
+	labels := PriceBucketLabels()
+	for i, upper := range PriceBuckets {
+		if price < upper {
+			return labels[i]
+		}
+	}
+	return labels[len(labels)-1]
+}
+
+// labels of all the buckets, cheapest first
+func PriceBucketLabels() []string {
+	labels := make([]string, 0, len(PriceBuckets)+1)
+	lower := 0.0
+	for _, upper := range PriceBuckets {
+		labels = append(labels, fmt.Sprintf("%g-%g", lower, upper))
+		lower = upper
+	}
+	return append(labels, fmt.Sprintf("%g+", lower))
+}
+
+// period when the car is in the workshop, it can't be booked in that time
+type Maintenance struct {
+	ID        int             `json:"id" db:"id"`
+	CarID     int             `json:"car_id" db:"car_id"`
+	Type      MaintenanceType `json:"type" db:"type"`
+	StartDate time.Time       `json:"start_date" db:"start_date"`
+	EndDate   time.Time       `json:"end_date" db:"end_date"`
+	Note      string          `json:"note" db:"note"`
+	Created   time.Time       `json:"created_at" db:"created"`
+}
+
+// scheduled maintenance with bookings it overlaps, they have to be moved to other cars
+type ScheduledMaintenance struct {
+	Maintenance *Maintenance `json:"maintenance"`
+	Conflicts   []*Booking   `json:"conflicting_bookings"`
+}
+
+// handing the booking over to another user, takes effect after he accepts it
+type BookingTransfer struct {
+	ID         int                   `json:"id" db:"id"`
+	BookingID  int                   `json:"booking_id" db:"booking_id"`
+	FromUserID int                   `json:"from_user_id" db:"from_user_id"`
+	ToUserID   int                   `json:"to_user_id" db:"to_user_id"`
+	Status     BookingTransferStatus `json:"status" db:"status"`
+	Created    time.Time             `json:"created_at" db:"created"`
+	Resolved   *time.Time            `json:"resolved_at,omitempty" db:"resolved"`
+}
+
+// moving the car to another company, it's requested by the current owner and
+// the car changes company after the owner of the other one accepts it
+type CarTransfer struct {
+	ID               int               `json:"id" db:"id"`
+	CarID            int               `json:"car_id" db:"car_id"`
+	FromCompanyID    int               `json:"from_company_id" db:"from_company_id"`
+	ToCompanyID      int               `json:"to_company_id" db:"to_company_id"` // same as from_company_id for moves between branches
+	FromBranchID     *int              `json:"from_branch_id,omitempty" db:"from_branch_id"`
+	ToBranchID       *int              `json:"to_branch_id,omitempty" db:"to_branch_id"`
+	Status           CarTransferStatus `json:"status" db:"status"`
+	MigrateBookings  bool              `json:"migrate_bookings" db:"migrate_bookings"`   // future bookings go with the car
+	MigratedBookings int               `json:"migrated_bookings" db:"migrated_bookings"` // how many of them there were when accepted
+	RequestedBy      int               `json:"requested_by" db:"requested_by"`
+	ResolvedBy       *int              `json:"resolved_by,omitempty" db:"resolved_by"`
+	Created          time.Time         `json:"created_at" db:"created"`
+	Resolved         *time.Time        `json:"resolved_at,omitempty" db:"resolved"`
+}
+
+// renter's review of a finished booking, one per booking
+type Review struct {
+	ID            int          `json:"id" db:"id"`
+	BookingID     int          `json:"booking_id" db:"booking_id"`
+	UserID        int          `json:"user_id" db:"user_id"`
+	CarID         int          `json:"car_id" db:"car_id"`
+	CompanyID     int          `json:"company_id" db:"company_id"`
+	CarRating     int          `json:"car_rating" db:"car_rating"` // 1-5
+	CompanyRating int          `json:"company_rating" db:"company_rating"`
+	Comment       string       `json:"comment" db:"comment"`
+	Reply         *string      `json:"reply,omitempty" db:"reply"` // company's answer
+	Replied       *time.Time   `json:"replied_at,omitempty" db:"replied"`
+	Status        ReviewStatus `json:"status" db:"status"`
+	ReportReason  *string      `json:"report_reason,omitempty" db:"report_reason"`
+	Created       time.Time    `json:"created_at" db:"created"`
+}
+
+// member of the company staff, invitations are matched by email and get the user id once accepted
+type CompanyMember struct {
+	ID        int                 `json:"id" db:"id"`
+	CompanyID int                 `json:"company_id" db:"company_id"`
+	UserID    *int                `json:"user_id,omitempty" db:"user_id"`
+	Email     string              `json:"email" db:"email"`
+	Role      CompanyRole         `json:"role" db:"role"`
+	Status    CompanyMemberStatus `json:"status" db:"status"`
+	InvitedBy *int                `json:"invited_by,omitempty" db:"invited_by"` // nil for the owner who created the company
+	Created   time.Time           `json:"created_at" db:"created"`
+	Joined    *time.Time          `json:"joined_at,omitempty" db:"joined"`
+}
+
+// additional driver of the booking, people without an account are invited by email
+// and become drivers after they register and accept the invitation
+type BookingDriver struct {
+	ID        int                 `json:"id" db:"id"`
+	BookingID int                 `json:"booking_id" db:"booking_id"`
+	UserID    *int                `json:"user_id,omitempty" db:"user_id"`
+	Email     string              `json:"email" db:"email"`
+	Status    BookingDriverStatus `json:"status" db:"status"`
+	Fee       float64             `json:"fee" db:"fee"` // for the whole rental
+	Created   time.Time           `json:"created_at" db:"created"`
+}
+
+// recurring booking, expanded into single day bookings on selected weekdays
+type BookingSeries struct {
+	ID        int         `json:"id" db:"id"`
+	UserID    int         `json:"user_id" db:"user_id"`
+	CarID     int         `json:"car_id" db:"car_id"`
+	StartDate time.Time   `json:"start_date" db:"start_date"` // first day of the series
+	EndDate   time.Time   `json:"end_date" db:"end_date"`     // last day of the series
+	Weekdays  WeekdayMask `json:"weekdays" db:"weekdays"`
+	Created   time.Time   `json:"created_at" db:"created"`
+}
+
+type BookingSeriesResult struct {
+	Series    *BookingSeries `json:"series"`
+	Bookings  []*Booking     `json:"bookings"`
+	Conflicts []string       `json:"conflicts"` // dates that couldn't be booked
 }
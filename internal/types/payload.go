@@ -19,11 +19,33 @@ type UpdateUserPayload struct {
 	Email    string `json:"email" validate:"omitempty"`
 }
 
+type UpdateDriverProfilePayload struct {
+	DateOfBirth     string `json:"date_of_birth" validate:"required,datetime=2006-01-02"`
+	LicenseNumber   string `json:"license_number" validate:"required,max=50"`
+	LicenseCountry  string `json:"license_country" validate:"required,iso3166_1_alpha2"`
+	LicenseCategory string `json:"license_category" validate:"required,max=20"`
+	LicenseIssued   string `json:"license_issued" validate:"required,datetime=2006-01-02"`
+	LicenseExpiry   string `json:"license_expiry" validate:"required,datetime=2006-01-02"`
+}
+
+type SavedSearchPayload struct {
+	Name   string `json:"name" validate:"required,max=100"`
+	Query  string `json:"query" validate:"required,max=1000"` // query string of GET /car/batch without the leading ?
+	Notify bool   `json:"notify"`
+}
+
+type FavoriteCarPayload struct {
+	Notify bool `json:"notify"`
+}
+
 type CreateCompanyPayload struct {
 	Name    string `json:"name" validate:"required"`
 	Email   string `json:"email" validate:"required"`
 	Phone   string `json:"phone" validate:"required"`
 	Address string `json:"address" validate:"required"`
+
+	Latitude  *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
 }
 
 type UpdateCompanyPayload struct {
@@ -31,6 +53,62 @@ type UpdateCompanyPayload struct {
 	Email   string `json:"email" validate:"omitempty"`
 	Phone   string `json:"phone" validate:"omitempty"`
 	Address string `json:"address" validate:"omitempty"`
+
+	Latitude  *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+}
+
+type BranchPayload struct {
+	Name          string                  `json:"name" validate:"required,max=100"`
+	Address       string                  `json:"address" validate:"required"`
+	Latitude      *float64                `json:"latitude" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude     *float64                `json:"longitude" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	TimeZone      string                  `json:"time_zone" validate:"required,timezone"`
+	OutOfHoursFee *float64                `json:"out_of_hours_fee" validate:"omitempty,gte=0"`
+	OpeningHours  []*OpeningHoursPayload  `json:"opening_hours" validate:"max=50,dive"`
+	Closures      []*BranchClosurePayload `json:"closures" validate:"max=366,dive"`
+}
+
+type OpeningHoursPayload struct {
+	Weekday int    `json:"weekday" validate:"min=0,max=6"` // 0 is sunday
+	Opens   string `json:"opens" validate:"required,datetime=15:04"`
+	Closes  string `json:"closes" validate:"required,datetime=15:04"`
+}
+
+type BranchClosurePayload struct {
+	Date   string `json:"date" validate:"required,datetime=2006-01-02"`
+	Reason string `json:"reason" validate:"max=200"`
+}
+
+// people without an account get the invitation by email and accept it after they register
+type InviteMemberPayload struct {
+	Email string      `json:"email" validate:"required,email,max=40"`
+	Role  CompanyRole `json:"role" validate:"required,oneof=owner manager agent mechanic"`
+}
+
+// without car id rules apply to the whole company
+type RentalRulesPayload struct {
+	CarID                *int    `json:"car_id" validate:"omitempty,gt=0"`
+	MinAge               int     `json:"min_age" validate:"gte=0,lte=100"`
+	MinYearsLicensed     int     `json:"min_years_licensed" validate:"gte=0,lte=80"`
+	YoungDriverAge       int     `json:"young_driver_age" validate:"gte=0,lte=100"`
+	YoungDriverSurcharge float64 `json:"young_driver_surcharge" validate:"gte=0"`
+	LicenseCategory      string  `json:"license_category" validate:"omitempty,max=20"`
+	AdditionalDriverFee  float64 `json:"additional_driver_fee" validate:"gte=0"`
+	NoShowGraceHours     int     `json:"no_show_grace_hours" validate:"gte=0,lte=720"`
+	NoShowFee            float64 `json:"no_show_fee" validate:"gte=0"`
+}
+
+type DynamicPricingPayload struct {
+	Enabled            bool    `json:"enabled"`
+	TargetUtilisation  float64 `json:"target_utilisation" validate:"gte=0,lte=1"`
+	UtilisationFactor  float64 `json:"utilisation_factor" validate:"gte=0,lte=5"`
+	LastMinuteDays     int     `json:"last_minute_days" validate:"gte=0,lte=60"`
+	LastMinuteFactor   float64 `json:"last_minute_factor" validate:"gte=-1,lte=1"`
+	EarlyBookingDays   int     `json:"early_booking_days" validate:"gte=0,lte=365"`
+	EarlyBookingFactor float64 `json:"early_booking_factor" validate:"gte=-1,lte=1"`
+	Floor              float64 `json:"floor" validate:"required,gt=0,lte=1"`
+	Ceiling            float64 `json:"ceiling" validate:"required,gte=1,lte=10"`
 }
 
 type CreateCarPayload struct {
@@ -41,6 +119,17 @@ type CreateCarPayload struct {
 	RegistrationNo string  `json:"registration_no" validate:"required"`
 	PricePerDay    float64 `json:"price_per_day" validate:"required,gt=0"`
 	CompanyID      int     `json:"company_id" validate:"required"`
+	CategoryID     *int    `json:"category_id" validate:"omitempty,gt=0"`
+
+	ServiceIntervalKm     int `json:"service_interval_km" validate:"omitempty,gte=1000,lte=100000"`
+	ServiceIntervalMonths int `json:"service_interval_months" validate:"omitempty,gte=1,lte=60"`
+
+	Latitude  *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+
+	VIN       string               `json:"vin" validate:"omitempty,vin"`
+	Features  *CarFeaturesPayload  `json:"features" validate:"omitempty"`
+	Documents *CarDocumentsPayload `json:"documents" validate:"omitempty"`
 }
 
 type UpdateCarPayload struct {
@@ -50,15 +139,170 @@ type UpdateCarPayload struct {
 	Color          string  `json:"color" validate:"omitempty"`
 	RegistrationNo string  `json:"registration_no" validate:"omitempty"`
 	PricePerDay    float64 `json:"price_per_day" validate:"omitempty,gt=0"`
+	CategoryID     *int    `json:"category_id" validate:"omitempty,gt=0"`
+
+	ServiceIntervalKm     int `json:"service_interval_km" validate:"omitempty,gte=1000,lte=100000"`
+	ServiceIntervalMonths int `json:"service_interval_months" validate:"omitempty,gte=1,lte=60"`
+
+	Latitude  *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+
+	VIN       string               `json:"vin" validate:"omitempty,vin"`
+	Features  *CarFeaturesPayload  `json:"features" validate:"omitempty"`
+	Documents *CarDocumentsPayload `json:"documents" validate:"omitempty"` // replaces all expiry dates, omitted ones are no longer tracked
+}
+
+type CarDocumentsPayload struct {
+	RegistrationExpiry   string `json:"registration_expiry" validate:"omitempty,datetime=2006-01-02"`
+	InsuranceExpiry      string `json:"insurance_expiry" validate:"omitempty,datetime=2006-01-02"`
+	RoadworthinessExpiry string `json:"roadworthiness_expiry" validate:"omitempty,datetime=2006-01-02"`
+}
+
+type CarFeaturesPayload struct {
+	Transmission    string `json:"transmission" validate:"omitempty,oneof=manual automatic"`
+	FuelType        string `json:"fuel_type" validate:"omitempty,oneof=petrol diesel electric hybrid lpg"`
+	Seats           int    `json:"seats" validate:"omitempty,gte=1,lte=60"`
+	Doors           int    `json:"doors" validate:"omitempty,gte=1,lte=6"`
+	AirConditioning bool   `json:"air_conditioning"`
+	Navigation      bool   `json:"navigation"`
+	ElectricRange   int    `json:"electric_range" validate:"gte=0,lte=2000"`
+	Towbar          bool   `json:"towbar"`
+}
+
+// recorded now if recorded_at is empty
+type CarReadingPayload struct {
+	Odometer   int    `json:"odometer" validate:"gte=0"`
+	FuelLevel  *int   `json:"fuel_level" validate:"omitempty,gte=0,lte=100"`
+	Source     string `json:"source" validate:"required,oneof=check_out check_in manual telemetry"`
+	BookingID  *int   `json:"booking_id" validate:"omitempty,gt=0"`
+	RecordedAt string `json:"recorded_at" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+}
+
+// batch of points sent by a device gateway
+type TelemetryPayload struct {
+	Points []*TelemetryPointPayload `json:"points" validate:"required,min=1,max=1000,dive"`
 }
 
+type TelemetryPointPayload struct {
+	CarID        int      `json:"car_id" validate:"required,gt=0"`
+	RecordedAt   string   `json:"recorded_at" validate:"required,datetime=2006-01-02T15:04:05Z07:00"`
+	Latitude     *float64 `json:"latitude" validate:"required_with=Longitude,omitempty,gte=-90,lte=90"`
+	Longitude    *float64 `json:"longitude" validate:"required_with=Latitude,omitempty,gte=-180,lte=180"`
+	Odometer     *int     `json:"odometer" validate:"omitempty,gte=0"`
+	FuelLevel    *int     `json:"fuel_level" validate:"omitempty,gte=0,lte=100"`
+	BatteryLevel *int     `json:"battery_level" validate:"omitempty,gte=0,lte=100"`
+	Ignition     *bool    `json:"ignition"`
+}
+
+// price change from the date on, has to be in the future
+type CarPricePayload struct {
+	PricePerDay   float64 `json:"price_per_day" validate:"required,gt=0"`
+	EffectiveFrom string  `json:"effective_from" validate:"required,datetime=2006-01-02"`
+}
+
+type ServiceRecordPayload struct {
+	Description string  `json:"description" validate:"required,max=500"`
+	Cost        float64 `json:"cost" validate:"gte=0"`
+	Odometer    int     `json:"odometer" validate:"gte=0"`
+	PerformedAt string  `json:"performed_at" validate:"required,datetime=2006-01-02"`
+}
+
+type CarStatusPayload struct {
+	Status string `json:"status" validate:"required,oneof=available in_service retired"`
+}
+
+// all images of the car in the new order
+type ReorderCarImagesPayload struct {
+	ImageIDs []int `json:"image_ids" validate:"required,min=1,dive,gt=0"`
+}
+
+// either a specific car or any car of the category, optionally from one company
 type CreateBookingPayload struct {
-	CarID     int    `json:"car_id" validate:"required"`
+	CarID             int                        `json:"car_id" validate:"required_without=CategoryID"`
+	CategoryID        int                        `json:"category_id" validate:"required_without=CarID"`
+	CompanyID         int                        `json:"company_id" validate:"omitempty,gt=0"`
+	StartDate         string                     `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate           string                     `json:"end_date" validate:"required,datetime=2006-01-02"`
+	AdditionalDrivers []*AdditionalDriverPayload `json:"additional_drivers" validate:"omitempty,max=5,dive"`
+	// pickup on the start date and return on the end date at the branch, in its local time
+	BranchID   int    `json:"branch_id" validate:"omitempty,gt=0"`
+	PickupTime string `json:"pickup_time" validate:"required_with=BranchID,omitempty,datetime=15:04"`
+	ReturnTime string `json:"return_time" validate:"required_with=BranchID,omitempty,datetime=15:04"`
+}
+
+// existing user by id or email, unknown emails get an invitation
+type AdditionalDriverPayload struct {
+	UserID int    `json:"user_id" validate:"required_without=Email"`
+	Email  string `json:"email" validate:"required_without=UserID,omitempty,email"`
+}
+
+type AssignCarPayload struct {
+	CarID int `json:"car_id" validate:"required"`
+}
+
+type CreateMaintenancePayload struct {
+	Type      string `json:"type" validate:"required,oneof=service tyre_change inspection"`
 	StartDate string `json:"start_date" validate:"required,datetime=2006-01-02"`
 	EndDate   string `json:"end_date" validate:"required,datetime=2006-01-02"`
+	Note      string `json:"note" validate:"max=500"`
+}
+
+// new renter by id or email, he has to have an account
+type TransferBookingPayload struct {
+	UserID int    `json:"user_id" validate:"required_without=Email"`
+	Email  string `json:"email" validate:"required_without=UserID,omitempty,email"`
+}
+
+// without migrate_bookings cars with future bookings can't be transferred
+// another company takes the car over once it accepts, moves between branches of the same company
+// happen right away, branch_id is the branch of the company the car ends up in
+type TransferCarPayload struct {
+	CompanyID       int  `json:"company_id" validate:"required_without=BranchID"`
+	BranchID        int  `json:"branch_id" validate:"required_without=CompanyID"`
+	MigrateBookings bool `json:"migrate_bookings"`
+}
+
+type CreateReviewPayload struct {
+	CarRating     int    `json:"car_rating" validate:"required,gte=1,lte=5"`
+	CompanyRating int    `json:"company_rating" validate:"required,gte=1,lte=5"`
+	Comment       string `json:"comment" validate:"max=2000"`
+}
+
+type ReviewReplyPayload struct {
+	Reply string `json:"reply" validate:"required,max=2000"`
+}
+
+type ReportReviewPayload struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+type ModerateReviewPayload struct {
+	Status ReviewStatus `json:"status" validate:"required,oneof=published hidden"`
+}
+
+// reason is sent to the company and required when rejecting
+type VerifyCompanyPayload struct {
+	Status CompanyStatus `json:"status" validate:"required,oneof=verified rejected"`
+	Reason string        `json:"reason" validate:"required_if=Status rejected,max=500"`
 }
 
 type UpdateBookingPayload struct {
 	StartDate string `json:"start_date" validate:"omitempty,datetime=2006-01-02"`
 	EndDate   string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
 }
+
+// weekdays as in time.Weekday, 0 - sunday, 6 - saturday
+type CreateBookingSeriesPayload struct {
+	CarID         int    `json:"car_id" validate:"required"`
+	StartDate     string `json:"start_date" validate:"required,datetime=2006-01-02"`
+	EndDate       string `json:"end_date" validate:"required,datetime=2006-01-02"`
+	Weekdays      []int  `json:"weekdays" validate:"required,min=1,dive,gte=0,lte=6"`
+	SkipConflicts bool   `json:"skip_conflicts"` // book the free days only instead of failing
+}
+
+// changes apply only to occurrences that haven't started yet
+type UpdateBookingSeriesPayload struct {
+	EndDate       string `json:"end_date" validate:"omitempty,datetime=2006-01-02"`
+	Weekdays      []int  `json:"weekdays" validate:"omitempty,min=1,dive,gte=0,lte=6"`
+	SkipConflicts bool   `json:"skip_conflicts"`
+}
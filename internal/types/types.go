@@ -5,6 +5,22 @@ type QueryOptions struct {
 	Offset       int
 	SortField    string
 	SortDiretion string
+	Near         *GeoFilter // only items within the radius, distance is returned as distance_km
+	Search       []string   // only items matching all the terms, ranked by relevance
+}
+
+type GeoFilter struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+// what can be read from the vin itself
+type VINInfo struct {
+	VIN          string `json:"vin"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	Region       string `json:"region,omitempty"` // where the manufacturer is registered
+	Year         int    `json:"year,omitempty"`   // model year
 }
 
 type FilterOperators map[string]string
@@ -19,6 +35,28 @@ var OperatorMap = FilterOperators{
 	"ct":  "LIKE",
 	"sw":  "LIKE",
 	"ew":  "LIKE",
+	"in":  "IN", // comma separated values, e.g. fuel_type[in]=petrol,diesel
+}
+
+// columns that can be used in filters and sorting, anything else is rejected
+// since field names end up in the query
+var CarQueryFields = []string{
+	"id", "company_id", "make", "model", "year", "color", "registration_no", "price_per_day", "category_id", "branch_id", "status", "created",
+	"transmission", "fuel_type", "seats", "doors", "air_conditioning", "navigation", "electric_range", "towbar",
+	"rating", "rating_count",
+}
+
+// not a car column, car stores filter cars by the status of their company,
+// only services set it so it isn't part of CarQueryFields
+const CompanyStatusFilter = "company_status"
+
+var CompanyQueryFields = []string{
+	"id", "owner_id", "name", "email", "phone", "address", "created",
+	"rating", "rating_count",
+}
+
+var ReviewQueryFields = []string{
+	"id", "car_rating", "company_rating", "created",
 }
 
 type QueryFilter struct {
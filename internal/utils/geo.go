@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+const earthRadiusKm = 6371.0
+
+// great-circle distance between two points using the haversine formula
+func DistanceKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLng := radians(lng2 - lng1)
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// wraps the table in a subquery with distance_km column and adds the radius filter,
+// uses only basic math functions so it doesn't need postgis
+//
+//	FROM car WHERE 1 = 1 --> FROM (SELECT *, ... AS distance_km FROM car WHERE latitude IS NOT NULL) AS car WHERE 1 = 1
+func WithDistance(table string, near *types.GeoFilter, filters []*types.QueryFilter) (string, []interface{}, []*types.QueryFilter) {
+	from := fmt.Sprintf(`(SELECT *, %f * 2 * ASIN(LEAST(1, SQRT(
+		POWER(SIN(RADIANS(latitude - ?) / 2), 2) + COS(RADIANS(?)) * COS(RADIANS(latitude)) * POWER(SIN(RADIANS(longitude - ?) / 2), 2)
+	))) AS distance_km FROM %s WHERE latitude IS NOT NULL AND longitude IS NOT NULL) AS %s`, earthRadiusKm, table, table)
+	args := []interface{}{near.Latitude, near.Latitude, near.Longitude}
+
+	filters = append(slices.Clip(filters), &types.QueryFilter{Field: "distance_km", Operator: "<=", Value: near.RadiusKm})
+	return from, args, filters
+}
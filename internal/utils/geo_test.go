@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	// warsaw to cracow
+	distance := DistanceKm(52.2297, 21.0122, 50.0647, 19.9450)
+	if math.Abs(distance-252) > 1 {
+		t.Errorf("expected about 252 km, got %f", distance)
+	}
+
+	if distance := DistanceKm(52.2297, 21.0122, 52.2297, 21.0122); distance != 0 {
+		t.Errorf("expected 0 km for the same point, got %f", distance)
+	}
+}
+
+func TestParseQueryOptionsNear(t *testing.T) {
+	tests := []struct {
+		query       string
+		expectError bool
+		radius      float64
+		sort        string
+	}{
+		{query: "near=52.23,21.01", radius: 10, sort: "distance_km"},
+		{query: "near=52.23,21.01&radius_km=25&sort=price_per_day-desc", radius: 25, sort: "price_per_day"},
+		{query: "near=52.23", expectError: true},
+		{query: "near=91,21.01", expectError: true},
+		{query: "near=52.23,21.01&radius_km=-1", expectError: true},
+		{query: "sort=distance_km-asc", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/car/batch?"+tt.query, nil)
+			opts, err := ParseQueryOptions(r, []string{"price_per_day"})
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if opts.Near == nil || opts.Near.RadiusKm != tt.radius || opts.SortField != tt.sort {
+				t.Errorf("expected radius %v sorted by %s, got %+v %+v", tt.radius, tt.sort, opts, opts.Near)
+			}
+		})
+	}
+}
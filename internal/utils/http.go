@@ -3,6 +3,7 @@ package utils
 import (
 	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -12,6 +13,13 @@ import (
 
 var validate = validator.New()
 
+func init() {
+	// vin tag checks the check digit, see ValidVIN
+	validate.RegisterValidation("vin", func(fl validator.FieldLevel) bool {
+		return ValidVIN(fl.Field().String())
+	})
+}
+
 func ValidateStruct(data interface{}) map[string]string {
 	errors := make(map[string]string)
 	err := validate.Struct(data)
@@ -23,33 +31,36 @@ func ValidateStruct(data interface{}) map[string]string {
 	return errors
 }
 
-// scraping filters from query
-func ParseQueryFilters(r *http.Request) ([]*types.QueryFilter, error) {
+// scraping filters from query, only given fields can be filtered on
+func ParseQueryFilters(r *http.Request, fields []string) ([]*types.QueryFilter, error) {
 	query := r.URL.Query()
 	filters := make([]*types.QueryFilter, 0)
 
 	for key, values := range query {
 		// skip pagination and sorting
-		if len(values) < 1 || key == "page" || key == "page_size" || key == "sort" {
+		if len(values) < 1 || isOptionKey(key) {
 			continue
 		}
 		if strings.Contains(key, "[") && strings.HasSuffix(key, "]") {
 			// scrape field and operator from key
 			// field[gt] --> field, gt
 			field := key[:strings.Index(key, "[")]
+			if !slices.Contains(fields, field) {
+				return nil, types.BadQueryParameter(fmt.Sprintf("Invalid filter field: %s", field))
+			}
 			operatorKey := key[strings.Index(key, "[")+1 : len(key)-1]
 			if operator, ok := types.OperatorMap[operatorKey]; ok {
 				//
-				value := values[0]
-				if operator == "LIKE" {
-					switch operatorKey {
-					case "sw":
-						value = value + "%"
-					case "ew":
-						value = "%" + value
-					case "ct":
-						value = "%" + value + "%"
-					}
+				var value interface{} = values[0]
+				switch operatorKey {
+				case "sw":
+					value = values[0] + "%"
+				case "ew":
+					value = "%" + values[0]
+				case "ct":
+					value = "%" + values[0] + "%"
+				case "in":
+					value = strings.Split(values[0], ",")
 				}
 				filters = append(filters, &types.QueryFilter{
 					Field:    field,
@@ -60,6 +71,9 @@ func ParseQueryFilters(r *http.Request) ([]*types.QueryFilter, error) {
 				return nil, types.BadQueryParameter(fmt.Sprintf("Invalid operator in filter: %s", operatorKey))
 			}
 		} else {
+			if !slices.Contains(fields, key) {
+				return nil, types.BadQueryParameter(fmt.Sprintf("Invalid filter field: %s", key))
+			}
 			// default for not provided operator
 			filters = append(filters, &types.QueryFilter{
 				Field:    key,
@@ -72,7 +86,16 @@ func ParseQueryFilters(r *http.Request) ([]*types.QueryFilter, error) {
 	return filters, nil
 }
 
-func ParseQueryOptions(r *http.Request) (*types.QueryOptions, error) {
+func isOptionKey(key string) bool {
+	switch key {
+	case "page", "page_size", "limit", "offset", "sort", "near", "radius_km", "q", "facets":
+		return true
+	}
+	return false
+}
+
+// pagination by page and page_size or limit and offset, sorting only by given fields
+func ParseQueryOptions(r *http.Request, fields []string) (*types.QueryOptions, error) {
 	query := r.URL.Query()
 
 	// default options if not provided in query
@@ -83,21 +106,40 @@ func ParseQueryOptions(r *http.Request) (*types.QueryOptions, error) {
 		SortDiretion: "asc",
 	}
 
+	for _, key := range []string{"page_size", "limit"} {
+		if pageSize, ok := query[key]; ok {
+			pageSizeInt, err := strconv.Atoi(pageSize[0])
+			if err != nil || pageSizeInt < 1 {
+				return nil, types.BadRequest(fmt.Sprintf("invalid %s value", key))
+			}
+			opts.Limit = pageSizeInt
+		}
+	}
+
 	if page, ok := query["page"]; ok {
 		pageInt, err := strconv.Atoi(page[0])
-		if err != nil {
+		if err != nil || pageInt < 1 {
 			return nil, types.BadRequest("invalid page value")
 		}
 		// calculate the offset based on page number
 		opts.Offset = (pageInt - 1) * opts.Limit
+	} else if offset, ok := query["offset"]; ok {
+		offsetInt, err := strconv.Atoi(offset[0])
+		if err != nil || offsetInt < 0 {
+			return nil, types.BadRequest("invalid offset value")
+		}
+		opts.Offset = offsetInt
 	}
 
-	if pageSize, ok := query["page_size"]; ok {
-		pageSizeInt, err := strconv.Atoi(pageSize[0])
+	if near, ok := query["near"]; ok {
+		geo, err := parseNear(near[0], query.Get("radius_km"))
 		if err != nil {
-			return nil, types.BadRequest("invalid pageSize value")
+			return nil, err
 		}
-		opts.Limit = pageSizeInt
+		opts.Near = geo
+		// closest first unless sorted by something else
+		opts.SortField = "distance_km"
+		fields = append(slices.Clip(fields), "distance_km")
 	}
 
 	if sort, ok := query["sort"]; ok {
@@ -105,9 +147,46 @@ func ParseQueryOptions(r *http.Request) (*types.QueryOptions, error) {
 		if len(sortParts) != 2 {
 			return nil, types.BadRequest("invalid sort value")
 		}
+		if !slices.Contains(fields, sortParts[0]) {
+			return nil, types.BadRequest(fmt.Sprintf("invalid sort field: %s", sortParts[0]))
+		}
+		direction := strings.ToLower(sortParts[1])
+		if direction != "asc" && direction != "desc" {
+			return nil, types.BadRequest("invalid sort direction")
+		}
 		opts.SortField = sortParts[0]
-		opts.SortDiretion = sortParts[1]
+		opts.SortDiretion = direction
 	}
 
 	return opts, nil
 }
+
+const (
+	defaultRadiusKm = 10
+	maxRadiusKm     = 500
+)
+
+// near=lat,lng with optional radius_km
+func parseNear(near string, radius string) (*types.GeoFilter, error) {
+	parts := strings.Split(near, ",")
+	if len(parts) != 2 {
+		return nil, types.BadQueryParameter("near, expected near=lat,lng")
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil, types.BadQueryParameter("near, invalid latitude")
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return nil, types.BadQueryParameter("near, invalid longitude")
+	}
+
+	geo := &types.GeoFilter{Latitude: lat, Longitude: lng, RadiusKm: defaultRadiusKm}
+	if radius != "" {
+		geo.RadiusKm, err = strconv.ParseFloat(radius, 64)
+		if err != nil || geo.RadiusKm <= 0 || geo.RadiusKm > maxRadiusKm {
+			return nil, types.BadQueryParameter(fmt.Sprintf("radius_km, has to be between 0 and %d", maxRadiusKm))
+		}
+	}
+	return geo, nil
+}
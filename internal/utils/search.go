@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// more words than this are ignored
+const maxSearchTerms = 10
+
+// lowercase words and numbers of the text without duplicates
+//
+//	"White Toyota, hybrid 2022" --> white, toyota, hybrid, 2022
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, w := range words {
+		if !slices.Contains(terms, w) {
+			terms = append(terms, w)
+		}
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// wraps the source in a subquery with relevance column, keeps only rows matching all the terms,
+// terms are matched as prefixes so "toyo" finds toyota, table needs search_vector column
+//
+//	FROM car --> FROM (SELECT *, ts_rank(search_vector, query) AS relevance FROM car, to_tsquery(...) query WHERE ...) AS car
+//
+// the placeholder comes after the source so it can be already wrapped by WithDistance
+func WithSearch(source string, alias string, terms []string) (string, []interface{}) {
+	prefixes := make([]string, len(terms))
+	for i, term := range terms {
+		// tokenized terms are only letters and digits, safe to use in tsquery syntax
+		prefixes[i] = term + ":*"
+	}
+	tsquery := strings.Join(prefixes, " & ")
+
+	from := fmt.Sprintf(`(SELECT *, ts_rank(search_vector, query) AS relevance FROM %s, to_tsquery('simple', ?) query
+		WHERE search_vector @@ query) AS %s`, source, alias)
+	return from, []interface{}{tsquery}
+}
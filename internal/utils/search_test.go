@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	terms := Tokenize("White Toyota, hybrid 2022 white; Škoda")
+	expected := []string{"white", "toyota", "hybrid", "2022", "škoda"}
+	if !slices.Equal(terms, expected) {
+		t.Errorf("expected %v, got %v", expected, terms)
+	}
+
+	// punctuation can't reach tsquery syntax
+	if terms := Tokenize("toyota:* | !(a & b)"); !slices.Equal(terms, []string{"toyota", "a", "b"}) {
+		t.Errorf("expected only words, got %v", terms)
+	}
+}
+
+func TestWithSearch(t *testing.T) {
+	from, args := WithSearch("car", "car", []string{"white", "toyota"})
+	if !strings.HasSuffix(from, ") AS car") || len(args) != 1 || args[0] != "white:* & toyota:*" {
+		t.Errorf("unexpected search subquery %s with args %v", from, args)
+	}
+}
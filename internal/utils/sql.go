@@ -2,18 +2,13 @@ package utils
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/mwdev22/CarRental/internal/types"
 )
 
 func BuildBatchQuery(query string, filters []*types.QueryFilter, opts *types.QueryOptions) (string, []interface{}) {
-	args := make([]interface{}, 0)
-	// i use question marks because filters and their count are dynamic
-	// could be 1, 2, 3 etc through loop, but its not necessary complexity i think
-	for _, filter := range filters {
-		query += fmt.Sprintf(" AND %s %s ?", filter.Field, filter.Operator)
-		args = append(args, filter.Value)
-	}
+	query, args := BuildFilters(query, filters)
 
 	if opts != nil {
 		query += fmt.Sprintf(` ORDER BY %s %s`, opts.SortField, opts.SortDiretion)
@@ -25,3 +20,29 @@ func BuildBatchQuery(query string, filters []*types.QueryFilter, opts *types.Que
 
 	return query, args
 }
+
+// only the filter conditions, for queries that order or group on their own
+func BuildFilters(query string, filters []*types.QueryFilter) (string, []interface{}) {
+	args := make([]interface{}, 0)
+	// i use question marks because filters and their count are dynamic
+	// could be 1, 2, 3 etc through loop, but its not necessary complexity i think
+	for _, filter := range filters {
+		// in filters get placeholder for every value
+		if values, ok := filter.Value.([]string); ok && filter.Operator == "IN" {
+			// nothing is in an empty list
+			if len(values) == 0 {
+				query += " AND FALSE"
+				continue
+			}
+			query += fmt.Sprintf(" AND %s IN (?%s)", filter.Field, strings.Repeat(", ?", len(values)-1))
+			for _, v := range values {
+				args = append(args, v)
+			}
+			continue
+		}
+		query += fmt.Sprintf(" AND %s %s ?", filter.Field, filter.Operator)
+		args = append(args, filter.Value)
+	}
+
+	return query, args
+}
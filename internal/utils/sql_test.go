@@ -41,3 +41,54 @@ func TestBuildBatchQuery(t *testing.T) {
 	}
 
 }
+
+func TestBuildBatchQueryIn(t *testing.T) {
+	query := "SELECT * FROM car WHERE 1=1"
+	filters := []*types.QueryFilter{
+		{
+			Field:    "fuel_type",
+			Operator: "IN",
+			Value:    []string{"petrol", "diesel", "lpg"},
+		},
+		{
+			Field:    "seats",
+			Operator: ">=",
+			Value:    "7",
+		},
+	}
+
+	expectedQuery := "SELECT * FROM car WHERE 1=1 AND fuel_type IN (?, ?, ?) AND seats >= ? ORDER BY id DESC"
+
+	result, args := BuildBatchQuery(query, filters, nil)
+
+	if result != expectedQuery {
+		t.Errorf("expected %s, got %s", expectedQuery, result)
+	}
+
+	if len(args) != 4 {
+		t.Errorf("expected 4 args, got %d", len(args))
+	}
+}
+
+func TestBuildBatchQueryEmptyIn(t *testing.T) {
+	query := "SELECT * FROM car WHERE 1=1"
+	filters := []*types.QueryFilter{
+		{
+			Field:    "company_id",
+			Operator: "IN",
+			Value:    []string{},
+		},
+	}
+
+	expectedQuery := "SELECT * FROM car WHERE 1=1 AND FALSE ORDER BY id DESC"
+
+	result, args := BuildBatchQuery(query, filters, nil)
+
+	if result != expectedQuery {
+		t.Errorf("expected %s, got %s", expectedQuery, result)
+	}
+
+	if len(args) != 0 {
+		t.Errorf("expected 0 args, got %d", len(args))
+	}
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mwdev22/CarRental/internal/types"
+)
+
+// values of vin characters for the check digit, I, O and Q aren't allowed
+var vinValues = map[rune]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+	'0': 0, '1': 1, '2': 2, '3': 3, '4': 4, '5': 5, '6': 6, '7': 7, '8': 8, '9': 9,
+}
+
+var vinWeights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// 17 characters with valid check digit on the 9th position (ISO 3779)
+func ValidVIN(vin string) bool {
+	vin = strings.ToUpper(vin)
+	if len(vin) != 17 {
+		return false
+	}
+
+	sum := 0
+	for i, c := range vin {
+		value, ok := vinValues[c]
+		if !ok {
+			return false
+		}
+		sum += value * vinWeights[i]
+	}
+
+	check := byte('0' + sum%11)
+	if sum%11 == 10 {
+		check = 'X'
+	}
+	return vin[8] == check
+}
+
+// world manufacturer identifiers of common makes, first three characters of the vin
+// or two for makes that use the third one for plants
+var vinManufacturers = map[string]string{
+	"WVW": "Volkswagen", "WV1": "Volkswagen", "WV2": "Volkswagen", "WAU": "Audi", "TRU": "Audi",
+	"WBA": "BMW", "WBS": "BMW", "WMW": "MINI", "WDB": "Mercedes-Benz", "WDD": "Mercedes-Benz", "W1K": "Mercedes-Benz",
+	"WP0": "Porsche", "W0L": "Opel", "WF0": "Ford", "1FA": "Ford", "1FT": "Ford", "1G1": "Chevrolet",
+	"VF1": "Renault", "VF3": "Peugeot", "VF7": "Citroen", "UU1": "Dacia", "ZFA": "Fiat", "ZAR": "Alfa Romeo",
+	"TMB": "Skoda", "VSS": "SEAT", "YV1": "Volvo", "SAL": "Land Rover", "SAJ": "Jaguar",
+	"JT": "Toyota", "SB1": "Toyota", "VNK": "Toyota", "JHM": "Honda", "JN1": "Nissan", "SJN": "Nissan",
+	"JM": "Mazda", "JF": "Subaru", "TSM": "Suzuki", "KMH": "Hyundai", "TMA": "Hyundai", "KNA": "Kia",
+	"5YJ": "Tesla", "LRW": "Tesla",
+}
+
+// model year codes on the 10th position, they repeat every 30 years
+const vinYearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+// decodes what can be read without external databases, unknown parts are left empty,
+// nil for invalid vin
+func DecodeVIN(vin string) *types.VINInfo {
+	vin = strings.ToUpper(vin)
+	if !ValidVIN(vin) {
+		return nil
+	}
+
+	info := &types.VINInfo{VIN: vin, Region: vinRegion(vin[0])}
+	if name, ok := vinManufacturers[vin[:3]]; ok {
+		info.Manufacturer = name
+	} else {
+		info.Manufacturer = vinManufacturers[vin[:2]]
+	}
+
+	// latest of the possible years, cars can have next year's model year
+	if i := strings.IndexByte(vinYearCodes, vin[9]); i >= 0 {
+		for year := 1980 + i; year <= time.Now().Year()+1; year += len(vinYearCodes) {
+			info.Year = year
+		}
+	}
+	return info
+}
+
+func vinRegion(c byte) string {
+	switch {
+	case c >= 'A' && c <= 'H':
+		return "Africa"
+	case c >= 'J' && c <= 'R':
+		return "Asia"
+	case c >= 'S' && c <= 'Z':
+		return "Europe"
+	case c >= '1' && c <= '5':
+		return "North America"
+	case c == '6' || c == '7':
+		return "Oceania"
+	case c == '8' || c == '9':
+		return "South America"
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestValidVIN(t *testing.T) {
+	tests := []struct {
+		vin   string
+		valid bool
+	}{
+		{vin: "1M8GDM9AXKP042788", valid: true},
+		{vin: "5yj3e1ea2kf317000", valid: true},
+		{vin: "5YJ3E1EA3KF317000", valid: false}, // wrong check digit
+		{vin: "5YJ3E1EA2KF31700", valid: false},
+		{vin: "5YJ3E1EA2KF3170O0", valid: false}, // O isn't allowed
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.vin, func(t *testing.T) {
+			if valid := ValidVIN(tt.vin); valid != tt.valid {
+				t.Errorf("expected valid %v, got %v", tt.valid, valid)
+			}
+			if errors := ValidateStruct(&struct {
+				VIN string `validate:"vin"`
+			}{tt.vin}); (len(errors) == 0) != tt.valid {
+				t.Errorf("expected vin validation %v, got %v", tt.valid, errors)
+			}
+		})
+	}
+}
+
+func TestDecodeVIN(t *testing.T) {
+	info := DecodeVIN("5yj3e1ea2kf317000")
+	if info == nil {
+		t.Fatalf("expected decoded vin")
+	}
+	if info.VIN != "5YJ3E1EA2KF317000" || info.Manufacturer != "Tesla" || info.Region != "North America" || info.Year != 2019 {
+		t.Errorf("unexpected decoded vin: %+v", info)
+	}
+
+	// manufacturer by two characters, Y stands for 2000 or 2030 which is still to come
+	info = DecodeVIN("JT2AE09W0Y0000002")
+	if info == nil {
+		t.Fatalf("expected decoded vin")
+	}
+	if info.Manufacturer != "Toyota" || info.Region != "Asia" || info.Year != 2000 {
+		t.Errorf("unexpected decoded vin: %+v", info)
+	}
+
+	if info := DecodeVIN("5YJ3E1EA3KF317000"); info != nil {
+		t.Errorf("expected nil for invalid vin, got %+v", info)
+	}
+}
@@ -0,0 +1,27 @@
+CREATE TABLE booking_series (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    weekdays INT NOT NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE booking (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    total DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    status INT NOT NULL DEFAULT 0,
+    series_id INT REFERENCES booking_series(id) ON DELETE SET NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_booking_user_id ON booking(user_id);
+
+CREATE INDEX idx_booking_car_dates ON booking(car_id, start_date, end_date);
+
+CREATE INDEX idx_booking_series_id ON booking(series_id);
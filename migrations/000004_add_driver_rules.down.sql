@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS rental_rules;
+DROP TABLE IF EXISTS driver_profile;
@@ -0,0 +1,28 @@
+CREATE TABLE driver_profile (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    date_of_birth DATE NOT NULL,
+    license_number VARCHAR(50) NOT NULL,
+    license_country CHAR(2) NOT NULL,
+    license_category VARCHAR(20) NOT NULL,
+    license_issued DATE NOT NULL,
+    license_expiry DATE NOT NULL,
+    updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE rental_rules (
+    id SERIAL PRIMARY KEY,
+    company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    car_id INT REFERENCES car(id) ON DELETE CASCADE,
+    min_age INT NOT NULL DEFAULT 0,
+    min_years_licensed INT NOT NULL DEFAULT 0,
+    young_driver_age INT NOT NULL DEFAULT 0,
+    young_driver_surcharge DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    license_category VARCHAR(20) NOT NULL DEFAULT '',
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- one company wide rule set and at most one per car
+CREATE UNIQUE INDEX idx_rental_rules_company ON rental_rules(company_id) WHERE car_id IS NULL;
+
+CREATE UNIQUE INDEX idx_rental_rules_car ON rental_rules(car_id) WHERE car_id IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS booking_driver;
+ALTER TABLE rental_rules DROP COLUMN IF EXISTS additional_driver_fee;
@@ -0,0 +1,15 @@
+ALTER TABLE rental_rules ADD COLUMN additional_driver_fee DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+CREATE TABLE booking_driver (
+    id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL REFERENCES booking(id) ON DELETE CASCADE,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(40) NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    fee DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_booking_driver_email ON booking_driver(booking_id, email);
+
+CREATE INDEX idx_booking_driver_user_id ON booking_driver(user_id);
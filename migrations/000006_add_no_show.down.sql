@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_booking_pending_pickup;
+ALTER TABLE booking DROP COLUMN IF EXISTS picked_up;
+ALTER TABLE rental_rules DROP COLUMN IF EXISTS no_show_fee;
+ALTER TABLE rental_rules DROP COLUMN IF EXISTS no_show_grace_hours;
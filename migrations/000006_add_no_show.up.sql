@@ -0,0 +1,6 @@
+ALTER TABLE rental_rules ADD COLUMN no_show_grace_hours INT NOT NULL DEFAULT 0;
+ALTER TABLE rental_rules ADD COLUMN no_show_fee DECIMAL(10, 2) NOT NULL DEFAULT 0;
+
+ALTER TABLE booking ADD COLUMN picked_up TIMESTAMP;
+
+CREATE INDEX idx_booking_pending_pickup ON booking(start_date) WHERE picked_up IS NULL;
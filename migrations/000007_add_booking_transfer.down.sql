@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS booking_transfer;
+ALTER TABLE booking DROP COLUMN IF EXISTS created_by;
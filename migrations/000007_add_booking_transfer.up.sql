@@ -0,0 +1,17 @@
+ALTER TABLE booking ADD COLUMN created_by INT REFERENCES users(id) ON DELETE SET NULL;
+UPDATE booking SET created_by = user_id;
+
+CREATE TABLE booking_transfer (
+    id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL REFERENCES booking(id) ON DELETE CASCADE,
+    from_user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    to_user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status INT NOT NULL DEFAULT 0,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved TIMESTAMP
+);
+
+CREATE INDEX idx_booking_transfer_booking_id ON booking_transfer(booking_id);
+
+-- only one pending transfer per booking
+CREATE UNIQUE INDEX idx_booking_transfer_pending ON booking_transfer(booking_id) WHERE status = 0;
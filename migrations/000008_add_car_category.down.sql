@@ -0,0 +1,3 @@
+ALTER TABLE booking DROP COLUMN IF EXISTS category_id;
+ALTER TABLE car DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS car_category;
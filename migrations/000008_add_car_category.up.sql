@@ -0,0 +1,22 @@
+CREATE TABLE car_category (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) UNIQUE NOT NULL,
+    seats INT NOT NULL,
+    doors INT NOT NULL,
+    transmission VARCHAR(20) NOT NULL,
+    fuel_type VARCHAR(20) NOT NULL,
+    luggage INT NOT NULL
+);
+
+INSERT INTO car_category (name, seats, doors, transmission, fuel_type, luggage) VALUES
+    ('economy', 4, 3, 'manual', 'petrol', 1),
+    ('compact', 5, 5, 'manual', 'petrol', 2),
+    ('suv', 5, 5, 'automatic', 'diesel', 3),
+    ('van', 9, 4, 'manual', 'diesel', 6),
+    ('luxury', 5, 4, 'automatic', 'petrol', 3);
+
+ALTER TABLE car ADD COLUMN category_id INT REFERENCES car_category(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_car_category_id ON car(category_id);
+
+ALTER TABLE booking ADD COLUMN category_id INT REFERENCES car_category(id) ON DELETE SET NULL;
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_car_seats;
+DROP INDEX IF EXISTS idx_car_transmission_fuel_type;
+ALTER TABLE car
+    DROP COLUMN IF EXISTS transmission,
+    DROP COLUMN IF EXISTS fuel_type,
+    DROP COLUMN IF EXISTS seats,
+    DROP COLUMN IF EXISTS doors,
+    DROP COLUMN IF EXISTS air_conditioning,
+    DROP COLUMN IF EXISTS navigation,
+    DROP COLUMN IF EXISTS electric_range,
+    DROP COLUMN IF EXISTS towbar;
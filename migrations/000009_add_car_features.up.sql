@@ -0,0 +1,13 @@
+ALTER TABLE car
+    ADD COLUMN transmission VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN fuel_type VARCHAR(20) NOT NULL DEFAULT '',
+    ADD COLUMN seats INT NOT NULL DEFAULT 0,
+    ADD COLUMN doors INT NOT NULL DEFAULT 0,
+    ADD COLUMN air_conditioning BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN navigation BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN electric_range INT NOT NULL DEFAULT 0,
+    ADD COLUMN towbar BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_car_transmission_fuel_type ON car(transmission, fuel_type);
+
+CREATE INDEX idx_car_seats ON car(seats);
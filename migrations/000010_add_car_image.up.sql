@@ -0,0 +1,16 @@
+CREATE TABLE car_image (
+    id SERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    thumbnail_key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    position INT NOT NULL,
+    is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_car_image_car_id ON car_image(car_id, position);
+
+-- only one primary image per car
+CREATE UNIQUE INDEX idx_car_image_primary ON car_image(car_id) WHERE is_primary;
@@ -0,0 +1,12 @@
+CREATE TABLE maintenance (
+    id SERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    CHECK (start_date <= end_date)
+);
+
+CREATE INDEX idx_maintenance_car_id ON maintenance(car_id, start_date, end_date);
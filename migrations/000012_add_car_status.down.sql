@@ -0,0 +1,6 @@
+ALTER TABLE booking_series DROP CONSTRAINT IF EXISTS booking_series_car_id_fkey,
+    ADD CONSTRAINT booking_series_car_id_fkey FOREIGN KEY (car_id) REFERENCES car(id) ON DELETE CASCADE;
+ALTER TABLE booking DROP CONSTRAINT IF EXISTS booking_car_id_fkey,
+    ADD CONSTRAINT booking_car_id_fkey FOREIGN KEY (car_id) REFERENCES car(id) ON DELETE CASCADE;
+
+ALTER TABLE car DROP COLUMN IF EXISTS status;
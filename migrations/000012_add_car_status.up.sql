@@ -0,0 +1,9 @@
+ALTER TABLE car ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'available';
+
+CREATE INDEX idx_car_status ON car(status);
+
+-- cars with bookings are retired instead of deleted, their history has to stay
+ALTER TABLE booking DROP CONSTRAINT IF EXISTS booking_car_id_fkey,
+    ADD CONSTRAINT booking_car_id_fkey FOREIGN KEY (car_id) REFERENCES car(id) ON DELETE RESTRICT;
+ALTER TABLE booking_series DROP CONSTRAINT IF EXISTS booking_series_car_id_fkey,
+    ADD CONSTRAINT booking_series_car_id_fkey FOREIGN KEY (car_id) REFERENCES car(id) ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS service_record;
+DROP TABLE IF EXISTS car_reading;
+ALTER TABLE car DROP COLUMN IF EXISTS service_interval_km, DROP COLUMN IF EXISTS service_interval_months;
@@ -0,0 +1,27 @@
+ALTER TABLE car
+    ADD COLUMN service_interval_km INT NOT NULL DEFAULT 0,
+    ADD COLUMN service_interval_months INT NOT NULL DEFAULT 0;
+
+CREATE TABLE car_reading (
+    id SERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    booking_id INT REFERENCES booking(id) ON DELETE SET NULL,
+    odometer INT NOT NULL,
+    fuel_level INT CHECK (fuel_level BETWEEN 0 AND 100),
+    source VARCHAR(20) NOT NULL,
+    recorded TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_car_reading_car_id ON car_reading(car_id, recorded);
+
+CREATE TABLE service_record (
+    id SERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    description TEXT NOT NULL,
+    cost DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    odometer INT NOT NULL,
+    performed DATE NOT NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_service_record_car_id ON service_record(car_id, performed);
@@ -0,0 +1,16 @@
+CREATE TABLE telemetry (
+    id BIGSERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    recorded TIMESTAMP NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    odometer INT,
+    fuel_level INT,
+    battery_level INT,
+    ignition BOOLEAN
+);
+
+CREATE INDEX idx_telemetry_car_id ON telemetry(car_id, recorded);
+
+-- used by the retention job
+CREATE INDEX idx_telemetry_recorded ON telemetry(recorded);
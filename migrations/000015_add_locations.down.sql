@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_company_location;
+DROP INDEX IF EXISTS idx_car_location;
+
+ALTER TABLE car DROP COLUMN IF EXISTS longitude;
+ALTER TABLE car DROP COLUMN IF EXISTS latitude;
+
+ALTER TABLE company DROP COLUMN IF EXISTS longitude;
+ALTER TABLE company DROP COLUMN IF EXISTS latitude;
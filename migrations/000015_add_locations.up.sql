@@ -0,0 +1,10 @@
+ALTER TABLE company ADD COLUMN latitude DOUBLE PRECISION;
+ALTER TABLE company ADD COLUMN longitude DOUBLE PRECISION;
+
+-- where the car is parked, updated from telemetry
+ALTER TABLE car ADD COLUMN latitude DOUBLE PRECISION;
+ALTER TABLE car ADD COLUMN longitude DOUBLE PRECISION;
+
+-- the near search has no spatial index, this keeps the scan to located rows
+CREATE INDEX idx_car_location ON car(latitude, longitude) WHERE latitude IS NOT NULL;
+CREATE INDEX idx_company_location ON company(latitude, longitude) WHERE latitude IS NOT NULL;
@@ -0,0 +1,11 @@
+-- make and model weigh the most, then color and year, then features
+ALTER TABLE car ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', make || ' ' || model), 'A') ||
+    setweight(to_tsvector('simple', coalesce(color, '') || ' ' || year::text), 'B') ||
+    setweight(to_tsvector('simple', transmission || ' ' || fuel_type ||
+        CASE WHEN air_conditioning THEN ' air conditioning' ELSE '' END ||
+        CASE WHEN navigation THEN ' navigation' ELSE '' END ||
+        CASE WHEN towbar THEN ' towbar' ELSE '' END), 'C')
+) STORED;
+
+CREATE INDEX idx_car_search_vector ON car USING GIN(search_vector);
@@ -0,0 +1,17 @@
+CREATE TABLE car_price (
+    id SERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    price_per_day DECIMAL(10, 2) NOT NULL,
+    effective_from DATE NOT NULL,
+    applied BOOLEAN NOT NULL DEFAULT FALSE,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_car_price_car_id ON car_price(car_id, effective_from);
+
+-- used by the job switching car prices
+CREATE INDEX idx_car_price_pending ON car_price(effective_from) WHERE NOT applied;
+
+-- current prices are the start of the history
+INSERT INTO car_price (car_id, price_per_day, effective_from, applied)
+SELECT id, price_per_day, COALESCE(created, CURRENT_TIMESTAMP)::date, TRUE FROM car;
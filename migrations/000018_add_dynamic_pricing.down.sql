@@ -0,0 +1 @@
+DROP TABLE IF EXISTS dynamic_pricing;
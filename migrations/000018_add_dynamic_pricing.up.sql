@@ -0,0 +1,16 @@
+CREATE TABLE dynamic_pricing (
+    company_id INT PRIMARY KEY REFERENCES company(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    target_utilisation DECIMAL(4, 3) NOT NULL DEFAULT 0,
+    utilisation_factor DECIMAL(5, 3) NOT NULL DEFAULT 0,
+    last_minute_days INT NOT NULL DEFAULT 0,
+    last_minute_factor DECIMAL(4, 3) NOT NULL DEFAULT 0,
+    early_booking_days INT NOT NULL DEFAULT 0,
+    early_booking_factor DECIMAL(4, 3) NOT NULL DEFAULT 0,
+    -- multiples of the car price
+    floor DECIMAL(5, 3) NOT NULL,
+    ceiling DECIMAL(5, 3) NOT NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
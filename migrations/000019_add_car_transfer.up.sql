@@ -0,0 +1,18 @@
+CREATE TABLE car_transfer (
+    id SERIAL PRIMARY KEY,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    from_company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    to_company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    status INT NOT NULL DEFAULT 0,
+    migrate_bookings BOOLEAN NOT NULL DEFAULT FALSE,
+    migrated_bookings INT NOT NULL DEFAULT 0,
+    requested_by INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    resolved_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved TIMESTAMP
+);
+
+CREATE INDEX idx_car_transfer_car_id ON car_transfer(car_id);
+
+-- only one pending transfer per car
+CREATE UNIQUE INDEX idx_car_transfer_pending ON car_transfer(car_id) WHERE status = 0;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_car_documents_expiry;
+DROP INDEX IF EXISTS idx_car_vin;
+ALTER TABLE car DROP COLUMN IF EXISTS roadworthiness_expiry;
+ALTER TABLE car DROP COLUMN IF EXISTS insurance_expiry;
+ALTER TABLE car DROP COLUMN IF EXISTS registration_expiry;
+ALTER TABLE car DROP COLUMN IF EXISTS vin;
@@ -0,0 +1,9 @@
+ALTER TABLE car ADD COLUMN vin CHAR(17);
+ALTER TABLE car ADD COLUMN registration_expiry DATE;
+ALTER TABLE car ADD COLUMN insurance_expiry DATE;
+ALTER TABLE car ADD COLUMN roadworthiness_expiry DATE;
+
+CREATE UNIQUE INDEX idx_car_vin ON car(vin) WHERE vin IS NOT NULL;
+
+-- used by the daily documents check
+CREATE INDEX idx_car_documents_expiry ON car(LEAST(registration_expiry, insurance_expiry, roadworthiness_expiry));
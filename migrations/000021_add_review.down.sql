@@ -0,0 +1,5 @@
+ALTER TABLE company DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE company DROP COLUMN IF EXISTS rating;
+ALTER TABLE car DROP COLUMN IF EXISTS rating_count;
+ALTER TABLE car DROP COLUMN IF EXISTS rating;
+DROP TABLE IF EXISTS review;
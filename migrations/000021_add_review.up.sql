@@ -0,0 +1,26 @@
+CREATE TABLE review (
+    id SERIAL PRIMARY KEY,
+    booking_id INT NOT NULL UNIQUE REFERENCES booking(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    car_rating SMALLINT NOT NULL CHECK (car_rating BETWEEN 1 AND 5),
+    company_rating SMALLINT NOT NULL CHECK (company_rating BETWEEN 1 AND 5),
+    comment TEXT NOT NULL DEFAULT '',
+    reply TEXT,
+    replied TIMESTAMP,
+    status VARCHAR(20) NOT NULL DEFAULT 'published',
+    report_reason TEXT,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_review_car_id ON review(car_id);
+CREATE INDEX idx_review_company_id ON review(company_id);
+-- moderation queue
+CREATE INDEX idx_review_status ON review(status);
+
+-- averages of visible reviews, kept up to date by the service
+ALTER TABLE car ADD COLUMN rating DECIMAL(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE car ADD COLUMN rating_count INT NOT NULL DEFAULT 0;
+ALTER TABLE company ADD COLUMN rating DECIMAL(3, 2) NOT NULL DEFAULT 0;
+ALTER TABLE company ADD COLUMN rating_count INT NOT NULL DEFAULT 0;
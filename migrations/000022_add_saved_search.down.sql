@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS favorite_car;
+DROP TABLE IF EXISTS saved_search;
@@ -0,0 +1,20 @@
+CREATE TABLE saved_search (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    query TEXT NOT NULL,
+    notify BOOLEAN NOT NULL DEFAULT FALSE,
+    last_car_id INT NOT NULL DEFAULT 0,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_saved_search_user_id ON saved_search(user_id);
+
+CREATE TABLE favorite_car (
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    car_id INT NOT NULL REFERENCES car(id) ON DELETE CASCADE,
+    notify BOOLEAN NOT NULL DEFAULT FALSE,
+    price DECIMAL(10, 2) NOT NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, car_id)
+);
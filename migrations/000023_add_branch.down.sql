@@ -0,0 +1,6 @@
+ALTER TABLE booking DROP COLUMN IF EXISTS return_time;
+ALTER TABLE booking DROP COLUMN IF EXISTS pickup_time;
+ALTER TABLE booking DROP COLUMN IF EXISTS branch_id;
+DROP TABLE IF EXISTS branch_closure;
+DROP TABLE IF EXISTS branch_hours;
+DROP TABLE IF EXISTS branch;
@@ -0,0 +1,33 @@
+CREATE TABLE branch (
+    id SERIAL PRIMARY KEY,
+    company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    address TEXT NOT NULL,
+    latitude DOUBLE PRECISION,
+    longitude DOUBLE PRECISION,
+    time_zone VARCHAR(64) NOT NULL,
+    out_of_hours_fee DECIMAL(10, 2)
+);
+
+CREATE INDEX idx_branch_company_id ON branch(company_id);
+
+-- times as HH:MM in the branch time zone
+CREATE TABLE branch_hours (
+    branch_id INT NOT NULL REFERENCES branch(id) ON DELETE CASCADE,
+    weekday SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+    opens CHAR(5) NOT NULL,
+    closes CHAR(5) NOT NULL
+);
+
+CREATE INDEX idx_branch_hours_branch_id ON branch_hours(branch_id);
+
+CREATE TABLE branch_closure (
+    branch_id INT NOT NULL REFERENCES branch(id) ON DELETE CASCADE,
+    date DATE NOT NULL,
+    reason VARCHAR(200) NOT NULL DEFAULT '',
+    PRIMARY KEY (branch_id, date)
+);
+
+ALTER TABLE booking ADD COLUMN branch_id INT REFERENCES branch(id) ON DELETE SET NULL;
+ALTER TABLE booking ADD COLUMN pickup_time CHAR(5);
+ALTER TABLE booking ADD COLUMN return_time CHAR(5);
@@ -0,0 +1,19 @@
+CREATE TABLE company_member (
+    id SERIAL PRIMARY KEY,
+    company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    user_id INT REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(40) NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'invited',
+    invited_by INT REFERENCES users(id) ON DELETE SET NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    joined TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_company_member_email ON company_member(company_id, email);
+CREATE INDEX idx_company_member_user_id ON company_member(user_id);
+
+-- existing owners become the first members of their companies
+INSERT INTO company_member (company_id, user_id, email, role, status, joined)
+SELECT company.id, users.id, users.email, 'owner', 'active', company.created
+FROM company JOIN users ON users.id = company.owner_id;
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS company_document;
+
+DROP INDEX IF EXISTS idx_company_status;
+
+ALTER TABLE company
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS rejection_reason,
+    DROP COLUMN IF EXISTS verified_at;
@@ -0,0 +1,23 @@
+-- companies created before verification was introduced stay live
+ALTER TABLE company
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'verified',
+    ADD COLUMN rejection_reason TEXT,
+    ADD COLUMN verified_at TIMESTAMP;
+
+UPDATE company SET verified_at = created;
+
+ALTER TABLE company ALTER COLUMN status SET DEFAULT 'pending';
+
+CREATE INDEX idx_company_status ON company(status);
+
+CREATE TABLE company_document (
+    id SERIAL PRIMARY KEY,
+    company_id INT NOT NULL REFERENCES company(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    content_type VARCHAR(50) NOT NULL,
+    size BIGINT NOT NULL,
+    created TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_company_document_company_id ON company_document(company_id);
@@ -0,0 +1,7 @@
+ALTER TABLE car_transfer
+    DROP COLUMN IF EXISTS from_branch_id,
+    DROP COLUMN IF EXISTS to_branch_id;
+
+DROP INDEX IF EXISTS idx_car_branch_id;
+
+ALTER TABLE car DROP COLUMN IF EXISTS branch_id;
@@ -0,0 +1,9 @@
+-- branch the car is kept at, cars of companies without branches have none
+ALTER TABLE car ADD COLUMN branch_id INT REFERENCES branch(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_car_branch_id ON car(branch_id);
+
+-- moves between branches of the same company are kept in the transfer history too
+ALTER TABLE car_transfer
+    ADD COLUMN from_branch_id INT REFERENCES branch(id) ON DELETE SET NULL,
+    ADD COLUMN to_branch_id INT REFERENCES branch(id) ON DELETE SET NULL;